@@ -2,8 +2,11 @@ package cmd
 
 import (
 	_ "costrict-keeper/cmd/component"
+	_ "costrict-keeper/cmd/log"
 	_ "costrict-keeper/cmd/misc"
 	_ "costrict-keeper/cmd/root"
 	_ "costrict-keeper/cmd/server"
 	_ "costrict-keeper/cmd/service"
+	_ "costrict-keeper/cmd/tunnel"
+	_ "costrict-keeper/cmd/upgrade"
 )
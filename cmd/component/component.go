@@ -11,14 +11,16 @@ import (
 
 var componentCmd = &cobra.Command{
 	Use:   "component",
-	Short: "Component operations (list/upgrade/remove etc.)",
-	Long:  `Component operations (list/upgrade/remove etc.)`,
+	Short: "Component operations (list/upgrade/remove/verify etc.)",
+	Long:  `Component operations (list/upgrade/remove/verify etc.)`,
 }
 
 const componentExample = `  # list component
   costrict component list
+  costrict component list --server --os windows --arch amd64
   costrict component upgrade codebase-indexer
   costrict component remove codebase-indexer
+  costrict component verify codebase-indexer
   costrict component upgrade -n codebase-indexer
   costrict component remove -n codebase-indexer`
 
@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"strings"
+	"time"
 
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
@@ -15,14 +16,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var optServer bool
+var (
+	optServer  bool
+	optNoCache bool
+	optOs      string
+	optArch    string
+)
 
 var listCmd = &cobra.Command{
 	Use:   "list [component name]",
 	Short: "List information of all components",
 	Long: `List information of all components, including local version and latest server version.
 If component name is specified, only show detailed information of that component.
-When --server flag is set, display all available packages on the server with their version information.`,
+When --server flag is set, display all available packages on the server with their version information,
+--os and --arch limit the packages to the specified platform.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := config.LoadSpec(); err != nil {
@@ -153,19 +160,6 @@ func listSpecificComponent(name string) {
 	}
 }
 
-// formatSize 格式化文件大小
-func formatSize(size uint64) string {
-	if size < 1024 {
-		return strconv.FormatUint(size, 10) + "B"
-	} else if size < 1024*1024 {
-		return strconv.FormatUint(size/1024, 10) + "KB"
-	} else if size < 1024*1024*1024 {
-		return strconv.FormatUint(size/(1024*1024), 10) + "MB"
-	} else {
-		return strconv.FormatUint(size/(1024*1024*1024), 10) + "GB"
-	}
-}
-
 // getPackageDetailInfo 获取包详细元数据信息
 func getPackageDetailInfo(infoUrl string) (*utils.PackageVersion, error) {
 	data, err := utils.GetBytes(infoUrl, nil)
@@ -201,13 +195,25 @@ type RemotePackageColumnsVerbose struct {
 	Description string `json:"description"`
 }
 
+// getCacheTTL 远程包信息缓存有效期，指定--no-cache时不使用缓存
+func getCacheTTL() time.Duration {
+	if optNoCache {
+		return 0
+	}
+	return time.Duration(config.App().Component.CacheTTL) * time.Second
+}
+
 // listRemotePackages 显示远程包列表
 func listRemotePackages() error {
 	var dataList []*orderedmap.OrderedMap
 
 	// 获取包列表
 	u := utils.NewUpgrader("", utils.UpgradeConfig{
-		BaseUrl: config.GetBaseURL() + "/costrict",
+		BaseUrl:    config.GetBaseURL() + "/costrict",
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		Mirrors:    config.App().Component.Mirrors,
+		CacheTTL:   getCacheTTL(),
 	})
 
 	packages, err := u.GetRemotePackages()
@@ -232,7 +238,13 @@ func listRemotePackages() error {
 // listRemotePackage 列出指定远程包的信息
 func listRemotePackage(packageName string) ([]*orderedmap.OrderedMap, error) {
 	u := utils.NewUpgrader(packageName, utils.UpgradeConfig{
-		BaseUrl: config.GetBaseURL() + "/costrict",
+		BaseUrl:    config.GetBaseURL() + "/costrict",
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		Mirrors:    config.App().Component.Mirrors,
+		CacheTTL:   getCacheTTL(),
+		Os:         optOs,
+		Arch:       optArch,
 	})
 
 	// 获取该软件包支持的所有平台
@@ -240,13 +252,17 @@ func listRemotePackage(packageName string) ([]*orderedmap.OrderedMap, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get remote platforms: %v", err)
 	}
+	platforms, err := selectPlatforms(pkg, optOs, optArch)
+	if err != nil {
+		return nil, err
+	}
 
 	var dataList []*orderedmap.OrderedMap
 
-	// 遍历所有支持的平台
-	for _, platform := range pkg.Platforms {
+	// 遍历所有选中的平台
+	for _, platform := range platforms {
 		// 获取该平台的远程版本列表
-		versList, err := u.GetRemoteVersions()
+		versList, err := u.GetPlatformVersions(platform.Os, platform.Arch)
 		if err != nil {
 			fmt.Printf("Warning: failed to get remote versions for platform %s/%s: %v\n",
 				platform.Os, platform.Arch, err)
@@ -279,8 +295,39 @@ func listRemotePackage(packageName string) ([]*orderedmap.OrderedMap, error) {
 	return dataList, nil
 }
 
+/**
+ * Select platforms of the package by os and arch
+ * @param {utils.PackageOverview} pkg - Platforms advertised by the package's platforms.json
+ * @param {string} osName - Operating system, any if empty
+ * @param {string} arch - Architecture, any if empty
+ * @returns {[]utils.PlatformId} Returns the matched platforms
+ * @returns {error} Returns error listing the supported platforms if nothing matches
+ */
+func selectPlatforms(pkg utils.PackageOverview, osName, arch string) ([]utils.PlatformId, error) {
+	if osName == "" && arch == "" {
+		return pkg.Platforms, nil
+	}
+	var selected []utils.PlatformId
+	var supported []string
+	for _, platform := range pkg.Platforms {
+		supported = append(supported, platform.Os+"/"+platform.Arch)
+		if (osName == "" || strings.EqualFold(platform.Os, osName)) &&
+			(arch == "" || strings.EqualFold(platform.Arch, arch)) {
+			selected = append(selected, platform)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("package '%s' doesn't support platform %s/%s, supported: %s",
+			pkg.PackageName, osName, arch, strings.Join(supported, ", "))
+	}
+	return selected, nil
+}
+
 func init() {
 	componentCmd.AddCommand(listCmd)
 	// 添加 server 标志
 	listCmd.Flags().BoolVarP(&optServer, "server", "s", false, "Show all remote packages available for download")
+	listCmd.Flags().BoolVar(&optNoCache, "no-cache", false, "Fetch remote package information without using local cache")
+	listCmd.Flags().StringVar(&optOs, "os", "", "Only show remote packages of the operating system, such as linux/windows/darwin")
+	listCmd.Flags().StringVar(&optArch, "arch", "", "Only show remote packages of the architecture, such as amd64/arm64")
 }
@@ -0,0 +1,100 @@
+package component
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/utils"
+)
+
+/**
+ * Use a temporary .costrict directory whose cloud base URL is baseUrl
+ * @param {string} baseUrl - Base URL of the test server
+ */
+func setupListEnv(t *testing.T, baseUrl string) {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	fname := filepath.Join(env.CostrictDir, "share", "auth.json")
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	auth := `{"id":"test-user","machine_id":"test-machine","access_token":"token","base_url":"` + baseUrl + `"}`
+	if err := os.WriteFile(fname, []byte(auth), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 生成指定平台的版本列表
+func platformInfo(osName, arch string, versions ...string) utils.PlatformInfo {
+	info := utils.PlatformInfo{PackageName: "alpha", Os: osName, Arch: arch}
+	for _, v := range versions {
+		var ver utils.VersionNumber
+		ver.Parse(v)
+		info.Versions = append(info.Versions, utils.VersionAddr{VersionId: ver})
+	}
+	info.Newest = info.Versions[len(info.Versions)-1]
+	return info
+}
+
+func TestListRemotePackagePerPlatform(t *testing.T) {
+	files := map[string]any{
+		"/costrict/alpha/platforms.json": utils.PackageOverview{
+			PackageName: "alpha",
+			Platforms:   []utils.PlatformId{{Os: "linux", Arch: "amd64"}, {Os: "windows", Arch: "amd64"}},
+		},
+		"/costrict/alpha/linux/amd64/platform.json":   platformInfo("linux", "amd64", "1.0.0", "1.1.0"),
+		"/costrict/alpha/windows/amd64/platform.json": platformInfo("windows", "amd64", "2.0.0"),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(data)
+	}))
+	defer srv.Close()
+	setupListEnv(t, srv.URL)
+
+	rows, err := listRemotePackage("alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := map[string][]string{}
+	for _, row := range rows {
+		osName, _ := row.Get("Os")
+		arch, _ := row.Get("Arch")
+		ver, _ := row.Get("Version")
+		platform := osName.(string) + "/" + arch.(string)
+		versions[platform] = append(versions[platform], ver.(string))
+	}
+	expected := map[string]string{
+		"linux/amd64":   "1.0.0,1.1.0",
+		"windows/amd64": "2.0.0",
+	}
+	if len(versions) != len(expected) {
+		t.Fatalf("expected platforms %v, got %v", expected, versions)
+	}
+	for platform, want := range expected {
+		got := versions[platform]
+		sort.Strings(got)
+		if strings.Join(got, ",") != want {
+			t.Errorf("versions of %s: expected %s, got %v", platform, want, got)
+		}
+	}
+}
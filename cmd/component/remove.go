@@ -47,7 +47,8 @@ var removeCmd = &cobra.Command{
 func removeComponent(component string) error {
 	// Call RemovePackage function to remove package
 	u := utils.NewUpgrader(component, utils.UpgradeConfig{
-		BaseDir: env.CostrictDir,
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
 	})
 	if err := u.RemovePackage(nil); err != nil {
 		fmt.Printf("Failed to remove component '%s': %v\n", component, err)
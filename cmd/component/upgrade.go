@@ -3,19 +3,28 @@ package component
 import (
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
 	"costrict-keeper/internal/utils"
+	"costrict-keeper/services"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var optComponent string
 var optVersion string
+var optNoPath bool
 
 var upgradeCmd = &cobra.Command{
 	Use:   "upgrade {component | -n component}",
 	Short: "Upgrade specified component",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Upgrade specified component
+
+By default the install directory is added to PATH (~/.bashrc, ~/.zshrc or ~/.profile on Linux/macOS,
+user PATH on Windows). Use --no-path, or set component.no_set_path in the app config, to leave shell
+rc files and PATH untouched, which is recommended for CI, containers and other managed environments.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		// Determine component name: prioritize positional arguments, then use command line arguments
 		component := optComponent
@@ -32,10 +41,28 @@ var upgradeCmd = &cobra.Command{
 	},
 }
 
+// 组件在系统规格中指定了upgrade_url时从该地址升级，否则使用全局的升级地址
+func componentUpgradeUrl(component string) string {
+	if err := config.LoadSpec(); err == nil {
+		for _, cpn := range config.Spec().Components {
+			if cpn.Name == component && cpn.UpgradeUrl != "" {
+				return cpn.UpgradeUrl
+			}
+		}
+	}
+	return config.Cloud().UpgradeUrl
+}
+
 func upgradeComponent(component string, version string) error {
 	u := utils.NewUpgrader(component, utils.UpgradeConfig{
-		BaseUrl: config.Cloud().UpgradeUrl,
-		BaseDir: env.CostrictDir,
+		BaseUrl:    componentUpgradeUrl(component),
+		PublicKey:  config.App().Component.PublicKey,
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		Mirrors:    config.App().Component.Mirrors,
+		Retries:    config.App().Component.VerifyRetries,
+		RateLimit:  config.App().Component.RateLimit,
+		NoSetPath:  optNoPath || config.App().Component.NoSetPath,
 	})
 
 	var specVer *utils.VersionNumber
@@ -48,15 +75,27 @@ func upgradeComponent(component string, version string) error {
 		specVer = &v
 	}
 
+	rec := models.UpgradeRecord{Component: component, To: version}
+	if cur, err := u.GetLocalVersion(nil); err == nil {
+		rec.From = cur.VersionId.String()
+	}
 	pkg, upgraded, err := u.UpgradePackage(specVer)
 	if err != nil {
 		fmt.Printf("The '%s' upgrade failed: %v\n", component, err)
+		rec.Result = models.UpgradeFailed
+		rec.Error = err.Error()
+		rec.Time = time.Now()
+		services.RecordUpgrade(rec)
 		return err
 	}
 	if !upgraded {
 		fmt.Printf("The '%s' version is up to date\n", component)
 	} else {
 		fmt.Printf("The '%s' is upgraded to version %s\n", component, pkg.VersionId.String())
+		rec.To = pkg.VersionId.String()
+		rec.Result = models.UpgradeSuccess
+		rec.Time = time.Now()
+		services.RecordUpgrade(rec)
 	}
 	return nil
 }
@@ -65,5 +104,6 @@ func init() {
 	upgradeCmd.Flags().SortFlags = false
 	upgradeCmd.Flags().StringVarP(&optVersion, "version", "v", "", "Specify the target version to upgrade")
 	upgradeCmd.Flags().StringVarP(&optComponent, "component", "n", "", "Specify the component name to upgrade")
+	upgradeCmd.Flags().BoolVar(&optNoPath, "no-path", false, "Don't add the install directory to PATH or modify shell rc files")
 	componentCmd.AddCommand(upgradeCmd)
 }
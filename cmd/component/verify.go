@@ -0,0 +1,68 @@
+package component
+
+import (
+	"costrict-keeper/services"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var optVerifyComponent string
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify {component | -n component}",
+	Short: "Verify installed component against its signed package metadata",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Determine component name: prioritize positional arguments, then use command line arguments
+		component := optVerifyComponent
+		if len(args) > 0 && args[0] != "" {
+			component = args[0]
+		}
+
+		if component == "" {
+			fmt.Println("Error: Component name must be specified")
+			return
+		}
+
+		if !verifyComponent(component) {
+			os.Exit(1)
+		}
+	},
+}
+
+/**
+ * Verify installed component and print the result
+ * @param {string} component - Name of the component to verify
+ * @returns {bool} Returns true if both checksum and signature are valid
+ * @description
+ * - Recomputes checksum of the installed file and compares it with <pkg>.json
+ * - Re-verifies the signature of <pkg>.json against the trusted public key
+ */
+func verifyComponent(component string) bool {
+	result := services.VerifyPackage(component)
+	passFail := func(ok bool) string {
+		if ok {
+			return "PASS"
+		}
+		return "FAIL"
+	}
+	fmt.Printf("Component: %s\n", result.Name)
+	fmt.Printf("Version:   %s\n", result.Version)
+	fmt.Printf("File:      %s\n", result.FilePath)
+	fmt.Printf("Checksum:  %s (expected: %s, actual: %s)\n", passFail(result.ChecksumOK), result.ExpectedChecksum, result.ActualChecksum)
+	fmt.Printf("Signature: %s\n", passFail(result.SignatureOK))
+	if !result.Passed {
+		fmt.Printf("The '%s' verification failed: %s\n", component, result.Error)
+		return false
+	}
+	fmt.Printf("The '%s' verification passed\n", component)
+	return true
+}
+
+func init() {
+	verifyCmd.Flags().SortFlags = false
+	verifyCmd.Flags().StringVarP(&optVerifyComponent, "component", "n", "", "Specify the component name to verify")
+	componentCmd.AddCommand(verifyCmd)
+}
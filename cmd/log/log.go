@@ -0,0 +1,26 @@
+package logcmd
+
+import (
+	"costrict-keeper/cmd/root"
+
+	"github.com/spf13/cobra"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Log operations (upload)",
+	Long:  `Log operations (upload)`,
+}
+
+const logExample = `  # upload new error lines of costrict logs
+  costrict log upload
+  # upload all log files of a directory
+  costrict log upload --dir /path/to/logs
+  # upload a log file
+  costrict log upload --file /path/to/app.log`
+
+func init() {
+	root.RootCmd.AddCommand(logCmd)
+
+	logCmd.Example = logExample
+}
@@ -0,0 +1,82 @@
+package logcmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/services"
+
+	"github.com/spf13/cobra"
+)
+
+var optLogDir string
+var optLogFile string
+
+var uploadCmd = &cobra.Command{
+	Use:   "upload [--dir path | --file path]",
+	Short: "Upload logs to the cloud on demand",
+	Long: `Upload logs to the cloud on demand, for troubleshooting with support
+
+Without options, error lines of costrict logs which haven't been uploaded are uploaded,
+the same as the periodic log reporter does.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := uploadLogs(optLogDir, optLogFile); err != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+/**
+ * Upload logs and print the result of each file
+ * @param {string} dir - Directory whose log files are uploaded
+ * @param {string} file - Log file to upload
+ * @returns {error} Returns error if any file failed to upload
+ * @description
+ * - Uploads error lines of the logs directory if neither dir nor file is specified
+ */
+func uploadLogs(dir, file string) error {
+	if dir != "" && file != "" {
+		fmt.Println("Error: --dir and --file can't be used together")
+		return fmt.Errorf("--dir and --file are exclusive")
+	}
+	ls := services.NewLogService()
+	var report services.LogUploadReport
+	var err error
+	switch {
+	case file != "":
+		if err = ls.UploadFile(file); err == nil {
+			report.Uploaded = append(report.Uploaded, file)
+		}
+	case dir != "":
+		report, err = ls.UploadDirectoryReport(dir)
+	default:
+		report, err = ls.UploadErrorsReport()
+	}
+	if err != nil {
+		fmt.Printf("Failed to upload logs to '%s': %v\n", config.Cloud().LogUrl, err)
+		return err
+	}
+	for _, fname := range report.Uploaded {
+		fmt.Printf("Uploaded: %s\n", fname)
+	}
+	var failed []string
+	for fname := range report.Failed {
+		failed = append(failed, fname)
+	}
+	sort.Strings(failed)
+	for _, fname := range failed {
+		fmt.Printf("Failed:   %s (%s)\n", fname, report.Failed[fname])
+	}
+	fmt.Printf("%d files uploaded, %d failed, target: %s\n", len(report.Uploaded), len(report.Failed), config.Cloud().LogUrl)
+	return report.Err()
+}
+
+func init() {
+	uploadCmd.Flags().SortFlags = false
+	uploadCmd.Flags().StringVarP(&optLogDir, "dir", "d", "", "Upload all log files of the directory")
+	uploadCmd.Flags().StringVarP(&optLogFile, "file", "f", "", "Upload the log file")
+	logCmd.AddCommand(uploadCmd)
+}
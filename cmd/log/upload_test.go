@@ -0,0 +1,167 @@
+package logcmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+)
+
+/**
+ * Mock log ingest server, records the uploaded files by name
+ * @description
+ * - Files named reject.log are refused with 500
+ */
+type ingestServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func (s *ingestServer) uploaded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s.files = map[string]string{}
+	return names
+}
+
+func newIngestServer(t *testing.T) *ingestServer {
+	t.Helper()
+	s := &ingestServer{files: map[string]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("logfile")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if header.Filename == "reject.log" {
+			http.Error(w, "rejected", http.StatusInternalServerError)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		s.mu.Lock()
+		s.files[header.Filename] = string(data)
+		s.mu.Unlock()
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// 使用临时的.costrict目录，日志上传到ingest
+func setupUploadEnv(t *testing.T, ingest *ingestServer) {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	writeFile(t, filepath.Join(env.CostrictDir, "share", "auth.json"),
+		`{"id":"test-user","machine_id":"test-machine","access_token":"test-token","base_url":"`+ingest.URL+`"}`)
+	writeFile(t, filepath.Join(env.CostrictDir, "config", "costrict.json"),
+		`{"cloud":{"log_url":"`+ingest.URL+`/logs"}}`)
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, fname, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func equalNames(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUploadLogsDir(t *testing.T) {
+	ingest := newIngestServer(t)
+	setupUploadEnv(t, ingest)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.log"), "INFO started\n")
+	writeFile(t, filepath.Join(dir, "worker.log"), "ERROR crashed\n")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not a log")
+
+	if err := uploadLogs(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ingest.uploaded(); !equalNames(got, "app.log", "worker.log") {
+		t.Errorf("uploaded = %v, want all .log files", got)
+	}
+
+	if err := uploadLogs("", filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ingest.uploaded(); !equalNames(got, "notes.txt") {
+		t.Errorf("uploaded = %v, want the specified file", got)
+	}
+
+	// 部分文件上传失败时报告错误
+	writeFile(t, filepath.Join(dir, "reject.log"), "ERROR rejected\n")
+	if err := uploadLogs(dir, ""); err == nil {
+		t.Error("rejected upload should be reported")
+	}
+	if got := ingest.uploaded(); !equalNames(got, "app.log", "worker.log") {
+		t.Errorf("uploaded = %v, other files should still be uploaded", got)
+	}
+
+	if err := uploadLogs(dir, filepath.Join(dir, "app.log")); err == nil {
+		t.Error("--dir and --file should be exclusive")
+	}
+}
+
+func TestUploadLogsErrors(t *testing.T) {
+	ingest := newIngestServer(t)
+	setupUploadEnv(t, ingest)
+	logDir := env.GetLogDir()
+	writeFile(t, filepath.Join(logDir, "costrict.log"), "INFO started\nERROR upgrade failed\nINFO done\n")
+	writeFile(t, filepath.Join(logDir, "quiet.log"), "INFO nothing wrong\n")
+
+	// 默认只上传日志目录中各文件的错误行
+	if err := uploadLogs("", ""); err != nil {
+		t.Fatal(err)
+	}
+	ingest.mu.Lock()
+	content := ingest.files["costrict.last-errors"]
+	ingest.mu.Unlock()
+	if got := ingest.uploaded(); !equalNames(got, "costrict.last-errors") {
+		t.Fatalf("uploaded = %v, want error lines of costrict.log only", got)
+	}
+	if content != "ERROR upgrade failed" {
+		t.Errorf("uploaded content = %q, want the error line", content)
+	}
+
+	// 错误行没有变化时不重复上传
+	if err := uploadLogs("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ingest.uploaded(); len(got) != 0 {
+		t.Errorf("uploaded = %v, unchanged errors shouldn't be uploaded again", got)
+	}
+}
@@ -1,7 +1,6 @@
 package client
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,7 +8,7 @@ import (
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/models"
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
 
 	"github.com/spf13/cobra"
 )
@@ -43,25 +42,14 @@ const checkExample = `  # Check server status
  * checkServerStatus()
  */
 func checkServerStatus() {
-	rpcClient := rpc.NewHTTPClient(nil)
-	resp, err := rpcClient.Post("/costrict/api/v1/check", nil)
+	checkResp, err := keeper.NewClient(nil).Check()
 	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
-		return
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return
-	}
-
-	var checkResp models.CheckResponse
-	if err := json.Unmarshal(resp.Body, &checkResp); err != nil {
-		fmt.Printf("Failed to unmarshal check response: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
 	// 成功反序列化，显示检查结果
-	displayCheckResults(checkResp)
+	displayCheckResults(*checkResp)
 }
 
 func displayServices(services []models.ServiceDetail) {
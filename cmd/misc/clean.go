@@ -6,6 +6,7 @@ import (
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/utils"
 	"costrict-keeper/services"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,15 +14,148 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	optCleanDryRun bool
+	optCleanAll    bool
+)
+
 var cleanCmd = &cobra.Command{
-	Use:   "clean",
-	Short: "Clean up all services, tunnels, processes and cache",
-	Long:  `Stop all services, terminate all tunnels, kill specified processes and clean up .costrict cache directory`,
+	Use:   "clean [--dry-run] [--all]",
+	Short: "Remove stale cache and old package files",
+	Long: `Remove cache entries of services/tunnels that are no longer running and package versions beyond the retention window.
+With --all, stop all services, terminate all tunnels, kill specified processes and clean up .costrict cache directory`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		cleanAll()
+		if optCleanAll {
+			if optCleanDryRun {
+				fmt.Println("Error: --dry-run can't be used together with --all")
+				return
+			}
+			cleanAll()
+			return
+		}
+		cleanStale(optCleanDryRun)
 	},
 }
 
+/**
+ * Remove stale cache entries and old package versions
+ * @param {bool} dryRun - Only report what would be removed
+ * @description
+ * - Removes cache/services and cache/tunnels entries whose process is not running
+ * - Removes package versions beyond the retention window (newest utils.PACKAGE_RESERVE_NUM are kept)
+ * - Reports reclaimed space
+ * @example
+ * cleanStale(true)
+ */
+func cleanStale(dryRun bool) {
+	var reclaimed uint64
+	reclaimed += cleanStaleCaches(filepath.Join(env.GetCacheDir(), "services"), dryRun)
+	reclaimed += cleanStaleCaches(filepath.Join(env.GetCacheDir(), "tunnels"), dryRun)
+	reclaimed += cleanOldPackages(dryRun)
+	if dryRun {
+		fmt.Printf("%s would be reclaimed\n", utils.FormatSize(reclaimed))
+	} else {
+		fmt.Printf("%s reclaimed\n", utils.FormatSize(reclaimed))
+	}
+}
+
+/**
+ * Check if the process recorded by a cache file is still alive
+ * @param {string} fname - Path of service or tunnel cache file
+ * @returns {bool} Returns true if the recorded process is running
+ * @description
+ * - Both ServiceCache and TunnelCache record the process ID in the "pid" field
+ * - Unreadable or corrupted cache files are regarded as stale
+ */
+func isCacheLive(fname string) bool {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return false
+	}
+	var cache struct {
+		Pid int `json:"pid"`
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return false
+	}
+	if cache.Pid <= 0 {
+		return false
+	}
+	running, err := utils.IsProcessRunning(cache.Pid)
+	return err == nil && running
+}
+
+/**
+ * Remove cache files whose process is no longer running
+ * @param {string} cacheDir - Directory holding cache files, such as cache/services
+ * @param {bool} dryRun - Only report what would be removed
+ * @returns {uint64} Returns the total size of stale cache files
+ */
+func cleanStaleCaches(cacheDir string, dryRun bool) uint64 {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return 0
+	}
+	var total uint64
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		fname := filepath.Join(cacheDir, entry.Name())
+		if isCacheLive(fname) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would remove stale cache: %s\n", fname)
+		} else if err := os.Remove(fname); err != nil {
+			fmt.Printf("Failed to remove stale cache %s: %v\n", fname, err)
+			continue
+		} else {
+			fmt.Printf("Removed stale cache: %s\n", fname)
+		}
+		total += uint64(info.Size())
+	}
+	return total
+}
+
+/**
+ * Remove package versions beyond the retention window
+ * @param {bool} dryRun - Only report what would be removed
+ * @returns {uint64} Returns the total size of old package files
+ */
+func cleanOldPackages(dryRun bool) uint64 {
+	u := utils.NewUpgrader(services.COSTRICT_NAME, utils.UpgradeConfig{
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+	})
+	olds, err := u.GetOldVersions()
+	if err != nil {
+		return 0
+	}
+	var total uint64
+	for _, old := range olds {
+		for _, fname := range []string{old.DescPath, old.DataPath} {
+			if info, err := os.Stat(fname); err == nil {
+				total += uint64(info.Size())
+			}
+		}
+		if dryRun {
+			fmt.Printf("Would remove package %s %s\n", old.PackageName, old.Version.String())
+		} else {
+			fmt.Printf("Remove package %s %s\n", old.PackageName, old.Version.String())
+		}
+	}
+	if !dryRun {
+		utils.RemoveVersions(olds)
+	}
+	return total
+}
+
 /**
  * Clean up all services, tunnels, processes and cache
  * @returns {error} Returns error if any cleanup step fails, nil on success
@@ -37,8 +171,23 @@ var cleanCmd = &cobra.Command{
  * - Cache cleanup errors
  */
 func cleanAll() error {
-	// 1. 杀掉还在运行的costrict程序
 	fmt.Println("Starting cleanup process...")
+	killAllProcesses()
+	fmt.Println("The remaining processes have been successfully cleaned up.")
+	// 3. 清理.costrict目录下的cache目录
+	cleanCacheDirectory()
+	fmt.Println("Cache directory cleaned successfully")
+	fmt.Println("Clean completed successfully")
+	return nil
+}
+
+/**
+ * Kill the costrict server and processes of all components
+ * @description
+ * - The current process is never killed
+ */
+func killAllProcesses() {
+	// 1. 杀掉还在运行的costrict程序
 	utils.KillSpecifiedProcess(services.COSTRICT_NAME)
 	// 2. 杀死所有组件/服务的进程
 	if err := config.LoadSpec(); err == nil {
@@ -49,12 +198,6 @@ func cleanAll() error {
 		}
 		utils.KillSpecifiedProcesses(targetProcesses)
 	}
-	fmt.Println("The remaining processes have been successfully cleaned up.")
-	// 3. 清理.costrict目录下的cache目录
-	cleanCacheDirectory()
-	fmt.Println("Cache directory cleaned successfully")
-	fmt.Println("Clean completed successfully")
-	return nil
 }
 
 /**
@@ -71,13 +214,12 @@ func cleanAll() error {
 func cleanCacheDirectory() {
 	fmt.Println("Cleaning up cache directory...")
 
-	costrictDir := env.CostrictDir
-	if costrictDir == "" {
+	if env.CostrictDir == "" {
 		fmt.Println("Failed to get .costrict directory path")
 		return
 	}
 
-	cacheDir := filepath.Join(costrictDir, "cache")
+	cacheDir := env.GetCacheDir()
 	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
 		fmt.Printf("Cache directory '%s' does not exist, skipping cleanup\n", cacheDir)
 		return
@@ -100,7 +242,7 @@ func cleanCacheDirectory() {
 	}
 
 	// 删除run目录及其所有内容
-	runDir := filepath.Join(env.CostrictDir, "run")
+	runDir := env.GetRunDir()
 	if err := os.RemoveAll(runDir); err != nil {
 		fmt.Printf("Failed to remove run directory %s: %v\n", runDir, err)
 	} else {
@@ -109,5 +251,8 @@ func cleanCacheDirectory() {
 }
 
 func init() {
+	cleanCmd.Flags().SortFlags = false
+	cleanCmd.Flags().BoolVar(&optCleanDryRun, "dry-run", false, "Only report what would be removed")
+	cleanCmd.Flags().BoolVar(&optCleanAll, "all", false, "Stop all services and processes, then remove all cache")
 	root.RootCmd.AddCommand(cleanCmd)
 }
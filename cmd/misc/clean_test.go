@@ -0,0 +1,71 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// 获取一个已经退出的进程的PID
+func exitedPid(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeCache(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	fname := filepath.Join(dir, name)
+	if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+func TestCleanStaleCaches(t *testing.T) {
+	dir := t.TempDir()
+	live := writeCache(t, dir, "live.json", fmt.Sprintf(`{"pid":%d}`, os.Getpid()))
+	stale := writeCache(t, dir, "stale.json", fmt.Sprintf(`{"pid":%d}`, exitedPid(t)))
+	corrupted := writeCache(t, dir, "corrupted.json", `{"pid":`)
+	noPid := writeCache(t, dir, "nopid.json", `{}`)
+	other := writeCache(t, dir, "readme.txt", `not a cache file`)
+
+	if !isCacheLive(live) {
+		t.Error("cache of the running process should be live")
+	}
+	for _, fname := range []string{stale, corrupted, noPid} {
+		if isCacheLive(fname) {
+			t.Errorf("'%s' should be stale", filepath.Base(fname))
+		}
+	}
+
+	// dry-run只报告，不删除
+	size := cleanStaleCaches(dir, true)
+	if size == 0 {
+		t.Error("dry-run should report the size of stale caches")
+	}
+	for _, fname := range []string{live, stale, corrupted, noPid, other} {
+		if _, err := os.Stat(fname); err != nil {
+			t.Errorf("dry-run removed '%s'", filepath.Base(fname))
+		}
+	}
+
+	if got := cleanStaleCaches(dir, false); got != size {
+		t.Errorf("reclaimed %d, dry-run reported %d", got, size)
+	}
+	for _, fname := range []string{live, other} {
+		if _, err := os.Stat(fname); err != nil {
+			t.Errorf("'%s' should be kept", filepath.Base(fname))
+		}
+	}
+	for _, fname := range []string{stale, corrupted, noPid} {
+		if _, err := os.Stat(fname); !os.IsNotExist(err) {
+			t.Errorf("'%s' should be removed", filepath.Base(fname))
+		}
+	}
+}
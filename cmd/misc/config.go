@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"costrict-keeper/cmd/root"
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/rpc/keeper"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration operations",
+	Long:  `Configuration operations`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show configuration",
+	Long:  "Show raw content of $HOME/.costrict/config/costrict.json, or the effective configuration with --effective",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if optConfigEffective {
+			showEffectiveConfig()
+		} else {
+			showRawConfig()
+		}
+	},
+}
+
+const configExample = `  # Show config file content
+  costrict config show
+  # Show configuration really used, with defaults applied and URL templates expanded
+  costrict config show --effective
+  # Fetch configuration from the cloud and apply it
+  costrict config sync`
+
+func showRawConfig() {
+	fname := filepath.Join(env.CostrictDir, "config", "costrict.json")
+
+	bytes, err := os.ReadFile(fname)
+	if err != nil {
+		fmt.Printf("Load '%s' failed: %v\n", fname, err)
+		return
+	}
+	fmt.Printf("%s\n", string(bytes))
+}
+
+/**
+ * Print effective configuration as JSON
+ * @description
+ * - Configuration after defaults are applied and cloud URL templates are expanded
+ * - Secrets are redacted
+ */
+func showEffectiveConfig() {
+	bytes, err := json.MarshalIndent(config.Effective(), "", "  ")
+	if err != nil {
+		fmt.Printf("Marshal config failed: %v\n", err)
+		return
+	}
+	fmt.Printf("%s\n", string(bytes))
+}
+
+var configSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Fetch configuration from the cloud and apply it",
+	Long:  "Ask the running costrict server to fetch costrict-config from the cloud and load it without restarting",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		syncConfig()
+	},
+}
+
+/**
+ * Ask costrict server to sync configuration and print changed settings
+ * @description
+ * - Secrets in changed settings are redacted by the server
+ */
+func syncConfig() {
+	client := keeper.NewClient(nil)
+	defer client.Close()
+
+	result, err := client.SyncConfig()
+	if err != nil {
+		fmt.Printf("Sync config failed: %v\n", err)
+		return
+	}
+	if !result.Changed {
+		fmt.Println("Configuration is up to date")
+		return
+	}
+	for _, c := range result.Changes {
+		fmt.Printf("%s: %v -> %v\n", c.Field, c.Old, c.New)
+	}
+}
+
+var optConfigEffective bool
+
+func init() {
+	configShowCmd.Flags().BoolVarP(&optConfigEffective, "effective", "e", false, "Show the effective configuration (defaulted, expanded, secrets redacted)")
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSyncCmd)
+	configCmd.Example = configExample
+	root.RootCmd.AddCommand(configCmd)
+}
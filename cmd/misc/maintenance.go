@@ -0,0 +1,39 @@
+package client
+
+import (
+	"fmt"
+
+	"costrict-keeper/cmd/root"
+	"costrict-keeper/internal/rpc/keeper"
+
+	"github.com/spf13/cobra"
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:       "maintenance {on|off}",
+	Short:     "Switch server maintenance mode",
+	Long:      `In maintenance mode the server stops recovering broken services and suppresses the midnight upgrade check, running services are left alone. Maintenance mode is cleared automatically after interval.maintenance seconds`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"on", "off"},
+	Run: func(cmd *cobra.Command, args []string) {
+		switchMaintenance(args[0])
+	},
+}
+
+func switchMaintenance(mode string) {
+	if mode != "on" && mode != "off" {
+		fmt.Println("Mode must be 'on' or 'off'")
+		return
+	}
+	if err := keeper.NewClient(nil).SetMaintenance(mode == "on"); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	fmt.Printf("Maintenance mode is %s\n", mode)
+}
+
+func init() {
+	maintenanceCmd.Example = `  costrict maintenance on
+  costrict maintenance off`
+	root.RootCmd.AddCommand(maintenanceCmd)
+}
@@ -3,9 +3,11 @@ package client
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"costrict-keeper/cmd/root"
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/rpc/keeper"
 
 	"github.com/spf13/cobra"
 )
@@ -36,19 +38,27 @@ var reloadCmd = &cobra.Command{
  * - Response parsing errors
  */
 func reloadServerConfig(ctx context.Context) {
-	rpcClient := rpc.NewHTTPClient(nil)
-
 	// 调用 costrict 的 RESTful API POST 方法
-	resp, err := rpcClient.Post("/costrict/api/v1/reload", nil)
+	diff, err := keeper.NewClient(nil).Reload()
 	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return
+	fmt.Println("Successfully reloaded server configuration")
+	printItemsDiff("service", diff.Services)
+	printItemsDiff("component", diff.Components)
+}
+
+func printItemsDiff(kind string, diff models.ItemsDiff) {
+	for _, name := range diff.Added {
+		fmt.Printf("  + %s %s\n", kind, name)
+	}
+	for _, name := range diff.Removed {
+		fmt.Printf("  - %s %s\n", kind, name)
+	}
+	for _, change := range diff.Modified {
+		fmt.Printf("  ~ %s %s: %s\n", kind, change.Name, strings.Join(change.Fields, ", "))
 	}
-	fmt.Printf("Successfully reloaded server configuration, status code: %d\n", resp.StatusCode)
 }
 
 func init() {
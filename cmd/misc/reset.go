@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"costrict-keeper/cmd/root"
+	"costrict-keeper/internal/env"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	optResetAll        bool
+	optResetKeepConfig bool
+	optResetYes        bool
+)
+
+var resetCmd = &cobra.Command{
+	Use:   "reset [--all] [--keep-config] [--yes]",
+	Short: "Purge costrict state for a clean reinstall",
+	Long: `Stop the costrict server and all component processes, then remove cache, package and run directories.
+The config directory and share/auth.json are preserved. With --all, bin, config and share/auth.json are removed too,
+use --keep-config together with --all to preserve the config directory.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		resetState(optResetAll, optResetKeepConfig, optResetYes)
+	},
+}
+
+/**
+ * Get paths to be removed by reset
+ * @param {bool} all - Also remove bin, config and share/auth.json
+ * @param {bool} keepConfig - Preserve config directory even if all is set
+ * @returns {[]string} Returns existing paths to be removed
+ */
+func getResetTargets(all, keepConfig bool) []string {
+	targets := []string{env.GetCacheDir(), env.GetPackageDir(), env.GetRunDir()}
+	if all {
+		targets = append(targets, filepath.Join(env.CostrictDir, "bin"))
+		targets = append(targets, filepath.Join(env.CostrictDir, "share", "auth.json"))
+		if !keepConfig {
+			targets = append(targets, filepath.Join(env.CostrictDir, "config"))
+		}
+	}
+	var existing []string
+	for _, target := range targets {
+		if _, err := os.Stat(target); err == nil {
+			existing = append(existing, target)
+		}
+	}
+	return existing
+}
+
+/**
+ * Purge costrict state after user confirmation
+ * @param {bool} all - Also remove bin, config and share/auth.json
+ * @param {bool} keepConfig - Preserve config directory even if all is set
+ * @param {bool} yes - Skip the confirmation prompt
+ * @description
+ * - Prints every path to be removed and asks for confirmation
+ * - Kills the costrict server and component processes before removing anything,
+ *   so no running process recreates the removed files
+ */
+func resetState(all, keepConfig, yes bool) {
+	targets := getResetTargets(all, keepConfig)
+	if len(targets) == 0 {
+		fmt.Println("Nothing to reset")
+		return
+	}
+	fmt.Println("The following will be deleted:")
+	for _, target := range targets {
+		fmt.Printf("  %s\n", target)
+	}
+	if !yes && !confirm("Proceed? [y/N]: ") {
+		fmt.Println("Reset cancelled")
+		return
+	}
+	fmt.Println("Stopping costrict server and component processes...")
+	killAllProcesses()
+	failed := 0
+	for _, target := range targets {
+		if err := os.RemoveAll(target); err != nil {
+			fmt.Printf("Failed to remove %s: %v\n", target, err)
+			failed++
+		} else {
+			fmt.Printf("Removed %s\n", target)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("Reset finished with %d error(s)\n", failed)
+		return
+	}
+	fmt.Println("Reset completed successfully")
+}
+
+// 读取用户输入，仅y/yes视为确认
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	resetCmd.Flags().SortFlags = false
+	resetCmd.Flags().BoolVar(&optResetAll, "all", false, "Also remove bin, config and share/auth.json")
+	resetCmd.Flags().BoolVar(&optResetKeepConfig, "keep-config", false, "Preserve config directory when --all is given")
+	resetCmd.Flags().BoolVarP(&optResetYes, "yes", "y", false, "Skip confirmation")
+	root.RootCmd.AddCommand(resetCmd)
+
+	resetCmd.Example = `  costrict reset
+  costrict reset --all --keep-config`
+}
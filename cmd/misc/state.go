@@ -1,14 +1,13 @@
 package client
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"costrict-keeper/cmd/root"
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/models"
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
 
 	"github.com/spf13/cobra"
 )
@@ -26,25 +25,14 @@ const stateExample = `  # Display server configuration and states
   costrict state`
 
 func showServerState() {
-	rpcClient := rpc.NewHTTPClient(nil)
-	resp, err := rpcClient.Get("/costrict/api/v1/state", nil)
+	respState, err := keeper.NewClient(nil).State()
 	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
-		return
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return
-	}
-
-	var respState models.ServerState
-	if err := json.Unmarshal(resp.Body, &respState); err != nil {
-		fmt.Printf("Failed to unmarshal state response: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
 	// 成功反序列化，显示检查结果
-	displayStates(respState)
+	displayStates(*respState)
 }
 
 func displayStates(results models.ServerState) {
@@ -60,6 +48,17 @@ func displayStates(results models.ServerState) {
 	fmt.Printf("安装目录: %s\n", results.Env.CostrictDir)
 	fmt.Printf("侦听端口: %v\n", results.Env.ListenPort)
 	fmt.Printf("软件版本: %v\n", results.Env.Version)
+	if results.SafeMode {
+		fmt.Printf("安全模式: 开启，不升级组件\n")
+	}
+	fmt.Println()
+
+	fmt.Println("=== 维护模式 ===")
+	if results.Maintenance.Enabled {
+		fmt.Printf("状态: 开启，自动退出时间: %s\n", results.Maintenance.Until.Format(time.RFC3339))
+	} else {
+		fmt.Printf("状态: 关闭\n")
+	}
 	fmt.Println()
 
 	// Display midnight rooster status
@@ -68,6 +67,18 @@ func displayStates(results models.ServerState) {
 	fmt.Printf("下次检查时间: %s\n", results.MidnightRooster.NextCheckTime.Format(time.RFC3339))
 	fmt.Println()
 
+	if len(results.OnceTasks) > 0 {
+		fmt.Println("=== once服务运行结果 ===")
+		for _, task := range results.OnceTasks {
+			fmt.Printf("%s: %s, 退出码: %d, 耗时: %dms", task.Name, task.Result, task.ExitCode, task.Duration)
+			if task.Error != "" {
+				fmt.Printf(", 原因: %s", task.Error)
+			}
+			fmt.Println()
+		}
+		fmt.Println()
+	}
+
 	fmt.Println("=== 端口分配信息 ===")
 	fmt.Printf("可分配范围: [%d, %d]\n", results.PortAlloc.Min, results.PortAlloc.Max)
 	fmt.Printf("已分配端口(%d): %v\n", len(results.PortAlloc.Allocates), results.PortAlloc.Allocates)
@@ -2,7 +2,6 @@ package client
 
 import (
 	"fmt"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 
@@ -30,7 +29,7 @@ var uploadCmd = &cobra.Command{
 	Short: "Upload logs to the cloud",
 	Run: func(cmd *cobra.Command, args []string) {
 		if optUploadFile == "" && optUploadDirectory == "" {
-			optUploadDirectory = filepath.Join(env.CostrictDir, "logs")
+			optUploadDirectory = env.GetLogDir()
 		}
 		logService = services.NewLogService()
 
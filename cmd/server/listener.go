@@ -95,7 +95,7 @@ func CreateListeners(addrs []ListenAddr) ([]net.Listener, error) {
 // 		// 确定socket目录
 // 		socketDir := cfg.SocketDir
 // 		if socketDir == "" {
-// 			socketDir = filepath.Join(env.CostrictDir, "run")
+// 			socketDir = env.GetRunDir()
 // 		}
 
 // 		socketPath = filepath.Join(socketDir, cfg.SocketName)
@@ -7,12 +7,13 @@ import (
 	_ "costrict-keeper/docs" // docs is generated by Swag CLI
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/lifecycle"
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/middleware"
+	"costrict-keeper/internal/supervisor"
 	"costrict-keeper/internal/utils"
 	"costrict-keeper/services"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -28,6 +29,7 @@ import (
 )
 
 var listenAddr string
+var optSafeMode bool
 
 var serverCmd = &cobra.Command{
 	Use:   "server",
@@ -65,6 +67,12 @@ func startServer() error {
 	if err := ensureSingleInstance(); err != nil {
 		return fmt.Errorf("failed to ensure single instance: %w", err)
 	}
+	// Listen for interrupt signals, and stop requests of the supervisor (Windows service control manager)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// 被systemd/Windows服务管理器托管时，尽早建立联系，启动完成后再报告就绪
+	supervisor.Start(quit)
+
 	config.UpdateRemoteConfigs()
 	config.LoadConfig(true)
 	config.LoadSpec()
@@ -79,14 +87,21 @@ func startServer() error {
 	env.Daemon = true
 
 	server := services.NewServer(config.App())
+	server.SetSafeMode(optSafeMode)
 	if err := server.Init(); err != nil {
 		return err
 	}
-	server.StartAllService()
+	if err := server.StartAllService(); err != nil {
+		return err
+	}
 	// Initialize services
 	router := gin.Default()
 	// 添加指标统计中间件
 	router.Use(middleware.MetricsMiddleware())
+	// 添加API认证中间件，配置了令牌时修改状态的接口需要认证
+	router.Use(middleware.AuthMiddleware())
+	// 添加令牌权限范围中间件，只读令牌不能调用修改状态的接口
+	router.Use(middleware.ScopeMiddleware())
 
 	apiController := controllers.NewAPIController(server)
 	apiController.RegisterRoutes(router)
@@ -98,14 +113,19 @@ func startServer() error {
 	componentController := controllers.NewComponentController(server.Components())
 	componentController.RegisterRoutes(router)
 
+	tunnelController := controllers.NewTunnelController(server.Tunnels())
+	tunnelController.RegisterRoutes(router)
+
 	// Register swagger routes
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Start all services, monitoring and log reporting
-	go server.StartMonitoring()
-	go server.StartReportMetrics()
-	go server.StartLogReporting()
-	go server.StartMidnightRooster()
+	lifecycle.Go("monitoring", server.StartMonitoring)
+	lifecycle.Go("metrics-report", server.StartReportMetrics)
+	lifecycle.Go("log-report", server.StartLogReporting)
+	lifecycle.Go("midnight-rooster", server.StartMidnightRooster)
+	lifecycle.Go("auth-watch", config.WatchAuthConfig)
+	lifecycle.Go("config-sync", watchSyncSignal)
 
 	listenAddrs := []ListenAddr{}
 	listenAddrs = append(listenAddrs, ListenAddr{
@@ -116,7 +136,7 @@ func startServer() error {
 	if IsUnixSocketSupported() {
 		listenAddrs = append(listenAddrs, ListenAddr{
 			Network: "unix",
-			Address: filepath.Join(env.CostrictDir, "run", "costrict.sock"),
+			Address: filepath.Join(env.GetRunDir(), "costrict.sock"),
 		})
 	} else {
 		listenAddrs = append(listenAddrs, ListenAddr{
@@ -132,17 +152,14 @@ func startServer() error {
 
 	// Create HTTP server
 	srv := &http.Server{
-		Handler: router,
+		Handler:     router,
+		ConnContext: middleware.WithNetwork,
 	}
 
-	// Create context for graceful shutdown
-	quit := make(chan os.Signal, 1)
-	// Listen for interrupt signals
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
 	// Start HTTP server on all listeners
-	for i, listener := range listeners {
-		go func(idx int, ln net.Listener) {
+	for _, listener := range listeners {
+		ln := listener
+		lifecycle.Go("listener", func(ctx context.Context) {
 			addr := ln.Addr().String()
 			network := ln.Addr().Network()
 			logger.Infof("Server starting on %s://%s", network, addr)
@@ -150,12 +167,16 @@ func startServer() error {
 			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 				logger.Fatalf("Server failed to start on %s://%s: %v", network, addr, err)
 			}
-		}(i, listener)
+		})
 	}
 
+	// 服务已启动、接口已侦听，通知进程管理器就绪
+	supervisor.Ready()
+
 	// Wait for interrupt signal
 	<-quit
 	logger.Info("Server is shutting down...")
+	supervisor.Stopping()
 
 	// Create shutdown context with 5 second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -166,12 +187,20 @@ func startServer() error {
 		logger.Fatal("Server forced to shutdown:", err)
 	}
 
-	// Gracefully shutdown other services
-	server.StopAllService(ctx)
+	// Gracefully shutdown other services, with its own timeout rather than what the HTTP server left,
+	// each service is force killed if it doesn't exit within service.kill_timeout
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Duration(config.App().Service.KillTimeout)*time.Second)
+	defer stopCancel()
+	server.StopAllService(stopCtx)
+	// Wait for background workers, report the ones that leaked
+	if err := lifecycle.Shutdown(5 * time.Second); err != nil {
+		logger.Warnf("Background workers didn't exit: %v", err)
+	}
 	services.UpdateCostrictStatus("exited")
 	cleanupPidFile()
 
 	logger.Info("Server exited gracefully")
+	supervisor.Stopped()
 	return nil
 }
 
@@ -226,7 +255,7 @@ func ensureSingleInstance() error {
 			}
 		}
 	} else {
-		runDir := filepath.Join(env.CostrictDir, "run")
+		runDir := env.GetRunDir()
 		if err := os.MkdirAll(runDir, 0755); err != nil {
 			logger.Errorf("Failed to mkdir '%s': %v", runDir, err)
 			return err
@@ -244,6 +273,33 @@ func ensureSingleInstance() error {
 	return nil
 }
 
+/**
+ * Sync configuration from the cloud whenever SIGHUP is received
+ * @param {context.Context} ctx - Context to stop watching
+ * @description
+ * - Does the same as POST /costrict/api/v1/config/sync
+ * - SIGHUP is never delivered on Windows, use the API there
+ */
+func watchSyncSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Info("Received SIGHUP, sync configuration from the cloud")
+			if result, err := config.SyncConfig(); err != nil {
+				logger.Errorf("Sync configuration failed: %v", err)
+			} else if !result.Changed {
+				logger.Info("Configuration is up to date")
+			}
+		}
+	}
+}
+
 /**
 * Get platform-specific PID file path
 * @returns {string} Full path to PID file
@@ -256,7 +312,7 @@ func ensureSingleInstance() error {
 * fmt.Printf("PID file path: %s", pidPath)
  */
 func getPidFilePath() string {
-	return filepath.Join(env.CostrictDir, "run", "costrict.pid")
+	return filepath.Join(env.GetRunDir(), "costrict.pid")
 }
 
 /**
@@ -340,5 +396,6 @@ func getPortFromAddress(address string) int {
 func init() {
 	serverCmd.Flags().SortFlags = false
 	serverCmd.Flags().StringVarP(&listenAddr, "listen", "l", "", "Server listening address (e.g., ':8080')")
+	serverCmd.Flags().BoolVar(&optSafeMode, "safe-mode", false, "Skip component upgrades and midnight rooster, start services with installed binaries")
 	root.RootCmd.AddCommand(serverCmd)
 }
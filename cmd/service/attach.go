@@ -0,0 +1,44 @@
+package service
+
+import (
+	"costrict-keeper/internal/rpc/keeper"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach {service-name}",
+	Short: "Manage detached service again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := attachService(args[0]); err != nil {
+			fmt.Println(err)
+		}
+	},
+}
+
+/**
+ * Attach detached service by name
+ * @param {string} serviceName - Name of the service to attach
+ * @returns {error} Returns error if attaching fails, nil on success
+ * @description
+ * - costrict resumes watching and restarting the service
+ * - If the process exited while detached, it's restarted by the next recovery check
+ */
+func attachService(serviceName string) error {
+	client := keeper.NewClient(nil)
+	defer client.Close()
+
+	detail, err := client.AttachService(serviceName)
+	if err != nil {
+		fmt.Printf("Failed to attach service '%s': %v\n", serviceName, err)
+		return err
+	}
+	fmt.Printf("Service '%s' has been attached (PID: %d)\n", serviceName, detail.Pid)
+	return nil
+}
+
+func init() {
+	serviceCmd.AddCommand(attachCmd)
+}
@@ -3,7 +3,7 @@ package service
 import (
 	"fmt"
 
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
 
 	"github.com/spf13/cobra"
 )
@@ -37,15 +37,8 @@ var closeCmd = &cobra.Command{
  * - Response parsing errors
  */
 func closeTunnel(serviceName string) {
-	rpcClient := rpc.NewHTTPClient(nil)
-	// 尝试调用 costrict 的 RESTful API
-	resp, err := rpcClient.Post(fmt.Sprintf("/costrict/api/v1/services/%s/close", serviceName), nil)
-	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
-		return
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
+	if err := keeper.NewClient(nil).CloseServiceTunnel(serviceName); err != nil {
+		fmt.Printf("%v\n", err)
 		return
 	}
 
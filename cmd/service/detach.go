@@ -0,0 +1,44 @@
+package service
+
+import (
+	"costrict-keeper/internal/rpc/keeper"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var detachCmd = &cobra.Command{
+	Use:   "detach {service-name}",
+	Short: "Stop managing service, leave its process running",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := detachService(args[0]); err != nil {
+			fmt.Println(err)
+		}
+	},
+}
+
+/**
+ * Detach service by name
+ * @param {string} serviceName - Name of the service to detach
+ * @returns {error} Returns error if detaching fails, nil on success
+ * @description
+ * - costrict stops watching and restarting the service, its process keeps running
+ * - Use 'costrict service attach' to manage it again
+ */
+func detachService(serviceName string) error {
+	client := keeper.NewClient(nil)
+	defer client.Close()
+
+	detail, err := client.DetachService(serviceName)
+	if err != nil {
+		fmt.Printf("Failed to detach service '%s': %v\n", serviceName, err)
+		return err
+	}
+	fmt.Printf("Service '%s' has been detached, process (PID: %d) keeps running\n", serviceName, detail.Pid)
+	return nil
+}
+
+func init() {
+	serviceCmd.AddCommand(detachCmd)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"costrict-keeper/internal/rpc/keeper"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// 操作完成后显示的动词过去式
+var groupActionDone = map[string]string{
+	"start":   "started",
+	"stop":    "stopped",
+	"restart": "restarted",
+}
+
+var groupCmd = &cobra.Command{
+	Use:       "group {start | stop | restart} group-name",
+	Short:     "Start, stop or restart all services of a group",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{"start", "stop", "restart"},
+	Run: func(cmd *cobra.Command, args []string) {
+		operateGroup(args[0], args[1])
+	},
+}
+
+/**
+ * Operate all services of a group via RPC client to costrict server
+ * @param {string} action - Operation: start/stop/restart
+ * @param {string} group - Group name
+ * @description
+ * - Calls /costrict/api/v1/groups/{group}/{action}
+ * - Displays operation result of each service in the group
+ * @example
+ * operateGroup("restart", "ai-tools")
+ */
+func operateGroup(action, group string) {
+	results, err := keeper.NewClient(nil).OperateGroup(group, action)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Printf("Failed to %s service '%s': %s\n", action, r.Name, r.Error)
+		} else {
+			fmt.Printf("Successfully %s service '%s'\n", groupActionDone[action], r.Name)
+		}
+	}
+}
+
+func init() {
+	serviceCmd.AddCommand(groupCmd)
+}
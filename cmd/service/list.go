@@ -1,24 +1,31 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"os"
 
 	"costrict-keeper/internal/models"
-	"costrict-keeper/internal/rpc"
-	"costrict-keeper/internal/utils"
+	"costrict-keeper/internal/rpc/keeper"
 
-	"github.com/iancoleman/orderedmap"
 	"github.com/spf13/cobra"
 )
 
+var optListOutput string
+
 var listCmd = &cobra.Command{
 	Use:   "list [service name]",
 	Short: "View service status",
 	Long:  "View running status of all services. If service name is specified, only show detailed information of that service.",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if optListOutput != "table" && optListOutput != "json" {
+			fmt.Printf("Invalid output format '%s', must be 'table' or 'json'\n", optListOutput)
+			return
+		}
 		showServiceStatus(context.Background(), args)
 	},
 }
@@ -37,107 +44,51 @@ var listCmd = &cobra.Command{
  * - Service status checking errors
  */
 func showServiceStatus(ctx context.Context, args []string) {
-	rpcClient := rpc.NewHTTPClient(nil)
+	client := keeper.NewClient(nil)
 
 	if len(args) == 0 {
 		// Display all services status via HTTP request
-		showAllServices(rpcClient)
+		showAllServices(client)
 	} else {
 		// Display detailed information of specified service via HTTP request
-		showSpecificService(rpcClient, args[0])
+		showSpecificService(client, args[0])
 	}
 }
 
-type Service_Columns struct {
-	Name      string
-	Port      int
-	Startup   string
-	Status    string
-	Pid       int
-	Healthy   string
-	TunPid    string
-	TunPort   string
-	TunStatus string
-	StartTime string
-}
-
 /**
  * Show all services status via HTTP request
- * @param {rpc.HTTPClient} client - HTTP client for API requests
+ * @param {keeper.Client} client - Client of keeper API
  * @returns {error} Returns error if request fails, nil on success
  * @description
- * - Sends GET request to /costrict/api/v1/services endpoint
- * - Parses and displays service information in tabular format
+ * - Sends GET request to /costrict/api/v1/services endpoint with the format of '-o'
+ * - Prints the table rendered by server, or the raw JSON for piping to other tools
  * - Handles connection errors and API response errors
  * @throws
  * - HTTP request errors
  * - JSON parsing errors
  * - Response processing errors
  */
-func showAllServices(client rpc.HTTPClient) error {
-	resp, err := client.Get("/costrict/api/v1/services", nil)
+func showAllServices(client *keeper.Client) error {
+	body, err := client.ListServicesFormatted(optListOutput)
 	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return err
 	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return fmt.Errorf("API error: %s", resp.Error)
-	}
 
-	var services []models.ServiceDetail
-	if err := json.Unmarshal(resp.Body, &services); err != nil {
-		fmt.Printf("Failed to unmarshal service list: %v\n", err)
-		return err
+	if optListOutput == "json" {
+		var out bytes.Buffer
+		if err := json.Indent(&out, body, "", "  "); err != nil {
+			out.Reset()
+			out.Write(body)
+		}
+		fmt.Fprintln(os.Stdout, out.String())
+		return nil
 	}
-
-	if len(services) == 0 {
+	if len(body) == 0 {
 		fmt.Println("No services found")
 		return nil
 	}
-
-	var dataList []*orderedmap.OrderedMap
-	for _, svc := range services {
-		row := Service_Columns{}
-		row.Name = svc.Name
-		row.Status = string(svc.Status)
-		row.Pid = svc.Pid
-		row.Port = svc.Port
-		row.StartTime = svc.StartTime
-		row.Startup = svc.Spec.Startup
-		if svc.Healthy == models.Healthy {
-			row.Healthy = "Y"
-		} else {
-			row.Healthy = "N"
-		}
-		if svc.Tunnel == nil {
-			if svc.Spec.Accessible == "remote" {
-				row.TunPid = "0"
-				row.TunPort = "0"
-				row.TunStatus = "Closed"
-			} else {
-				row.TunPid = "-"
-				row.TunPort = "-"
-				row.TunStatus = "-"
-			}
-		} else {
-			row.TunPid = fmt.Sprint(svc.Tunnel.Pid)
-			row.TunPort = fmt.Sprint(svc.Tunnel.Pairs[0].MappingPort)
-			if svc.Tunnel.Status == models.StatusRunning {
-				if svc.Tunnel.Healthy == models.Healthy {
-					row.TunStatus = "Opened"
-				} else {
-					row.TunStatus = "Unhealthy"
-				}
-			} else {
-				row.TunStatus = "Closed"
-			}
-		}
-
-		recordMap, _ := utils.StructToOrderedMap(row)
-		dataList = append(dataList, recordMap)
-	}
-	utils.PrintFormat(dataList)
+	fmt.Fprintln(os.Stdout, string(body))
 	return nil
 }
 
@@ -167,41 +118,6 @@ func showAllServices(client rpc.HTTPClient) error {
  * - JSON parsing errors
  * - Response processing errors
  */
-/**
- * Get service detail from API
- * @param {rpc.HTTPClient} client - HTTP client for API requests
- * @param {string} name - Name of the service to get details for
- * @returns {models.ServiceDetail} Service detail information
- * @returns {error} Returns error if request fails, nil on success
- * @description
- * - Sends GET request to /costrict/api/v1/services/{name} endpoint
- * - Returns parsed service detail information
- * - Handles connection errors and API response errors
- * @throws
- * - HTTP request errors
- * - JSON parsing errors
- * - Response processing errors
- */
-func getServiceDetail(client rpc.HTTPClient, name string) (*models.ServiceDetail, error) {
-	resp, err := client.Get(fmt.Sprintf("/costrict/api/v1/services/%s", name), nil)
-	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
-		return nil, err
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return nil, fmt.Errorf("API error: %s", resp.Error)
-	}
-
-	var detail models.ServiceDetail
-	if err := json.Unmarshal(resp.Body, &detail); err != nil {
-		fmt.Printf("Failed to unmarshal service detail: %v\n", err)
-		return nil, err
-	}
-
-	return &detail, nil
-}
-
 /**
  * Display service detail information
  * @param {models.ServiceDetail} detail - Service detail information to display
@@ -220,6 +136,10 @@ func displayServiceDetail(detail *models.ServiceDetail, name string) {
 	fmt.Printf("Startup command: %s\n", detail.Process.Command)
 	fmt.Printf("Startup args: %+v\n", detail.Process.Args)
 	fmt.Printf("Startup mode: %s\n", detail.Spec.Startup)
+	fmt.Printf("Enabled: %v\n", detail.Enabled)
+	if detail.Reason != "" {
+		fmt.Printf("Unhealthy reason: %s\n", detail.Reason)
+	}
 	fmt.Printf("Protocol: %s\n", detail.Spec.Protocol)
 	if detail.Spec.Metrics != "" {
 		fmt.Printf("Metrics endpoint: %s\n", detail.Spec.Metrics)
@@ -243,21 +163,32 @@ func displayServiceDetail(detail *models.ServiceDetail, name string) {
 
 	// Display endpoint URL
 	if detail.Spec.Protocol != "" && detail.Port > 0 {
-		endpointURL := fmt.Sprintf("%s://localhost:%d", detail.Spec.Protocol, detail.Port)
+		host := detail.Spec.Host
+		if host == "" {
+			host = "localhost"
+		}
+		endpointURL := fmt.Sprintf("%s://%s", detail.Spec.Protocol, net.JoinHostPort(host, fmt.Sprint(detail.Port)))
 		fmt.Printf("Access URL: %s\n", endpointURL)
 	}
 
+	if detail.Tunnel == nil {
+		return
+	}
 	if len(detail.Tunnel.Pairs) > 0 {
 		fmt.Printf("Local Port: %d\n", detail.Tunnel.Pairs[0].LocalPort)
 		fmt.Printf("Mapping Port: %d\n", detail.Tunnel.Pairs[0].MappingPort)
 	}
 	fmt.Printf("Tunnel PID: %d\n", detail.Tunnel.Pid)
 	fmt.Printf("Tunnel Status: %s\n", detail.Tunnel.Status)
+	if detail.Tunnel.Uptime != "" {
+		fmt.Printf("Tunnel Uptime: %s\n", detail.Tunnel.Uptime)
+	}
+	fmt.Printf("Tunnel Reopens: %d\n", detail.Tunnel.ReopenCount)
 }
 
 /**
  * Show specific service details via HTTP request
- * @param {rpc.HTTPClient} client - HTTP client for API requests
+ * @param {keeper.Client} client - Client of keeper API
  * @param {string} name - Name of the service to get details for
  * @returns {error} Returns error if request fails, nil on success
  * @description
@@ -269,15 +200,23 @@ func displayServiceDetail(detail *models.ServiceDetail, name string) {
  * - JSON parsing errors
  * - Response processing errors
  */
-func showSpecificService(client rpc.HTTPClient, name string) error {
-	detail, err := getServiceDetail(client, name)
+func showSpecificService(client *keeper.Client, name string) error {
+	detail, err := client.GetService(name)
 	if err != nil {
+		fmt.Printf("%v\n", err)
 		return err
 	}
+	if optListOutput == "json" {
+		data, _ := json.MarshalIndent(detail, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
 	displayServiceDetail(detail, name)
 	return nil
 }
 
 func init() {
+	listCmd.Flags().SortFlags = false
+	listCmd.Flags().StringVarP(&optListOutput, "output", "o", "table", "Output format: table/json")
 	serviceCmd.AddCommand(listCmd)
 }
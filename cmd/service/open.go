@@ -1,11 +1,9 @@
 package service
 
 import (
-	"encoding/json"
 	"fmt"
 
-	"costrict-keeper/internal/models"
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
 
 	"github.com/spf13/cobra"
 )
@@ -40,20 +38,9 @@ var openCmd = &cobra.Command{
  * - Response parsing errors
  */
 func openTunnel(appName string) {
-	rpcClient := rpc.NewHTTPClient(nil)
-	resp, err := rpcClient.Post(fmt.Sprintf("/costrict/api/v1/services/%s/open", appName), nil)
+	tun, err := keeper.NewClient(nil).OpenServiceTunnel(appName)
 	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
-		return
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return
-	}
-
-	var tun models.TunnelDetail
-	if err := json.Unmarshal(resp.Body, &tun); err != nil {
-		fmt.Printf("Failed to unmarshal tunnel instance: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"costrict-keeper/internal/rpc/keeper"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var resetCmd = &cobra.Command{
+	Use:   "reset-restarts {service-name}",
+	Short: "Reset auto restart counter of service",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := resetRestartCount(args[0]); err != nil {
+			fmt.Println(err)
+		}
+	},
+}
+
+/**
+ * Reset auto restart counter of service by name
+ * @param {string} serviceName - Name of the service
+ * @returns {error} Returns error if resetting fails, nil on success
+ * @description
+ * - Once the service used up its max_restart, costrict stops restarting it when it crashes
+ * - Resetting the counter re-enables auto restart without restarting costrict
+ */
+func resetRestartCount(serviceName string) error {
+	client := keeper.NewClient(nil)
+	defer client.Close()
+
+	detail, err := client.ResetRestartCount(serviceName)
+	if err != nil {
+		fmt.Printf("Failed to reset restart counter of service '%s': %v\n", serviceName, err)
+		return err
+	}
+	fmt.Printf("Restart counter of service '%s' has been reset (max restart: %d)\n", serviceName, detail.Process.MaxRestartCount)
+	return nil
+}
+
+func init() {
+	serviceCmd.AddCommand(resetCmd)
+}
@@ -2,20 +2,28 @@ package service
 
 import (
 	"context"
-	"costrict-keeper/internal/models"
-	"costrict-keeper/internal/rpc"
-	"encoding/json"
+	"costrict-keeper/internal/rpc/keeper"
 	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var optRestartTag string
+
 var restartCmd = &cobra.Command{
-	Use:   "restart {service-name}",
+	Use:   "restart {service-name | --tag tag}",
 	Short: "Restart service",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if optRestartTag != "" {
+			restartServicesByTag(context.Background(), optRestartTag)
+			return
+		}
+		if len(args) == 0 {
+			fmt.Println("Error: Service name or tag must be specified")
+			return
+		}
 		restartService(context.Background(), args[0])
 	},
 }
@@ -63,20 +71,9 @@ var restartCmd = &cobra.Command{
  * }
  */
 func restartService(ctx context.Context, serviceName string) {
-	rpcClient := rpc.NewHTTPClient(nil)
-	resp, err := rpcClient.Post(fmt.Sprintf("/costrict/api/v1/services/%s/restart", serviceName), nil)
+	serviceDetail, err := keeper.NewClient(nil).RestartService(serviceName)
 	if err != nil {
-		fmt.Printf("failed to call costrict API: %w\n", err)
-		return
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error(%d): %s\n", resp.StatusCode, resp.Error)
-		return
-	}
-
-	var serviceDetail models.ServiceDetail
-	if err := json.Unmarshal(resp.Body, &serviceDetail); err != nil {
-		fmt.Printf("failed to unmarshal service detail: %w\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
@@ -102,6 +99,36 @@ func restartService(ctx context.Context, serviceName string) {
 	}
 }
 
+/**
+ * Restart all services with the specified tag via batch API
+ * @param {context.Context} ctx - Context for request cancellation and timeout
+ * @param {string} tag - Tag of services to restart
+ * @description
+ * - Calls /costrict/api/v1/services/batch/restart with tag in request body
+ * - Displays restart result of each service
+ * @example
+ * restartServicesByTag(context.Background(), "ai")
+ */
+func restartServicesByTag(ctx context.Context, tag string) {
+	results, err := keeper.NewClient(nil).RestartServicesByTag(tag)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Printf("No services with tag '%s'\n", tag)
+		return
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Printf("Failed to restart service '%s': %s\n", r.Name, r.Error)
+		} else {
+			fmt.Printf("Successfully restarted service '%s'\n", r.Name)
+		}
+	}
+}
+
 func init() {
+	restartCmd.Flags().StringVarP(&optRestartTag, "tag", "t", "", "Restart all services with the tag")
 	serviceCmd.AddCommand(restartCmd)
 }
@@ -1,20 +1,23 @@
 package service
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
-	"costrict-keeper/internal/models"
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
 
 	"github.com/spf13/cobra"
 )
 
+var optForce bool
+
 var startCmd = &cobra.Command{
-	Use:   "start {service-name}",
+	Use:   "start {service-name} [--force]",
 	Short: "Start service",
-	Args:  cobra.ExactArgs(1),
+	Long: `Start service
+
+A service disabled by "enabled": false in system-spec.json is refused unless --force is given.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		serviceName := args[0]
 		if serviceName == "" {
@@ -22,13 +25,14 @@ var startCmd = &cobra.Command{
 			return
 		}
 
-		startService(serviceName)
+		startService(serviceName, optForce)
 	},
 }
 
 /**
  * Start service via RPC connection to costrict server
  * @param {string} serviceName - Service name to start
+ * @param {bool} force - Start the service even if it's disabled
  * @returns {void} No return value, outputs results directly or exits on error
  * @description
  * - Attempts to connect to costrict server via Unix socket
@@ -42,20 +46,10 @@ var startCmd = &cobra.Command{
  * @example
  * startService("codebase-syncer")
  */
-func startService(serviceName string) {
-	rpcClient := rpc.NewHTTPClient(nil)
-	resp, err := rpcClient.Post(fmt.Sprintf("/costrict/api/v1/services/%s/start", serviceName), nil)
+func startService(serviceName string, force bool) {
+	serviceDetail, err := keeper.NewClient(nil).StartService(serviceName, force)
 	if err != nil {
-		fmt.Printf("Failed to call costrict API: %v\n", err)
-		return
-	}
-	if resp.Error != "" {
-		fmt.Printf("Costrict API returned error: %s\n", resp.Error)
-		return
-	}
-	var serviceDetail models.ServiceDetail
-	if err := json.Unmarshal(resp.Body, &serviceDetail); err != nil {
-		fmt.Printf("Failed to unmarshal service detail: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
@@ -82,5 +76,6 @@ func startService(serviceName string) {
 }
 
 func init() {
+	startCmd.Flags().BoolVarP(&optForce, "force", "f", false, "Start the service even if it's disabled")
 	serviceCmd.AddCommand(startCmd)
 }
@@ -1,9 +1,8 @@
 package service
 
 import (
-	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -36,20 +35,14 @@ var stopCmd = &cobra.Command{
  * }
  */
 func stopService(serviceName string) error {
-	rpcClient := rpc.NewHTTPClient(nil)
-	defer rpcClient.Close()
+	client := keeper.NewClient(nil)
+	defer client.Close()
 
-	apiPath := fmt.Sprintf("/costrict/api/v1/services/%s/stop", serviceName)
-	resp, err := rpcClient.Post(apiPath, nil)
-	if err == nil {
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			fmt.Printf("Failed to stop service '%s': %+v\n", serviceName, resp.Body)
-			return os.ErrInvalid
-		}
-		fmt.Printf("Service '%s' has been stopped\n", serviceName)
-		return nil
+	if err := client.StopService(serviceName); err != nil {
+		fmt.Printf("Failed to stop service '%s': %v\n", serviceName, err)
+		return err
 	}
-	fmt.Printf("Service '%s' stop failed\n", serviceName)
+	fmt.Printf("Service '%s' has been stopped\n", serviceName)
 	return nil
 }
 
@@ -0,0 +1,43 @@
+package tunnel
+
+import (
+	"fmt"
+	"strconv"
+
+	"costrict-keeper/internal/rpc/keeper"
+
+	"github.com/spf13/cobra"
+)
+
+var closeCmd = &cobra.Command{
+	Use:   "close {appname} {localport}",
+	Short: "Close tunnel of specified local port",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		port, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Printf("Invalid local port: %s\n", args[1])
+			return
+		}
+		closeTunnel(args[0], port)
+	},
+}
+
+/**
+ * Close tunnel via RPC connection to costrict server
+ * @param {string} appName - Application name
+ * @param {int} port - Local port of the tunnel
+ * @description
+ * - Calls DELETE /costrict/api/v1/tunnels/{appName}/{port} endpoint to close tunnel
+ */
+func closeTunnel(appName string, port int) {
+	if err := keeper.NewClient(nil).CloseTunnel(appName, port); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	fmt.Printf("Successfully closed tunnel %s:%d\n", appName, port)
+}
+
+func init() {
+	tunnelCmd.AddCommand(closeCmd)
+}
@@ -0,0 +1,51 @@
+package tunnel
+
+import (
+	"fmt"
+	"strconv"
+
+	"costrict-keeper/internal/rpc/keeper"
+
+	"github.com/spf13/cobra"
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open {appname} {localport}",
+	Short: "Open tunnel for specified local port",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		port, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Printf("Invalid local port: %s\n", args[1])
+			return
+		}
+		openTunnel(args[0], port)
+	},
+}
+
+/**
+ * Open tunnel via RPC connection to costrict server
+ * @param {string} appName - Application name
+ * @param {int} port - Local port to expose
+ * @description
+ * - Calls /costrict/api/v1/tunnels endpoint to open tunnel
+ * - Prints the mapping port allocated for the local port
+ */
+func openTunnel(appName string, port int) {
+	tun, err := keeper.NewClient(nil).OpenTunnel(appName, port)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	fmt.Printf("Successfully opened tunnel for %s\n", appName)
+	fmt.Printf("  Status: %s\n", tun.Status)
+	fmt.Printf("  PID: %d\n", tun.Pid)
+	for _, pair := range tun.Pairs {
+		fmt.Printf("  Local Port: %d -> Mapping Port: %d\n", pair.LocalPort, pair.MappingPort)
+	}
+}
+
+func init() {
+	tunnelCmd.AddCommand(openCmd)
+}
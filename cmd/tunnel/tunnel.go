@@ -0,0 +1,24 @@
+package tunnel
+
+import (
+	"costrict-keeper/cmd/root"
+
+	"github.com/spf13/cobra"
+)
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Tunnel operations for arbitrary local ports (open/close)",
+	Long:  `Tunnel operations for arbitrary local ports (open/close)`,
+}
+
+const tunnelExample = `  # expose local dev server listening on 3000
+  costrict tunnel open myapp 3000
+  # close the tunnel
+  costrict tunnel close myapp 3000`
+
+func init() {
+	root.RootCmd.AddCommand(tunnelCmd)
+
+	tunnelCmd.Example = tunnelExample
+}
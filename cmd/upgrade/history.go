@@ -0,0 +1,75 @@
+package upgrade
+
+import (
+	"fmt"
+
+	"costrict-keeper/internal/utils"
+	"costrict-keeper/services"
+
+	"github.com/iancoleman/orderedmap"
+	"github.com/spf13/cobra"
+)
+
+var optLimit int
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent component upgrades",
+	Long: `Show recent component upgrades, newest first
+
+Each upgrade is recorded in cache/upgrade-history.json with the component, versions before and after,
+time and result, whether it was upgraded by the costrict server or by 'costrict component upgrade'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		showHistory(optLimit)
+	},
+}
+
+/**
+ *	Fields displayed in list format
+ */
+type History_Columns struct {
+	Time      string `json:"time"`
+	Component string `json:"component"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Result    string `json:"result"`
+	Error     string `json:"error"`
+}
+
+func showHistory(limit int) {
+	records, err := services.GetUpgradeHistory(limit)
+	if err != nil {
+		fmt.Printf("Failed to load upgrade history: %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No upgrade records found")
+		return
+	}
+	var dataList []*orderedmap.OrderedMap
+	for _, rec := range records {
+		row := History_Columns{
+			Time:      rec.Time.Local().Format("2006-01-02 15:04:05"),
+			Component: rec.Component,
+			From:      rec.From,
+			To:        rec.To,
+			Result:    rec.Result,
+			Error:     rec.Error,
+		}
+		if row.From == "" {
+			row.From = "-"
+		}
+		if row.To == "" {
+			row.To = "-"
+		}
+		recordMap, _ := utils.StructToOrderedMap(row)
+		dataList = append(dataList, recordMap)
+	}
+	utils.PrintFormat(dataList)
+}
+
+func init() {
+	historyCmd.Flags().IntVarP(&optLimit, "limit", "n", 20, "Max number of records to show, 0 shows all")
+	upgradeCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,149 @@
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"costrict-keeper/internal/models"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// 组件还没有开始升级
+const stagePending = "pending"
+
+// 组件已经是最新版本，不需要升级
+const stageUpToDate = "up-to-date"
+
+// 升级进度表中一个组件的状态
+type upgradeRow struct {
+	name  string
+	stage string
+	from  string
+	to    string
+	err   string
+}
+
+/**
+ * Live view of the upgrade progress
+ * @property {[]*upgradeRow} rows - Components in display order
+ * @property {bool} live - Redraw the table in place, only when stdout is a terminal
+ * @property {int} lines - Lines of the last rendered table, erased before redrawing
+ */
+type progressView struct {
+	rows  []*upgradeRow
+	index map[string]*upgradeRow
+	live  bool
+	lines int
+}
+
+func newProgressView(names []string, live bool) *progressView {
+	view := &progressView{
+		index: make(map[string]*upgradeRow),
+		live:  live,
+	}
+	for _, name := range names {
+		row := &upgradeRow{name: name, stage: stagePending}
+		view.rows = append(view.rows, row)
+		view.index[name] = row
+	}
+	return view
+}
+
+// 终端上原地刷新进度表，输出被重定向时逐行输出进度
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+/**
+ * Apply progress reported by the server
+ * @param {string} name - Component name
+ * @param {models.UpgradeProgress} progress - Progress of the component
+ * @returns {bool} Returns true if the view changed
+ */
+func (v *progressView) update(name string, progress models.UpgradeProgress) bool {
+	row, ok := v.index[name]
+	if !ok || row.stage == string(progress.Stage) {
+		return false
+	}
+	row.stage = string(progress.Stage)
+	row.err = progress.Error
+	if !v.live {
+		fmt.Printf("[%d/%d] %s: %s\n", progress.Index, progress.Total, name, row.stage)
+	}
+	return true
+}
+
+// 按升级结果设置各组件的最终状态，并输出最终的进度表
+func (v *progressView) finish(results []models.ComponentUpgradeResult) {
+	for _, result := range results {
+		row, ok := v.index[result.Name]
+		if !ok {
+			continue
+		}
+		row.from = result.OldVersion
+		row.to = result.NewVersion
+		row.err = result.Error
+		switch {
+		case result.Error != "":
+			row.stage = string(models.UpgradeFailure)
+		case result.OldVersion == result.NewVersion:
+			row.stage = stageUpToDate
+		default:
+			row.stage = string(models.UpgradeDone)
+		}
+	}
+	v.live = true
+	v.render()
+}
+
+// 输出进度表，终端上覆盖上次输出的进度表
+func (v *progressView) render() {
+	if !v.live {
+		return
+	}
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"COMPONENT", "STATUS", "FROM", "TO", "ERROR"})
+	for _, row := range v.rows {
+		t.AppendRow(table.Row{row.name, row.stage, dash(row.from), dash(row.to), row.err})
+	}
+	text := t.Render()
+	if v.lines > 0 {
+		// 光标上移到上次输出的进度表开头，并清除到屏幕末尾
+		fmt.Printf("\033[%dA\033[J", v.lines)
+	}
+	fmt.Println(text)
+	v.lines = strings.Count(text, "\n") + 1
+}
+
+/**
+ * Print the summary of the upgrade
+ * @returns {error} Returns error if any component failed
+ */
+func (v *progressView) summary() error {
+	var upgraded, upToDate, failed int
+	for _, row := range v.rows {
+		switch row.stage {
+		case string(models.UpgradeDone):
+			upgraded++
+		case stageUpToDate:
+			upToDate++
+		default:
+			failed++
+		}
+	}
+	fmt.Printf("\n%d components: %d upgraded, %d up to date, %d failed\n", len(v.rows), upgraded, upToDate, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d components failed to upgrade", failed, len(v.rows))
+	}
+	return nil
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
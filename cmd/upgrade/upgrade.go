@@ -0,0 +1,129 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"costrict-keeper/cmd/root"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/rpc"
+	"costrict-keeper/internal/rpc/keeper"
+
+	"github.com/spf13/cobra"
+)
+
+var optTimeout int
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [component...]",
+	Short: "Upgrade components with live progress, or show upgrade records",
+	Long: `Upgrade components through the running costrict server and show live progress
+
+All components are upgraded if no component is specified. The status of each component
+(pending/downloading/verified/activating/done/failed) is refreshed as the server reports
+progress, and a summary is printed at the end. Exits with code 1 if any component failed.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runUpgrade(args, time.Duration(optTimeout)*time.Second); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+const upgradeExample = `  # upgrade all components
+  costrict upgrade
+  # upgrade specified components
+  costrict upgrade codebase-syncer codebase-indexer
+  # show the last 20 upgrades
+  costrict upgrade history
+  # show the last 5 upgrades
+  costrict upgrade history -n 5`
+
+/**
+ * Upgrade components by the server and render live progress
+ * @param {[]string} names - Components to upgrade, all components if empty
+ * @param {time.Duration} timeout - Max time to wait for the upgrade to finish
+ * @returns {error} Returns error if the server can't be called or any component failed
+ * @description
+ * - Subscribes upgrade_progress events before calling the bulk upgrade API,
+ *   progress isn't shown if the subscription fails but the upgrade still runs
+ * - Final status of each component is taken from the upgrade results
+ */
+func runUpgrade(names []string, timeout time.Duration) error {
+	config := rpc.DefaultHTTPConfig()
+	if len(names) == 0 {
+		components, err := keeper.NewClient(rpc.NewHTTPClient(config)).ListComponents()
+		if err != nil {
+			return err
+		}
+		for _, cpn := range components {
+			names = append(names, cpn.Name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fmt.Println("No components to upgrade")
+		return nil
+	}
+
+	view := newProgressView(names, isTerminal(os.Stdout))
+	events, cancel, err := keeper.SubscribeEvents(config)
+	if err != nil {
+		fmt.Printf("Live progress is unavailable: %v\n", err)
+	} else {
+		defer cancel()
+	}
+	view.render()
+
+	// 升级可能需要下载较大的安装包，使用单独的超时时间
+	upgradeConfig := *config
+	upgradeConfig.Timeout = timeout
+	type upgradeResponse struct {
+		results []models.ComponentUpgradeResult
+		err     error
+	}
+	done := make(chan upgradeResponse, 1)
+	go func() {
+		client := keeper.NewClient(rpc.NewHTTPClient(&upgradeConfig))
+		results, err := client.UpgradeComponents(models.ComponentUpgradeRequest{Names: names})
+		done <- upgradeResponse{results, err}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Type != models.EventUpgradeProgress {
+				continue
+			}
+			var progress models.UpgradeProgress
+			data, _ := json.Marshal(event.Data)
+			if err := json.Unmarshal(data, &progress); err != nil {
+				continue
+			}
+			if view.update(event.Name, progress) {
+				view.render()
+			}
+		case resp := <-done:
+			if resp.err != nil {
+				return resp.err
+			}
+			view.finish(resp.results)
+			return view.summary()
+		}
+	}
+}
+
+func init() {
+	root.RootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().IntVarP(&optTimeout, "timeout", "t", 1800, "Max seconds to wait for the upgrade to finish")
+	upgradeCmd.Example = upgradeExample
+}
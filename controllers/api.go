@@ -1,13 +1,23 @@
 package controllers
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/models"
 	"costrict-keeper/services"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
 )
 
+// 事件订阅连接上发送心跳的间隔
+const eventHeartbeatInterval = 30 * time.Second
+
 type APIController struct {
 	server *services.Server
 }
@@ -45,13 +55,38 @@ func NewAPIController(server *services.Server) *APIController {
  */
 func (a *APIController) RegisterRoutes(r *gin.Engine) {
 	r.GET("/healthz", a.Healthz)
+	r.GET("/costrict/api/v1/metrics.json", a.MetricsSnapshot)
 	r.GET("/costrict/api/v1/state", a.GetState)
+	r.GET("/costrict/api/v1/spec", a.GetSpec)
 	r.POST("/costrict/api/v1/reload", a.ReloadConfig)
+	r.POST("/costrict/api/v1/config/sync", a.SyncConfig)
 	r.POST("/costrict/api/v1/check", a.Check)
+	r.GET("/readyz", a.Readyz)
+	r.POST("/costrict/api/v1/drain", a.Drain)
+	r.POST("/costrict/api/v1/undrain", a.Undrain)
+	r.POST("/costrict/api/v1/maintenance", a.Maintenance)
+	r.GET("/costrict/api/v1/events", a.Events)
+	r.GET("/costrict/api/v1/dependencies", a.Dependencies)
+	r.GET("/costrict/api/v1/unhealthy", a.Unhealthy)
+	r.GET("/costrict/api/v1/processes", a.Processes)
+	r.POST("/costrict/api/v1/processes/cleanup", a.CleanupProcesses)
+}
+
+/**
+ * Reject request which starts services or opens tunnels in drain mode
+ * @param {*gin.Context} c - Gin context
+ * @returns {bool} Returns true if the request has been rejected with 503
+ */
+func rejectIfDraining(c *gin.Context) bool {
+	if !services.IsDraining() {
+		return false
+	}
+	respondError(c, http.StatusServiceUnavailable, "server.draining", "Server is draining, no new services or tunnels are accepted")
+	return true
 }
 
 // @Summary 获取服务器状态
-// @Description 获取服务器状态信息，包括系统规格、认证配置、软件配置和云配置，端口分配信息，等
+// @Description 获取服务器状态信息，包括系统规格、认证配置、软件配置和云配置(凭据已脱敏)，端口分配信息，等
 // @Tags Config
 // @Accept json
 // @Produce json
@@ -61,23 +96,64 @@ func (a *APIController) GetState(c *gin.Context) {
 	c.JSON(200, a.server.GetState())
 }
 
+// @Summary 获取系统规格
+// @Description 获取服务器正在使用的系统规格(服务、组件、管理程序)，命令行及钩子中的凭据已脱敏
+// @Description raw=true时重新读取磁盘上的system-spec.json，文件修改后未重新加载时与正在使用的规格不同
+// @Tags Config
+// @Produce json
+// @Param raw query bool false "读取磁盘上的system-spec.json，而不是正在使用的规格"
+// @Success 200 {object} models.SystemSpecification
+// @Failure 500 {object} models.ErrorResponse
+// @Router /costrict/api/v1/spec [get]
+func (a *APIController) GetSpec(c *gin.Context) {
+	if c.Query("raw") != "true" {
+		c.JSON(http.StatusOK, config.EffectiveSpec())
+		return
+	}
+	spec, err := config.RawSpec()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "config.spec_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, spec)
+}
+
 // @Summary 重新加载配置
-// @Description 重新加载应用配置文件
+// @Description 重新加载应用配置文件和系统规格(system-spec.json)，返回系统规格中新增、删除、修改的服务和组件
+// @Description 运行中的服务在重启后才使用新的规格
 // @Tags Config
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.SpecDiff
 // @Failure 500 {object} models.ErrorResponse
 // @Router /costrict/api/v1/reload [post]
 func (a *APIController) ReloadConfig(c *gin.Context) {
 	// 调用配置重新加载方法
 	if err := config.ReloadConfig(false); err != nil {
-		c.JSON(500, &models.ErrorResponse{
-			Code:  "config.reload_failed",
-			Error: "Failed to reload configuration: " + err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "config.reload_failed", "Failed to reload configuration: "+err.Error())
+		return
+	}
+	diff, err := config.ReloadSpec()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "config.reload_failed", "Failed to reload system specification: "+err.Error())
 		return
 	}
+	c.JSON(http.StatusOK, diff)
+}
 
-	c.JSON(200, gin.H{"status": "success"})
+// @Summary 同步云端配置
+// @Description 从云端下载最新的costrict-config配置包并加载，不重启costrict，返回配置是否变化及变化的配置项(凭据已脱敏)
+// @Description 与SIGHUP信号的处理相同，用于中心服务器推送配置变更后，通知keeper拉取
+// @Tags Config
+// @Produce json
+// @Success 200 {object} models.ConfigSyncResult
+// @Failure 500 {object} models.ErrorResponse
+// @Router /costrict/api/v1/config/sync [post]
+func (a *APIController) SyncConfig(c *gin.Context) {
+	result, err := config.SyncConfig()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "config.sync_failed", "Failed to sync configuration: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
 
 // @Summary 执行系统检查
@@ -98,11 +174,213 @@ func (a *APIController) Check(c *gin.Context) {
 // @Summary 业务就绪探针
 // @Description 检查服务是否已经做好准备，返回服务版本、启动时间、健康状态和关键指标统计结果
 // @Tags System
+// @Description deep=true时通过映射端口逐个检查远程访问服务的隧道是否端到端可达，结果计入tunnelReachable，开销较大
 // @Produce json
+// @Param deep query bool false "是否检查隧道端到端可达性"
 // @Success 200 {object} models.HealthResponse
 // @Router /healthz [get]
 func (a *APIController) Healthz(c *gin.Context) {
 	// 调用server的GetHealthz方法获取健康检查响应
-	response := a.server.GetHealthz()
+	response := a.server.GetHealthz(c.Query("deep") == "true")
 	c.JSON(200, response)
 }
+
+// @Summary 获取指标快照
+// @Description 以JSON格式返回当前的计数器和仪表盘指标，包括关键指标、各服务的请求/错误/OOM次数/运行时长及采集的白名单指标、各组件的版本
+// @Description 便于脚本和仪表盘直接使用，Prometheus采集请使用/metrics
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.MetricsSnapshot
+// @Router /costrict/api/v1/metrics.json [get]
+func (a *APIController) MetricsSnapshot(c *gin.Context) {
+	c.JSON(http.StatusOK, a.server.GetMetricsSnapshot())
+}
+
+// @Summary 列出不健康的条目
+// @Description 只返回处于非健康状态的服务、组件和隧道，根据监测流程记录的状态计算，不探测服务也不获取云端版本
+// @Description 返回空数组表示全部健康，监控系统可以频繁轮询，发现问题后再调用/check获取完整信息
+// @Tags System
+// @Produce json
+// @Success 200 {array} models.UnhealthyItem
+// @Router /costrict/api/v1/unhealthy [get]
+func (a *APIController) Unhealthy(c *gin.Context) {
+	c.JSON(http.StatusOK, a.server.GetUnhealthy())
+}
+
+// @Summary 检查上游依赖
+// @Description 探测配置的云端服务(升级服务器、隧道管理、日志上报、pushgateway)是否可达及访问延迟
+// @Description 用于区分是keeper自身故障还是到云端的网络故障
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.DependenciesResponse
+// @Router /costrict/api/v1/dependencies [get]
+func (a *APIController) Dependencies(c *gin.Context) {
+	c.JSON(http.StatusOK, a.server.CheckDependencies())
+}
+
+// @Summary 列出受管理的进程
+// @Description 列出costrict管理的服务进程和隧道进程(PID、进程名、状态、重启次数、运行时长)，以及组件进程中不受管理的多余进程的PID
+// @Description 用于交互式排查进程泄漏
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.ProcessesResponse
+// @Router /costrict/api/v1/processes [get]
+func (a *APIController) Processes(c *gin.Context) {
+	c.JSON(http.StatusOK, a.server.GetProcesses())
+}
+
+// @Summary 清理多余进程
+// @Description 杀死指定的多余进程(进程列表接口返回的excessive)，或者指定all为true杀死检测到的全部多余进程
+// @Description 每个进程都会再次确认是多余的、且是costrict的组件程序(按进程名和路径)，不会杀死keeper自身
+// @Tags System
+// @Accept json
+// @Produce json
+// @Param request body models.ProcessCleanupRequest true "要清理的PID列表，或all为true"
+// @Success 200 {array} models.ProcessCleanupResult
+// @Failure 400 {object} models.ErrorResponse
+// @Router /costrict/api/v1/processes/cleanup [post]
+func (a *APIController) CleanupProcesses(c *gin.Context) {
+	var req models.ProcessCleanupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "server.invalid_request", "Invalid request: "+err.Error())
+		return
+	}
+	if !req.All && len(req.Pids) == 0 {
+		respondError(c, http.StatusBadRequest, "server.invalid_request", "Either pids or all must be specified")
+		return
+	}
+	c.JSON(http.StatusOK, a.server.CleanupProcesses(req))
+}
+
+// @Summary 业务可用探针
+// @Description 服务处于排空模式时返回503，负载均衡/编排系统据此不再派发新的工作
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.SuccessResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /readyz [get]
+func (a *APIController) Readyz(c *gin.Context) {
+	if services.IsDraining() {
+		respondError(c, http.StatusServiceUnavailable, "server.draining", "Server is draining")
+		return
+	}
+	c.JSON(http.StatusOK, &models.SuccessResponse{Status: "ready"})
+}
+
+// @Summary 进入排空模式
+// @Description 不再启动服务和打开隧道(相关接口返回503)，也不再自动恢复故障服务，已运行的服务和隧道不受影响，用于平滑升级
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.SuccessResponse
+// @Router /costrict/api/v1/drain [post]
+func (a *APIController) Drain(c *gin.Context) {
+	services.SetDraining(true)
+	respondSuccess(c)
+}
+
+// @Summary 退出排空模式
+// @Description 恢复正常模式，重新允许启动服务和打开隧道
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.SuccessResponse
+// @Router /costrict/api/v1/undrain [post]
+func (a *APIController) Undrain(c *gin.Context) {
+	services.SetDraining(false)
+	respondSuccess(c)
+}
+
+// @Summary 切换维护模式
+// @Description mode为on时进入维护模式：暂停自动恢复故障服务和半夜鸡叫升级检查，已运行的服务不受影响
+// @Description 维护模式超过配置的最长持续时间(interval.maintenance)后自动退出；mode为off时退出维护模式
+// @Tags System
+// @Accept json
+// @Produce json
+// @Param request body models.MaintenanceRequest true "维护模式开关"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /costrict/api/v1/maintenance [post]
+func (a *APIController) Maintenance(c *gin.Context) {
+	var req models.MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "server.invalid_request", "Invalid request: "+err.Error())
+		return
+	}
+	switch req.Mode {
+	case "on":
+		services.SetMaintenance(true)
+	case "off":
+		services.SetMaintenance(false)
+	default:
+		respondError(c, http.StatusBadRequest, "server.invalid_request", "Mode must be 'on' or 'off'")
+		return
+	}
+	respondSuccess(c)
+}
+
+// @Summary 订阅状态变化事件
+// @Description 通过WebSocket推送服务/隧道/组件的状态变化事件，事件类型包括service_up、service_down、tunnel_reopened、upgrade_available、upgrade_progress
+// @Description 每30秒推送一次heartbeat事件，客户端可据此检测断线
+// @Tags System
+// @Produce json
+// @Success 101 {object} models.Event
+// @Router /costrict/api/v1/events [get]
+func (a *APIController) Events(c *gin.Context) {
+	server := websocket.Server{
+		Handshake: checkEventsOrigin,
+		Handler:   serveEvents,
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+/**
+ * Check Origin of the events subscription
+ * @returns {error} Returns error if the Origin isn't a local page, the handshake is rejected with 403
+ * @description
+ * - Non-browser clients don't send Origin, they're allowed
+ * - Browsers send Origin on cross-site WebSocket connections, which aren't restricted by CORS,
+ *   so pages of other sites must not be able to read status events of the keeper
+ */
+func checkEventsOrigin(cfg *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		cfg.Origin = u
+		return nil
+	}
+	return fmt.Errorf("origin %q isn't allowed", origin)
+}
+
+func serveEvents(ws *websocket.Conn) {
+	events, cancel := services.GetEventBus().Subscribe()
+	defer cancel()
+
+	// 客户端不会发送数据，读取结束说明连接已经断开
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(eventHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		var event models.Event
+		select {
+		case <-closed:
+			return
+		case event = <-events:
+		case t := <-ticker.C:
+			event = models.Event{Type: models.EventHeartbeat, Time: t}
+		}
+		if err := websocket.JSON.Send(ws, event); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+func dialEvents(t *testing.T, srv *httptest.Server, origin string) error {
+	t.Helper()
+	location := strings.Replace(srv.URL, "http", "ws", 1) + "/costrict/api/v1/events"
+	cfg, err := websocket.NewConfig(location, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := websocket.DialConfig(cfg)
+	if err == nil {
+		ws.Close()
+	}
+	return err
+}
+
+func TestEventsOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	a := &APIController{}
+	r.GET("/costrict/api/v1/events", a.Events)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, origin := range []string{"http://localhost", "http://127.0.0.1:8080", "http://[::1]:3000"} {
+		if err := dialEvents(t, srv, origin); err != nil {
+			t.Errorf("local origin %s is rejected: %v", origin, err)
+		}
+	}
+	for _, origin := range []string{"http://evil.example.com", "http://localhost.evil.com"} {
+		if err := dialEvents(t, srv, origin); err == nil {
+			t.Errorf("origin %s should be rejected", origin)
+		}
+	}
+}
+
+func TestCheckEventsOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/costrict/api/v1/events", nil)
+	if err := checkEventsOrigin(&websocket.Config{}, req); err != nil {
+		t.Errorf("clients without Origin should be allowed: %v", err)
+	}
+	// 沙箱中的页面和本地文件发送的Origin为null
+	req.Header.Set("Origin", "null")
+	if err := checkEventsOrigin(&websocket.Config{}, req); err == nil {
+		t.Error("origin null should be rejected")
+	}
+}
+
+// 写入系统规格文件
+func writeSpecFile(t *testing.T, spec models.SystemSpecification) {
+	t.Helper()
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(env.CostrictDir, "share", "system-spec.json")
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 请求GET /spec，返回解析后的系统规格
+func getSpec(t *testing.T, query string) models.SystemSpecification {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	(&APIController{}).RegisterRoutes(r)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/costrict/api/v1/spec"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var spec models.SystemSpecification
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestGetSpec(t *testing.T) {
+	env.CostrictDir = t.TempDir()
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	writeSpecFile(t, models.SystemSpecification{
+		Manager: models.ManagerSpecification{
+			Component: models.ComponentSpecification{Name: "costrict", Version: "^1.0.0"},
+			Service:   models.ServiceSpecification{Name: "costrict", Startup: models.StartupAlways},
+		},
+		Components: []models.ComponentSpecification{
+			{Name: "codebase-syncer", Version: "^1.2.0", PostInstall: "setup --api-key s3cr3t"},
+		},
+		Services: []models.ServiceSpecification{{
+			Name:     "codebase-syncer",
+			Startup:  models.StartupAlways,
+			Command:  "codebase-syncer --password=hunter2",
+			Args:     []string{"--token", "abc123", "--secret-key=xyz", "--port", "{{.LocalPort}}"},
+			PreStart: "init --token abc123",
+		}},
+	})
+	if _, err := config.ReloadSpec(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 除凭据外，响应与加载的规格完全一致
+	want := *config.Spec()
+	want.Components = []models.ComponentSpecification{
+		{Name: "codebase-syncer", Version: "^1.2.0", PostInstall: "setup --api-key ******"},
+	}
+	svc := want.Services[0]
+	svc.Command = "codebase-syncer --password=******"
+	svc.Args = []string{"--token", "******", "--secret-key=******", "--port", "{{.LocalPort}}"}
+	svc.PreStart = "init --token ******"
+	want.Services = []models.ServiceSpecification{svc}
+
+	// 按JSON比较，空切片和nil在响应中没有区别
+	got, _ := json.Marshal(getSpec(t, ""))
+	expected, _ := json.Marshal(want)
+	if string(got) != string(expected) {
+		t.Errorf("GET /spec = %s\nwant %s", got, expected)
+	}
+	// 脱敏的是副本，不影响正在使用的规格
+	if config.Spec().Services[0].Args[1] != "abc123" {
+		t.Error("loaded specification is redacted")
+	}
+
+	// 文件修改后未重新加载，只有raw=true返回磁盘上的规格
+	writeSpecFile(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{{Name: "costrict-admin", Startup: models.StartupNone}},
+	})
+	if got := getSpec(t, ""); len(got.Services) != 1 || got.Services[0].Name != "codebase-syncer" {
+		t.Errorf("GET /spec = %+v, want the loaded specification", got.Services)
+	}
+	if got := getSpec(t, "?raw=true"); len(got.Services) != 1 || got.Services[0].Name != "costrict-admin" {
+		t.Errorf("GET /spec?raw=true = %+v, want the specification on disk", got.Services)
+	}
+}
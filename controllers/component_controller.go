@@ -4,6 +4,8 @@ import (
 	"costrict-keeper/internal/models"
 	"costrict-keeper/services"
 	"fmt"
+	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -45,48 +47,118 @@ func (c *ComponentController) RegisterRoutes(r *gin.Engine) {
 	// 组件管理接口
 	api.GET("/components", c.ListComponents)
 	api.GET("/components/:name", c.GetComponentDetail)
+	api.POST("/components/upgrade", c.UpgradeComponents)
 	api.POST("/components/:name/upgrade", c.UpgradeComponent)
+	api.GET("/components/:name/verify", c.VerifyComponent)
 	api.DELETE("/components/:name", c.DeleteComponent)
+	api.GET("/upgrade/history", c.GetUpgradeHistory)
 }
 
+// 升级历史默认返回的记录数
+const defaultHistoryLimit = 20
+
 // @Summary 获取组件列表
 // @Description 获取所有已安装组件信息
 // @Tags Components
 // @Produce json
+// @Produce text/table
+// @Param format query string false "响应格式: json(默认)/table, 优先于Accept头"
 // @Success 200 {array} models.ComponentDetail
+// @Failure 400 {object} models.ErrorResponse
 // @Router /costrict/api/v1/components [get]
 func (c *ComponentController) ListComponents(g *gin.Context) {
 	var components []models.ComponentDetail
 	for _, ci := range c.component.GetComponents(true, true) {
 		components = append(components, ci.GetDetail())
 	}
-	g.JSON(200, components)
+	respondList(g, components, func() []interface{} { return componentRows(components) })
 }
 
 // @Summary 升级组件
 // @Description 升级指定组件到最新版本
 // @Tags Components
 // @Param name path string true "组件名称"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.SuccessResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Router /costrict/api/v1/components/{name}/upgrade [post]
 func (c *ComponentController) UpgradeComponent(g *gin.Context) {
 	name := g.Param("name")
 	if err := c.component.UpgradeComponent(name); err != nil {
 		if err == services.ErrComponentNotFound {
-			g.JSON(404, &models.ErrorResponse{
-				Code:  "component.not_found",
-				Error: fmt.Sprintf("Component [%s] isn't exist", name),
-			})
+			respondError(g, http.StatusNotFound, "component.not_found", fmt.Sprintf("Component [%s] isn't exist", name))
 		} else {
-			g.JSON(500, &models.ErrorResponse{
-				Code:  "component.upgrade_failed",
-				Error: err.Error(),
-			})
+			respondError(g, http.StatusInternalServerError, "component.upgrade_failed", err.Error())
 		}
 		return
 	}
-	g.JSON(200, gin.H{"status": "success"})
+	respondSuccess(g)
+}
+
+// @Summary 批量升级组件
+// @Description 升级指定的组件(未指定时升级全部组件)，可以为组件指定目标版本，未指定版本的升级到最新版本
+// @Description 返回每个组件升级前后的版本及错误信息，未指定的组件不受影响
+// @Tags Components
+// @Accept json
+// @Produce json
+// @Param request body models.ComponentUpgradeRequest false "要升级的组件及目标版本，为空则升级全部组件"
+// @Success 200 {array} models.ComponentUpgradeResult
+// @Failure 400 {object} models.ErrorResponse
+// @Router /costrict/api/v1/components/upgrade [post]
+func (c *ComponentController) UpgradeComponents(g *gin.Context) {
+	var req models.ComponentUpgradeRequest
+	if g.Request.ContentLength > 0 {
+		if err := g.ShouldBindJSON(&req); err != nil {
+			respondError(g, http.StatusBadRequest, "component.invalid_request", err.Error())
+			return
+		}
+	}
+	g.JSON(http.StatusOK, c.component.UpgradeComponents(req))
+}
+
+// @Summary 获取升级历史
+// @Description 获取最近的组件升级记录(组件、升级前后的版本、时间、结果)，最新的在前
+// @Tags Components
+// @Produce json
+// @Param limit query int false "返回的记录数，默认20，0表示全部"
+// @Success 200 {array} models.UpgradeRecord
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /costrict/api/v1/upgrade/history [get]
+func (c *ComponentController) GetUpgradeHistory(g *gin.Context) {
+	limit := defaultHistoryLimit
+	if s := g.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			respondError(g, http.StatusBadRequest, "upgrade.invalid_limit", fmt.Sprintf("Invalid limit '%s'", s))
+			return
+		}
+		limit = n
+	}
+	records, err := services.GetUpgradeHistory(limit)
+	if err != nil {
+		respondError(g, http.StatusInternalServerError, "upgrade.history_failed", err.Error())
+		return
+	}
+	g.JSON(http.StatusOK, records)
+}
+
+// @Summary 校验组件
+// @Description 重新计算已安装文件的校验和并与包描述文件比较，再用可信公钥验证包描述文件的签名
+// @Description 校验未通过时仍返回200，passed为false，error说明原因
+// @Tags Components
+// @Produce json
+// @Param name path string true "组件名称"
+// @Success 200 {object} models.ComponentVerifyResult
+// @Failure 404 {object} models.ErrorResponse
+// @Router /costrict/api/v1/components/{name}/verify [get]
+func (c *ComponentController) VerifyComponent(g *gin.Context) {
+	name := g.Param("name")
+	result, err := c.component.VerifyComponent(name)
+	if err != nil {
+		respondError(g, http.StatusNotFound, "component.not_found", fmt.Sprintf("Component [%s] isn't exist", name))
+		return
+	}
+	g.JSON(http.StatusOK, result)
 }
 
 // @Summary 获取组件详情
@@ -100,10 +172,7 @@ func (c *ComponentController) GetComponentDetail(g *gin.Context) {
 	name := g.Param("name")
 	ci := c.component.GetComponent(name)
 	if ci == nil {
-		g.JSON(404, &models.ErrorResponse{
-			Code:  "component.not_found",
-			Error: fmt.Sprintf("Component [%s] isn't exist", name),
-		})
+		respondError(g, http.StatusNotFound, "component.not_found", fmt.Sprintf("Component [%s] isn't exist", name))
 		return
 	}
 	g.JSON(200, ci.GetDetail())
@@ -113,16 +182,11 @@ func (c *ComponentController) GetComponentDetail(g *gin.Context) {
 // @Description 根据组件名删除指定组件
 // @Tags Components
 // @Param name path string true "组件名称"
-// @Success 200 {object} map[string]interface{}
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 501 {object} models.ErrorResponse
 // @Router /costrict/api/v1/components/{name} [delete]
 func (c *ComponentController) DeleteComponent(g *gin.Context) {
 	_ = g.Param("name")
 
-	// 注意：这里需要实现删除组件的逻辑
-	// 目前先返回成功状态，实际项目中需要实现具体的删除逻辑
-	g.JSON(404, &models.ErrorResponse{
-		Code:  "component.not_implemented",
-		Error: "component deletion not implemented yet",
-	})
+	// 删除组件尚未实现，返回501，避免客户端误以为组件不存在
+	respondError(g, http.StatusNotImplemented, "component.not_implemented", "component deletion not implemented yet")
 }
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteComponentNotImplemented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewComponentController(services.GetComponentManager()).RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/costrict/api/v1/components/costrict", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", w.Code)
+	}
+	var resp models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != "component.not_implemented" {
+		t.Errorf("response = %s, err = %v", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/utils"
+
+	"github.com/iancoleman/orderedmap"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * Send error response in the unified format
+ * @param {*gin.Context} c - Gin context
+ * @param {int} status - HTTP status code
+ * @param {string} code - Error code, see models.ErrorResponse for the taxonomy
+ * @param {string} message - Human readable error message
+ */
+func respondError(c *gin.Context, status int, code string, message string) {
+	c.JSON(status, &models.ErrorResponse{
+		Code:  code,
+		Error: message,
+	})
+}
+
+// 列表接口支持的响应格式，默认为JSON
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+	mimeTable   = "text/table"
+)
+
+/**
+ * Get response format requested by client
+ * @param {*gin.Context} c - Gin context
+ * @returns {string} Returns formatJSON or formatTable
+ * @returns {bool} Returns false if the format isn't supported
+ * @description
+ * - 'format' query parameter takes precedence over Accept header
+ * - Accept header containing 'text/table' requests table format
+ * - JSON is used by default
+ */
+func responseFormat(c *gin.Context) (string, bool) {
+	if format := c.Query("format"); format != "" {
+		return format, format == formatJSON || format == formatTable
+	}
+	if strings.Contains(c.GetHeader("Accept"), mimeTable) {
+		return formatTable, true
+	}
+	return formatJSON, true
+}
+
+/**
+ * Send list response in the format requested by client
+ * @param {*gin.Context} c - Gin context
+ * @param {interface{}} data - List data sent as JSON
+ * @param {func() []interface{}} rows - Builds table rows (structs), only called for table format
+ * @description
+ * - Table is rendered as plain text with content type 'text/table'
+ * - Responds 400 if the requested format isn't supported
+ */
+func respondList(c *gin.Context, data interface{}, rows func() []interface{}) {
+	format, ok := responseFormat(c)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "server.invalid_request", "Format must be 'json' or 'table'")
+		return
+	}
+	if format == formatJSON {
+		c.JSON(http.StatusOK, data)
+		return
+	}
+	var dataList []*orderedmap.OrderedMap
+	for _, row := range rows() {
+		record, err := utils.StructToOrderedMap(row)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "server.internal_error", err.Error())
+			return
+		}
+		dataList = append(dataList, record)
+	}
+	text, err := utils.FormatTable(dataList)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "server.internal_error", err.Error())
+		return
+	}
+	c.Data(http.StatusOK, mimeTable+"; charset=utf-8", []byte(text))
+}
+
+/**
+ * Send success response of operations which return no data
+ * @param {*gin.Context} c - Gin context
+ */
+func respondSuccess(c *gin.Context) {
+	c.JSON(http.StatusOK, &models.SuccessResponse{Status: "success"})
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"costrict-keeper/internal/models"
 	"costrict-keeper/services"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 )
@@ -47,13 +49,19 @@ func (s *ServiceController) RegisterRoutes(r *gin.Engine) {
 	api := r.Group("/costrict/api/v1")
 	// 服务管理接口
 	api.GET("/services", s.ListServices)
+	api.POST("/services/batch/:action", s.BatchServices)
 	api.POST("/services/:name/start", s.StartService)
 	api.POST("/services/:name/stop", s.StopService)
 	api.POST("/services/:name/restart", s.RestartService)
+	api.POST("/services/:name/detach", s.DetachService)
+	api.POST("/services/:name/attach", s.AttachService)
+	api.POST("/services/:name/reset-restarts", s.ResetRestartCount)
 	api.POST("/services/:name/open", s.OpenTunnel)
 	api.POST("/services/:name/close", s.CloseTunnel)
 	api.POST("/services/:name/reopen", s.ReopenTunnel)
+	api.GET("/services/:name/tunnel", s.GetTunnel)
 	api.GET("/services/:name", s.GetService)
+	api.POST("/groups/:group/:action", s.GroupServices)
 }
 
 // ListServices lists all managed services
@@ -63,14 +71,125 @@ func (s *ServiceController) RegisterRoutes(r *gin.Engine) {
 //	@Tags			Services
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{array}		services.ServiceDetail	"List of service instances"
-//	@Failure		500	{object}	models.ErrorResponse		"Internal server error response"
+//	@Produce		text/table
+//	@Param			tag		query		string					false	"Only list services with the tag"
+//	@Param			group	query		string					false	"Only list services in the group"
+//	@Param			format	query		string					false	"Response format: json(default)/table, overrides Accept header"
+//	@Success		200		{array}		services.ServiceDetail	"List of service instances"
+//	@Failure		400		{object}	models.ErrorResponse	"Invalid format error response"
+//	@Failure		500		{object}	models.ErrorResponse		"Internal server error response"
 //	@Router			/costrict/api/v1/services [get]
 func (s *ServiceController) ListServices(c *gin.Context) {
+	tag := c.Query("tag")
+	group := c.Query("group")
 	var results []models.ServiceDetail
 	for _, svc := range s.service.GetInstances(true) {
+		if !svc.HasTag(tag) || !svc.InGroup(group) {
+			continue
+		}
 		results = append(results, svc.GetDetail())
 	}
+	respondList(c, results, func() []interface{} { return serviceRows(results) })
+}
+
+// BatchServices performs an operation on a group of services
+//
+//	@Summary		Batch operate services
+//	@Description	Start, stop or restart managed services selected by names and/or tag
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			action	path		string					true	"Operation: start/stop/restart"
+//	@Param			request	body		models.BatchRequest		false	"Services to operate, all services if omitted"
+//	@Success		200		{array}		models.BatchResult		"Operation result of each service"
+//	@Failure		400		{object}	models.ErrorResponse	"Invalid action or request error response"
+//	@Router			/costrict/api/v1/services/batch/{action} [post]
+func (s *ServiceController) BatchServices(c *gin.Context) {
+	action := c.Param("action")
+
+	var req models.BatchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, "service.invalid_request", err.Error())
+			return
+		}
+	}
+
+	if action != "stop" && rejectIfDraining(c) {
+		return
+	}
+
+	var operate func(name string) error
+	switch action {
+	case "start":
+		operate = func(name string) error { return s.service.StartService(c.Request.Context(), name, false) }
+	case "stop":
+		operate = s.service.StopService
+	case "restart":
+		operate = func(name string) error { return s.service.RestartService(c.Request.Context(), name) }
+	default:
+		respondError(c, http.StatusBadRequest, "service.invalid_action", fmt.Sprintf("invalid action [%s]", action))
+		return
+	}
+
+	results := []models.BatchResult{}
+	for _, svc := range s.service.GetInstances(false) {
+		name := svc.GetName()
+		if !svc.HasTag(req.Tag) || (len(req.Names) > 0 && !slices.Contains(req.Names, name)) {
+			continue
+		}
+		result := models.BatchResult{Name: name, Status: "success"}
+		if err := operate(name); errors.Is(err, services.ErrServiceNotReady) {
+			result.Status = "not_ready"
+			result.Error = err.Error()
+		} else if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	c.JSON(200, results)
+}
+
+// GroupServices performs an operation on all services of a group
+//
+//	@Summary		Operate service group
+//	@Description	Start, stop or restart all services in the group, services of other groups are untouched
+//	@Tags			Services
+//	@Produce		json
+//	@Param			group	path		string					true	"Group name"
+//	@Param			action	path		string					true	"Operation: start/stop/restart"
+//	@Success		200		{array}		models.BatchResult		"Operation result of each service in the group"
+//	@Failure		400		{object}	models.ErrorResponse	"Invalid action error response"
+//	@Failure		404		{object}	models.ErrorResponse	"Group not found error response"
+//	@Router			/costrict/api/v1/groups/{group}/{action} [post]
+func (s *ServiceController) GroupServices(c *gin.Context) {
+	group := c.Param("group")
+	action := c.Param("action")
+
+	var results []models.BatchResult
+	var err error
+	switch action {
+	case "start":
+		if rejectIfDraining(c) {
+			return
+		}
+		results, err = s.service.StartGroup(c.Request.Context(), group)
+	case "stop":
+		results, err = s.service.StopGroup(group)
+	case "restart":
+		if rejectIfDraining(c) {
+			return
+		}
+		results, err = s.service.RestartGroup(c.Request.Context(), group)
+	default:
+		respondError(c, http.StatusBadRequest, "service.invalid_action", fmt.Sprintf("invalid action [%s]", action))
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusNotFound, "service.group_notexist", fmt.Sprintf("group [%s] isn't exist", group))
+		return
+	}
 	c.JSON(200, results)
 }
 
@@ -82,25 +201,31 @@ func (s *ServiceController) ListServices(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			name	path		string					true	"Service name"
-//	@Success		200		{object}	map[string]interface{}	"Service restart success response"
+//	@Success		200		{object}	services.ServiceDetail	"Service restart success response"
+//	@Success		202		{object}	services.ServiceDetail	"Service restarted but not ready in time"
 //	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
+//	@Failure		409		{object}	models.ErrorResponse	"Service is disabled and isn't running"
 //	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
 //	@Router			/costrict/api/v1/services/{name}/restart [post]
 func (s *ServiceController) RestartService(c *gin.Context) {
+	if rejectIfDraining(c) {
+		return
+	}
 	name := c.Param("name")
 
 	svc := s.service.GetInstance(name)
 	if svc == nil {
-		c.JSON(404, &models.ErrorResponse{
-			Code:  "service.notexist",
-			Error: fmt.Sprintf("service [%s] isn't exist", name),
-		})
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 		return
 	}
-	if err := s.service.RestartService(c.Request.Context(), name); err != nil {
-		c.JSON(500, &models.ErrorResponse{
-			Error: err.Error(),
-		})
+	if err := s.service.RestartService(c.Request.Context(), name); errors.Is(err, services.ErrServiceNotReady) {
+		c.JSON(http.StatusAccepted, svc.GetDetail())
+		return
+	} else if errors.Is(err, services.ErrServiceDisabled) {
+		respondError(c, http.StatusConflict, "service.disabled", err.Error())
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "service.restart_failed", err.Error())
 		return
 	}
 
@@ -115,24 +240,33 @@ func (s *ServiceController) RestartService(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			name	path		string					true	"Service name"
-//	@Success		200		{object}	map[string]interface{}	"Service start success response"
+//	@Param			force	query		bool					false	"Start the service even if it's disabled"
+//	@Success		200		{object}	services.ServiceDetail	"Service start success response"
+//	@Success		202		{object}	services.ServiceDetail	"Service started but not ready in time"
 //	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
+//	@Failure		409		{object}	models.ErrorResponse	"Service is disabled and force isn't set"
 //	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
 //	@Router			/costrict/api/v1/services/{name}/start [post]
 func (s *ServiceController) StartService(c *gin.Context) {
+	if rejectIfDraining(c) {
+		return
+	}
 	name := c.Param("name")
 
 	svc := s.service.GetInstance(name)
 	if svc == nil {
-		c.JSON(404, &models.ErrorResponse{
-			Error: fmt.Sprintf("service [%s] isn't exist", name),
-		})
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 		return
 	}
-	if err := s.service.StartService(c.Request.Context(), name); err != nil {
-		c.JSON(500, &models.ErrorResponse{
-			Error: err.Error(),
-		})
+	force := c.Query("force") == "true"
+	if err := s.service.StartService(c.Request.Context(), name, force); errors.Is(err, services.ErrServiceNotReady) {
+		c.JSON(http.StatusAccepted, svc.GetDetail())
+		return
+	} else if errors.Is(err, services.ErrServiceDisabled) {
+		respondError(c, http.StatusConflict, "service.disabled", err.Error())
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "service.start_failed", err.Error())
 		return
 	}
 	// 获取启动后的服务详细信息
@@ -147,7 +281,7 @@ func (s *ServiceController) StartService(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			name	path		string					true	"Service name"
-//	@Success		200		{object}	map[string]interface{}	"Service stop success response"
+//	@Success		200		{object}	models.SuccessResponse	"Service stop success response"
 //	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
 //	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
 //	@Router			/costrict/api/v1/services/{name}/stop [post]
@@ -155,24 +289,104 @@ func (s *ServiceController) StopService(c *gin.Context) {
 	name := c.Param("name")
 
 	if name == "costrict" {
-		c.JSON(200, gin.H{"status": "success"})
+		respondSuccess(c)
 		os.Exit(0)
 		return
 	}
 	svc := s.service.GetInstance(name)
 	if svc == nil {
-		c.JSON(404, &models.ErrorResponse{
-			Error: fmt.Sprintf("service [%s] isn't exist", name),
-		})
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 		return
 	}
 	if err := s.service.StopService(name); err != nil {
-		c.JSON(404, &models.ErrorResponse{
-			Error: err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "service.stop_failed", err.Error())
+		return
+	}
+	respondSuccess(c)
+}
+
+// DetachService stops managing a service without killing its process
+//
+//	@Summary		Detach service
+//	@Description	Stop watching and recovering the service, its process keeps running (e.g. for manual debugging)
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string					true	"Service name"
+//	@Success		200		{object}	services.ServiceDetail	"Detached service detail"
+//	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
+//	@Failure		409		{object}	models.ErrorResponse	"Service isn't running error response"
+//	@Router			/costrict/api/v1/services/{name}/detach [post]
+func (s *ServiceController) DetachService(c *gin.Context) {
+	name := c.Param("name")
+
+	svc := s.service.GetInstance(name)
+	if svc == nil || name == services.COSTRICT_NAME {
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
+		return
+	}
+	if err := s.service.DetachService(name); err != nil {
+		respondError(c, http.StatusConflict, "service.detach_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, svc.GetDetail())
+}
+
+// AttachService re-adopts the process of a detached service
+//
+//	@Summary		Attach service
+//	@Description	Resume watching and recovering the detached service
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string					true	"Service name"
+//	@Success		200		{object}	services.ServiceDetail	"Attached service detail"
+//	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
+//	@Failure		409		{object}	models.ErrorResponse	"Service isn't detached error response"
+//	@Failure		500		{object}	models.ErrorResponse	"Process exited while detached error response"
+//	@Router			/costrict/api/v1/services/{name}/attach [post]
+func (s *ServiceController) AttachService(c *gin.Context) {
+	name := c.Param("name")
+
+	svc := s.service.GetInstance(name)
+	if svc == nil || name == services.COSTRICT_NAME {
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
+		return
+	}
+	if err := s.service.AttachService(name); errors.Is(err, services.ErrServiceNotDetached) {
+		respondError(c, http.StatusConflict, "service.attach_failed", err.Error())
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "service.attach_failed", err.Error())
 		return
 	}
-	c.JSON(200, gin.H{"status": "success"})
+	c.JSON(http.StatusOK, svc.GetDetail())
+}
+
+// ResetRestartCount clears the auto restart counter of a service
+//
+//	@Summary		Reset restart counter
+//	@Description	Clear the restart counter and crash loop flag, so a service which used up its max_restart is auto restarted again without restarting the keeper
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string					true	"Service name"
+//	@Success		200		{object}	services.ServiceDetail	"Service detail after reset"
+//	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
+//	@Router			/costrict/api/v1/services/{name}/reset-restarts [post]
+func (s *ServiceController) ResetRestartCount(c *gin.Context) {
+	name := c.Param("name")
+
+	svc := s.service.GetInstance(name)
+	if svc == nil || name == services.COSTRICT_NAME {
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
+		return
+	}
+	if err := s.service.ResetRestartCount(name); err != nil {
+		respondError(c, http.StatusNotFound, "service.notexist", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, svc.GetDetail())
 }
 
 // OpenTunnel creates reverse tunnel for application
@@ -188,19 +402,18 @@ func (s *ServiceController) StopService(c *gin.Context) {
 //	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
 //	@Router			/costrict/api/v1/services/{name}/open [post]
 func (s *ServiceController) OpenTunnel(c *gin.Context) {
+	if rejectIfDraining(c) {
+		return
+	}
 	name := c.Param("name")
 
 	svc := s.service.GetInstance(name)
 	if svc == nil {
-		c.JSON(404, &models.ErrorResponse{
-			Error: fmt.Sprintf("service [%s] isn't exist", name),
-		})
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 		return
 	}
 	if err := svc.OpenTunnel(context.Background()); err != nil {
-		c.JSON(http.StatusInternalServerError, &models.ErrorResponse{
-			Error: err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "tunnel.open_failed", err.Error())
 		return
 	}
 
@@ -215,7 +428,7 @@ func (s *ServiceController) OpenTunnel(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			name	path		string					true	"Service name"
-//	@Success		200		{object}	map[string]interface{}	"Tunnel close operation success response"
+//	@Success		200		{object}	models.SuccessResponse	"Tunnel close operation success response"
 //	@Failure		404		{object}	models.ErrorResponse	"Service not found error response"
 //	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
 //	@Router			/costrict/api/v1/services/{name}/close [post]
@@ -224,21 +437,15 @@ func (s *ServiceController) CloseTunnel(c *gin.Context) {
 
 	svc := s.service.GetInstance(name)
 	if svc == nil {
-		c.JSON(404, &models.ErrorResponse{
-			Code:  "service.notexist",
-			Error: fmt.Sprintf("service [%s] isn't exist", name),
-		})
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 		return
 	}
 	if err := svc.CloseTunnel(); err != nil {
-		c.JSON(http.StatusInternalServerError, &models.ErrorResponse{
-			Code:  "tunnel.close_failed",
-			Error: err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "tunnel.close_failed", err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	respondSuccess(c)
 }
 
 // ReopenTunnel restarts application's reverse tunnel
@@ -254,24 +461,55 @@ func (s *ServiceController) CloseTunnel(c *gin.Context) {
 //	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
 //	@Router			/costrict/api/v1/services/{name}/reopen [post]
 func (s *ServiceController) ReopenTunnel(c *gin.Context) {
+	if rejectIfDraining(c) {
+		return
+	}
 	name := c.Param("name")
 
 	svc := s.service.GetInstance(name)
 	if svc == nil {
-		c.JSON(404, &models.ErrorResponse{
-			Error: fmt.Sprintf("service [%s] isn't exist", name),
-		})
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 		return
 	}
 	if err := svc.ReopenTunnel(context.Background()); err != nil {
-		c.JSON(http.StatusInternalServerError, &models.ErrorResponse{
-			Error: err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, "tunnel.reopen_failed", err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, svc.GetTunnel().GetDetail())
 }
 
+// GetTunnel gets the reverse tunnel of a service without changing it
+//
+//	@Summary		Get reverse tunnel of service
+//	@Description	Get status and port mappings of the reverse tunnel of the specified service, read-only
+//	@Tags			Services
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string					true	"Service name"
+//	@Success		200		{object}	models.TunnelDetail		"Tunnel detail"
+//	@Failure		404		{object}	models.ErrorResponse	"Service not found, isn't remote accessible or its tunnel isn't created yet error response"
+//	@Router			/costrict/api/v1/services/{name}/tunnel [get]
+func (s *ServiceController) GetTunnel(c *gin.Context) {
+	name := c.Param("name")
+
+	svc := s.service.GetInstance(name)
+	if svc == nil {
+		respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
+		return
+	}
+	if !svc.IsRemoteAccessible() {
+		respondError(c, http.StatusNotFound, "tunnel.notexist", fmt.Sprintf("service [%s] isn't remote accessible, no tunnel", name))
+		return
+	}
+	// 远程访问的服务在启动后才创建隧道
+	tun := svc.GetTunnel()
+	if tun == nil {
+		respondError(c, http.StatusNotFound, "tunnel.notcreated", fmt.Sprintf("tunnel of service [%s] isn't created yet, start the service first", name))
+		return
+	}
+	c.JSON(http.StatusOK, tun.GetDetail())
+}
+
 // GetService gets detailed information of a specific service by name
 //
 //	@Summary		Get service information
@@ -293,8 +531,5 @@ func (s *ServiceController) GetService(c *gin.Context) {
 		return
 	}
 
-	c.JSON(404, &models.ErrorResponse{
-		Code:  "service.notexist",
-		Error: fmt.Sprintf("service [%s] isn't exist", name),
-	})
+	respondError(c, http.StatusNotFound, "service.notexist", fmt.Sprintf("service [%s] isn't exist", name))
 }
@@ -0,0 +1,125 @@
+//go:build linux || darwin
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/tun"
+	"costrict-keeper/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func writeTestFile(t *testing.T, fname string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/**
+ * Load a temporary .costrict directory with a remote service and a local-only service
+ * @param {string} tunmanUrl - URL of the tunnel manager
+ * @description
+ * - Both services run sleep, tunnels run sleep too
+ * - Returns the initialized service manager, services aren't started
+ */
+func setupTunnelServices(t *testing.T, tunmanUrl string) *services.ServiceManager {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	writeTestFile(t, filepath.Join(env.CostrictDir, "share", "auth.json"),
+		[]byte(`{"id":"test-user","machine_id":"test-machine","access_token":"test-token","base_url":"http://127.0.0.1:1"}`))
+	writeTestFile(t, filepath.Join(env.CostrictDir, "config", "costrict.json"),
+		[]byte(`{"service":{"ready_timeout":-1},"cloud":{"tunman_url":"`+tunmanUrl+`"},`+
+			`"tunnel":{"command":"sleep","args":["30"]}}`))
+	spec, _ := json.Marshal(models.SystemSpecification{
+		Components: []models.ComponentSpecification{{Name: "remote-svc"}, {Name: "local-svc"}},
+		Services: []models.ServiceSpecification{
+			{Name: "remote-svc", Command: "sleep", Args: []string{"30"}, Accessible: "remote", Startup: models.StartupAlways},
+			{Name: "local-svc", Command: "sleep", Args: []string{"30"}, Accessible: "local", Startup: models.StartupAlways},
+		},
+	})
+	writeTestFile(t, filepath.Join(env.CostrictDir, "share", "system-spec.json"), spec)
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.ReloadSpec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.GetComponentManager().Init(); err != nil {
+		t.Fatal(err)
+	}
+	sm := services.GetServiceManager()
+	if err := sm.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return sm
+}
+
+func getServiceTunnel(t *testing.T, r *gin.Engine, name string, out any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/costrict/api/v1/services/"+name+"/tunnel", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	return w.Code
+}
+
+func TestGetServiceTunnel(t *testing.T) {
+	tunman := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req tun.PortAllocationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(tun.PortAllocationResponse{
+			AppName:     req.AppName,
+			ClientPort:  req.ClientPort,
+			MappingPort: 40001,
+		})
+	}))
+	defer tunman.Close()
+	sm := setupTunnelServices(t, tunman.URL)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewServiceController(sm).RegisterRoutes(r)
+
+	var resp models.ErrorResponse
+	if code := getServiceTunnel(t, r, "local-svc", &resp); code != http.StatusNotFound || resp.Code != "tunnel.notexist" {
+		t.Errorf("local-only service: status = %d, code = %s", code, resp.Code)
+	}
+	if code := getServiceTunnel(t, r, "missing-svc", &resp); code != http.StatusNotFound || resp.Code != "service.notexist" {
+		t.Errorf("unknown service: status = %d, code = %s", code, resp.Code)
+	}
+
+	if err := sm.StartService(context.Background(), "remote-svc", false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sm.GetInstance("remote-svc").CloseTunnel()
+		sm.StopService("remote-svc")
+	})
+	var detail models.TunnelDetail
+	if code := getServiceTunnel(t, r, "remote-svc", &detail); code != http.StatusOK {
+		t.Fatalf("remote service with open tunnel: status = %d", code)
+	}
+	if detail.Status != models.StatusRunning || len(detail.Pairs) != 1 || detail.Pairs[0].MappingPort != 40001 {
+		t.Errorf("tunnel detail = %+v, want running with mapping port 40001", detail)
+	}
+}
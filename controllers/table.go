@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"costrict-keeper/internal/models"
+)
+
+// 服务列表的表格行
+type serviceRow struct {
+	Name       string
+	Port       int
+	Startup    string
+	Status     string
+	Pid        int
+	Healthy    string
+	TunPid     string
+	TunPort    string
+	TunStatus  string
+	TunReopens string
+	StartTime  string
+}
+
+// 组件列表的表格行
+type componentRow struct {
+	Name        string
+	Installed   string
+	Local       string
+	Newest      string
+	NeedUpgrade string
+}
+
+// 隧道列表的表格行
+type tunnelRow struct {
+	Name        string
+	Status      string
+	Pid         int
+	Healthy     string
+	Ports       string
+	CreatedTime string
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Y"
+	}
+	return "N"
+}
+
+func serviceRows(services []models.ServiceDetail) []interface{} {
+	var rows []interface{}
+	for _, svc := range services {
+		row := serviceRow{
+			Name:      svc.Name,
+			Port:      svc.Port,
+			Startup:   string(svc.Spec.Startup),
+			Status:    string(svc.Status),
+			Pid:       svc.Pid,
+			Healthy:   yesNo(svc.Healthy == models.Healthy),
+			StartTime: svc.StartTime,
+		}
+		if svc.Tunnel == nil {
+			if svc.Spec.Accessible == "remote" {
+				row.TunPid = "0"
+				row.TunPort = "0"
+				row.TunStatus = "Closed"
+				row.TunReopens = "0"
+			} else {
+				row.TunPid = "-"
+				row.TunPort = "-"
+				row.TunStatus = "-"
+				row.TunReopens = "-"
+			}
+		} else {
+			row.TunPid = fmt.Sprint(svc.Tunnel.Pid)
+			row.TunReopens = fmt.Sprint(svc.Tunnel.ReopenCount)
+			row.TunPort = "-"
+			if len(svc.Tunnel.Pairs) > 0 {
+				row.TunPort = fmt.Sprint(svc.Tunnel.Pairs[0].MappingPort)
+			}
+			if svc.Tunnel.Status == models.StatusRunning {
+				if svc.Tunnel.Healthy == models.Healthy {
+					row.TunStatus = "Opened"
+				} else {
+					row.TunStatus = "Unhealthy"
+				}
+			} else {
+				row.TunStatus = "Closed"
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func componentRows(components []models.ComponentDetail) []interface{} {
+	var rows []interface{}
+	for _, cpn := range components {
+		rows = append(rows, componentRow{
+			Name:        cpn.Name,
+			Installed:   yesNo(cpn.Installed),
+			Local:       cpn.Local.Version,
+			Newest:      cpn.Remote.Newest,
+			NeedUpgrade: yesNo(cpn.NeedUpgrade),
+		})
+	}
+	return rows
+}
+
+func tunnelRows(tunnels []models.TunnelDetail) []interface{} {
+	var rows []interface{}
+	for _, tun := range tunnels {
+		var ports []string
+		for _, pair := range tun.Pairs {
+			ports = append(ports, fmt.Sprintf("%d->%d", pair.LocalPort, pair.MappingPort))
+		}
+		rows = append(rows, tunnelRow{
+			Name:        tun.Name,
+			Status:      string(tun.Status),
+			Pid:         tun.Pid,
+			Healthy:     yesNo(tun.Healthy == models.Healthy),
+			Ports:       strings.Join(ports, ","),
+			CreatedTime: tun.CreatedTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return rows
+}
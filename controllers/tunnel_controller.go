@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/services"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TunnelController struct {
+	tunnel *services.TunnelManager
+}
+
+/**
+ * Create new Tunnel controller instance
+ * @param {*services.TunnelManager} tunnel - Tunnel manager instance for managing ad-hoc tunnels
+ * @returns {*TunnelController} New Tunnel controller instance
+ */
+func NewTunnelController(tunnel *services.TunnelManager) *TunnelController {
+	return &TunnelController{
+		tunnel: tunnel,
+	}
+}
+
+/**
+ * Register all tunnel API routes to Gin engine
+ * @param {*gin.Engine} r - Gin router instance
+ * @description
+ * - Registers routes for tunnels of arbitrary local ports (list/open/close)
+ */
+func (t *TunnelController) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/costrict/api/v1")
+	api.GET("/tunnels", t.ListTunnels)
+	api.POST("/tunnels", t.OpenTunnel)
+	api.DELETE("/tunnels/:app/:port", t.CloseTunnel)
+}
+
+// ListTunnels lists all ad-hoc tunnels
+//
+//	@Summary		List ad-hoc tunnels
+//	@Description	Get list of tunnels opened for arbitrary local ports
+//	@Tags			Tunnels
+//	@Produce		json
+//	@Produce		text/table
+//	@Param			format	query		string					false	"Response format: json(default)/table, overrides Accept header"
+//	@Success		200		{array}		models.TunnelDetail		"List of tunnels"
+//	@Failure		400		{object}	models.ErrorResponse	"Invalid format error response"
+//	@Router			/costrict/api/v1/tunnels [get]
+func (t *TunnelController) ListTunnels(c *gin.Context) {
+	tunnels := t.tunnel.GetTunnels()
+	respondList(c, tunnels, func() []interface{} { return tunnelRows(tunnels) })
+}
+
+// OpenTunnel creates reverse tunnel for a local port
+//
+//	@Summary		Create reverse tunnel for local port
+//	@Description	Create a reverse tunnel for an arbitrary local port without defining a service
+//	@Tags			Tunnels
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.TunnelRequest	true	"Application name and local port"
+//	@Success		200		{object}	models.TunnelDetail		"Tunnel information with port mappings and status"
+//	@Failure		400		{object}	models.ErrorResponse	"Invalid request error response"
+//	@Failure		500		{object}	models.ErrorResponse	"Internal server error response"
+//	@Router			/costrict/api/v1/tunnels [post]
+func (t *TunnelController) OpenTunnel(c *gin.Context) {
+	if rejectIfDraining(c) {
+		return
+	}
+	var req models.TunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "tunnel.invalid_request", err.Error())
+		return
+	}
+	if req.LocalPort <= 0 || req.LocalPort > 65535 {
+		respondError(c, http.StatusBadRequest, "tunnel.invalid_request", fmt.Sprintf("invalid local port: %d", req.LocalPort))
+		return
+	}
+	detail, err := t.tunnel.OpenTunnel(context.Background(), req.AppName, req.LocalPort)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "tunnel.open_failed", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// CloseTunnel closes reverse tunnel of a local port
+//
+//	@Summary		Close reverse tunnel for local port
+//	@Description	Close the reverse tunnel opened for an arbitrary local port
+//	@Tags			Tunnels
+//	@Produce		json
+//	@Param			app		path		string					true	"Application name"
+//	@Param			port	path		int						true	"Local port"
+//	@Success		200		{object}	models.SuccessResponse	"Tunnel close operation success response"
+//	@Failure		400		{object}	models.ErrorResponse	"Invalid request error response"
+//	@Failure		404		{object}	models.ErrorResponse	"Tunnel not found error response"
+//	@Router			/costrict/api/v1/tunnels/{app}/{port} [delete]
+func (t *TunnelController) CloseTunnel(c *gin.Context) {
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "tunnel.invalid_request", fmt.Sprintf("invalid local port: %s", c.Param("port")))
+		return
+	}
+	if err := t.tunnel.CloseTunnel(c.Param("app"), port); err != nil {
+		respondError(c, http.StatusNotFound, "tunnel.notexist", err.Error())
+		return
+	}
+	respondSuccess(c)
+}
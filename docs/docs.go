@@ -58,7 +58,8 @@ const docTemplate = `{
             "get": {
                 "description": "获取所有已安装组件信息",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/table"
                 ],
                 "tags": [
                     "Components"
@@ -73,6 +74,62 @@ const docTemplate = `{
                                 "$ref": "#/definitions/services.ComponentInstance"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                },
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "响应格式: json(默认)/table, 优先于Accept头",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ]
+            }
+        },
+        "/costrict/api/v1/components/upgrade": {
+            "post": {
+                "description": "升级指定的组件(未指定时升级全部组件)，可以为组件指定目标版本，未指定版本的升级到最新版本\n返回每个组件升级前后的版本及错误信息，未指定的组件不受影响",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Components"
+                ],
+                "summary": "批量升级组件",
+                "parameters": [
+                    {
+                        "description": "要升级的组件及目标版本，为空则升级全部组件",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.ComponentUpgradeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ComponentUpgradeResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -93,31 +150,58 @@ const docTemplate = `{
                         "required": true
                     }
                 ],
+                "responses": {
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/components/{name}/upgrade": {
+            "post": {
+                "description": "升级指定组件到最新版本",
+                "tags": [
+                    "Components"
+                ],
+                "summary": "升级组件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "组件名称",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/costrict/api/v1/components/{name}/upgrade": {
-            "post": {
-                "description": "升级指定组件到最新版本",
+        "/costrict/api/v1/components/{name}/verify": {
+            "get": {
+                "description": "重新计算已安装文件的校验和并与包描述文件比较，再用可信公钥验证包描述文件的签名\n校验未通过时仍返回200，passed为false，error说明原因",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "Components"
                 ],
-                "summary": "升级组件",
+                "summary": "校验组件",
                 "parameters": [
                     {
                         "type": "string",
@@ -131,116 +215,148 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.ComponentVerifyResult"
                         }
                     },
                     "404": {
-                        "description": "{\"code\": \"component.not_found\", \"message\": \"Component not found\"}",
+                        "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/costrict/api/v1/reload": {
+        "/costrict/api/v1/config/sync": {
             "post": {
-                "description": "重新加载应用配置文件",
+                "description": "从云端下载最新的costrict-config配置包并加载，不重启costrict，返回配置是否变化及变化的配置项(凭据已脱敏)\n与SIGHUP信号的处理相同，用于中心服务器推送配置变更后，通知keeper拉取",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "Config"
                 ],
-                "summary": "重新加载配置",
+                "summary": "同步云端配置",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.ConfigSyncResult"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/costrict/api/v1/services": {
+        "/costrict/api/v1/dependencies": {
             "get": {
-                "description": "Get list of all managed services with their current status",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "探测配置的云端服务(升级服务器、隧道管理、日志上报、pushgateway)是否可达及访问延迟\n用于区分是keeper自身故障还是到云端的网络故障",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Services"
+                    "System"
                 ],
-                "summary": "List all services",
+                "summary": "检查上游依赖",
                 "responses": {
                     "200": {
-                        "description": "List of service instances",
+                        "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/services.ServiceInstance"
-                            }
+                            "$ref": "#/definitions/models.DependenciesResponse"
                         }
-                    },
-                    "500": {
-                        "description": "Internal server error response",
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/drain": {
+            "post": {
+                "description": "不再启动服务和打开隧道(相关接口返回503)，也不再自动恢复故障服务，已运行的服务和隧道不受影响，用于平滑升级",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "进入排空模式",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                            "$ref": "#/definitions/models.SuccessResponse"
                         }
                     }
                 }
             }
         },
-        "/costrict/api/v1/services/{name}": {
+        "/costrict/api/v1/events": {
             "get": {
-                "description": "Get detailed information of a specific service by its name",
-                "consumes": [
+                "description": "通过WebSocket推送服务/隧道/组件的状态变化事件，事件类型包括service_up、service_down、tunnel_reopened、upgrade_available、upgrade_progress\n每30秒推送一次heartbeat事件，客户端可据此检测断线",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "订阅状态变化事件",
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/models.Event"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/groups/{group}/{action}": {
+            "post": {
+                "description": "Start, stop or restart all services in the group, services of other groups are untouched",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Services"
                 ],
-                "summary": "Get service information",
+                "summary": "Operate service group",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Service name",
-                        "name": "name",
+                        "description": "Group name",
+                        "name": "group",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Operation: start/stop/restart",
+                        "name": "action",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Service detail information",
+                        "description": "Operation result of each service in the group",
                         "schema": {
-                            "$ref": "#/definitions/services.ServiceDetail"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.BatchResult"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Service not found error response",
+                    "400": {
+                        "description": "Invalid action error response",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error response",
+                    "404": {
+                        "description": "Group not found error response",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -248,9 +364,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/costrict/api/v1/services/{name}/close": {
+        "/costrict/api/v1/maintenance": {
             "post": {
-                "description": "Close the reverse tunnel for the specified service to disable remote access",
+                "description": "mode为on时进入维护模式：暂停自动恢复故障服务和半夜鸡叫升级检查，已运行的服务不受影响\n维护模式超过配置的最长持续时间(interval.maintenance)后自动退出；mode为off时退出维护模式",
                 "consumes": [
                     "application/json"
                 ],
@@ -258,43 +374,79 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Services"
+                    "System"
                 ],
-                "summary": "Close reverse tunnel for service",
+                "summary": "切换维护模式",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Service name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "description": "维护模式开关",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.MaintenanceRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tunnel close operation success response",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.TunnelResponse"
+                            "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
-                    "404": {
-                        "description": "Service not found error response",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
-                    },
-                    "500": {
-                        "description": "Internal server error response",
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/metrics.json": {
+            "get": {
+                "description": "以JSON格式返回当前的计数器和仪表盘指标，包括关键指标、各服务的请求/错误/OOM次数/运行时长及采集的白名单指标、各组件的版本\n便于脚本和仪表盘直接使用，Prometheus采集请使用/metrics",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "获取指标快照",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                            "$ref": "#/definitions/models.MetricsSnapshot"
                         }
                     }
                 }
             }
         },
-        "/costrict/api/v1/services/{name}/open": {
+        "/costrict/api/v1/processes": {
+            "get": {
+                "description": "列出costrict管理的服务进程和隧道进程(PID、进程名、状态、重启次数、运行时长)，以及组件进程中不受管理的多余进程的PID\n用于交互式排查进程泄漏",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "列出受管理的进程",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProcessesResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/processes/cleanup": {
             "post": {
-                "description": "Create a reverse tunnel for the specified service to enable remote access",
+                "description": "杀死指定的多余进程(进程列表接口返回的excessive)，或者指定all为true杀死检测到的全部多余进程\n每个进程都会再次确认是多余的、且是costrict的组件程序(按进程名和路径)，不会杀死keeper自身",
                 "consumes": [
                     "application/json"
                 ],
@@ -302,33 +454,55 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Services"
+                    "System"
                 ],
-                "summary": "Create reverse tunnel for service",
+                "summary": "清理多余进程",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Service name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "description": "要清理的PID列表，或all为true",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ProcessCleanupRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tunnel information with port mappings and status",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/services.TunnelInstance"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ProcessCleanupResult"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Service not found error response",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/reload": {
+            "post": {
+                "description": "重新加载应用配置文件和系统规格(system-spec.json)，返回系统规格中新增、删除、修改的服务和组件\n运行中的服务在重启后才使用新的规格",
+                "tags": [
+                    "Config"
+                ],
+                "summary": "重新加载配置",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SpecDiff"
+                        }
                     },
                     "500": {
-                        "description": "Internal server error response",
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -336,19 +510,127 @@ const docTemplate = `{
                 }
             }
         },
-        "/costrict/api/v1/services/{name}/reopen": {
-            "post": {
-                "description": "Restart the reverse tunnel for the specified service to refresh connection and port mapping",
+        "/costrict/api/v1/services": {
+            "get": {
+                "description": "Get list of all managed services with their current status",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/table"
                 ],
                 "tags": [
                     "Services"
                 ],
-                "summary": "Restart reverse tunnel for service",
+                "summary": "List all services",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only list services with the tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list services in the group",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json(default)/table, overrides Accept header",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of service instances",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.ServiceInstance"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid format error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/services/batch/{action}": {
+            "post": {
+                "description": "Start, stop or restart managed services selected by names and/or tag",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Batch operate services",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Operation: start/stop/restart",
+                        "name": "action",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Services to operate, all services if omitted",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Operation result of each service",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.BatchResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid action or request error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/services/{name}": {
+            "get": {
+                "description": "Get detailed information of a specific service by its name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Get service information",
                 "parameters": [
                     {
                         "type": "string",
@@ -360,9 +642,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Tunnel restart operation success response",
+                        "description": "Service detail information",
                         "schema": {
-                            "$ref": "#/definitions/models.TunnelResponse"
+                            "$ref": "#/definitions/services.ServiceDetail"
                         }
                     },
                     "404": {
@@ -380,9 +662,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/costrict/api/v1/services/{name}/restart": {
+        "/costrict/api/v1/services/{name}/attach": {
             "post": {
-                "description": "Restart a specific service by its name",
+                "description": "Resume watching and recovering the detached service",
                 "consumes": [
                     "application/json"
                 ],
@@ -392,7 +674,7 @@ const docTemplate = `{
                 "tags": [
                     "Services"
                 ],
-                "summary": "Restart service",
+                "summary": "Attach service",
                 "parameters": [
                     {
                         "type": "string",
@@ -404,10 +686,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Service restart success response",
+                        "description": "Attached service detail",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/services.ServiceDetail"
                         }
                     },
                     "404": {
@@ -416,8 +697,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Service isn't detached error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
-                        "description": "Internal server error response",
+                        "description": "Process exited while detached error response",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -425,9 +712,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/costrict/api/v1/services/{name}/start": {
+        "/costrict/api/v1/services/{name}/close": {
             "post": {
-                "description": "Start a specific service by its name",
+                "description": "Close the reverse tunnel for the specified service to disable remote access",
                 "consumes": [
                     "application/json"
                 ],
@@ -437,7 +724,7 @@ const docTemplate = `{
                 "tags": [
                     "Services"
                 ],
-                "summary": "Start service",
+                "summary": "Close reverse tunnel for service",
                 "parameters": [
                     {
                         "type": "string",
@@ -449,10 +736,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Service start success response",
+                        "description": "Tunnel close operation success response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
                     "404": {
@@ -470,9 +756,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/costrict/api/v1/services/{name}/stop": {
+        "/costrict/api/v1/services/{name}/detach": {
             "post": {
-                "description": "Stop a specific service by its name",
+                "description": "Stop watching and recovering the service, its process keeps running (e.g. for manual debugging)",
                 "consumes": [
                     "application/json"
                 ],
@@ -482,7 +768,7 @@ const docTemplate = `{
                 "tags": [
                     "Services"
                 ],
-                "summary": "Stop service",
+                "summary": "Detach service",
                 "parameters": [
                     {
                         "type": "string",
@@ -494,10 +780,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Service stop success response",
+                        "description": "Detached service detail",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/services.ServiceDetail"
                         }
                     },
                     "404": {
@@ -506,8 +791,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error response",
+                    "409": {
+                        "description": "Service isn't running error response",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -515,109 +800,907 @@ const docTemplate = `{
                 }
             }
         },
-        "/healthz": {
-            "get": {
-                "description": "检查服务是否已经做好准备，返回服务版本、启动时间、健康状态和关键指标统计结果",
+        "/costrict/api/v1/services/{name}/open": {
+            "post": {
+                "description": "Create a reverse tunnel for the specified service to enable remote access",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "System"
+                    "Services"
+                ],
+                "summary": "Create reverse tunnel for service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "业务就绪探针",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Tunnel information with port mappings and status",
                         "schema": {
-                            "$ref": "#/definitions/models.HealthResponse"
+                            "$ref": "#/definitions/services.TunnelInstance"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.CheckResponse": {
-            "description": "系统检查API响应数据结构",
-            "type": "object",
-            "properties": {
-                "components": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.ComponentCheckResult"
+        },
+        "/costrict/api/v1/services/{name}/reopen": {
+            "post": {
+                "description": "Restart the reverse tunnel for the specified service to refresh connection and port mapping",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Restart reverse tunnel for service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
                     }
-                },
-                "failedChecks": {
-                    "type": "integer",
-                    "example": 2
-                },
-                "midnightRooster": {
-                    "$ref": "#/definitions/models.MidnightRoosterCheckResult"
-                },
-                "overallStatus": {
-                    "type": "string",
-                    "example": "healthy"
-                },
-                "passedChecks": {
-                    "type": "integer",
-                    "example": 8
-                },
-                "services": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.ServiceCheckResult"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tunnel restart operation success response",
+                        "schema": {
+                            "$ref": "#/definitions/models.TunnelResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
-                },
-                "timestamp": {
-                    "type": "string",
-                    "example": "2024-01-01T10:00:00Z"
-                },
-                "totalChecks": {
-                    "type": "integer",
-                    "example": 10
                 }
             }
         },
-        "models.ComponentCheckResult": {
-            "description": "组件状态检查结果",
-            "type": "object",
-            "properties": {
-                "installed": {
-                    "type": "boolean",
-                    "example": true
-                },
-                "localVersion": {
-                    "type": "string",
-                    "example": "1.0.0"
-                },
-                "name": {
-                    "type": "string",
-                    "example": "costrict"
-                },
-                "needUpgrade": {
-                    "type": "boolean",
-                    "example": true
-                },
-                "remoteVersion": {
-                    "type": "string",
+        "/costrict/api/v1/services/{name}/reset-restarts": {
+            "post": {
+                "description": "Clear the restart counter and crash loop flag, so a service which used up its max_restart is auto restarted again without restarting the keeper",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Reset restart counter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service detail after reset",
+                        "schema": {
+                            "$ref": "#/definitions/services.ServiceDetail"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/services/{name}/restart": {
+            "post": {
+                "description": "Restart a specific service by its name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Restart service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service restart success response",
+                        "schema": {
+                            "$ref": "#/definitions/services.ServiceDetail"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Service restarted but not ready in time",
+                        "schema": {
+                            "$ref": "#/definitions/services.ServiceDetail"
+                        }
+                    },
+                    "409": {
+                        "description": "Service is disabled and isn't running",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/services/{name}/start": {
+            "post": {
+                "description": "Start a specific service by its name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Start service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Start the service even if it's disabled",
+                        "name": "force",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service start success response",
+                        "schema": {
+                            "$ref": "#/definitions/services.ServiceDetail"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Service started but not ready in time",
+                        "schema": {
+                            "$ref": "#/definitions/services.ServiceDetail"
+                        }
+                    },
+                    "409": {
+                        "description": "Service is disabled and force isn't set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/services/{name}/stop": {
+            "post": {
+                "description": "Stop a specific service by its name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Stop service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service stop success response",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/services/{name}/tunnel": {
+            "get": {
+                "description": "Get status and port mappings of the reverse tunnel of the specified service, read-only",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Get reverse tunnel of service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tunnel detail",
+                        "schema": {
+                            "$ref": "#/definitions/models.TunnelDetail"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found, isn't remote accessible or its tunnel isn't created yet error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/spec": {
+            "get": {
+                "description": "获取服务器正在使用的系统规格(服务、组件、管理程序)，命令行及钩子中的凭据已脱敏\nraw=true时重新读取磁盘上的system-spec.json，文件修改后未重新加载时与正在使用的规格不同",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Config"
+                ],
+                "summary": "获取系统规格",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "读取磁盘上的system-spec.json，而不是正在使用的规格",
+                        "name": "raw",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SystemSpecification"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/tunnels": {
+            "get": {
+                "description": "Get list of tunnels opened for arbitrary local ports",
+                "produces": [
+                    "application/json",
+                    "text/table"
+                ],
+                "tags": [
+                    "Tunnels"
+                ],
+                "summary": "List ad-hoc tunnels",
+                "responses": {
+                    "200": {
+                        "description": "List of tunnels",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.TunnelDetail"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid format error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                },
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Response format: json(default)/table, overrides Accept header",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ]
+            },
+            "post": {
+                "description": "Create a reverse tunnel for an arbitrary local port without defining a service",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tunnels"
+                ],
+                "summary": "Create reverse tunnel for local port",
+                "parameters": [
+                    {
+                        "description": "Application name and local port",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TunnelRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tunnel information with port mappings and status",
+                        "schema": {
+                            "$ref": "#/definitions/models.TunnelDetail"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/tunnels/{app}/{port}": {
+            "delete": {
+                "description": "Close the reverse tunnel opened for an arbitrary local port",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tunnels"
+                ],
+                "summary": "Close reverse tunnel for local port",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Application name",
+                        "name": "app",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Local port",
+                        "name": "port",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tunnel close operation success response",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Tunnel not found error response",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/undrain": {
+            "post": {
+                "description": "恢复正常模式，重新允许启动服务和打开隧道",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "退出排空模式",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/unhealthy": {
+            "get": {
+                "description": "只返回处于非健康状态的服务、组件和隧道，根据监测流程记录的状态计算，不探测服务也不获取云端版本\n返回空数组表示全部健康，监控系统可以频繁轮询，发现问题后再调用/check获取完整信息",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "列出不健康的条目",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UnhealthyItem"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/costrict/api/v1/upgrade/history": {
+            "get": {
+                "description": "获取最近的组件升级记录(组件、升级前后的版本、时间、结果)，最新的在前",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Components"
+                ],
+                "summary": "获取升级历史",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "返回的记录数，默认20，0表示全部",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UpgradeRecord"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "检查服务是否已经做好准备，返回服务版本、启动时间、健康状态和关键指标统计结果\ndeep=true时通过映射端口逐个检查远程访问服务的隧道是否端到端可达，结果计入tunnelReachable，开销较大",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "业务就绪探针",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "是否检查隧道端到端可达性",
+                        "name": "deep",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "服务处于排空模式时返回503，负载均衡/编排系统据此不再派发新的工作",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "业务可用探针",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.BatchRequest": {
+            "type": "object",
+            "properties": {
+                "names": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BatchResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CheckResponse": {
+            "description": "系统检查API响应数据结构",
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ComponentCheckResult"
+                    }
+                },
+                "failedChecks": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "midnightRooster": {
+                    "$ref": "#/definitions/models.MidnightRoosterCheckResult"
+                },
+                "overallStatus": {
+                    "type": "string",
+                    "example": "healthy"
+                },
+                "passedChecks": {
+                    "type": "integer",
+                    "example": 8
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceCheckResult"
+                    }
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2024-01-01T10:00:00Z"
+                },
+                "totalChecks": {
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "models.ComponentCheckResult": {
+            "description": "组件状态检查结果",
+            "type": "object",
+            "properties": {
+                "installed": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "localVersion": {
+                    "type": "string",
+                    "example": "1.0.0"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "costrict"
+                },
+                "needUpgrade": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "remoteVersion": {
+                    "type": "string",
                     "example": "1.1.0"
                 }
             }
         },
-        "models.ComponentSpecification": {
+        "models.ComponentSpecification": {
+            "type": "object",
+            "properties": {
+                "install_dir": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "post_install": {
+                    "type": "string"
+                },
+                "upgrade": {
+                    "$ref": "#/definitions/models.UpgradeSpecification"
+                },
+                "upgrade_url": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ComponentUpgradeRequest": {
+            "type": "object",
+            "properties": {
+                "names": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "versions": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.ComponentUpgradeResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "newVersion": {
+                    "type": "string"
+                },
+                "oldVersion": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ComponentVerifyResult": {
+            "type": "object",
+            "properties": {
+                "actualChecksum": {
+                    "type": "string"
+                },
+                "checksumOk": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expectedChecksum": {
+                    "type": "string"
+                },
+                "filePath": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "passed": {
+                    "type": "boolean"
+                },
+                "signatureOk": {
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ComponentVersion": {
+            "description": "组件名称及本地安装的版本",
             "type": "object",
             "properties": {
-                "install_dir": {
-                    "type": "string"
+                "installed": {
+                    "type": "boolean"
                 },
                 "name": {
+                    "type": "string",
+                    "example": "costrict"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.0.0"
+                }
+            }
+        },
+        "models.ConfigChange": {
+            "type": "object",
+            "properties": {
+                "field": {
                     "type": "string"
                 },
-                "upgrade": {
-                    "$ref": "#/definitions/models.UpgradeSpecification"
+                "new": {},
+                "old": {}
+            }
+        },
+        "models.ConfigSyncResult": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "boolean"
                 },
-                "version": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ConfigChange"
+                    }
+                }
+            }
+        },
+        "models.DependenciesResponse": {
+            "description": "上游依赖检查API响应数据结构",
+            "type": "object",
+            "properties": {
+                "dependencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DependencyStatus"
+                    }
+                },
+                "reachable": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2024-01-01T10:00:00Z"
+                }
+            }
+        },
+        "models.DependencyStatus": {
+            "description": "上游依赖(云端服务)的可达性和访问延迟",
+            "type": "object",
+            "properties": {
+                "error": {
                     "type": "string"
+                },
+                "latency": {
+                    "type": "integer",
+                    "example": 35
+                },
+                "name": {
+                    "type": "string",
+                    "example": "upgrade"
+                },
+                "reachable": {
+                    "type": "boolean"
+                },
+                "statusCode": {
+                    "type": "integer",
+                    "example": 200
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://zgsm.sangfor.com/costrict"
                 }
             }
         },
@@ -629,10 +1712,82 @@ const docTemplate = `{
                 }
             }
         },
+        "models.Event": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "detail of the service/tunnel/component"
+                },
+                "name": {
+                    "description": "name of service/component",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "time when the event happened",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "event type",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.EventType"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.EventType": {
+            "type": "string",
+            "enum": [
+                "service_up",
+                "service_down",
+                "tunnel_reopened",
+                "upgrade_available",
+                "heartbeat",
+                "crash_loop",
+                "upgrade_failed",
+                "self_upgrade_invalid",
+                "upgrade_progress",
+                "service_unhealthy"
+            ],
+            "x-enum-varnames": [
+                "EventServiceUp",
+                "EventServiceDown",
+                "EventTunnelReopened",
+                "EventUpgradeAvailable",
+                "EventHeartbeat",
+                "EventCrashLoop",
+                "EventUpgradeFailed",
+                "EventSelfUpgradeInvalid",
+                "EventUpgradeProgress",
+                "EventServiceUnhealthy"
+            ]
+        },
+        "models.HealthRecord": {
+            "type": "object",
+            "properties": {
+                "healthy": {
+                    "description": "result of the check",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "why the tunnel isn't healthy",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "time of the check",
+                    "type": "string"
+                }
+            }
+        },
         "models.HealthResponse": {
             "description": "健康检查API响应数据结构",
             "type": "object",
             "properties": {
+                "exportError": {
+                    "type": "string",
+                    "example": "open .well-known.json: permission denied"
+                },
                 "metrics": {
                     "$ref": "#/definitions/models.Metrics"
                 },
@@ -644,6 +1799,12 @@ const docTemplate = `{
                     "type": "string",
                     "example": "UP"
                 },
+                "tunnels": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TunnelReachability"
+                    }
+                },
                 "uptime": {
                     "type": "string",
                     "example": "1h30m45s"
@@ -654,6 +1815,112 @@ const docTemplate = `{
                 }
             }
         },
+        "models.ItemChange": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ItemsDiff": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "modified": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ItemChange"
+                    }
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.MaintenanceRequest": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "type": "string",
+                    "example": "on"
+                }
+            }
+        },
+        "models.ManagedProcess": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "description": "进程类型(service/tunnel)",
+                    "type": "string",
+                    "example": "service"
+                },
+                "name": {
+                    "description": "进程名",
+                    "type": "string",
+                    "example": "codebase-syncer"
+                },
+                "owner": {
+                    "description": "所属的服务名，隧道则为应用名",
+                    "type": "string",
+                    "example": "codebase-syncer"
+                },
+                "pid": {
+                    "description": "进程PID",
+                    "type": "integer"
+                },
+                "restartCount": {
+                    "description": "重启次数",
+                    "type": "integer"
+                },
+                "startTime": {
+                    "description": "启动时间",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "状态",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RunStatus"
+                        }
+                    ]
+                },
+                "title": {
+                    "description": "显示用的名字",
+                    "type": "string"
+                },
+                "uptime": {
+                    "description": "运行时长，未运行时为空",
+                    "type": "string",
+                    "example": "1h30m45s"
+                }
+            }
+        },
+        "models.ManagerSpecification": {
+            "type": "object",
+            "properties": {
+                "component": {
+                    "$ref": "#/definitions/models.ComponentSpecification"
+                },
+                "service": {
+                    "$ref": "#/definitions/models.ServiceSpecification"
+                }
+            }
+        },
         "models.Metrics": {
             "description": "系统关键指标数据结构",
             "type": "object",
@@ -677,12 +1944,44 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 1000
                 },
+                "tunnelReachable": {
+                    "type": "integer"
+                },
                 "upgradedComponents": {
                     "type": "integer",
                     "example": 4
                 }
             }
         },
+        "models.MetricsSnapshot": {
+            "description": "当前各项指标的JSON快照，与Prometheus格式的/metrics相互独立",
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ComponentVersion"
+                    }
+                },
+                "metrics": {
+                    "$ref": "#/definitions/models.Metrics"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceMetrics"
+                    }
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2024-01-01T10:00:00Z"
+                },
+                "uptime": {
+                    "type": "string",
+                    "example": "1h30m45s"
+                }
+            }
+        },
         "models.MidnightRoosterCheckResult": {
             "description": "半夜鸡叫自动升级检查结果",
             "type": "object",
@@ -699,25 +1998,92 @@ const docTemplate = `{
                     "type": "string",
                     "example": "2024-01-02T03:30:00Z"
                 },
-                "status": {
-                    "type": "string",
-                    "example": "active"
+                "status": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "upgradesNeeded": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "models.PortPair": {
+            "type": "object",
+            "properties": {
+                "localPort": {
+                    "description": "local port",
+                    "type": "integer"
+                },
+                "mappingPort": {
+                    "description": "mapping port to cloud",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ProcessCleanupRequest": {
+            "type": "object",
+            "properties": {
+                "all": {
+                    "description": "清理检测到的全部多余进程",
+                    "type": "boolean"
+                },
+                "pids": {
+                    "description": "要清理的多余进程的PID，一般来自进程列表接口的excessive",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.ProcessCleanupResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "未杀死的原因",
+                    "type": "string"
+                },
+                "killed": {
+                    "description": "是否已杀死",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "进程所属的组件名",
+                    "type": "string"
+                },
+                "pid": {
+                    "description": "进程PID",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ProcessesResponse": {
+            "type": "object",
+            "properties": {
+                "excessive": {
+                    "description": "组件进程中不受管理的多余进程的PID",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
                 },
-                "upgradesNeeded": {
-                    "type": "integer",
-                    "example": 2
+                "processes": {
+                    "description": "受管理的服务和隧道进程",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ManagedProcess"
+                    }
                 }
             }
         },
-        "models.PortPair": {
+        "models.ResourceLimits": {
             "type": "object",
             "properties": {
-                "localPort": {
-                    "description": "local port",
+                "cpu": {
                     "type": "integer"
                 },
-                "mappingPort": {
-                    "description": "mapping port to cloud",
+                "memory": {
                     "type": "integer"
                 }
             }
@@ -728,13 +2094,17 @@ const docTemplate = `{
                 "exited",
                 "running",
                 "stopped",
-                "error"
+                "error",
+                "pending",
+                "detached"
             ],
             "x-enum-varnames": [
                 "StatusExited",
                 "StatusRunning",
                 "StatusStopped",
-                "StatusError"
+                "StatusError",
+                "StatusPending",
+                "StatusDetached"
             ]
         },
         "models.ServiceCheckResult": {
@@ -786,38 +2156,190 @@ const docTemplate = `{
                 }
             }
         },
+        "models.ServiceMetrics": {
+            "description": "单个服务的计数器和仪表盘指标",
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "number"
+                },
+                "healthy": {
+                    "type": "string",
+                    "example": "healthy"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "codebase-syncer"
+                },
+                "oomKills": {
+                    "type": "number"
+                },
+                "requests": {
+                    "type": "number"
+                },
+                "scraped": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                },
+                "uptime": {
+                    "type": "number"
+                }
+            }
+        },
         "models.ServiceSpecification": {
             "type": "object",
             "properties": {
                 "accessible": {
                     "type": "string"
                 },
+                "arch": {
+                    "type": "string"
+                },
                 "args": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
                 },
+                "cascade_restart": {
+                    "type": "boolean"
+                },
+                "cascade_stop": {
+                    "type": "boolean"
+                },
                 "command": {
                     "type": "string"
                 },
+                "depends_on": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "failure_action": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
                 "healthy": {
                     "type": "string"
                 },
+                "host": {
+                    "type": "string"
+                },
+                "limits": {
+                    "$ref": "#/definitions/models.ResourceLimits"
+                },
+                "max_restart": {
+                    "type": "integer"
+                },
                 "metrics": {
                     "type": "string"
                 },
                 "name": {
                     "type": "string"
                 },
+                "os": {
+                    "type": "string"
+                },
                 "port": {
                     "type": "integer"
                 },
+                "post_stop": {
+                    "type": "string"
+                },
+                "pre_start": {
+                    "type": "string"
+                },
                 "protocol": {
                     "type": "string"
                 },
+                "shell": {
+                    "type": "boolean"
+                },
                 "startup": {
+                    "$ref": "#/definitions/models.StartupMode"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user": {
+                    "type": "string"
+                },
+                "user_group": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SpecDiff": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "$ref": "#/definitions/models.ItemsDiff"
+                },
+                "services": {
+                    "$ref": "#/definitions/models.ItemsDiff"
+                }
+            }
+        },
+        "models.StartupMode": {
+            "type": "string",
+            "enum": [
+                "always",
+                "once",
+                "none"
+            ],
+            "x-enum-varnames": [
+                "StartupAlways",
+                "StartupOnce",
+                "StartupNone"
+            ]
+        },
+        "models.SuccessResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "success"
+                }
+            }
+        },
+        "models.SystemSpecification": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ComponentSpecification"
+                    }
+                },
+                "configuration": {
                     "type": "string"
+                },
+                "configurations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ComponentSpecification"
+                    }
+                },
+                "manager": {
+                    "$ref": "#/definitions/models.ManagerSpecification"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceSpecification"
+                    }
                 }
             }
         },
@@ -842,6 +2364,107 @@ const docTemplate = `{
                 }
             }
         },
+        "models.TunnelDetail": {
+            "type": "object",
+            "properties": {
+                "createdTime": {
+                    "description": "creation time",
+                    "type": "string"
+                },
+                "healthHistory": {
+                    "description": "recent health check results, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.HealthRecord"
+                    }
+                },
+                "healthy": {
+                    "description": "Works fine",
+                    "type": "string"
+                },
+                "maxRestartCount": {
+                    "description": "the process isn't auto restarted beyond this",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "service name",
+                    "type": "string"
+                },
+                "pairs": {
+                    "description": "Port pairs",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PortPair"
+                    }
+                },
+                "pid": {
+                    "description": "process ID of the tunnel",
+                    "type": "integer"
+                },
+                "reopenCount": {
+                    "description": "times the tunnel was reopened, a growing count means flapping",
+                    "type": "integer"
+                },
+                "restartCount": {
+                    "description": "times the tunnel process was auto restarted",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "tunnel status(running/stopped/error/exited)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RunStatus"
+                        }
+                    ]
+                },
+                "uptime": {
+                    "description": "time since the tunnel was opened, empty if not running",
+                    "type": "string"
+                }
+            }
+        },
+        "models.TunnelReachability": {
+            "description": "通过映射端口检查远程访问服务的隧道是否能到达本地服务",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "dial tcp 10.0.0.1:30001: i/o timeout"
+                },
+                "localPort": {
+                    "type": "integer",
+                    "example": 8080
+                },
+                "mappingPort": {
+                    "type": "integer",
+                    "example": 30001
+                },
+                "name": {
+                    "type": "string",
+                    "example": "codebase-syncer"
+                },
+                "reachable": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.TunnelRequest": {
+            "type": "object",
+            "required": [
+                "appName",
+                "localPort"
+            ],
+            "properties": {
+                "appName": {
+                    "description": "application name",
+                    "type": "string"
+                },
+                "localPort": {
+                    "description": "local port to expose",
+                    "type": "integer"
+                }
+            }
+        },
         "models.TunnelResponse": {
             "type": "object",
             "properties": {
@@ -859,6 +2482,51 @@ const docTemplate = `{
                 }
             }
         },
+        "models.UnhealthyItem": {
+            "description": "处于非健康状态的服务、组件或隧道",
+            "type": "object",
+            "properties": {
+                "healthy": {
+                    "type": "string",
+                    "example": "unavailable"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "service"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "codebase-syncer"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "service status is error"
+                }
+            }
+        },
+        "models.UpgradeRecord": {
+            "type": "object",
+            "properties": {
+                "component": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
         "models.UpgradeSpecification": {
             "type": "object",
             "properties": {
@@ -905,6 +2573,12 @@ const docTemplate = `{
                 "command": {
                     "type": "string"
                 },
+                "exitClass": {
+                    "type": "string"
+                },
+                "exitCode": {
+                    "type": "integer"
+                },
                 "instanceName": {
                     "type": "string"
                 },
@@ -914,6 +2588,14 @@ const docTemplate = `{
                 "lastExitTime": {
                     "type": "string"
                 },
+                "limits": {
+                    "description": "进程的资源限制",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ResourceLimits"
+                        }
+                    ]
+                },
                 "maxRestartCount": {
                     "type": "integer"
                 },
@@ -943,9 +2625,19 @@ const docTemplate = `{
                 "component": {
                     "$ref": "#/definitions/services.ComponentInstance"
                 },
+                "group": {
+                    "type": "string"
+                },
+                "healthReason": {
+                    "description": "服务不健康的原因，如\"port 9001 not accepting connections\"",
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
+                "oom": {
+                    "type": "boolean"
+                },
                 "pid": {
                     "type": "integer"
                 },
@@ -964,6 +2656,12 @@ const docTemplate = `{
                 "status": {
                     "type": "string"
                 },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "tunnel": {
                     "$ref": "#/definitions/services.TunnelInstance"
                 }
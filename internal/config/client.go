@@ -1,12 +1,22 @@
 package config
 
 import (
+	"context"
 	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/logger"
+	"costrict-keeper/internal/utils"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 /**
@@ -16,26 +26,61 @@ import (
  * @property {string} access_token - JWT access token for authentication
  * @property {string} machine_id - Machine unique identifier
  * @property {string} base_url - Base URL for API endpoints
+ * @property {string} readonly_token - Token which can only access read-only APIs of keeper
+ * @property {string} token_command - Shell command which mints a short-lived access token,
+ *   auth.token_command of app config takes precedence, access_token is used if neither is set
  */
 type AuthConfig struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	AccessToken string `json:"access_token"`
-	MachineID   string `json:"machine_id"`
-	BaseUrl     string `json:"base_url"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	AccessToken   string `json:"access_token"`
+	MachineID     string `json:"machine_id"`
+	BaseUrl       string `json:"base_url"`
+	ReadOnlyToken string `json:"readonly_token,omitempty"`
+	TokenCommand  string `json:"token_command,omitempty"`
 }
 
 var (
-	authConfig *AuthConfig
-	authLock   sync.RWMutex
+	authConfig  *AuthConfig
+	authLock    sync.RWMutex
+	authModTime time.Time // auth.json最近一次加载时的修改时间，用于发现令牌轮换
+	cmdToken    string    // 令牌命令最近一次获取的令牌，命令失败时在过期前继续使用
+	cmdExpiry   time.Time // cmdToken的过期时间，从JWT的exp中获取，未知则为零值
+	cmdFailedAt time.Time // 令牌命令最近一次失败的时间，避免每次请求都重新执行失败的命令
+)
+
+const (
+	// 执行令牌命令的超时时间
+	tokenCommandTimeout = 10 * time.Second
+	// 令牌命令获取的令牌在过期前这段时间内重新执行命令
+	tokenRefreshMargin = time.Minute
+	// 令牌命令失败后至少间隔这段时间再重试
+	tokenRetryInterval = 30 * time.Second
 )
 
+// ErrAuthFailed 云端拒绝了访问令牌，且重新加载令牌后依然被拒绝
+var ErrAuthFailed = errors.New("authentication failed; token may be expired")
+
+// 访问云端服务的HTTP客户端
+var cloudClient = &http.Client{
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+func getAuthPath() string {
+	return filepath.Join(env.CostrictDir, "share", "auth.json")
+}
+
 /**
  * Load client configuration from auth.json file
  * @returns {error} Returns error if loading fails, nil on success
  * @description
  * - Loads client authentication configuration from .costrict/share/auth.json
  * - File contains client ID, name, access token, machine ID and base URL
+ * - If a token command is configured, its output overrides the access token
+ * - If the token command fails, the last token it returned is kept until expiry, then the
+ *   static access token of auth.json is used
  * - Configuration is cached in memory for subsequent calls
  * @throws
  * - File not found error (os.Stat, os.Open)
@@ -47,9 +92,10 @@ var (
  * }
  */
 func LoadAuthConfig() error {
-	authPath := filepath.Join(env.CostrictDir, "share", "auth.json")
+	authPath := getAuthPath()
 
-	if _, err := os.Stat(authPath); os.IsNotExist(err) {
+	stat, err := os.Stat(authPath)
+	if os.IsNotExist(err) {
 		return fmt.Errorf("auth config file not found: %s", authPath)
 	}
 
@@ -63,14 +109,164 @@ func LoadAuthConfig() error {
 	if err := json.NewDecoder(file).Decode(&newConfig); err != nil {
 		return fmt.Errorf("failed to decode auth config: %w", err)
 	}
+	command := getTokenCommand(&newConfig)
+	token, err := runTokenCommand(command)
 
 	authLock.Lock()
 	defer authLock.Unlock()
 
+	if err != nil {
+		cmdFailedAt = time.Now()
+		if cmdToken != "" && (cmdExpiry.IsZero() || time.Now().Before(cmdExpiry)) {
+			logger.Warnf("Get access token from command failed, keep using the previous one: %v", err)
+			newConfig.AccessToken = cmdToken
+		} else {
+			logger.Warnf("Get access token from command failed, fall back to access_token of auth.json: %v", err)
+		}
+	} else if token != "" {
+		cmdToken = token
+		cmdExpiry = getTokenExpiry(token)
+		cmdFailedAt = time.Time{}
+		newConfig.AccessToken = token
+	}
 	authConfig = &newConfig
+	if stat != nil {
+		authModTime = stat.ModTime()
+	}
 	return nil
 }
 
+/**
+ * Get the token command, auth.token_command of app config takes precedence over auth.json
+ */
+func getTokenCommand(auth *AuthConfig) string {
+	if cfg := loadedApp(); cfg != nil && cfg.Auth.TokenCommand != "" {
+		return cfg.Auth.TokenCommand
+	}
+	return auth.TokenCommand
+}
+
+/**
+ * Run token command to get access token
+ * @param {string} command - Shell command which prints the access token
+ * @returns {string} Returns the trimmed output of the command, empty if no command configured
+ * @returns {error} Returns error if the command fails or outputs nothing
+ */
+func runTokenCommand(command string) (string, error) {
+	if command == "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	output, err := utils.ShellCommand(ctx, command).Output()
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return "", fmt.Errorf("command output is empty")
+	}
+	return token, nil
+}
+
+/**
+ * Get expiry time of JWT token without verifying it
+ * @returns {time.Time} Returns zero time if the token isn't JWT or has no exp claim
+ */
+func getTokenExpiry(token string) time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return time.Time{}
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
+
+/**
+ * Check whether the token from the token command is about to expire and should be refreshed
+ * @description
+ * - Tokens without known expiry are refreshed by WatchAuthConfig only
+ * - Doesn't retry within tokenRetryInterval after the command failed
+ */
+func tokenNeedsRefresh() bool {
+	authLock.RLock()
+	defer authLock.RUnlock()
+
+	if authConfig == nil || cmdToken == "" || cmdExpiry.IsZero() {
+		return false
+	}
+	if time.Since(cmdFailedAt) < tokenRetryInterval {
+		return false
+	}
+	return time.Until(cmdExpiry) < tokenRefreshMargin
+}
+
+/**
+ * Watch auth.json and token command for rotated access token
+ * @param {context.Context} ctx - Context to stop watching
+ * @description
+ * - Checks modification time of auth.json every auth.watch_interval seconds,
+ *   reloads it under authLock when changed
+ * - Re-runs token command only when the token it returned is about to expire,
+ *   or every period if the expiry of the token is unknown
+ * - Keeps the cached configuration if reloading fails
+ * @example
+ * lifecycle.Go("auth-watch", config.WatchAuthConfig)
+ */
+func WatchAuthConfig(ctx context.Context) {
+	interval := time.Duration(App().Auth.WatchInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshAuthConfig()
+		}
+	}
+}
+
+func refreshAuthConfig() {
+	stat, err := os.Stat(getAuthPath())
+	if err != nil {
+		logger.Warnf("Check auth config failed: %v", err)
+		return
+	}
+	authLock.RLock()
+	changed := !stat.ModTime().Equal(authModTime)
+	oldToken := ""
+	command := ""
+	if authConfig != nil {
+		oldToken = authConfig.AccessToken
+		command = getTokenCommand(authConfig)
+	}
+	expiryKnown := cmdToken != "" && !cmdExpiry.IsZero()
+	authLock.RUnlock()
+
+	if !changed {
+		if command == "" {
+			return
+		}
+		// 令牌过期时间已知的，缓存到快过期时才重新执行命令
+		if expiryKnown && !tokenNeedsRefresh() {
+			return
+		}
+	}
+	if err := LoadAuthConfig(); err != nil {
+		logger.Warnf("Reload auth config failed, keep using the cached one: %v", err)
+		return
+	}
+	if GetAuthConfig().AccessToken != oldToken {
+		logger.Infof("Access token has been rotated")
+	}
+}
+
 /**
  * Get client configuration instance
  * @returns {AuthConfig} Returns client configuration instance
@@ -102,6 +298,21 @@ func GetAuthConfig() AuthConfig {
 	return *authConfig
 }
 
+/**
+ * Get the client authentication configuration, tokens redacted
+ * @returns {AuthConfig} Returns a copy of the auth config
+ * @description
+ * - access_token and readonly_token are redacted, token options in token_command too
+ * - Used where the configuration is shown, e.g. the state API, which read-only clients can call
+ */
+func EffectiveAuth() AuthConfig {
+	ac := GetAuthConfig()
+	ac.AccessToken = redact(ac.AccessToken)
+	ac.ReadOnlyToken = redact(ac.ReadOnlyToken)
+	ac.TokenCommand = redactText(ac.TokenCommand)
+	return ac
+}
+
 /**
  * Check if client is configured
  * @returns {bool} Returns true if client is properly configured, false otherwise
@@ -118,10 +329,73 @@ func IsAuthConfigured() bool {
 	return auth.ID != "" && auth.AccessToken != "" && auth.MachineID != ""
 }
 
+/**
+ * Get the Authorization header carrying the access token
+ * @returns {string} Returns the header name
+ * @returns {string} Returns the header value
+ * @description
+ * - Re-runs the token command first if the token it returned is about to expire
+ */
 func GetAuthHeader() (string, string) {
+	if tokenNeedsRefresh() {
+		if err := LoadAuthConfig(); err != nil {
+			logger.Warnf("Refresh access token failed: %v", err)
+		}
+	}
 	return "Authorization", "Bearer " + GetAuthConfig().AccessToken
 }
 
+/**
+ * Send authenticated request to cloud services
+ * @param {func() (*http.Request, error)} newRequest - Builds the request, called again when retrying
+ * @returns {*http.Response} Returns response of the request, caller must close its body
+ * @returns {error} Returns error if sending fails, or ErrAuthFailed if the token is still rejected after reload
+ * @description
+ * - Sets the Authorization header with the access token unless the request has one
+ * - On 401/403, reloads the token from auth.json (and token command) and retries once
+ * @example
+ * resp, err := DoCloudRequest(func() (*http.Request, error) {
+ *     return http.NewRequest("GET", url, nil)
+ * })
+ */
+func DoCloudRequest(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	resp, err := doCloudRequest(newRequest)
+	if err != nil || !isAuthRejected(resp) {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	logger.Warnf("Cloud rejected access token (%d), reload token and retry", resp.StatusCode)
+	if err := LoadAuthConfig(); err != nil {
+		logger.Warnf("Reload auth config failed: %v", err)
+	}
+	resp, err = doCloudRequest(newRequest)
+	if err != nil {
+		return nil, err
+	}
+	if isAuthRejected(resp) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w (status code: %d)", ErrAuthFailed, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func doCloudRequest(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if req.Header.Get("Authorization") == "" {
+		authKey, authValue := GetAuthHeader()
+		req.Header.Set(authKey, authValue)
+	}
+	return cloudClient.Do(req)
+}
+
+func isAuthRejected(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
+
 /**
  * Get base URL for API requests
  * @returns {string} Returns base URL or empty string if not configured
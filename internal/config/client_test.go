@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func writeAuthConfig(t *testing.T, auth AuthConfig) {
+	t.Helper()
+	data, _ := json.Marshal(auth)
+	if err := os.WriteFile(getAuthPath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 云端只接受newToken
+func newTokenServer(t *testing.T, newToken string, requests *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer "+newToken {
+			http.Error(w, "token expired", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDoCloudRequestReloadsToken(t *testing.T) {
+	var requests []string
+	srv := newTokenServer(t, "new-token", &requests)
+	setupCostrictDir(t, srv.URL)
+	writeAuthConfig(t, AuthConfig{BaseUrl: srv.URL, AccessToken: "old-token"})
+	if err := LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// 其它程序刷新了auth.json中的令牌
+	writeAuthConfig(t, AuthConfig{BaseUrl: srv.URL, AccessToken: "new-token"})
+
+	resp, err := DoCloudRequest(func() (*http.Request, error) {
+		return http.NewRequest("GET", srv.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(requests) != 2 || requests[0] != "Bearer old-token" || requests[1] != "Bearer new-token" {
+		t.Errorf("requests = %q, want old token then new token", requests)
+	}
+}
+
+func TestDoCloudRequestAuthFailed(t *testing.T) {
+	var requests []string
+	srv := newTokenServer(t, "new-token", &requests)
+	setupCostrictDir(t, srv.URL)
+	writeAuthConfig(t, AuthConfig{BaseUrl: srv.URL, AccessToken: "old-token"})
+
+	_, err := DoCloudRequest(func() (*http.Request, error) {
+		return http.NewRequest("GET", srv.URL, nil)
+	})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("err = %v, want ErrAuthFailed", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("requests = %d, want exactly one retry", len(requests))
+	}
+}
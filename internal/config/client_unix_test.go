@@ -0,0 +1,118 @@
+//go:build linux || darwin
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+/**
+ * Configure a stub token command which prints the content of a token file
+ * @returns {func(string)} Sets the token printed by the command, empty makes the command fail
+ * @returns {func() int} Returns how many times the command ran
+ */
+func setupTokenCommand(t *testing.T) (func(string), func() int) {
+	t.Helper()
+	setupCostrictDir(t, "http://127.0.0.1:1")
+	cmdToken, cmdExpiry, cmdFailedAt = "", time.Time{}, time.Time{}
+	t.Cleanup(func() { cmdToken, cmdExpiry, cmdFailedAt = "", time.Time{}, time.Time{} })
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	countFile := filepath.Join(dir, "count")
+	command := fmt.Sprintf("echo run >> '%s'; test -s '%s' && cat '%s'", countFile, tokenFile, tokenFile)
+	writeAuthConfig(t, AuthConfig{AccessToken: "static-token", TokenCommand: command})
+
+	setToken := func(token string) {
+		if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := func() int {
+		data, _ := os.ReadFile(countFile)
+		return strings.Count(string(data), "run")
+	}
+	return setToken, runs
+}
+
+func jwtToken(t *testing.T, expiry time.Time) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": expiry.Unix()}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestTokenCommand(t *testing.T) {
+	setToken, runs := setupTokenCommand(t)
+	token := jwtToken(t, time.Now().Add(time.Hour))
+	setToken(token)
+	if err := LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if _, value := GetAuthHeader(); value != "Bearer "+token {
+		t.Errorf("auth header = %s, want token from the command", value)
+	}
+
+	// 令牌远未过期，定期检查不重新执行命令
+	for i := 0; i < 3; i++ {
+		refreshAuthConfig()
+		GetAuthHeader()
+	}
+	if n := runs(); n != 1 {
+		t.Errorf("command ran %d times, want 1 while the token is valid", n)
+	}
+
+	// 令牌快过期时重新执行命令
+	authLock.Lock()
+	cmdExpiry = time.Now().Add(tokenRefreshMargin / 2)
+	authLock.Unlock()
+	fresh := jwtToken(t, time.Now().Add(2*time.Hour))
+	setToken(fresh)
+	refreshAuthConfig()
+	if n := runs(); n != 2 {
+		t.Errorf("command ran %d times, want 2 after the token is about to expire", n)
+	}
+	if _, value := GetAuthHeader(); value != "Bearer "+fresh {
+		t.Errorf("auth header = %s, want the refreshed token", value)
+	}
+}
+
+func TestTokenCommandUnknownExpiry(t *testing.T) {
+	setToken, runs := setupTokenCommand(t)
+	setToken("opaque-token")
+	if err := LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// 不知道过期时间的令牌，每个检查周期都重新获取
+	refreshAuthConfig()
+	refreshAuthConfig()
+	if n := runs(); n != 3 {
+		t.Errorf("command ran %d times, want 3", n)
+	}
+	if _, value := GetAuthHeader(); value != "Bearer opaque-token" {
+		t.Errorf("auth header = %s, want token from the command", value)
+	}
+}
+
+func TestTokenCommandFailure(t *testing.T) {
+	_, runs := setupTokenCommand(t)
+	// 命令失败时使用auth.json中的静态令牌
+	if err := LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if runs() != 1 {
+		t.Fatalf("command ran %d times, want 1", runs())
+	}
+	if _, value := GetAuthHeader(); value != "Bearer static-token" {
+		t.Errorf("auth header = %s, want the static token", value)
+	}
+}
@@ -6,10 +6,15 @@ import (
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/utils"
 	"encoding/json"
+	"fmt"
 	"html/template"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 type MidnightRooster struct {
@@ -21,11 +26,18 @@ type MaintainInterval struct {
 	Monitoring    int `json:"monitoring,omitempty"`
 	MetricsReport int `json:"metrics_report,omitempty"`
 	LogReport     int `json:"log_report,omitempty"`
+	Maintenance   int `json:"maintenance,omitempty"` // 维护模式最长持续时间(秒)，超时自动退出，默认7200
 }
 
 type ServiceConfig struct {
-	MinPort int `json:"min_port,omitempty"`
-	MaxPort int `json:"max_port,omitempty"`
+	MinPort      int    `json:"min_port,omitempty"`
+	MaxPort      int    `json:"max_port,omitempty"`
+	KillTimeout  int    `json:"kill_timeout,omitempty"`  // 进程优雅退出的等待时间(秒)，超时后强制杀死，退出时每个服务单独计时，默认1
+	ReadyTimeout int    `json:"ready_timeout,omitempty"` // 启动服务后等待服务就绪的时间(秒)，默认10，负数表示不等待
+	Protocol     string `json:"protocol,omitempty"`      // 服务未指定protocol时使用的协议：http/https/grpc，默认http
+	MaxRestart   int    `json:"max_restart,omitempty"`   // 服务进程异常退出后自动重启的最大次数，服务未指定max_restart时使用，默认3，小于0不重启
+	StickyPort   bool   `json:"sticky_port,omitempty"`   // 未指定端口的服务优先使用上次分配的端口(记录在服务缓存文件中)，被占用时才分配新端口，便于配置防火墙规则
+	OnceTimeout  int    `json:"once_timeout,omitempty"`  // 启动时等待全部once服务运行结束的最长时间(秒)，超时后强制杀死，默认60
 }
 
 type TunnelConfig struct {
@@ -33,24 +45,119 @@ type TunnelConfig struct {
 	Command     string   `json:"command,omitempty"`
 	Args        []string `json:"args,omitempty"`
 	Timeout     int      `json:"timeout,omitempty"`
+	MaxRestart  int      `json:"max_restart,omitempty"` // 隧道进程异常退出后自动重启的最大次数，默认3，小于0不重启
+}
+
+/**
+ * Access token source settings
+ * @property {string} token_command - Shell command whose output is used as the access token,
+ *   overriding access_token and token_command of auth.json, used when tokens are issued by an external agent.
+ *   JWT tokens are cached and the command is re-run shortly before they expire
+ * @property {int} watch_interval - Interval in seconds to check auth.json and token command for
+ *   a rotated token (default: 30)
+ */
+type AuthSourceConfig struct {
+	TokenCommand  string `json:"token_command,omitempty"`
+	WatchInterval int    `json:"watch_interval,omitempty"`
+}
+
+/**
+ * Keeper API authentication settings
+ * @property {string} token - Token required in Authorization header to call mutating APIs,
+ *   authentication is disabled if empty
+ * @property {bool} socket - Also require the token on unix socket/named pipe, off by default
+ *   since only local users can access them
+ */
+type ApiConfig struct {
+	Token  string `json:"token,omitempty"`
+	Socket bool   `json:"socket,omitempty"`
+}
+
+/**
+ * Directory overrides, used when data of costrict must be split, e.g. logs on another volume.
+ * Directories may use ~ and environment variables, relative ones are relative to CostrictDir
+ * @property {string} log - Directory of log files (default: CostrictDir/logs)
+ * @property {string} cache - Directory of cache files (default: CostrictDir/cache)
+ * @property {string} package - Directory of package descriptions (default: CostrictDir/package)
+ * @property {string} run - Directory of socket and pid files (default: CostrictDir/run)
+ */
+type DirConfig struct {
+	Log     string `json:"log,omitempty"`
+	Cache   string `json:"cache,omitempty"`
+	Package string `json:"package,omitempty"`
+	Run     string `json:"run,omitempty"`
 }
 
 type ComponentConfig struct {
-	PublicKey string `json:"public_key,omitempty"`
+	PublicKey     string   `json:"public_key,omitempty"`
+	CacheTTL      int      `json:"cache_ttl,omitempty"`      // 远程包列表缓存有效期(秒)，默认300
+	VerifyRetries int      `json:"verify_retries,omitempty"` // 下载的包校验失败时重新下载的次数，默认2，小于0不重试
+	Mirrors       []string `json:"mirrors,omitempty"`        // 备用的升级服务器基地址，upgrade_url连接失败时依次尝试
+	RateLimit     int64    `json:"rate_limit,omitempty"`     // 下载包的速度上限(字节/秒)，默认0不限速，避免批量升级占满共享带宽
+	NoSetPath     bool     `json:"no_set_path,omitempty"`    // 安装程序后不把安装目录加入PATH(不修改~/.bashrc等启动脚本和Windows用户环境变量)，CI/容器等受管环境推荐开启
+	SelfUpgrade   bool     `json:"self_upgrade,omitempty"`   // 启动时同时升级管理程序自身，新版本校验通过后才激活，下次重启后生效，默认关闭
+}
+
+/**
+ * Remote configuration fetching settings
+ * @property {int} attempts - Maximum fetch attempts before giving up (default: 3)
+ * @property {int} interval - Initial backoff between attempts in seconds (default: 2)
+ * @property {int} maxInterval - Upper bound of the backoff in seconds (default: 30)
+ */
+type RemoteConfig struct {
+	Attempts    int `json:"attempts,omitempty"`
+	Interval    int `json:"interval,omitempty"`
+	MaxInterval int `json:"max_interval,omitempty"`
+}
+
+/**
+ * Service metrics ingestion settings
+ * @property {[]string} whitelist - Metric names scraped from services that are re-exposed,
+ *   metrics not in the list are dropped to keep label cardinality bounded
+ * @property {string} push_username - Basic-auth username of the pushgateway
+ * @property {string} push_password - Basic-auth password of the pushgateway
+ * @property {string} push_token - Bearer token of the pushgateway, ignored if basic-auth is configured
+ * @property {map[string]string} push_headers - Extra HTTP headers sent to the pushgateway
+ * @property {[]string} push_collectors - Names of metrics pushed to the pushgateway, all metrics if empty
+ * @property {[]float64} buckets - Upper bounds(seconds) of the request duration histogram buckets,
+ *   must be ascending, prometheus.DefBuckets if empty
+ */
+type MetricsConfig struct {
+	Whitelist      []string          `json:"whitelist,omitempty"`
+	PushUsername   string            `json:"push_username,omitempty"`
+	PushPassword   string            `json:"push_password,omitempty"`
+	PushToken      string            `json:"push_token,omitempty"`
+	PushHeaders    map[string]string `json:"push_headers,omitempty"`
+	PushCollectors []string          `json:"push_collectors,omitempty"`
+	Buckets        []float64         `json:"buckets,omitempty"`
 }
 
 /**
  * Logging configuration
  * @property {string} level - Log level (debug/info/warn/error)
- * @property {string} path - Log file path
+ * @property {string} path - Log file path, may use ~ and environment variables, relative to CostrictDir
  * @property {int64} maxSize - Maximum log file size in bytes (default: 5242880, which is 5MB)
  * @property {int} backup - Maximum number of log backup files (default: 1)
  */
 type LogConfig struct {
-	Level   string `json:"level"`
-	Path    string `json:"path"`
-	MaxSize int64  `json:"maxSize"`
-	Backup  int    `json:"backup"`
+	Level         string            `json:"level"`
+	Path          string            `json:"path"`
+	MaxSize       int64             `json:"maxSize"`
+	Backup        int               `json:"backup"`
+	UploadHeaders map[string]string `json:"upload_headers,omitempty"` // 上传日志时附加的HTTP头，如租户标识
+}
+
+/**
+ * Critical event notification settings
+ * @property {string} webhook - URL which critical events are POSTed to as JSON, notification
+ *   is disabled if empty
+ * @property {map[string]string} headers - Extra HTTP headers sent with the webhook request
+ * @property {int} timeout - Timeout of the webhook request in seconds (default: 5)
+ */
+type NotifyConfig struct {
+	Webhook string            `json:"webhook,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout int               `json:"timeout,omitempty"`
 }
 
 type CloudConfig struct {
@@ -70,11 +177,18 @@ type AppConfig struct {
 	Component ComponentConfig  `json:"component,omitempty"`
 	Cloud     CloudConfig      `json:"cloud,omitempty"`
 	Log       LogConfig        `json:"log,omitempty"`
+	Remote    RemoteConfig     `json:"remote,omitempty"`
+	Metrics   MetricsConfig    `json:"metrics,omitempty"`
+	Auth      AuthSourceConfig `json:"auth,omitempty"`
+	Api       ApiConfig        `json:"api,omitempty"`
+	Dirs      DirConfig        `json:"dirs,omitempty"`
+	Notify    NotifyConfig     `json:"notify,omitempty"`
 }
 
 var (
 	appConfig   *AppConfig
 	cloudConfig *CloudConfig
+	configLock  sync.RWMutex //保护appConfig和cloudConfig，/reload替换配置时服务的后台协程仍在读取
 )
 
 /**
@@ -93,10 +207,29 @@ func (cfg *AppConfig) loadConfig(configPath string) error {
 	if err := json.NewDecoder(file).Decode(&newConfig); err != nil {
 		return err
 	}
+	if err := validateBuckets(newConfig.Metrics.Buckets); err != nil {
+		return fmt.Errorf("invalid metrics.buckets: %v", err)
+	}
 	*cfg = newConfig
 	return nil
 }
 
+/**
+ * Check histogram buckets
+ * @param {[]float64} buckets - Upper bounds of the buckets
+ * @returns {error} Returns error if the bounds aren't strictly ascending
+ * @description
+ * - prometheus panics on unsorted buckets, so they are rejected when loading the config
+ */
+func validateBuckets(buckets []float64) error {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("bucket %v isn't greater than the previous bucket %v", buckets[i], buckets[i-1])
+		}
+	}
+	return nil
+}
+
 func (cfg *AppConfig) correctConfig() {
 	if cfg.Listen == "" {
 		cfg.Listen = "localhost:8999"
@@ -116,6 +249,9 @@ func (cfg *AppConfig) correctConfig() {
 	if cfg.Interval.LogReport == 0 {
 		cfg.Interval.LogReport = 600
 	}
+	if cfg.Interval.Maintenance == 0 {
+		cfg.Interval.Maintenance = 7200
+	}
 	// LogReportInterval 默认为 0，表示不上报日志
 	if cfg.Cloud.PushgatewayUrl == "" {
 		cfg.Cloud.PushgatewayUrl = "{{.BaseUrl}}/pushgateway"
@@ -138,6 +274,25 @@ func (cfg *AppConfig) correctConfig() {
 	if cfg.Service.MaxPort == 0 {
 		cfg.Service.MaxPort = cfg.Service.MinPort + 1000
 	}
+	if cfg.Service.KillTimeout == 0 {
+		cfg.Service.KillTimeout = 1
+	}
+	if cfg.Service.OnceTimeout == 0 {
+		// once服务在就绪通知之前运行，需小于systemd默认的启动超时(TimeoutStartSec=90s)
+		cfg.Service.OnceTimeout = 60
+	}
+	if cfg.Service.ReadyTimeout == 0 {
+		cfg.Service.ReadyTimeout = 10
+	}
+	if cfg.Service.Protocol == "" {
+		cfg.Service.Protocol = "http"
+	}
+	if cfg.Service.MaxRestart == 0 {
+		cfg.Service.MaxRestart = 3
+	}
+	if cfg.Tunnel.MaxRestart == 0 {
+		cfg.Tunnel.MaxRestart = 3
+	}
 	if cfg.Tunnel.ProcessName == "" {
 		cfg.Tunnel.ProcessName = "cotun"
 	}
@@ -163,13 +318,38 @@ func (cfg *AppConfig) correctConfig() {
 	}
 	if cfg.Log.Path == "" {
 		cfg.Log.Path = "console" // 默认输出到控制台
-	}
+	} else if cfg.Log.Path != "console" {
+		cfg.Log.Path = utils.ExpandPath(cfg.Log.Path)
+	}
+	// 目录可以使用~、环境变量，相对路径相对于CostrictDir
+	cfg.Dirs.Log = utils.ExpandPath(cfg.Dirs.Log)
+	cfg.Dirs.Cache = utils.ExpandPath(cfg.Dirs.Cache)
+	cfg.Dirs.Package = utils.ExpandPath(cfg.Dirs.Package)
+	cfg.Dirs.Run = utils.ExpandPath(cfg.Dirs.Run)
 	if cfg.Log.MaxSize == 0 {
 		cfg.Log.MaxSize = 1 * 1024 * 1024 // 默认1M
 	}
 	if cfg.Log.Backup == 0 {
 		cfg.Log.Backup = 1
 	}
+	if cfg.Component.CacheTTL == 0 {
+		cfg.Component.CacheTTL = 300
+	}
+	if cfg.Remote.Attempts == 0 {
+		cfg.Remote.Attempts = 3
+	}
+	if cfg.Remote.Interval == 0 {
+		cfg.Remote.Interval = 2
+	}
+	if cfg.Remote.MaxInterval == 0 {
+		cfg.Remote.MaxInterval = 30
+	}
+	if cfg.Auth.WatchInterval == 0 {
+		cfg.Auth.WatchInterval = 30
+	}
+	if cfg.Notify.Timeout == 0 {
+		cfg.Notify.Timeout = 5
+	}
 }
 
 func expandUrl(baseUrl string, pattern string) (string, error) {
@@ -225,14 +405,25 @@ func LoadConfig(ignoreError bool) error {
 	var cfg AppConfig
 	configPath := filepath.Join(env.CostrictDir, "config", "costrict.json")
 	if err := cfg.loadConfig(configPath); err != nil {
-		if !ignoreError {
+		// 配置文件不可用时，优先使用最近一次成功获取的配置，而不是默认配置
+		if goodErr := cfg.loadConfig(getKnownGoodPath()); goodErr == nil {
+			logger.Warnf("Load '%s' failed: %v, fall back to the last known-good config", configPath, err)
+		} else if !ignoreError {
 			return err
 		}
 	}
 	cfg.correctConfig()
 	utils.SetAvailablePortRange(cfg.Service.MinPort, cfg.Service.MaxPort)
-	cloudConfig = expandCloudConfig(&cfg.Cloud)
+	utils.SetKillGracePeriod(time.Duration(cfg.Service.KillTimeout) * time.Second)
+	env.LogDir = cfg.Dirs.Log
+	env.CacheDir = cfg.Dirs.Cache
+	env.PackageDir = cfg.Dirs.Package
+	env.RunDir = cfg.Dirs.Run
+	cloud := expandCloudConfig(&cfg.Cloud)
+	configLock.Lock()
+	cloudConfig = cloud
 	appConfig = &cfg
+	configLock.Unlock()
 	return nil
 }
 
@@ -254,17 +445,92 @@ func ReloadConfig(ignoreError bool) error {
  * @returns {AppConfig} Returns configuration instance
  */
 func App() *AppConfig {
-	if appConfig == nil {
+	cfg := loadedApp()
+	if cfg == nil {
 		log.Fatal("Must run config.LoadConfig first")
 		return nil
 	}
+	return cfg
+}
+
+// 取得已加载的配置，未加载时返回nil；配置加载后不再修改，只会整体替换
+func loadedApp() *AppConfig {
+	configLock.RLock()
+	defer configLock.RUnlock()
 	return appConfig
 }
 
+/**
+ * Get effective configuration
+ * @returns {AppConfig} Returns a copy of the configuration in use, with defaults applied,
+ *   cloud URL templates expanded and secrets redacted
+ * @description
+ * - Differs from the raw config file, used to debug which settings keeper really uses
+ */
+func Effective() AppConfig {
+	cfg := *App()
+	if cloud := Cloud(); cloud != nil {
+		cfg.Cloud = *cloud
+	}
+	return redactConfig(cfg)
+}
+
+// 脱敏配置中的凭据，返回副本
+func redactConfig(cfg AppConfig) AppConfig {
+	cfg.Metrics.PushPassword = redact(cfg.Metrics.PushPassword)
+	cfg.Metrics.PushToken = redact(cfg.Metrics.PushToken)
+	cfg.Metrics.PushHeaders = redactHeaders(cfg.Metrics.PushHeaders)
+	cfg.Log.UploadHeaders = redactHeaders(cfg.Log.UploadHeaders)
+	cfg.Notify.Webhook = redactURL(cfg.Notify.Webhook)
+	cfg.Notify.Headers = redactHeaders(cfg.Notify.Headers)
+	cfg.Api.Token = redact(cfg.Api.Token)
+	return cfg
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "******"
+}
+
+// 脱敏URL中的凭据，Slack/钉钉/飞书等webhook的token在路径或查询参数中，只保留scheme和host
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redact(rawURL)
+	}
+	result := u.Scheme + "://" + u.Host
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		result += "/" + redact("x")
+	}
+	return result
+}
+
+// 脱敏HTTP头中可能包含凭据的值
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return headers
+	}
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		lower := strings.ToLower(k)
+		if strings.Contains(lower, "auth") || strings.Contains(lower, "token") ||
+			strings.Contains(lower, "secret") || strings.Contains(lower, "key") {
+			v = redact(v)
+		}
+		result[k] = v
+	}
+	return result
+}
+
 func Cloud() *CloudConfig {
-	if cloudConfig == nil {
+	configLock.RLock()
+	cloud := cloudConfig
+	configLock.RUnlock()
+	if cloud == nil {
 		log.Fatal("Must run config.LoadConfig first")
 		return nil
 	}
-	return cloudConfig
+	return cloud
 }
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"costrict-keeper/internal/env"
+)
+
+func writeAppConfig(t *testing.T, content string) {
+	t.Helper()
+	configPath := filepath.Join(env.CostrictDir, "config", "costrict.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	setupCostrictDir(t, "http://cloud.example.com")
+	writeAppConfig(t, `{
+		"api": {"token": "api-secret"},
+		"metrics": {"push_password": "push-secret", "push_headers": {"Authorization": "Bearer x", "X-Tenant": "t1"}},
+		"cloud": {"upgrade_url": "{{.BaseUrl}}/custom/upgrade"},
+		"notify": {"webhook": "https://hooks.example.com/services/T0/B0/hook-secret?access_token=query-secret"}
+	}`)
+	if err := LoadConfig(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 和命令行输出一样经过JSON编码，按JSON字段比较
+	data, err := json.Marshal(Effective())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg AppConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Service.MinPort != 9000 || cfg.Service.MaxPort != 10000 {
+		t.Errorf("port range = %d-%d, want default 9000-10000", cfg.Service.MinPort, cfg.Service.MaxPort)
+	}
+	if cfg.Service.KillTimeout != 1 || cfg.Service.OnceTimeout != 60 || cfg.Service.Protocol != "http" {
+		t.Errorf("service defaults aren't applied: %+v", cfg.Service)
+	}
+	if want := "http://cloud.example.com/tunnel-manager/api/v1"; cfg.Cloud.TunManagerUrl != want {
+		t.Errorf("tunman_url = %q, want %q", cfg.Cloud.TunManagerUrl, want)
+	}
+	if want := "http://cloud.example.com/custom/upgrade"; cfg.Cloud.UpgradeUrl != want {
+		t.Errorf("upgrade_url = %q, want %q", cfg.Cloud.UpgradeUrl, want)
+	}
+	if cfg.Api.Token != "******" || cfg.Metrics.PushPassword != "******" {
+		t.Errorf("secrets aren't redacted: token %q, password %q", cfg.Api.Token, cfg.Metrics.PushPassword)
+	}
+	if cfg.Metrics.PushHeaders["Authorization"] != "******" || cfg.Metrics.PushHeaders["X-Tenant"] != "t1" {
+		t.Errorf("push headers = %v", cfg.Metrics.PushHeaders)
+	}
+	if want := "https://hooks.example.com/******"; cfg.Notify.Webhook != want {
+		t.Errorf("notify.webhook = %q, want %q", cfg.Notify.Webhook, want)
+	}
+	// 生效配置是副本，脱敏不影响正在使用的配置
+	if App().Api.Token != "api-secret" {
+		t.Errorf("redaction changed the config in use: %q", App().Api.Token)
+	}
+}
+
+func TestDiffConfigRedactsWebhook(t *testing.T) {
+	var oldCfg, newCfg AppConfig
+	oldCfg.Notify.Webhook = "https://oapi.example.com/robot/send?access_token=old-secret"
+	newCfg.Notify.Webhook = "https://oapi.example.com/robot/send?access_token=new-secret"
+
+	changes := diffConfig(oldCfg, newCfg)
+	if len(changes) != 1 || changes[0].Field != "notify.webhook" {
+		t.Fatalf("changes = %+v, want notify.webhook only", changes)
+	}
+	for _, v := range []interface{}{changes[0].Old, changes[0].New} {
+		if v != "https://oapi.example.com/******" {
+			t.Errorf("webhook isn't redacted in the diff: %v", v)
+		}
+	}
+}
+
+func TestLoadConfigDirOverrides(t *testing.T) {
+	setupCostrictDir(t, "http://127.0.0.1:1")
+	logDir := t.TempDir()
+	data, _ := json.Marshal(map[string]any{"dirs": map[string]string{"log": logDir, "cache": "mycache"}})
+	writeAppConfig(t, string(data))
+	if err := LoadConfig(false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.LogDir, env.CacheDir = "", "" })
+	if got := env.GetLogDir(); got != logDir {
+		t.Errorf("log dir = %q, want %q", got, logDir)
+	}
+	// 相对路径相对于CostrictDir
+	if got, want := env.GetCacheDir(), filepath.Join(env.CostrictDir, "mycache"); got != want {
+		t.Errorf("cache dir = %q, want %q", got, want)
+	}
+	if got, want := env.GetRunDir(), filepath.Join(env.CostrictDir, "run"); got != want {
+		t.Errorf("run dir = %q, want default %q", got, want)
+	}
+}
@@ -3,8 +3,15 @@ package config
 import (
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/logger"
+	"costrict-keeper/internal/models"
 	"costrict-keeper/internal/utils"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
 )
 
 /**
@@ -28,8 +35,9 @@ import (
  */
 func fetchRemoteConfig(pkgName string) error {
 	u := utils.NewUpgrader(pkgName, utils.UpgradeConfig{
-		BaseUrl: fmt.Sprintf("%s/costrict", GetBaseURL()),
-		BaseDir: env.CostrictDir,
+		BaseUrl:    fmt.Sprintf("%s/costrict", GetBaseURL()),
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
 	})
 
 	pkg, upgraded, err := u.UpgradePackage(nil)
@@ -45,13 +53,170 @@ func fetchRemoteConfig(pkgName string) error {
 	return nil
 }
 
+/**
+ * 带重试的远程配置获取
+ * @param {string} pkgName - 要获取的配置包名称
+ * @returns {error} 所有尝试均失败时返回最后一次的错误，成功返回nil
+ * @description
+ * - 按配置的次数(remote.attempts)重试fetchRemoteConfig
+ * - 两次尝试之间采用指数退避，初始间隔为remote.interval秒，上限为remote.max_interval秒
+ * - 用于启动阶段规避短暂的网络故障
+ * @example
+ * if err := fetchRemoteConfigWithRetry("system"); err != nil {
+ *     logger.Errorf("Fetch failed: %v", err)
+ * }
+ */
+func fetchRemoteConfigWithRetry(pkgName string) error {
+	remote := RemoteConfig{Attempts: 3, Interval: 2, MaxInterval: 30}
+	if cfg := loadedApp(); cfg != nil {
+		remote = cfg.Remote
+	}
+	interval := time.Duration(remote.Interval) * time.Second
+	maxInterval := time.Duration(remote.MaxInterval) * time.Second
+	// 至少尝试一次，避免配置错误时静默跳过远程配置的获取
+	attempts := remote.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fetchRemoteConfig(pkgName); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		logger.Warnf("Fetch '%s' failed (attempt %d/%d), retry in %v", pkgName, attempt, attempts, interval)
+		time.Sleep(interval)
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+	return err
+}
+
+/**
+ * 最近一次成功获取的配置文件的备份路径
+ */
+func getKnownGoodPath() string {
+	return filepath.Join(env.GetCacheDir(), "config", "costrict.json")
+}
+
+/**
+ * 备份当前配置文件，作为最近一次可用的配置
+ * @returns {error} 备份失败返回错误，成功返回nil
+ * @description
+ * - 仅当配置文件能够被正确解析时才进行备份，避免把损坏的配置当作可用配置
+ * - 备份文件保存在.costrict/cache/config/costrict.json
+ */
+func saveKnownGoodConfig() error {
+	configPath := filepath.Join(env.CostrictDir, "config", "costrict.json")
+	var cfg AppConfig
+	if err := cfg.loadConfig(configPath); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	goodPath := getKnownGoodPath()
+	if err := os.MkdirAll(filepath.Dir(goodPath), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(goodPath, data, 0644)
+}
+
+/**
+ * Fetch app configuration from the cloud and apply it without restarting
+ * @returns {models.ConfigSyncResult} Returns whether the configuration changed and the changed settings
+ * @returns {error} Returns error if fetching or loading fails, the configuration in use is kept then
+ * @description
+ * - Settings are compared with their real values, but reported with secrets redacted,
+ *   so a rotated token shows up as changed without leaking
+ * - Components which cached settings at startup keep using them until keeper restarts
+ */
+func SyncConfig() (models.ConfigSyncResult, error) {
+	old := *App()
+	if err := fetchRemoteConfig("costrict-config"); err != nil {
+		return models.ConfigSyncResult{}, err
+	}
+	if err := LoadConfig(false); err != nil {
+		return models.ConfigSyncResult{}, err
+	}
+	if err := saveKnownGoodConfig(); err != nil {
+		logger.Warnf("Save known-good config failed: %v", err)
+	}
+	changes := diffConfig(old, *App())
+	for _, c := range changes {
+		logger.Infof("Config '%s' changed: %v -> %v", c.Field, c.Old, c.New)
+	}
+	return models.ConfigSyncResult{Changed: len(changes) > 0, Changes: changes}, nil
+}
+
+// 比较两份配置，返回值不同的配置项，报告的值已经脱敏
+func diffConfig(oldCfg, newCfg AppConfig) []models.ConfigChange {
+	oldRaw, newRaw := flattenConfig(oldCfg), flattenConfig(newCfg)
+	oldShow, newShow := flattenConfig(redactConfig(oldCfg)), flattenConfig(redactConfig(newCfg))
+
+	fields := make(map[string]bool)
+	for k := range oldRaw {
+		fields[k] = true
+	}
+	for k := range newRaw {
+		fields[k] = true
+	}
+	var changes []models.ConfigChange
+	for field := range fields {
+		if reflect.DeepEqual(oldRaw[field], newRaw[field]) {
+			continue
+		}
+		changes = append(changes, models.ConfigChange{Field: field, Old: oldShow[field], New: newShow[field]})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// 把配置展开为"json路径->值"的形式，数组作为整体比较，空对象不产生配置项
+func flattenConfig(cfg AppConfig) map[string]interface{} {
+	result := make(map[string]interface{})
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return result
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return result
+	}
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if m, ok := value.(map[string]interface{}); ok {
+			for k, v := range m {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, v)
+			}
+			return
+		}
+		result[prefix] = value
+	}
+	walk("", root)
+	return result
+}
+
 func UpdateRemoteConfigs() error {
 	var lasterr error
-	if err := fetchRemoteConfig("costrict-config"); err != nil {
+	if err := fetchRemoteConfigWithRetry("costrict-config"); err != nil {
 		logger.Errorf("Fetch failed: %v", err)
 		lasterr = err
+	} else if err := saveKnownGoodConfig(); err != nil {
+		logger.Warnf("Save known-good config failed: %v", err)
 	}
-	if err := fetchRemoteConfig("system"); err != nil {
+	if err := fetchRemoteConfigWithRetry("system"); err != nil {
 		logger.Errorf("Fetch failed: %v", err)
 		lasterr = err
 	}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/utils"
+)
+
+// 使用临时目录作为.costrict目录，并让云端地址指向baseUrl
+func setupCostrictDir(t *testing.T, baseUrl string) {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	authPath := getAuthPath()
+	if err := os.MkdirAll(filepath.Dir(authPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(AuthConfig{BaseUrl: baseUrl, MachineID: "test-machine"})
+	if err := os.WriteFile(authPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	authConfig = nil
+	appConfig = nil
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	t.Cleanup(func() {
+		authConfig = nil
+		appConfig = nil
+	})
+}
+
+func TestFetchRemoteConfigWithRetry(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		// 云端最新版本为0.0.0，本地无需升级，视为获取成功
+		json.NewEncoder(w).Encode(utils.PlatformInfo{PackageName: "system"})
+	}))
+	defer srv.Close()
+	setupCostrictDir(t, srv.URL)
+	appConfig = &AppConfig{Remote: RemoteConfig{Attempts: 3}}
+
+	if err := fetchRemoteConfigWithRetry("system"); err != nil {
+		t.Fatalf("fetch should succeed on the third attempt: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestFetchRemoteConfigWithRetryAttemptsOnce(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	setupCostrictDir(t, srv.URL)
+	appConfig = &AppConfig{Remote: RemoteConfig{Attempts: -1}}
+
+	if err := fetchRemoteConfigWithRetry("system"); err == nil {
+		t.Fatal("fetch should fail")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestLoadConfigFallsBackToKnownGood(t *testing.T) {
+	setupCostrictDir(t, "http://127.0.0.1:1")
+	configPath := filepath.Join(env.CostrictDir, "config", "costrict.json")
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	if err := os.WriteFile(configPath, []byte(`{"remote":{"attempts":7}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveKnownGoodConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{broken`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadConfig(false); err != nil {
+		t.Fatalf("LoadConfig should fall back to the known-good config: %v", err)
+	}
+	if App().Remote.Attempts != 7 {
+		t.Errorf("attempts = %d, want 7 from the known-good config", App().Remote.Attempts)
+	}
+}
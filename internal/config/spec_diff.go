@@ -0,0 +1,108 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"costrict-keeper/internal/logger"
+	"costrict-keeper/internal/models"
+)
+
+/**
+ * Compute structured diff between two system specifications
+ * @param {models.SystemSpecification} oldSpec - Specification in use, nil is regarded as empty
+ * @param {models.SystemSpecification} newSpec - Newly loaded specification
+ * @returns {models.SpecDiff} Returns added/removed/modified services and components
+ * @description
+ * - Services and components are matched by name
+ * - Modified items list the json names of changed fields
+ */
+func DiffSpec(oldSpec, newSpec *models.SystemSpecification) models.SpecDiff {
+	if oldSpec == nil {
+		oldSpec = &models.SystemSpecification{}
+	}
+	if newSpec == nil {
+		newSpec = &models.SystemSpecification{}
+	}
+	diff := models.SpecDiff{}
+	diff.Services = diffItems(oldSpec.Services, newSpec.Services, func(s models.ServiceSpecification) string {
+		return s.Name
+	})
+	diff.Components = diffItems(oldSpec.Components, newSpec.Components, func(c models.ComponentSpecification) string {
+		return c.Name
+	})
+	return diff
+}
+
+func diffItems[T any](olds, news []T, nameOf func(T) string) models.ItemsDiff {
+	diff := models.ItemsDiff{}
+	oldItems := make(map[string]T)
+	for _, item := range olds {
+		oldItems[nameOf(item)] = item
+	}
+	newNames := make(map[string]bool)
+	for _, item := range news {
+		name := nameOf(item)
+		newNames[name] = true
+		old, ok := oldItems[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		if fields := changedFields(old, item); len(fields) > 0 {
+			diff.Modified = append(diff.Modified, models.ItemChange{Name: name, Fields: fields})
+		}
+	}
+	for _, item := range olds {
+		if name := nameOf(item); !newNames[name] {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	return diff
+}
+
+// 比较两个同类型结构体，返回值不同的字段的json名
+func changedFields(a, b interface{}) []string {
+	va := reflect.ValueOf(a)
+	vb := reflect.ValueOf(b)
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		if reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			continue
+		}
+		field := va.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+/**
+ * Reload system specification from system-spec.json
+ * @returns {models.SpecDiff} Returns changes between the previous and the reloaded specification
+ * @returns {error} Returns error if the file can't be loaded, the previous specification is kept then
+ * @description
+ * - Changes are logged so operators can anticipate which services will be restarted
+ * - Running services keep their specification until they are restarted
+ */
+func ReloadSpec() (models.SpecDiff, error) {
+	spec, err := loadLocalSpec()
+	if err != nil {
+		return models.SpecDiff{}, err
+	}
+	systemLock.Lock()
+	diff := DiffSpec(system, spec)
+	system = spec
+	systemLock.Unlock()
+	if diff.IsEmpty() {
+		logger.Info("System specification reloaded, nothing changed")
+	} else {
+		data, _ := json.Marshal(diff)
+		logger.Infof("System specification reloaded, changes: %s", string(data))
+	}
+	return diff, nil
+}
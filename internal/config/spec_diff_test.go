@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+)
+
+func writeSpec(t *testing.T, spec models.SystemSpecification) {
+	t.Helper()
+	data, _ := json.Marshal(spec)
+	fname := filepath.Join(env.CostrictDir, "share", "system-spec.json")
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffSpec(t *testing.T) {
+	oldSpec := &models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			{Name: "codebase-syncer", Startup: models.StartupAlways, Command: "syncer", Port: 9001},
+			{Name: "legacy", Startup: models.StartupAlways, Command: "legacy"},
+		},
+		Components: []models.ComponentSpecification{{Name: "syncer", Version: "1.0.0"}},
+	}
+	newSpec := &models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			{Name: "codebase-syncer", Startup: models.StartupAlways, Command: "syncer", Args: []string{"-v"}, Port: 9002},
+			{Name: "code-indexer", Startup: models.StartupAlways, Command: "indexer"},
+		},
+		Components: []models.ComponentSpecification{{Name: "syncer", Version: "1.0.0"}},
+	}
+
+	diff := DiffSpec(oldSpec, newSpec)
+	want := models.ItemsDiff{
+		Added:    []string{"code-indexer"},
+		Removed:  []string{"legacy"},
+		Modified: []models.ItemChange{{Name: "codebase-syncer", Fields: []string{"args", "port"}}},
+	}
+	if !reflect.DeepEqual(diff.Services, want) {
+		t.Errorf("services diff = %+v, want %+v", diff.Services, want)
+	}
+	if !reflect.DeepEqual(diff.Components, models.ItemsDiff{}) {
+		t.Errorf("components diff = %+v, want empty", diff.Components)
+	}
+	if !DiffSpec(newSpec, newSpec).IsEmpty() {
+		t.Error("diff of identical specs isn't empty")
+	}
+}
+
+func TestReloadSpecConcurrent(t *testing.T) {
+	setupCostrictDir(t, "http://127.0.0.1:1")
+	systemLock.Lock()
+	system = nil
+	systemLock.Unlock()
+	spec := models.SystemSpecification{
+		Services: []models.ServiceSpecification{{Name: "codebase-syncer", Startup: models.StartupAlways}},
+	}
+	writeSpec(t, spec)
+	if err := LoadSpec(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 监控等协程读取规格的同时重新加载，以 -race 运行时检查数据竞争
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					if len(Spec().Services) != 1 {
+						t.Error("reader saw a partially loaded specification")
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := ReloadSpec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	spec.Services = append(spec.Services, models.ServiceSpecification{Name: "code-indexer", Startup: models.StartupOnce})
+	writeSpec(t, spec)
+	diff, err := ReloadSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff.Services.Added, []string{"code-indexer"}) {
+		t.Errorf("added services = %v, want [code-indexer]", diff.Services.Added)
+	}
+	if len(Spec().Services) != 2 {
+		t.Errorf("%d services after reload, want 2", len(Spec().Services))
+	}
+}
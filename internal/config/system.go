@@ -4,11 +4,15 @@ import (
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/utils"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 )
 
 func loadLocalSpec() (*models.SystemSpecification, error) {
@@ -22,28 +26,203 @@ func loadLocalSpec() (*models.SystemSpecification, error) {
 	if err := json.Unmarshal(bytes, &spec); err != nil {
 		return nil, fmt.Errorf("unmarshal 'system-spec.json' failed: %v", err)
 	}
+	if err := validateStartup(&spec); err != nil {
+		return nil, fmt.Errorf("invalid 'system-spec.json': %v", err)
+	}
+	if err := validateFailureAction(&spec); err != nil {
+		return nil, fmt.Errorf("invalid 'system-spec.json': %v", err)
+	}
+	if err := validateHealthCheck(&spec); err != nil {
+		return nil, fmt.Errorf("invalid 'system-spec.json': %v", err)
+	}
 	return &spec, nil
 }
 
-var system *models.SystemSpecification
+/**
+ * Check startup modes of all services in the specification
+ * @param {models.SystemSpecification} spec - Specification to check
+ * @returns {error} Returns error listing services with unknown startup mode
+ * @description
+ * - An unknown mode (such as the typo "allways") would silently leave the service
+ *   not started, so the whole specification is rejected instead
+ */
+func validateStartup(spec *models.SystemSpecification) error {
+	var invalid []string
+	check := func(svc *models.ServiceSpecification) {
+		if svc.Startup.IsValid() {
+			return
+		}
+		logger.Errorf("Service [%s] has unknown startup mode '%s', must be always/once/none", svc.Name, svc.Startup)
+		invalid = append(invalid, fmt.Sprintf("%s(%s)", svc.Name, svc.Startup))
+	}
+	check(&spec.Manager.Service)
+	for i := range spec.Services {
+		check(&spec.Services[i])
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("unknown startup mode of services: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+/**
+ * Check failure actions of all services in the specification
+ * @param {models.SystemSpecification} spec - Specification to check
+ * @returns {error} Returns error listing services with unknown failure action
+ * @description
+ * - An unknown action (such as the typo "alret") would silently fall back to restart,
+ *   which may restart a stateful service unexpectedly, so the whole specification is rejected
+ */
+func validateFailureAction(spec *models.SystemSpecification) error {
+	var invalid []string
+	check := func(svc *models.ServiceSpecification) {
+		switch svc.FailureAction {
+		case "", models.FailureRestart, models.FailureAlert, models.FailureNone:
+			return
+		}
+		logger.Errorf("Service [%s] has unknown failure action '%s', must be restart/alert/none", svc.Name, svc.FailureAction)
+		invalid = append(invalid, fmt.Sprintf("%s(%s)", svc.Name, svc.FailureAction))
+	}
+	check(&spec.Manager.Service)
+	for i := range spec.Services {
+		check(&spec.Services[i])
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("unknown failure action of services: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+/**
+ * Check health_check.expect of all services in the specification
+ * @param {models.SystemSpecification} spec - Specification to check
+ * @returns {error} Returns error listing services with invalid expect
+ * @description
+ * - An invalid regular expression would make every probe fail, so the service is
+ *   regarded unhealthy forever and restarted again and again
+ * - The compiled expressions are cached for probing
+ */
+func validateHealthCheck(spec *models.SystemSpecification) error {
+	var invalid []string
+	check := func(svc *models.ServiceSpecification) {
+		if svc.HealthCheck.Expect == "" {
+			return
+		}
+		if _, err := utils.CompileExpect(svc.HealthCheck.Expect); err != nil {
+			logger.Errorf("Service [%s] has invalid health_check.expect: %v", svc.Name, err)
+			invalid = append(invalid, svc.Name)
+		}
+	}
+	check(&spec.Manager.Service)
+	for i := range spec.Services {
+		check(&spec.Services[i])
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid health_check.expect of services: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+var (
+	system     *models.SystemSpecification
+	systemLock sync.RWMutex // 保护system指针，重新加载时整体替换，已取得的规格不会被修改
+)
 
 func LoadSpec() error {
+	systemLock.Lock()
+	defer systemLock.Unlock()
 	if system != nil {
 		return nil
 	}
-	var err error
-	system, err = loadLocalSpec()
+	spec, err := loadLocalSpec()
 	if err != nil {
 		logger.Errorf("Load failed: %v", err)
 		return err
 	}
+	system = spec
 	return nil
 }
 
 func Spec() *models.SystemSpecification {
-	if system == nil {
+	systemLock.RLock()
+	spec := system
+	systemLock.RUnlock()
+	if spec == nil {
 		log.Fatalln("Must run config.LoadSpec first")
 		return nil
 	}
-	return system
+	return spec
+}
+
+// 命令行中可能包含凭据的选项，如 --token=xxx、--api-key xxx、password: xxx
+var secretArgPattern = regexp.MustCompile(`(?i)^(-{0,2}[\w.-]*(token|password|passwd|secret|key)[\w.-]*)(=|:)(.+)$`)
+var secretFlagPattern = regexp.MustCompile(`(?i)^-{1,2}[\w.-]*(token|password|passwd|secret|key)[\w.-]*$`)
+var secretTextPattern = regexp.MustCompile(`(?i)(-{0,2}[\w.-]*(token|password|passwd|secret|key)[\w.-]*(=|:\s*|\s+))([^\s'"-][^\s'"]*)`)
+
+/**
+ * Get the system specification the server is running with, secrets redacted
+ * @returns {models.SystemSpecification} Returns a copy of the loaded specification
+ * @description
+ * - Values of token/password/secret/key options in commands, args and hooks are redacted
+ */
+func EffectiveSpec() models.SystemSpecification {
+	return redactSpec(Spec())
+}
+
+/**
+ * Read system-spec.json from disk, secrets redacted
+ * @returns {models.SystemSpecification} Returns the specification in the file
+ * @returns {error} Returns error if the file can't be loaded
+ * @description
+ * - May differ from EffectiveSpec if the file was changed but not reloaded yet
+ */
+func RawSpec() (models.SystemSpecification, error) {
+	spec, err := loadLocalSpec()
+	if err != nil {
+		return models.SystemSpecification{}, err
+	}
+	return redactSpec(spec), nil
+}
+
+// 脱敏系统规格中的凭据，返回副本
+func redactSpec(spec *models.SystemSpecification) models.SystemSpecification {
+	result := *spec
+	result.Manager.Service = redactService(spec.Manager.Service)
+	result.Services = make([]models.ServiceSpecification, len(spec.Services))
+	for i, svc := range spec.Services {
+		result.Services[i] = redactService(svc)
+	}
+	result.Components = append([]models.ComponentSpecification(nil), spec.Components...)
+	result.Configurations = append([]models.ComponentSpecification(nil), spec.Configurations...)
+	for i := range result.Components {
+		result.Components[i].PostInstall = redactText(result.Components[i].PostInstall)
+	}
+	for i := range result.Configurations {
+		result.Configurations[i].PostInstall = redactText(result.Configurations[i].PostInstall)
+	}
+	return result
+}
+
+func redactService(svc models.ServiceSpecification) models.ServiceSpecification {
+	svc.Command = redactText(svc.Command)
+	svc.PreStart = redactText(svc.PreStart)
+	svc.PostStop = redactText(svc.PostStop)
+	if len(svc.Args) > 0 {
+		args := make([]string, len(svc.Args))
+		for i, arg := range svc.Args {
+			switch {
+			case secretArgPattern.MatchString(arg):
+				arg = secretArgPattern.ReplaceAllString(arg, "${1}${3}"+redact("x"))
+			case i > 0 && secretFlagPattern.MatchString(svc.Args[i-1]):
+				arg = redact(arg)
+			}
+			args[i] = arg
+		}
+		svc.Args = args
+	}
+	return svc
+}
+
+func redactText(text string) string {
+	return secretTextPattern.ReplaceAllString(text, "${1}"+redact("x"))
 }
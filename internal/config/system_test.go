@@ -0,0 +1,161 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"costrict-keeper/internal/models"
+)
+
+func TestLoadSpecStartupMode(t *testing.T) {
+	tests := []struct {
+		startup models.StartupMode
+		valid   bool
+	}{
+		{models.StartupAlways, true},
+		{models.StartupOnce, true},
+		{models.StartupNone, true},
+		{"", true},
+		{"allways", false},
+		{"Always", false},
+		{"on-demand", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.startup), func(t *testing.T) {
+			setupCostrictDir(t, "http://127.0.0.1:1")
+			writeSpec(t, models.SystemSpecification{
+				Manager: models.ManagerSpecification{
+					Service: models.ServiceSpecification{Name: "costrict", Startup: models.StartupAlways},
+				},
+				Services: []models.ServiceSpecification{{Name: "codebase-syncer", Startup: tt.startup}},
+			})
+			spec, err := loadLocalSpec()
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("startup %q is rejected: %v", tt.startup, err)
+				}
+				if spec.Services[0].Startup != tt.startup {
+					t.Errorf("startup = %q, want %q", spec.Services[0].Startup, tt.startup)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("unknown startup %q is accepted", tt.startup)
+			}
+			if !strings.Contains(err.Error(), "codebase-syncer("+string(tt.startup)+")") {
+				t.Errorf("error doesn't name the service: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadSpecFailureAction(t *testing.T) {
+	tests := []struct {
+		action string
+		valid  bool
+	}{
+		{models.FailureRestart, true},
+		{models.FailureAlert, true},
+		{models.FailureNone, true},
+		{"", true},
+		{"alret", false},
+		{"Alert", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			setupCostrictDir(t, "http://127.0.0.1:1")
+			writeSpec(t, models.SystemSpecification{
+				Services: []models.ServiceSpecification{
+					{Name: "mysql", Startup: models.StartupAlways, FailureAction: tt.action},
+				},
+			})
+			_, err := loadLocalSpec()
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("failure action %q is rejected: %v", tt.action, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("unknown failure action %q is accepted", tt.action)
+			}
+			if !strings.Contains(err.Error(), "mysql("+tt.action+")") {
+				t.Errorf("error doesn't name the service: %v", err)
+			}
+		})
+	}
+}
+
+func TestReloadSpecKeepsPreviousOnInvalidStartup(t *testing.T) {
+	setupCostrictDir(t, "http://127.0.0.1:1")
+	spec := models.SystemSpecification{
+		Services: []models.ServiceSpecification{{Name: "codebase-syncer", Startup: models.StartupAlways}},
+	}
+	writeSpec(t, spec)
+	if _, err := ReloadSpec(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 拼写错误的启动模式使整个规格被拒绝，继续使用之前的规格
+	spec.Services[0].Startup = "allways"
+	writeSpec(t, spec)
+	if _, err := ReloadSpec(); err == nil {
+		t.Fatal("specification with unknown startup mode is accepted")
+	}
+	if got := Spec().Services[0].Startup; got != models.StartupAlways {
+		t.Errorf("startup in use = %q, want the previous %q", got, models.StartupAlways)
+	}
+}
+
+func TestLoadSpecHealthCheckExpect(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect string
+		valid  bool
+	}{
+		{"empty", "", true},
+		{"regexp", `^\+PONG`, true},
+		{"invalid", "([", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCostrictDir(t, "http://127.0.0.1:1")
+			spec := models.SystemSpecification{
+				Services: []models.ServiceSpecification{{
+					Name:        "redis",
+					Startup:     models.StartupAlways,
+					HealthCheck: models.HealthCheckSpec{Send: "PING\r\n", Expect: tt.expect},
+				}},
+			}
+			writeSpec(t, spec)
+			_, err := loadLocalSpec()
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("expect %q is rejected: %v", tt.expect, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("invalid expect %q is accepted", tt.expect)
+			}
+			if !strings.Contains(err.Error(), "redis") {
+				t.Errorf("error doesn't name the service: %v", err)
+			}
+
+			// 无效的expect使整个规格被拒绝，继续使用之前的规格
+			spec.Services[0].HealthCheck.Expect = "PONG"
+			writeSpec(t, spec)
+			if _, err := ReloadSpec(); err != nil {
+				t.Fatal(err)
+			}
+			spec.Services[0].HealthCheck.Expect = tt.expect
+			writeSpec(t, spec)
+			if _, err := ReloadSpec(); err == nil {
+				t.Fatal("specification with invalid expect is accepted")
+			}
+			if got := Spec().Services[0].HealthCheck.Expect; got != "PONG" {
+				t.Errorf("expect in use = %q, want the previous %q", got, "PONG")
+			}
+		})
+	}
+}
@@ -12,6 +12,14 @@ var Version string = ""
 // (default: %USERPROFILE%/.costrict on Windows, $HOME/.costrict on Linux)
 var CostrictDir string = GetCostrictDir()
 
+// 可单独配置的子目录，为空时使用CostrictDir下的默认目录
+var (
+	LogDir     string = "" // 日志目录 (default: CostrictDir/logs)
+	CacheDir   string = "" // 缓存目录 (default: CostrictDir/cache)
+	PackageDir string = "" // 安装包目录 (default: CostrictDir/package)
+	RunDir     string = "" // 运行时文件(socket,pid)目录 (default: CostrictDir/run)
+)
+
 /**
  * Get costrict directory path
  * @returns {string} Returns costrict directory path
@@ -20,3 +28,30 @@ func GetCostrictDir() string {
 	homeDir, _ := os.UserHomeDir()
 	return filepath.Join(homeDir, ".costrict")
 }
+
+/**
+ * Get directories of costrict data, each can be overridden by configuration
+ * @returns {string} Returns the configured directory, or the default sub-directory of CostrictDir
+ */
+func GetLogDir() string {
+	return getSubDir(LogDir, "logs")
+}
+
+func GetCacheDir() string {
+	return getSubDir(CacheDir, "cache")
+}
+
+func GetPackageDir() string {
+	return getSubDir(PackageDir, "package")
+}
+
+func GetRunDir() string {
+	return getSubDir(RunDir, "run")
+}
+
+func getSubDir(dir string, name string) string {
+	if dir != "" {
+		return dir
+	}
+	return filepath.Join(CostrictDir, name)
+}
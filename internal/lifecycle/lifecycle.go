@@ -0,0 +1,130 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"costrict-keeper/internal/logger"
+)
+
+/**
+ * Registry of background goroutines (workers)
+ * @description
+ * - Every long-running goroutine is started by Go() with a name
+ * - Workers share one context, which is cancelled by Shutdown()
+ * - Shutdown() waits until all workers return, so leaks become visible
+ */
+type registry struct {
+	mutex   sync.Mutex
+	wg      sync.WaitGroup
+	workers map[string]int
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+var reg = newRegistry()
+
+func newRegistry() *registry {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &registry{
+		workers: make(map[string]int),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+/**
+ * Start a named background worker
+ * @param {string} name - Worker name, workers with the same name are counted together
+ * @param {func(context.Context)} fn - Worker body, should return when ctx is done
+ * @description
+ * - Registers the worker before the goroutine starts and removes it when fn returns
+ * - The context passed to fn is cancelled by Shutdown()
+ * @example
+ * lifecycle.Go("monitoring", server.StartMonitoring)
+ */
+func Go(name string, fn func(ctx context.Context)) {
+	reg.mutex.Lock()
+	reg.workers[name]++
+	reg.wg.Add(1)
+	reg.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			reg.mutex.Lock()
+			reg.workers[name]--
+			if reg.workers[name] <= 0 {
+				delete(reg.workers, name)
+			}
+			reg.mutex.Unlock()
+			reg.wg.Done()
+		}()
+		fn(reg.ctx)
+	}()
+}
+
+/**
+ * Get the number of running workers grouped by name
+ * @returns {map[string]int} Returns a copy of the worker table
+ */
+func Workers() map[string]int {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	workers := make(map[string]int, len(reg.workers))
+	for name, count := range reg.workers {
+		workers[name] = count
+	}
+	return workers
+}
+
+/**
+ * Get the total number of running workers
+ * @returns {int} Returns worker count
+ */
+func Count() int {
+	total := 0
+	for _, count := range Workers() {
+		total += count
+	}
+	return total
+}
+
+/**
+ * Stop all workers and wait for them to exit
+ * @param {time.Duration} timeout - Maximum time to wait
+ * @returns {error} Returns error listing the workers still running after timeout, nil on success
+ * @description
+ * - Cancels the context shared by all workers
+ * - Waits until all workers return or timeout expires
+ * @example
+ * if err := lifecycle.Shutdown(5 * time.Second); err != nil {
+ *     logger.Warnf("Some workers didn't exit: %v", err)
+ * }
+ */
+func Shutdown(timeout time.Duration) error {
+	reg.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		reg.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("All background workers exited")
+		return nil
+	case <-time.After(timeout):
+		var names []string
+		for name, count := range Workers() {
+			names = append(names, fmt.Sprintf("%s(%d)", name, count))
+		}
+		sort.Strings(names)
+		return fmt.Errorf("workers still running after %v: %s", timeout, strings.Join(names, ", "))
+	}
+}
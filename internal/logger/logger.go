@@ -231,7 +231,7 @@ func InitLogger(logPath, level string, isServerMode bool, maxSize int64, backup
 	// 根据配置设置输出位置
 	if logPath == "console" || logPath == "" {
 		// 如果没有指定日志路径，使用默认路径
-		logPath := filepath.Join(env.CostrictDir, "logs", "costrict.log")
+		logPath := filepath.Join(env.GetLogDir(), "costrict.log")
 		output = setupLogFileOutput(logPath, maxSize, backup)
 	} else {
 		output = setupLogFileOutput(logPath, maxSize, backup)
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"costrict-keeper/internal/env"
+)
+
+func TestInitLoggerUsesLogDir(t *testing.T) {
+	env.CostrictDir = t.TempDir()
+	env.LogDir = t.TempDir()
+	old := defaultLogger
+	t.Cleanup(func() {
+		env.LogDir = ""
+		defaultLogger = old
+	})
+
+	InitLogger("", "info", false, 0, 0)
+	// 关闭日志文件，临时目录才能被删除
+	defer func() {
+		if closer, ok := defaultLogger.infoLogger.Writer().(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+	Info("written to the configured log dir")
+
+	data, err := os.ReadFile(filepath.Join(env.LogDir, "costrict.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "written to the configured log dir") {
+		t.Errorf("log file content = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(env.CostrictDir, "logs")); !os.IsNotExist(err) {
+		t.Errorf("logs are written under CostrictDir: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type networkKey struct{}
+
+/**
+ * 记录连接所属的网络类型(tcp/unix/pipe)，供认证中间件区分请求来源
+ * @description
+ * - 作为http.Server.ConnContext使用
+ */
+func WithNetwork(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, networkKey{}, conn.LocalAddr().Network())
+}
+
+/**
+ * API认证中间件
+ * @description
+ * - 配置了api.token或只读令牌(readonly_token)时，调用启动/停止/重启/升级/删除等修改状态的接口，
+ *   需要在Authorization头中携带api.token，只配置了只读令牌时修改状态的接口无法通过TCP调用
+ * - 令牌可以是"Bearer <token>"形式，也可以直接是令牌本身
+ * - 只读令牌视为已认证，由ScopeMiddleware拒绝其修改状态的请求(403)
+ * - TCP连接总是需要认证；unix socket/命名管道仅本机用户可访问，默认不需要认证，可通过api.socket开启
+ * - 缺少或错误的令牌返回401
+ */
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		api := config.App().Api
+		readOnly := config.GetAuthConfig().ReadOnlyToken
+		if (api.Token == "" && readOnly == "") || isReadOnlyRequest(c) || !requireAuth(c, api.Socket) {
+			c.Next()
+			return
+		}
+		authorization := c.GetHeader("Authorization")
+		if (api.Token != "" && isValidToken(authorization, api.Token)) || isReadOnlyToken(authorization) {
+			c.Next()
+			return
+		}
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, &models.ErrorResponse{
+			Code:  "auth.unauthorized",
+			Error: "Missing or invalid token to " + c.Request.Method + " " + c.Request.URL.Path,
+		})
+	}
+}
+
+// 判断请求是否需要认证，非TCP连接仅在开启socket认证时需要
+func requireAuth(c *gin.Context, socket bool) bool {
+	network, _ := c.Request.Context().Value(networkKey{}).(string)
+	if network == "tcp" || network == "" {
+		return true
+	}
+	return socket
+}
+
+// 判断Authorization头携带的令牌是否与配置的令牌一致
+func isValidToken(authorization string, expected string) bool {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 在临时目录中写入api令牌和只读令牌，并加载配置
+func setupTokens(t *testing.T, apiToken, readOnlyToken string) {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	files := map[string]string{
+		filepath.Join("config", "costrict.json"): `{"api":{"token":"` + apiToken + `"}}`,
+		filepath.Join("share", "auth.json"):      `{"readonly_token":"` + readOnlyToken + `"}`,
+	}
+	for name, content := range files {
+		fname := filepath.Join(env.CostrictDir, name)
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := config.LoadConfig(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(), ScopeMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/costrict/api/v1/services", ok)
+	router.POST("/costrict/api/v1/services/:name/start", ok)
+	router.POST("/costrict/api/v1/check", ok)
+	return router
+}
+
+func request(router *gin.Engine, method, path, token string) int {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+const (
+	listPath  = "/costrict/api/v1/services"
+	startPath = "/costrict/api/v1/services/codebase-syncer/start"
+	checkPath = "/costrict/api/v1/check"
+)
+
+func TestReadOnlyTokenScope(t *testing.T) {
+	setupTokens(t, "full-token", "ro-token")
+	router := newAuthRouter()
+
+	cases := []struct {
+		method, path, token string
+		want                int
+	}{
+		{http.MethodGet, listPath, "", http.StatusOK},
+		{http.MethodGet, listPath, "ro-token", http.StatusOK},
+		{http.MethodPost, checkPath, "ro-token", http.StatusOK},
+		{http.MethodPost, startPath, "ro-token", http.StatusForbidden},
+		{http.MethodPost, startPath, "full-token", http.StatusOK},
+		{http.MethodPost, startPath, "", http.StatusUnauthorized},
+		{http.MethodPost, startPath, "wrong-token", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if got := request(router, c.method, c.path, c.token); got != c.want {
+			t.Errorf("%s %s with token '%s' = %d, want %d", c.method, c.path, c.token, got, c.want)
+		}
+	}
+}
+
+func TestReadOnlyTokenWithoutApiToken(t *testing.T) {
+	setupTokens(t, "", "ro-token")
+	router := newAuthRouter()
+
+	// 只配置了只读令牌时，不能通过省略令牌绕过限制
+	if got := request(router, http.MethodPost, startPath, ""); got != http.StatusUnauthorized {
+		t.Errorf("mutating request without token = %d, want 401", got)
+	}
+	if got := request(router, http.MethodPost, startPath, "ro-token"); got != http.StatusForbidden {
+		t.Errorf("mutating request with read-only token = %d, want 403", got)
+	}
+	if got := request(router, http.MethodGet, listPath, "ro-token"); got != http.StatusOK {
+		t.Errorf("read-only request with read-only token = %d, want 200", got)
+	}
+}
+
+func TestNoTokenConfigured(t *testing.T) {
+	setupTokens(t, "", "")
+	router := newAuthRouter()
+
+	if got := request(router, http.MethodPost, startPath, ""); got != http.StatusOK {
+		t.Errorf("mutating request without any token configured = %d, want 200", got)
+	}
+}
+
+func TestForgedReadOnlyJWT(t *testing.T) {
+	setupTokens(t, "full-token", "ro-token")
+	router := newAuthRouter()
+
+	// 声明readonly scope的JWT，签名密钥是伪造者自己的，不能因此通过认证
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"scope": "readonly"}).SignedString([]byte("attacker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"scope": "readonly"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{forged, unsigned} {
+		if got := request(router, http.MethodPost, startPath, token); got != http.StatusUnauthorized {
+			t.Errorf("mutating request with forged token = %d, want 401", got)
+		}
+	}
+}
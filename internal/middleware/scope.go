@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 只读令牌可以访问的非GET接口
+var readOnlyPosts = map[string]bool{
+	"/costrict/api/v1/check": true,
+}
+
+/**
+ * 令牌权限范围中间件
+ * @description
+ * - 请求携带的Bearer令牌等于auth.json中配置的readonly_token时，视为只读令牌
+ * - 不信任令牌自身声明的scope，未经验证签名的JWT可以被任意伪造
+ * - 只读令牌仅能访问查询类接口(GET请求及/check)，访问启动/停止/重启/升级/删除等修改状态的接口时返回403
+ * - 其它请求不受影响
+ * - 便于监控系统轮询状态，而不具备修改状态的能力
+ */
+func ScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isReadOnlyToken(c.GetHeader("Authorization")) || isReadOnlyRequest(c) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, &models.ErrorResponse{
+			Code:  "auth.forbidden",
+			Error: "Read-only token is not allowed to " + c.Request.Method + " " + c.Request.URL.Path,
+		})
+	}
+}
+
+// 判断请求是否只读取状态，不修改状态
+func isReadOnlyRequest(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return readOnlyPosts[c.FullPath()]
+	}
+	return false
+}
+
+// 判断Authorization头携带的令牌是否为只读令牌
+func isReadOnlyToken(authorization string) bool {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	readOnly := config.GetAuthConfig().ReadOnlyToken
+	return readOnly != "" && subtle.ConstantTimeCompare([]byte(token), []byte(readOnly)) == 1
+}
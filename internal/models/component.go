@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type PackageDetail struct {
 	PackageType string `json:"packageType"` //包类型: exec/conf
 	FileName    string `json:"fileName"`    //被打包的文件的相对路径(相对.costrict目录,为空则安装到默认路径)
@@ -14,11 +16,83 @@ type PackageRepo struct {
 	Versions []string `json:"versions"`
 }
 
+/**
+ * Request of upgrading components in batch
+ * @property {[]string} names - Names of components to upgrade, empty means all components
+ * @property {map[string]string} versions - Target version of components, the newest version if not specified
+ */
+type ComponentUpgradeRequest struct {
+	Names    []string          `json:"names,omitempty"`
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+/**
+ * Result of upgrading one component in a batch request
+ * @property {string} name - Component name
+ * @property {string} oldVersion - Version before upgrade, empty if not installed
+ * @property {string} newVersion - Version after upgrade
+ * @property {string} error - Error message if the upgrade failed
+ */
+type ComponentUpgradeResult struct {
+	Name       string `json:"name"`
+	OldVersion string `json:"oldVersion"`
+	NewVersion string `json:"newVersion"`
+	Error      string `json:"error,omitempty"`
+}
+
+/**
+ * Record of one component upgrade, kept in the upgrade history
+ * @property {string} component - Component name
+ * @property {string} from - Version before upgrade, empty if not installed
+ * @property {string} to - Target version, empty if the newest version couldn't be resolved
+ * @property {time.Time} time - Time the upgrade finished
+ * @property {string} result - Upgrade result: success/failed
+ * @property {string} error - Error message if the upgrade failed
+ */
+type UpgradeRecord struct {
+	Component string    `json:"component"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Time      time.Time `json:"time"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+const (
+	UpgradeSuccess = "success"
+	UpgradeFailed  = "failed"
+)
+
+/**
+ * Result of verifying an installed component against its signed package metadata
+ * @property {string} name - Component name
+ * @property {string} version - Installed version
+ * @property {string} filePath - Path of the installed file
+ * @property {string} expectedChecksum - Checksum recorded in the package metadata
+ * @property {string} actualChecksum - Checksum recomputed from the installed file
+ * @property {bool} checksumOk - Whether the checksums match
+ * @property {bool} signatureOk - Whether the signature of the metadata is valid
+ * @property {bool} passed - Whether both checks passed
+ * @property {string} error - Reason of the failure
+ */
+type ComponentVerifyResult struct {
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	FilePath         string `json:"filePath"`
+	ExpectedChecksum string `json:"expectedChecksum"`
+	ActualChecksum   string `json:"actualChecksum"`
+	ChecksumOK       bool   `json:"checksumOk"`
+	SignatureOK      bool   `json:"signatureOk"`
+	Passed           bool   `json:"passed"`
+	Error            string `json:"error,omitempty"`
+}
+
 type ComponentDetail struct {
 	Name        string                 `json:"name"`
 	Spec        ComponentSpecification `json:"spec"`
 	Local       PackageDetail          `json:"local"`
 	Remote      PackageRepo            `json:"remote"`
 	Installed   bool                   `json:"installed"`
+	Incomplete  bool                   `json:"incomplete,omitempty"`
 	NeedUpgrade bool                   `json:"need_upgrade"`
 }
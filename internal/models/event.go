@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type EventType string
+
+const (
+	EventServiceUp        EventType = "service_up"        // 服务启动或恢复运行
+	EventServiceDown      EventType = "service_down"      // 服务停止或异常退出
+	EventTunnelReopened   EventType = "tunnel_reopened"   // 隧道被重新打开
+	EventUpgradeAvailable EventType = "upgrade_available" // 组件有新版本可以升级
+	EventHeartbeat        EventType = "heartbeat"         // 心跳，用于保持连接及检测断线
+
+	EventCrashLoop          EventType = "crash_loop"           // 服务反复崩溃，自动重启次数已用完
+	EventUpgradeFailed      EventType = "upgrade_failed"       // 组件升级失败
+	EventSelfUpgradeInvalid EventType = "self_upgrade_invalid" // 下载的新版本管理程序校验失败
+	EventUpgradeProgress    EventType = "upgrade_progress"     // 批量升级组件的进度
+	EventServiceUnhealthy   EventType = "service_unhealthy"    // 服务不可用，按failure_action只告警不重启
+)
+
+// 批量升级中单个组件所处的阶段
+type UpgradeStage string
+
+const (
+	UpgradeDownloading UpgradeStage = "downloading" // 正在下载并校验安装包
+	UpgradeVerified    UpgradeStage = "verified"    // 安装包已下载并通过校验
+	UpgradeActivating  UpgradeStage = "activating"  // 正在安装新版本
+	UpgradeDone        UpgradeStage = "done"        // 升级完成
+	UpgradeFailure     UpgradeStage = "failed"      // 升级失败
+)
+
+// 批量升级中单个组件的进度
+type UpgradeProgress struct {
+	Stage UpgradeStage `json:"stage"`           // current stage
+	Index int          `json:"index"`           // index of the component, starts from 1
+	Total int          `json:"total"`           // number of components to be upgraded
+	Error string       `json:"error,omitempty"` // error message if failed
+}
+
+// 推送给订阅者的状态变化事件
+type Event struct {
+	Type EventType   `json:"type"`           // event type
+	Name string      `json:"name,omitempty"` // name of service/component
+	Time time.Time   `json:"time"`           // time when the event happened
+	Data interface{} `json:"data,omitempty"` // detail of the service/tunnel/component
+}
+
+// 关键事件的失败信息
+type FailureInfo struct {
+	Version string `json:"version,omitempty"` // version which failed to be installed
+	Error   string `json:"error"`             // error message
+}
+
+// 通过webhook推送给外部系统的关键事件通知
+type Notification struct {
+	MachineID string `json:"machineId,omitempty"` // machine which the event happened on
+	Event
+}
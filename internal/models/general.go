@@ -1,7 +1,26 @@
 package models
 
 // ErrorResponse defines API error response format
+//
+// Code is always set as "<domain>.<reason>", clients should check it rather than Error:
+//   - auth: unauthorized, forbidden
+//   - server: draining, invalid_request
+//   - config: reload_failed, sync_failed
+//   - service: notexist, group_notexist, invalid_request, invalid_action, start_failed, stop_failed, restart_failed,
+//     detach_failed, attach_failed
+//   - tunnel: notexist, invalid_request, open_failed, close_failed, reopen_failed
+//   - component: not_found, invalid_request, upgrade_failed, not_implemented
 type ErrorResponse struct {
 	Code  string `json:"code"`
 	Error string `json:"error"`
 }
+
+// MaintenanceRequest defines request to switch maintenance mode, mode is "on" or "off"
+type MaintenanceRequest struct {
+	Mode string `json:"mode" example:"on"`
+}
+
+// SuccessResponse defines API response format of operations which return no data
+type SuccessResponse struct {
+	Status string `json:"status" example:"success"`
+}
@@ -3,11 +3,23 @@ package models
 // HealthResponse 健康检查响应结构
 // @Description 健康检查API响应数据结构
 type HealthResponse struct {
-	Version   string  `json:"version" example:"1.0.0" description:"服务版本"`
-	StartTime string  `json:"startTime" example:"2024-01-01T10:00:00Z" description:"启动时间"`
-	Status    string  `json:"status" example:"UP" description:"健康状态"`
-	Uptime    string  `json:"uptime" example:"1h30m45s" description:"运行时长"`
-	Metrics   Metrics `json:"metrics" description:"关键指标"`
+	Version     string               `json:"version" example:"1.0.0" description:"服务版本"`
+	StartTime   string               `json:"startTime" example:"2024-01-01T10:00:00Z" description:"启动时间"`
+	Status      string               `json:"status" example:"UP" description:"健康状态"`
+	Uptime      string               `json:"uptime" example:"1h30m45s" description:"运行时长"`
+	Metrics     Metrics              `json:"metrics" description:"关键指标"`
+	ExportError string               `json:"exportError,omitempty" example:"open .well-known.json: permission denied" description:"最近一次导出.well-known.json的错误，成功时为空"`
+	Tunnels     []TunnelReachability `json:"tunnels,omitempty" description:"深度检查时各远程访问服务隧道的可达性"`
+}
+
+// TunnelReachability 隧道端到端可达性
+// @Description 通过映射端口检查远程访问服务的隧道是否能到达本地服务
+type TunnelReachability struct {
+	Name        string `json:"name" example:"codebase-syncer" description:"服务名称"`
+	LocalPort   int    `json:"localPort" example:"8080" description:"本地端口"`
+	MappingPort int    `json:"mappingPort" example:"30001" description:"映射端口"`
+	Reachable   bool   `json:"reachable" description:"是否可达"`
+	Error       string `json:"error,omitempty" example:"dial tcp 10.0.0.1:30001: i/o timeout" description:"不可达的原因"`
 }
 
 // Metrics 关键指标结构
@@ -17,10 +29,69 @@ type Metrics struct {
 	ErrorRequests      int64 `json:"errorRequests"`
 	ActiveServices     int   `json:"activeServices"`
 	ActiveTunnels      int   `json:"activeTunnels"`
+	TunnelReachable    *int  `json:"tunnelReachable,omitempty"` // 深度检查时端到端可达的隧道数
 	TotalComponents    int   `json:"totalComponents"`
 	UpgradedComponents int   `json:"upgradedComponents"`
 }
 
+// UnhealthyItem 不健康的条目
+// @Description 处于非健康状态的服务、组件或隧道
+type UnhealthyItem struct {
+	Kind    string        `json:"kind" example:"service" description:"类型: service/component/tunnel"`
+	Name    string        `json:"name" example:"codebase-syncer" description:"名称"`
+	Healthy HealthyStatus `json:"healthy" example:"unavailable" description:"健康状态"`
+	Reason  string        `json:"reason" example:"service status is error" description:"不健康的原因"`
+}
+
+// MetricsSnapshot 指标快照
+// @Description 当前各项指标的JSON快照，与Prometheus格式的/metrics相互独立
+type MetricsSnapshot struct {
+	Timestamp  string             `json:"timestamp" example:"2024-01-01T10:00:00Z" description:"采集时间"`
+	Uptime     string             `json:"uptime" example:"1h30m45s" description:"运行时长"`
+	Metrics    Metrics            `json:"metrics" description:"关键指标，与/healthz相同"`
+	Services   []ServiceMetrics   `json:"services" description:"各服务的指标"`
+	Components []ComponentVersion `json:"components" description:"各组件的本地版本"`
+}
+
+// ServiceMetrics 服务指标
+// @Description 单个服务的计数器和仪表盘指标
+type ServiceMetrics struct {
+	Name     string             `json:"name" example:"codebase-syncer" description:"服务名称"`
+	Healthy  HealthyStatus      `json:"healthy" example:"healthy" description:"健康状态"`
+	Requests float64            `json:"requests" description:"请求总数"`
+	Errors   float64            `json:"errors" description:"错误请求总数"`
+	OOMKills float64            `json:"oomKills" description:"被OOM killer杀死的次数"`
+	Uptime   float64            `json:"uptime" description:"运行时长(秒)"`
+	Scraped  map[string]float64 `json:"scraped,omitempty" description:"从服务采集的白名单指标"`
+}
+
+// ComponentVersion 组件版本
+// @Description 组件名称及本地安装的版本
+type ComponentVersion struct {
+	Name      string `json:"name" example:"costrict" description:"组件名称"`
+	Version   string `json:"version" example:"1.0.0" description:"本地版本"`
+	Installed bool   `json:"installed" description:"是否已安装"`
+}
+
+// DependencyStatus 上游依赖的可达性
+// @Description 上游依赖(云端服务)的可达性和访问延迟
+type DependencyStatus struct {
+	Name       string `json:"name" example:"upgrade" description:"依赖名称"`
+	Url        string `json:"url" example:"https://zgsm.sangfor.com/costrict" description:"依赖的地址"`
+	Reachable  bool   `json:"reachable" description:"是否可达，收到HTTP响应即认为可达"`
+	StatusCode int    `json:"statusCode,omitempty" example:"200" description:"HTTP响应码"`
+	Latency    int64  `json:"latency" example:"35" description:"访问延迟(毫秒)"`
+	Error      string `json:"error,omitempty" description:"不可达的原因"`
+}
+
+// DependenciesResponse 上游依赖检查响应结构
+// @Description 上游依赖检查API响应数据结构
+type DependenciesResponse struct {
+	Timestamp    string             `json:"timestamp" example:"2024-01-01T10:00:00Z" description:"检查时间"`
+	Reachable    bool               `json:"reachable" description:"所有依赖均可达"`
+	Dependencies []DependencyStatus `json:"dependencies" description:"各依赖的可达性"`
+}
+
 type HealthyStatus string
 
 const (
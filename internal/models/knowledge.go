@@ -13,19 +13,31 @@ package models
  * @property {string} metrics - Metrics endpoint path
  * @property {string} healthy - Health check endpoint path
  * @property {string} accessible - Accessible: remote/local
+ * @property {TunnelKnowledge} tunnel - Tunnel of remote service, used to discover public mapping ports
  */
 type ServiceKnowledge struct {
-	Name       string `json:"name"`
-	Version    string `json:"version"`
-	Installed  bool   `json:"installed"`
-	Startup    string `json:"startup"`
-	Status     string `json:"status"`
-	Protocol   string `json:"protocol,omitempty"`
-	Port       int    `json:"port,omitempty"`
-	Command    string `json:"command,omitempty"`
-	Metrics    string `json:"metrics,omitempty"`
-	Healthy    string `json:"healthy,omitempty"`
-	Accessible string `json:"accessible,omitempty"`
+	Name       string           `json:"name"`
+	Version    string           `json:"version"`
+	Installed  bool             `json:"installed"`
+	Startup    string           `json:"startup"`
+	Status     string           `json:"status"`
+	Protocol   string           `json:"protocol,omitempty"`
+	Port       int              `json:"port,omitempty"`
+	Command    string           `json:"command,omitempty"`
+	Metrics    string           `json:"metrics,omitempty"`
+	Healthy    string           `json:"healthy,omitempty"`
+	Accessible string           `json:"accessible,omitempty"`
+	Tunnel     *TunnelKnowledge `json:"tunnel,omitempty"`
+}
+
+/**
+ * Tunnel mapping of remote service (part of ServiceKnowledge)
+ * @property {string} status - Tunnel status: running/stopped/error/exited/pending
+ * @property {[]PortPair} pairs - Local port to mapping port pairs
+ */
+type TunnelKnowledge struct {
+	Status RunStatus  `json:"status"`
+	Pairs  []PortPair `json:"pairs"`
 }
 
 /**
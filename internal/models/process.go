@@ -13,19 +13,73 @@ const (
 	StatusError RunStatus = "error"
 	// 表示被用户手动停止，5分钟检测流程不会尝试重启，用户通过启动命令可以手动启动
 	StatusStopped RunStatus = "stopped"
+	// 仅用于隧道，表示隧道管理服务暂时不可达，监测流程会按退避间隔重试打开隧道
+	StatusPending RunStatus = "pending"
+	// 仅用于服务，表示服务进程仍在运行，但已脱离管理，不再监测和自动恢复，通过attach重新接管
+	StatusDetached RunStatus = "detached"
 )
 
+// 进程退出原因的分类
+type ExitClass string
+
+const (
+	// 进程正常退出，退出码为0
+	ExitNormal ExitClass = "normal"
+	// 进程以非0退出码退出
+	ExitError ExitClass = "error"
+	// 进程被信号杀死
+	ExitSignal ExitClass = "signal"
+	// 进程因内存不足被系统的OOM killer杀死
+	ExitOOM ExitClass = "oom"
+)
+
+// 受keeper管理的进程
+type ManagedProcess struct {
+	Kind         string    `json:"kind" example:"service"`              //进程类型(service/tunnel)
+	Owner        string    `json:"owner" example:"codebase-syncer"`     //所属的服务名，隧道则为应用名
+	Pid          int       `json:"pid"`                                 //进程PID
+	Name         string    `json:"name" example:"codebase-syncer"`      //进程名
+	Title        string    `json:"title"`                               //显示用的名字
+	Status       RunStatus `json:"status"`                              //状态
+	RestartCount int       `json:"restartCount"`                        //重启次数
+	StartTime    time.Time `json:"startTime"`                           //启动时间
+	Uptime       string    `json:"uptime,omitempty" example:"1h30m45s"` //运行时长，未运行时为空
+}
+
+// 进程列表，用于排查进程泄漏
+type ProcessesResponse struct {
+	Processes []ManagedProcess `json:"processes"` //受管理的服务和隧道进程
+	Excessive []int            `json:"excessive"` //组件进程中不受管理的多余进程的PID
+}
+
+// 清理多余进程的请求，必须明确指定要清理的PID，或者指定all清理全部多余进程
+type ProcessCleanupRequest struct {
+	Pids []int `json:"pids,omitempty"` //要清理的多余进程的PID，一般来自进程列表接口的excessive
+	All  bool  `json:"all,omitempty"`  //清理检测到的全部多余进程
+}
+
+// 清理单个多余进程的结果
+type ProcessCleanupResult struct {
+	Pid    int    `json:"pid"`             //进程PID
+	Name   string `json:"name,omitempty"`  //进程所属的组件名
+	Killed bool   `json:"killed"`          //是否已杀死
+	Error  string `json:"error,omitempty"` //未杀死的原因
+}
+
 type ProcessDetail struct {
-	Title           string    `json:"title"`           //显示用的名字
-	ProcessName     string    `json:"processName"`     //进程名，用于查找进程
-	Command         string    `json:"command"`         //进程启动命令
-	Args            []string  `json:"args"`            //进程参数
-	WorkDir         string    `json:"workDir"`         //工作目录
-	MaxRestartCount int       `json:"maxRestartCount"` //最大重启次数
-	Pid             int       `json:"pid"`             //进程PID
-	Status          RunStatus `json:"status"`          //状态
-	RestartCount    int       `json:"restartCount"`    //重启次数
-	StartTime       time.Time `json:"startTime"`       //启动时间
-	LastExitTime    time.Time `json:"lastExitTime"`    //最后一次退出的时间
-	LastExitReason  string    `json:"lastExitReason"`  //最后一次退出的原因
+	Title           string         `json:"title"`           //显示用的名字
+	ProcessName     string         `json:"processName"`     //进程名，用于查找进程
+	Command         string         `json:"command"`         //进程启动命令
+	Args            []string       `json:"args"`            //进程参数
+	WorkDir         string         `json:"workDir"`         //工作目录
+	MaxRestartCount int            `json:"maxRestartCount"` //最大重启次数
+	Pid             int            `json:"pid"`             //进程PID
+	Status          RunStatus      `json:"status"`          //状态
+	RestartCount    int            `json:"restartCount"`    //重启次数
+	StartTime       time.Time      `json:"startTime"`       //启动时间
+	LastExitTime    time.Time      `json:"lastExitTime"`    //最后一次退出的时间
+	LastExitReason  string         `json:"lastExitReason"`  //最后一次退出的原因
+	ExitCode        int            `json:"exitCode"`        //最后一次退出的退出码，被信号杀死时为128+信号值
+	ExitClass       ExitClass      `json:"exitClass"`       //最后一次退出的原因分类(normal/error/signal/oom)
+	Limits          ResourceLimits `json:"limits"`          //进程的资源限制
 }
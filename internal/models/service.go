@@ -2,13 +2,40 @@ package models
 
 type ServiceDetail struct {
 	Name      string               `json:"name"`
+	Tags      []string             `json:"tags,omitempty"`
+	Group     string               `json:"group,omitempty"`
 	Pid       int                  `json:"pid"`
 	Port      int                  `json:"port"`
 	Status    RunStatus            `json:"status"`
 	StartTime string               `json:"startTime"`
 	Healthy   HealthyStatus        `json:"healthy"`
+	Reason    string               `json:"healthReason,omitempty"` // 服务不健康的原因，如"port 9001 not accepting connections"
+	OOM       bool                 `json:"oom"`                    // 服务进程最后一次退出是被OOM killer杀死的
+	Enabled   bool                 `json:"enabled"`                // 服务被禁用时不自动启动也不自动恢复
 	Spec      ServiceSpecification `json:"spec"`
 	Process   ProcessDetail        `json:"process,omitempty"`
 	Tunnel    *TunnelDetail        `json:"tunnel,omitempty"`
 	Component *ComponentDetail     `json:"component,omitempty"`
 }
+
+/**
+ * Batch operation request, selects services by names and/or tag
+ * @property {[]string} names - Names of services to operate, empty means all services
+ * @property {string} tag - Only operate services with the tag, empty means no tag filter
+ */
+type BatchRequest struct {
+	Names []string `json:"names,omitempty"`
+	Tag   string   `json:"tag,omitempty"`
+}
+
+/**
+ * Result of an operation on one service in a batch request
+ * @property {string} name - Service name
+ * @property {string} status - Operation result: success/failed/not_ready
+ * @property {string} error - Error message if the operation failed
+ */
+type BatchResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
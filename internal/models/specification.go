@@ -1,36 +1,205 @@
 package models
 
+import "strings"
+
 /**
  * Service configuration
  * @property {string} name - Service name
- * @property {string} startup - Startup mode: always/once/none
- * @property {string} command - Startup command
- * @property {string} protocol - Network protocol
+ * @property {StartupMode} startup - Startup mode: always/once/none, empty is the same as none
+ * @property {string} command - Startup command, command and args are templates which may use {{.LocalPort}},
+ *   {{.ProcessPath}}, {{.ProcessName}} and {{.LogLevel}} (log level of the keeper, such as "--log-level={{.LogLevel}}")
+ * @property {string} protocol - Network protocol: http/https/grpc, service.protocol of app config if empty
  * @property {int} port - Service port
+ * @property {string} host - Host the service binds to, checks cover both IPv4 and IPv6 loopback if empty
  * @property {string} metrics - Metrics endpoint path
- * @property {string} healthy - Health check endpoint path
+ * @property {string} healthy - Health check endpoint path, for grpc it's the service name checked by
+ *   grpc.health.v1.Health ("/" checks the whole server)
  * @property {string} accessible - Accessible: remote/local
+ * @property {[]string} dependsOn - Names of services this service depends on
+ * @property {bool} cascadeStop - Stopping this service also stops services depending on it
+ * @property {bool} cascadeRestart - Starting this service again restarts dependents stopped by cascade
+ * @property {[]string} tags - Tags for grouping services, such as "ai", "infra"
+ * @property {string} group - Group the service belongs to, such as "ai-tools", "proxies",
+ *   services of a group can be started/stopped/restarted together
+ * @property {bool} shell - Run command through a shell (sh -c/cmd /c) instead of direct exec, default false.
+ *   The command is interpreted by the shell, so pipes and env interpolation are available, but so is injection:
+ *   never render untrusted values into the command, use {{quote .X}} for values which may contain metacharacters
+ * @property {ResourceLimits} limits - Resource limits of the service process, no limit if unset
+ * @property {HealthCheckSpec} healthCheck - How the service health is checked, derived from healthy and protocol if unset
+ * @property {string} onFailure - Policy when a "once" service fails at startup: continue/abort, default continue.
+ *   With abort, remaining services aren't started and the server fails to start
+ * @property {string} preStart - Shell command run before the service process is started, such as creating
+ *   a directory. Templates are expanded the same as the command ({{.LocalPort}}, {{.ProcessPath}}...),
+ *   a failed hook aborts the start
+ * @property {string} postStop - Shell command run after the service process is stopped, such as cleanup,
+ *   a failed hook is only logged
+ * @property {int} maxRestart - Max times the process is restarted after crashing, service.max_restart of
+ *   app config if 0, negative disables auto restart
+ * @property {string} failureAction - What monitoring does when the service is unavailable: restart/alert/none,
+ *   default restart. alert only logs, publishes a service_unhealthy event and counts the alert, for stateful
+ *   services which shouldn't be restarted unexpectedly, the crashed process isn't restarted either; none does
+ *   nothing. Unknown values are rejected when the specification is loaded
+ * @property {*bool} enabled - Whether the service is enabled, default true. A disabled service is loaded but
+ *   neither auto started nor recovered, and can only be started manually with force
+ * @property {string} os - Operating systems the service runs on, comma separated (e.g. "windows", "linux,darwin"),
+ *   any if empty. The service is skipped on other platforms
+ * @property {string} arch - Architectures the service runs on, comma separated (e.g. "amd64,arm64"), any if empty
+ * @property {string} user - Unix user (name or uid) the service process and its pre_start/post_stop hooks run as,
+ *   the user of costrict if empty. Requires costrict to run as root, not supported on Windows
+ * @property {string} userGroup - Unix group (name or gid) the service process runs as, the primary group of
+ *   user if empty. Named user_group since group is the service group
  */
 type ServiceSpecification struct {
-	Name       string   `json:"name"`
-	Startup    string   `json:"startup"`
-	Command    string   `json:"command,omitempty"`
-	Args       []string `json:"args,omitempty"`
-	Protocol   string   `json:"protocol,omitempty"`
-	Port       int      `json:"port,omitempty"`
-	Metrics    string   `json:"metrics,omitempty"`
-	Healthy    string   `json:"healthy,omitempty"`
-	Accessible string   `json:"accessible,omitempty"`
+	Name           string          `json:"name"`
+	Startup        StartupMode     `json:"startup"`
+	Command        string          `json:"command,omitempty"`
+	Args           []string        `json:"args,omitempty"`
+	Protocol       string          `json:"protocol,omitempty"`
+	Port           int             `json:"port,omitempty"`
+	Host           string          `json:"host,omitempty"`
+	Metrics        string          `json:"metrics,omitempty"`
+	Healthy        string          `json:"healthy,omitempty"`
+	Accessible     string          `json:"accessible,omitempty"`
+	DependsOn      []string        `json:"depends_on,omitempty"`
+	CascadeStop    bool            `json:"cascade_stop,omitempty"`
+	CascadeRestart bool            `json:"cascade_restart,omitempty"`
+	Tags           []string        `json:"tags,omitempty"`
+	Group          string          `json:"group,omitempty"`
+	Shell          bool            `json:"shell,omitempty"`
+	Limits         ResourceLimits  `json:"limits,omitempty"`
+	OnFailure      string          `json:"on_failure,omitempty"`
+	HealthCheck    HealthCheckSpec `json:"health_check,omitempty"`
+	PreStart       string          `json:"pre_start,omitempty"`
+	PostStop       string          `json:"post_stop,omitempty"`
+	MaxRestart     int             `json:"max_restart,omitempty"`
+	FailureAction  string          `json:"failure_action,omitempty"`
+	Enabled        *bool           `json:"enabled,omitempty"`
+	Os             string          `json:"os,omitempty"`
+	Arch           string          `json:"arch,omitempty"`
+	User           string          `json:"user,omitempty"`
+	UserGroup      string          `json:"user_group,omitempty"`
+}
+
+/**
+ * Check if the service can run on the platform
+ * @param {string} goos - Operating system, such as runtime.GOOS
+ * @param {string} goarch - Architecture, such as runtime.GOARCH
+ * @returns {bool} Returns true if both os and arch constraints are empty or contain the platform
+ */
+func (s *ServiceSpecification) MatchPlatform(goos, goarch string) bool {
+	return matchList(s.Os, goos) && matchList(s.Arch, goarch)
+}
+
+// 逗号分隔的列表为空，或者包含指定的值(不区分大小写)
+func matchList(list, value string) bool {
+	if strings.TrimSpace(list) == "" {
+		return true
+	}
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Check if the service is enabled
+ * @returns {bool} Returns true unless enabled is explicitly false
+ */
+func (s *ServiceSpecification) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+/**
+ * Health check settings of service
+ * @property {string} type - Health check mode:
+ *   - port: the service port is connectable
+ *   - http: GET the healthy path, 2xx means healthy, https is used if the protocol is https
+ *   - grpc: call grpc.health.v1.Health/Check for the service named by healthy, SERVING means healthy
+ *   - tcp: connect to the service port, send the send bytes and wait for a reply matching expect
+ *   If unset, tcp is used when send or expect is declared, http (or grpc for grpc services) is used when
+ *   healthy is declared, otherwise port, and periodic monitoring checks the port only
+ * @property {string} send - Bytes sent after connected for tcp check, nothing is sent if empty
+ * @property {string} expect - Regular expression the reply must match for tcp check (use ^ for a prefix),
+ *   the reply isn't read if empty
+ * @property {int} timeout - Timeout of the handshake in seconds, 1 if unset
+ */
+type HealthCheckSpec struct {
+	Type    string `json:"type,omitempty"`
+	Send    string `json:"send,omitempty"`
+	Expect  string `json:"expect,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+/**
+ * Check if a send/expect handshake is configured
+ * @returns {bool} Returns true if send or expect is declared
+ */
+func (hc HealthCheckSpec) HasHandshake() bool {
+	return hc.Send != "" || hc.Expect != ""
+}
+
+const (
+	OnFailureContinue = "continue"
+	OnFailureAbort    = "abort"
+)
+
+const (
+	FailureRestart = "restart"
+	FailureAlert   = "alert"
+	FailureNone    = "none"
+)
+
+// 服务的启动模式
+type StartupMode string
+
+const (
+	// 随costrict启动并常驻，守护模式下异常退出后自动重启
+	StartupAlways StartupMode = "always"
+	// costrict启动时运行一次，不监测也不重启
+	StartupOnce StartupMode = "once"
+	// 不自动启动，未设置启动模式等同于none
+	StartupNone StartupMode = "none"
+)
+
+/**
+ * Check if the startup mode is known
+ * @returns {bool} Returns true for always/once/none and empty (same as none)
+ */
+func (m StartupMode) IsValid() bool {
+	switch m {
+	case StartupAlways, StartupOnce, StartupNone, "":
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * Resource limits of service process, zero means no limit
+ * @property {int64} memory - Max memory in bytes, RLIMIT_AS on Linux, process memory limit of Job Object on Windows
+ * @property {int} cpu - Max CPU in percent of one core (e.g. 50 is half a core, 200 is two cores),
+ *   cpu.max of cgroup v2 on Linux (requires a delegated cgroup), CPU rate hard cap of Job Object on Windows
+ */
+type ResourceLimits struct {
+	Memory int64 `json:"memory,omitempty"`
+	CPU    int   `json:"cpu,omitempty"`
 }
 
 /**
  * Component configuration
  * @property {string} name - Component name
  * @property {string} version - Version compatibility range
+ * @property {string} postInstall - Shell command run once after each version is installed,
+ *   templates are expanded the same as service commands ({{.ProcessName}}, {{.ProcessPath}})
+ * @property {string} upgradeUrl - Base URL the component is upgraded from, overrides cloud.upgrade_url if set
  */
 type ComponentSpecification struct {
-	Name    string `json:"name"`
-	Version string `json:"version,omitempty"`
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	PostInstall string `json:"post_install,omitempty"`
+	UpgradeUrl  string `json:"upgrade_url,omitempty"`
 }
 
 type ManagerSpecification struct {
@@ -38,6 +207,63 @@ type ManagerSpecification struct {
 	Service   ServiceSpecification   `json:"service"`
 }
 
+/**
+ * Changes of a modified item
+ * @property {string} name - Name of the service or component
+ * @property {[]string} fields - Json names of changed fields
+ */
+type ItemChange struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+/**
+ * Changes of services or components, items are matched by name
+ */
+type ItemsDiff struct {
+	Added    []string     `json:"added,omitempty"`
+	Removed  []string     `json:"removed,omitempty"`
+	Modified []ItemChange `json:"modified,omitempty"`
+}
+
+func (d ItemsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+/**
+ * Structured diff between two system specifications, produced on config reload
+ */
+type SpecDiff struct {
+	Services   ItemsDiff `json:"services"`
+	Components ItemsDiff `json:"components"`
+}
+
+func (d SpecDiff) IsEmpty() bool {
+	return d.Services.IsEmpty() && d.Components.IsEmpty()
+}
+
+/**
+ * Changed setting of app configuration
+ * @property {string} field - Dotted json path of the setting, such as "service.kill_timeout"
+ * @property {interface{}} old - Value before the change, secrets redacted, null if the setting is added
+ * @property {interface{}} new - Value after the change, secrets redacted, null if the setting is removed
+ */
+type ConfigChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+/**
+ * Result of syncing app configuration from the cloud
+ * @property {bool} changed - Whether the configuration in use changed
+ * @property {[]ConfigChange} changes - Changed settings, sorted by field
+ */
+type ConfigSyncResult struct {
+	Changed bool           `json:"changed"`
+	Changes []ConfigChange `json:"changes,omitempty"`
+}
+
 /**
  * System definition (system-spec.json)
  * @property {string} configuration - Configuration format version
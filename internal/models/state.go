@@ -30,10 +30,61 @@ type ServerConfig struct {
 	Cloud      string `json:"cloud"`
 }
 
+/**
+ * Background worker statistics
+ * @property {int} total - Number of workers registered in lifecycle registry
+ * @property {int} goroutines - Number of goroutines in the process
+ * @property {map[string]int} workers - Running workers grouped by name
+ */
+type WorkerState struct {
+	Total      int            `json:"total"`
+	Goroutines int            `json:"goroutines"`
+	Workers    map[string]int `json:"workers"`
+}
+
+/**
+ * Maintenance mode state
+ * @property {bool} enabled - Whether the server is in maintenance mode
+ * @property {time.Time} until - Time when maintenance mode is cleared automatically
+ */
+type MaintenanceState struct {
+	Enabled bool      `json:"enabled"`
+	Until   time.Time `json:"until,omitempty"`
+}
+
+const (
+	OnceSuccess = "success"
+	OnceFailed  = "failed"
+	OnceTimeout = "timeout"
+)
+
+/**
+ * Result of a "once" service run at startup
+ * @property {string} name - Service name
+ * @property {string} result - Result of the run: success/failed/timeout
+ * @property {int} exitCode - Exit code of the process, -1 if it couldn't start
+ * @property {string} error - Why the run failed, empty on success
+ * @property {time.Time} startTime - Time the run started
+ * @property {int64} duration - Run time in milliseconds
+ */
+type OnceTaskResult struct {
+	Name      string    `json:"name"`
+	Result    string    `json:"result"`
+	ExitCode  int       `json:"exitCode"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	Duration  int64     `json:"duration"`
+}
+
 type ServerState struct {
 	StartTime       time.Time            `json:"startTime"`
+	SafeMode        bool                 `json:"safeMode"`
+	Maintenance     MaintenanceState     `json:"maintenance"`
 	MidnightRooster MidnightRoosterState `json:"midnightRooster"`
 	PortAlloc       PortAllocState       `json:"portAlloc"`
 	Env             EnvConfig            `json:"env"`
 	Config          ServerConfig         `json:"config"`
+	Workers         WorkerState          `json:"workers"`
+	ExportError     string               `json:"exportError,omitempty"` // error of the last .well-known.json export, empty if it succeeded
+	OnceTasks       []OnceTaskResult     `json:"onceTasks,omitempty"`   // results of "once" services run at startup
 }
@@ -8,10 +8,28 @@ type PortPair struct {
 }
 
 type TunnelDetail struct {
-	Name        string        `json:"name"`        // service name
-	Status      RunStatus     `json:"status"`      // tunnel status(running/stopped/error/exited)
-	Pairs       []PortPair    `json:"pairs"`       // Port pairs
-	CreatedTime time.Time     `json:"createdTime"` // creation time
-	Pid         int           `json:"pid"`         // process ID of the tunnel
-	Healthy     HealthyStatus `json:"healthy"`     // Works fine
+	Name            string         `json:"name"`                    // service name
+	Status          RunStatus      `json:"status"`                  // tunnel status(running/stopped/error/exited/pending)
+	Pairs           []PortPair     `json:"pairs"`                   // Port pairs
+	CreatedTime     time.Time      `json:"createdTime"`             // creation time
+	Pid             int            `json:"pid"`                     // process ID of the tunnel
+	Healthy         HealthyStatus  `json:"healthy"`                 // Works fine
+	RestartCount    int            `json:"restartCount"`            // times the tunnel process was auto restarted
+	MaxRestartCount int            `json:"maxRestartCount"`         // the process isn't auto restarted beyond this
+	Uptime          string         `json:"uptime,omitempty"`        // time since the tunnel was opened, empty if not running
+	ReopenCount     int            `json:"reopenCount"`             // times the tunnel was reopened, a growing count means flapping
+	HealthHistory   []HealthRecord `json:"healthHistory,omitempty"` // recent health check results, oldest first
+}
+
+// 隧道的一次健康检查结果
+type HealthRecord struct {
+	Time    time.Time     `json:"time"`             // time of the check
+	Healthy HealthyStatus `json:"healthy"`          // result of the check
+	Reason  string        `json:"reason,omitempty"` // why the tunnel isn't healthy
+}
+
+// 为任意本地端口打开隧道的请求
+type TunnelRequest struct {
+	AppName   string `json:"appName" binding:"required"`   // application name
+	LocalPort int    `json:"localPort" binding:"required"` // local port to expose
 }
@@ -0,0 +1,108 @@
+//go:build !windows
+
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+/**
+ * Make the command run as the specified user/group
+ * @param {exec.Cmd} cmd - Command to be started
+ * @param {string} userName - User name or uid, the current user if empty
+ * @param {string} groupName - Group name or gid, the primary group of the user if empty
+ * @returns {error} Returns error if the user or group doesn't exist
+ * @description
+ * - Nothing is changed if both are empty or equal to the current uid/gid
+ * - Supplementary groups are set to the groups of the user, which requires root
+ * - Used for service processes and their pre_start/post_stop hooks
+ */
+func SetCredential(cmd *exec.Cmd, userName, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+	uid := os.Getuid()
+	gid := os.Getgid()
+	var groups []uint32
+	if userName != "" {
+		u, err := lookupUser(userName)
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return fmt.Errorf("invalid uid '%s' of user '%s'", u.Uid, userName)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return fmt.Errorf("invalid gid '%s' of user '%s'", u.Gid, userName)
+		}
+		ids, _ := u.GroupIds()
+		for _, id := range ids {
+			if n, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(n))
+			}
+		}
+	}
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("invalid gid '%s' of group '%s'", g.Gid, groupName)
+		}
+	}
+	if uid == os.Getuid() && gid == os.Getgid() {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}
+	return nil
+}
+
+// 按名字查找用户，找不到时把它当作uid查找
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, convErr := strconv.Atoi(name); convErr == nil {
+		if u, idErr := user.LookupId(name); idErr == nil {
+			return u, nil
+		}
+	}
+	var unknown user.UnknownUserError
+	if errors.As(err, &unknown) {
+		return nil, fmt.Errorf("user '%s' doesn't exist", name)
+	}
+	return nil, fmt.Errorf("lookup user '%s' failed: %v", name, err)
+}
+
+// 按名字查找用户组，找不到时把它当作gid查找
+func lookupGroup(name string) (*user.Group, error) {
+	g, err := user.LookupGroup(name)
+	if err == nil {
+		return g, nil
+	}
+	if _, convErr := strconv.Atoi(name); convErr == nil {
+		if g, idErr := user.LookupGroupId(name); idErr == nil {
+			return g, nil
+		}
+	}
+	var unknown user.UnknownGroupError
+	if errors.As(err, &unknown) {
+		return nil, fmt.Errorf("group '%s' doesn't exist", name)
+	}
+	return nil, fmt.Errorf("lookup group '%s' failed: %v", name, err)
+}
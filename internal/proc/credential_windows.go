@@ -0,0 +1,16 @@
+//go:build windows
+
+package proc
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// SetCredential Windows不支持以其他用户/用户组运行进程，指定了user/user_group时报错
+func SetCredential(cmd *exec.Cmd, userName, groupName string) error {
+	if userName != "" || groupName != "" {
+		return fmt.Errorf("running process as another user/group isn't supported on Windows")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package proc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"costrict-keeper/internal/logger"
+)
+
+// cgroup v2 cpu.max的周期(微秒)
+const cpuPeriod = 100000
+
+// 进程资源限制占用的系统资源
+type limitState struct {
+	cgroupDir  string   // 限制CPU的cgroup目录，进程退出后删除
+	cgroupFile *os.File // 打开的cgroup目录，启动进程时通过CgroupFD直接加入，启动后关闭
+}
+
+/**
+ * prepareResourceLimits 启动进程前准备资源限制
+ * @param {exec.Cmd} cmd - 待启动的命令
+ * @returns {bool} 设置了需要在启动时生效的限制时返回true
+ * @description
+ * - CPU限制在本进程所在cgroup(v2)的同级创建cgroup并设置cpu.max，需要cgroup已委派给当前用户
+ * - 通过CgroupFD让子进程在执行命令前就处于该cgroup中，而不是启动后再移入
+ * - 准备失败不影响进程运行，只记录警告
+ */
+func (pi *ProcessInstance) prepareResourceLimits(cmd *exec.Cmd) bool {
+	if pi.Limits.CPU <= 0 {
+		return false
+	}
+	dir, err := createCPUCgroup(pi.Limits.CPU)
+	if err != nil {
+		logger.Warnf("Failed to limit CPU of process '%s': %v", pi.Title, err)
+		return false
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		logger.Warnf("Failed to limit CPU of process '%s': %v", pi.Title, err)
+		return false
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(file.Fd())
+	pi.limitState.cgroupDir = dir
+	pi.limitState.cgroupFile = file
+	return true
+}
+
+/**
+ * applyResourceLimits 对刚启动的子进程应用资源限制
+ * @description
+ * - 内存限制通过prlimit设置子进程的RLIMIT_AS
+ * - 限制失败不影响进程运行，只记录警告
+ */
+func (pi *ProcessInstance) applyResourceLimits() {
+	if pi.limitState.cgroupFile != nil {
+		pi.limitState.cgroupFile.Close()
+		pi.limitState.cgroupFile = nil
+	}
+	pid := pi.Pid()
+	if pi.Limits.Memory > 0 {
+		limit := syscall.Rlimit{Cur: uint64(pi.Limits.Memory), Max: uint64(pi.Limits.Memory)}
+		if err := prlimit(pid, syscall.RLIMIT_AS, &limit); err != nil {
+			logger.Warnf("Failed to limit memory of process '%s' (PID: %d): %v", pi.Title, pid, err)
+		}
+	}
+}
+
+/**
+ * releaseResourceLimits 进程退出或启动失败后释放资源限制占用的系统资源
+ */
+func (pi *ProcessInstance) releaseResourceLimits() {
+	if pi.limitState.cgroupFile != nil {
+		pi.limitState.cgroupFile.Close()
+		pi.limitState.cgroupFile = nil
+	}
+	if pi.limitState.cgroupDir != "" {
+		// 进程派生的子进程仍在运行时无法删除，忽略错误
+		os.Remove(pi.limitState.cgroupDir)
+		pi.limitState.cgroupDir = ""
+	}
+}
+
+func prlimit(pid int, resource int, limit *syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// 创建限制CPU配额的cgroup，percent为100表示一个核
+func createCPUCgroup(percent int) (string, error) {
+	self := selfCgroupPath()
+	if self == "" {
+		return "", fmt.Errorf("cgroup v2 is unavailable")
+	}
+	parent := filepath.Join("/sys/fs/cgroup", filepath.Dir(self))
+	// 混合模式下/sys/fs/cgroup不是cgroup v2的挂载点
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is unavailable")
+	}
+	dir, err := os.MkdirTemp(parent, "costrict-")
+	if err != nil {
+		return "", err
+	}
+	quota := fmt.Sprintf("%d %d", cpuPeriod*percent/100, cpuPeriod)
+	if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(quota), 0644); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+	return dir, nil
+}
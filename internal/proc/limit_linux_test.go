@@ -0,0 +1,71 @@
+//go:build linux
+
+package proc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/models"
+)
+
+func TestMemoryLimitApplied(t *testing.T) {
+	pi := NewProcessInstance("memory limit", "sleep", "sleep", []string{"30"})
+	pi.Limits = models.ResourceLimits{Memory: 1 << 30}
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer pi.StopProcess()
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", pi.Pid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "Max address space") {
+			if fields := strings.Fields(line); len(fields) < 5 || fields[3] != "1073741824" || fields[4] != "1073741824" {
+				t.Fatalf("unexpected address space limit: %q", line)
+			}
+			return
+		}
+	}
+	t.Fatal("address space limit isn't found")
+}
+
+func TestCPULimitBeforeExec(t *testing.T) {
+	// 需要本进程所在的cgroup(v2)已委派给当前用户
+	dir, err := createCPUCgroup(50)
+	if err != nil {
+		t.Skipf("cgroup isn't available: %v", err)
+	}
+	os.Remove(dir)
+
+	output := filepath.Join(t.TempDir(), "cgroup")
+	pi := NewProcessInstance("cpu limit", "cat", "cat /proc/self/cgroup > "+output, nil)
+	pi.Shell = true
+	pi.Limits = models.ResourceLimits{CPU: 50}
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := pi.WaitProcess(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 进程执行的第一条命令就已处于限制CPU的cgroup中
+	if !strings.Contains(string(data), "/costrict-") {
+		t.Fatalf("process isn't in the limited cgroup: %q", data)
+	}
+	if pi.limitState.cgroupDir != "" {
+		t.Fatal("cgroup isn't removed after the process exits")
+	}
+}
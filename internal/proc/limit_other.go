@@ -0,0 +1,27 @@
+//go:build !linux && !windows
+
+package proc
+
+import (
+	"os/exec"
+
+	"costrict-keeper/internal/logger"
+)
+
+// 进程资源限制占用的系统资源
+type limitState struct{}
+
+// prepareResourceLimits 当前平台没有需要在启动前准备的限制
+func (pi *ProcessInstance) prepareResourceLimits(cmd *exec.Cmd) bool {
+	return false
+}
+
+// applyResourceLimits 当前平台不支持限制子进程的资源，只记录警告
+func (pi *ProcessInstance) applyResourceLimits() {
+	if pi.Limits.Memory > 0 || pi.Limits.CPU > 0 {
+		logger.Warnf("Resource limits of process '%s' are not supported on this platform", pi.Title)
+	}
+}
+
+func (pi *ProcessInstance) releaseResourceLimits() {
+}
@@ -0,0 +1,221 @@
+//go:build windows
+
+package proc
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"costrict-keeper/internal/logger"
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procCreateToolhelp32Snapshot = kernel32.NewProc("CreateToolhelp32Snapshot")
+	procThread32First            = kernel32.NewProc("Thread32First")
+	procThread32Next             = kernel32.NewProc("Thread32Next")
+	procOpenThread               = kernel32.NewProc("OpenThread")
+	procResumeThread             = kernel32.NewProc("ResumeThread")
+)
+
+const (
+	jobObjectExtendedLimitInformationClass  = 9
+	jobObjectCpuRateControlInformationClass = 15
+	jobObjectLimitProcessMemory             = 0x00000100
+	jobObjectCpuRateControlEnable           = 0x1
+	jobObjectCpuRateControlHardCap          = 0x4
+	processSetQuota                         = 0x0100
+	processTerminate                        = 0x0001
+	createSuspended                         = 0x00000004
+	th32csSnapThread                        = 0x00000004
+	threadSuspendResume                     = 0x0002
+)
+
+type threadEntry32 struct {
+	Size           uint32
+	Usage          uint32
+	ThreadID       uint32
+	OwnerProcessID uint32
+	BasePri        int32
+	DeltaPri       int32
+	Flags          uint32
+}
+
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+type jobObjectExtendedLimitInformation struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+type jobObjectCpuRateControlInformation struct {
+	ControlFlags uint32
+	CpuRate      uint32
+}
+
+// 进程资源限制占用的系统资源
+type limitState struct {
+	job syscall.Handle // 限制进程资源的Job Object，进程退出后关闭
+}
+
+/**
+ * prepareResourceLimits 启动进程前准备资源限制
+ * @param {exec.Cmd} cmd - 待启动的命令
+ * @returns {bool} 设置了需要在启动时生效的限制时返回true
+ * @description
+ * - 创建设置了进程内存上限和CPU速率硬上限的Job Object
+ * - 以挂起方式创建进程，加入Job Object后再恢复运行，避免进程在受限前就执行
+ */
+func (pi *ProcessInstance) prepareResourceLimits(cmd *exec.Cmd) bool {
+	if pi.Limits.Memory <= 0 && pi.Limits.CPU <= 0 {
+		return false
+	}
+	job, err := createLimitJob(pi.Limits.Memory, pi.Limits.CPU)
+	if err != nil {
+		logger.Warnf("Failed to create job object for process '%s': %v", pi.Title, err)
+		return false
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= createSuspended
+	pi.limitState.job = job
+	return true
+}
+
+/**
+ * applyResourceLimits 把挂起的子进程加入Job Object后恢复运行
+ * @description
+ * - 子进程派生的进程自动加入同一个Job Object
+ * - 限制失败不影响进程运行，只记录警告，但进程总要恢复运行
+ */
+func (pi *ProcessInstance) applyResourceLimits() {
+	if pi.limitState.job == 0 {
+		return
+	}
+	pid := pi.Pid()
+	defer func() {
+		if err := resumeProcess(pid); err != nil {
+			logger.Errorf("Failed to resume process '%s' (PID: %d): %v", pi.Title, pid, err)
+		}
+	}()
+	process, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(pid))
+	if err != nil {
+		logger.Warnf("Failed to open process '%s' (PID: %d): %v", pi.Title, pid, err)
+		pi.releaseResourceLimits()
+		return
+	}
+	defer syscall.CloseHandle(process)
+
+	if ret, _, err := procAssignProcessToJobObject.Call(uintptr(pi.limitState.job), uintptr(process)); ret == 0 {
+		logger.Warnf("Failed to limit resources of process '%s' (PID: %d): %v", pi.Title, pid, err)
+		pi.releaseResourceLimits()
+	}
+}
+
+/**
+ * releaseResourceLimits 进程退出后关闭Job Object
+ */
+func (pi *ProcessInstance) releaseResourceLimits() {
+	if pi.limitState.job != 0 {
+		syscall.CloseHandle(pi.limitState.job)
+		pi.limitState.job = 0
+	}
+}
+
+// 恢复以挂起方式创建的进程的所有线程
+func resumeProcess(pid int) error {
+	ret, _, err := procCreateToolhelp32Snapshot.Call(th32csSnapThread, 0)
+	if syscall.Handle(ret) == syscall.InvalidHandle {
+		return err
+	}
+	snapshot := syscall.Handle(ret)
+	defer syscall.CloseHandle(snapshot)
+
+	entry := threadEntry32{}
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	ret, _, err = procThread32First.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&entry)))
+	for ret != 0 {
+		if entry.OwnerProcessID == uint32(pid) {
+			thread, _, err := procOpenThread.Call(threadSuspendResume, 0, uintptr(entry.ThreadID))
+			if thread == 0 {
+				return err
+			}
+			count, _, err := procResumeThread.Call(thread)
+			syscall.CloseHandle(syscall.Handle(thread))
+			if int32(count) == -1 {
+				return err
+			}
+		}
+		ret, _, err = procThread32Next.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&entry)))
+	}
+	if err != syscall.ERROR_NO_MORE_FILES {
+		return err
+	}
+	return nil
+}
+
+// 创建设置了内存和CPU上限的Job Object，cpu为100表示一个核
+func createLimitJob(memory int64, cpu int) (syscall.Handle, error) {
+	ret, _, err := procCreateJobObjectW.Call(0, 0)
+	if ret == 0 {
+		return 0, err
+	}
+	job := syscall.Handle(ret)
+
+	if memory > 0 {
+		info := jobObjectExtendedLimitInformation{}
+		info.BasicLimitInformation.LimitFlags = jobObjectLimitProcessMemory
+		info.ProcessMemoryLimit = uintptr(memory)
+		if ret, _, err := procSetInformationJobObject.Call(uintptr(job), jobObjectExtendedLimitInformationClass,
+			uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); ret == 0 {
+			syscall.CloseHandle(job)
+			return 0, err
+		}
+	}
+	if cpu > 0 {
+		// CpuRate是占全部CPU周期的万分比
+		rate := cpu * 100 / runtime.NumCPU()
+		if rate > 10000 {
+			rate = 10000
+		}
+		info := jobObjectCpuRateControlInformation{
+			ControlFlags: jobObjectCpuRateControlEnable | jobObjectCpuRateControlHardCap,
+			CpuRate:      uint32(max(rate, 1)),
+		}
+		if ret, _, err := procSetInformationJobObject.Call(uintptr(job), jobObjectCpuRateControlInformationClass,
+			uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); ret == 0 {
+			syscall.CloseHandle(job)
+			return 0, err
+		}
+	}
+	return job, nil
+}
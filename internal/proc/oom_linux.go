@@ -0,0 +1,65 @@
+//go:build linux
+
+package proc
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/**
+ * readOOMKillCount 读取OOM killer杀死进程的累计次数
+ * @returns {int64} 累计次数，无法获取时返回-1
+ * @description
+ * - 优先使用本进程所在cgroup(v2)的memory.events，子进程默认和本进程处于同一cgroup
+ * - cgroup v1或读取失败时，使用系统全局的/proc/vmstat(Linux 4.13+)
+ */
+func readOOMKillCount() int64 {
+	if path := selfCgroupPath(); path != "" {
+		eventsFile := filepath.Join("/sys/fs/cgroup", path, "memory.events")
+		if count := readCounter(eventsFile, "oom_kill"); count >= 0 {
+			return count
+		}
+	}
+	return readCounter("/proc/vmstat", "oom_kill")
+}
+
+// 本进程所在cgroup(v2)的路径，cgroup v1或读取失败时返回空串
+func selfCgroupPath() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path
+		}
+	}
+	return ""
+}
+
+// 从"key value"格式的文件中读取计数器
+func readCounter(fname, key string) int64 {
+	file, err := os.Open(fname)
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != key {
+			continue
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return -1
+		}
+		return count
+	}
+	return -1
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package proc
+
+// readOOMKillCount 非Linux平台无法检测OOM，始终返回-1
+func readOOMKillCount() int64 {
+	return -1
+}
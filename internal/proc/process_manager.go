@@ -6,8 +6,10 @@ import (
 	"os"
 	"os/exec"
 	"sync"
+	"syscall"
 	"time"
 
+	"costrict-keeper/internal/lifecycle"
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/models"
 	"costrict-keeper/internal/utils"
@@ -16,6 +18,7 @@ import (
 type processWatcher struct {
 	maxRestartCount int                    //最大重启次数(监测程序通过重启解决临时故障)
 	onChanged       func(*ProcessInstance) //监测到进程重启/停止的回调函数
+	disabled        bool                   //暂停监测，进程退出后不回调也不重启，设置保留以便重新接管
 }
 
 /**
@@ -24,29 +27,43 @@ type processWatcher struct {
  * @property {string} procName - 进程列表显示的进程名，processName+pid可以确定一个进程身份，放误杀
  * @property {string} command - 执行命令
  * @property {[]string} args - 命令参数
+ * @property {bool} shell - command为shell脚本，通过sh -c/cmd /c执行
  * @property {string} workDir - 工作目录
+ * @property {[]string} env - 进程的环境变量，为nil时继承keeper的环境变量
  * @property {int} pid - 进程ID
  * @property {string} status - 进程状态: running/exited/stopped/error
  * @property {int} restartCount - 重启次数
  * @property {time.Time} startTime - 启动时间
  * @property {time.Time} lastExitTime - 最后退出时间
  * @property {string} lastExitReason - 最后退出原因
+ * @property {int} exitCode - 最后退出的退出码
+ * @property {models.ExitClass} exitClass - 最后退出原因的分类
+ * @property {models.ResourceLimits} limits - 进程的资源限制，为0的项不限制
  * @property {processWatcher} watcher - 监控协程设置
  */
 type ProcessInstance struct {
-	Title          string           //显示用的名字
-	ProcessName    string           //进程名，用于查找进程
-	Command        string           //进程启动命令
-	Args           []string         //进程参数
-	WorkDir        string           //工作目录
-	Status         models.RunStatus //状态
-	RestartCount   int              //重启次数
-	StartTime      time.Time        //启动时间
-	LastExitTime   time.Time        //最后一次退出的时间
-	LastExitReason string           //最后一次退出的原因
-	watcher        processWatcher   //监测协程的设置
-	process        *os.Process      //统一的进程对象，用于Wait()
-	mutex          sync.Mutex       //保护实例数据一致性的读写锁
+	Title          string                //显示用的名字
+	ProcessName    string                //进程名，用于查找进程
+	Command        string                //进程启动命令
+	Args           []string              //进程参数
+	Shell          bool                  //Command为shell脚本，通过shell执行
+	WorkDir        string                //工作目录
+	Env            []string              //环境变量，为nil时继承keeper的环境变量
+	User           string                //运行进程的用户(名字或uid)，为空时使用当前用户，仅支持Unix
+	Group          string                //运行进程的用户组(名字或gid)，为空时使用User的主组，仅支持Unix
+	Status         models.RunStatus      //状态
+	RestartCount   int                   //重启次数
+	StartTime      time.Time             //启动时间
+	LastExitTime   time.Time             //最后一次退出的时间
+	LastExitReason string                //最后一次退出的原因
+	ExitCode       int                   //最后一次退出的退出码
+	ExitClass      models.ExitClass      //最后一次退出的原因分类
+	Limits         models.ResourceLimits //进程的资源限制
+	limitState     limitState            //资源限制占用的系统资源
+	oomKills       int64                 //进程启动时OOM killer的累计杀进程次数，用于判断进程是否被OOM杀死
+	watcher        processWatcher        //监测协程的设置
+	process        *os.Process           //统一的进程对象，用于Wait()
+	mutex          sync.Mutex            //保护实例数据一致性的读写锁
 }
 
 /**
@@ -80,6 +97,53 @@ func (pi *ProcessInstance) SetWatcher(maxRestart int, onChanged func(*ProcessIns
 	pi.watcher.maxRestartCount = maxRestart
 }
 
+/**
+ * ResetRestartCount 清零重启次数
+ * @description
+ * - 重启次数用完后进程退出不再自动重启，清零后恢复自动重启
+ * - 不启动已经退出的进程，由调用者决定是否重新启动
+ */
+func (pi *ProcessInstance) ResetRestartCount() {
+	pi.mutex.Lock()
+	defer pi.mutex.Unlock()
+
+	pi.RestartCount = 0
+}
+
+/**
+ * DisableWatcher 停止监测进程，进程继续运行
+ * @description
+ * - 进程退出后不再回调onChanged，也不会自动重启
+ * - 监测设置被保留，AttachProcess可以重新接管进程
+ */
+func (pi *ProcessInstance) DisableWatcher() {
+	pi.mutex.Lock()
+	defer pi.mutex.Unlock()
+
+	pi.watcher.disabled = true
+}
+
+/**
+ * AttachProcess 重新接管被DisableWatcher停止监测的进程
+ * @returns {error} 进程已经不在运行时返回错误
+ * @description
+ * - 恢复进程的监测，进程退出后按原设置回调和自动重启
+ * - 停止监测期间进程已经退出的，由调用者决定是否重新启动
+ */
+func (pi *ProcessInstance) AttachProcess() error {
+	pi.mutex.Lock()
+	defer pi.mutex.Unlock()
+
+	pi.watcher.disabled = false
+	if pi.Status != models.StatusRunning || pi.process == nil {
+		return fmt.Errorf("process '%s' isn't running", pi.Title)
+	}
+	if running, err := utils.IsProcessRunning(pi.Pid()); err != nil || !running {
+		return fmt.Errorf("process '%s' (PID: %d) isn't running", pi.Title, pi.Pid())
+	}
+	return nil
+}
+
 func (pi *ProcessInstance) Pid() int {
 	if pi.process == nil {
 		return 0
@@ -90,7 +154,16 @@ func (pi *ProcessInstance) Pid() int {
 func (pi *ProcessInstance) GetDetail() models.ProcessDetail {
 	pi.mutex.Lock()
 	defer pi.mutex.Unlock()
+	return pi.DetailLocked()
+}
 
+/**
+ * DetailLocked 获取进程详情，调用者必须已持有进程锁
+ * @returns {models.ProcessDetail} 进程详情的快照
+ * @description
+ * - 用于SetWatcher的回调中：回调时持有进程锁，调用GetDetail会死锁
+ */
+func (pi *ProcessInstance) DetailLocked() models.ProcessDetail {
 	return models.ProcessDetail{
 		Title:           pi.Title,
 		ProcessName:     pi.ProcessName,
@@ -104,6 +177,9 @@ func (pi *ProcessInstance) GetDetail() models.ProcessDetail {
 		StartTime:       pi.StartTime,
 		LastExitTime:    pi.LastExitTime,
 		LastExitReason:  pi.LastExitReason,
+		ExitCode:        pi.ExitCode,
+		ExitClass:       pi.ExitClass,
+		Limits:          pi.Limits,
 	}
 }
 
@@ -124,30 +200,60 @@ func (pi *ProcessInstance) StartProcess(ctx context.Context) error {
 	return pi.startProcess(ctx)
 }
 
-func (pi *ProcessInstance) startProcess(ctx context.Context) error {
-	if pi.Status == models.StatusRunning {
-		return nil
-	}
-	fullCommand := pi.Command
-	for _, arg := range pi.Args {
-		fullCommand += " " + arg
+// 创建启动进程的命令
+func (pi *ProcessInstance) newCommand(ctx context.Context) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if pi.Shell {
+		cmd = utils.ShellCommand(ctx, pi.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, pi.Command, pi.Args...)
 	}
-	logger.Infof("Executing command: %s", fullCommand)
-
-	// 创建命令
-	cmd := exec.CommandContext(ctx, pi.Command, pi.Args...)
 
 	// 设置工作目录
 	if pi.WorkDir != "" {
 		cmd.Dir = pi.WorkDir
 	}
+	cmd.Env = pi.Env
 
 	if pi.watcher.onChanged == nil {
 		// 设置进程属性，使子进程在父进程退出后继续运行
 		utils.SetNewPG(cmd)
 	}
 
-	if err := cmd.Start(); err != nil {
+	// 以指定的用户/用户组运行
+	if err := SetCredential(cmd, pi.User, pi.Group); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (pi *ProcessInstance) startProcess(ctx context.Context) error {
+	if pi.Status == models.StatusRunning {
+		return nil
+	}
+	fullCommand := pi.Command
+	for _, arg := range pi.Args {
+		fullCommand += " " + arg
+	}
+	logger.Infof("Executing command: %s", fullCommand)
+
+	cmd, err := pi.newCommand(ctx)
+	if err == nil {
+		// 资源限制在进程开始执行前生效，避免进程在受限前就耗尽资源
+		if pi.prepareResourceLimits(cmd) {
+			if err = cmd.Start(); err != nil {
+				// 系统不支持启动时加入cgroup等情况，去掉限制后重新启动
+				logger.Warnf("Failed to start process '%s' with resource limits: %v, start without limits", pi.Title, err)
+				pi.releaseResourceLimits()
+				if cmd, err = pi.newCommand(ctx); err == nil {
+					err = cmd.Start()
+				}
+			}
+		} else {
+			err = cmd.Start()
+		}
+	}
+	if err != nil {
 		pi.Status = models.StatusError
 		pi.LastExitReason = fmt.Sprintf("start failed: %v", err)
 		logger.Errorf("Failed to start process '%s', error: %v", pi.Title, err)
@@ -155,13 +261,17 @@ func (pi *ProcessInstance) startProcess(ctx context.Context) error {
 	}
 
 	pi.process = cmd.Process // 保存进程对象，用于统一Wait()
+	pi.applyResourceLimits()
+	pi.oomKills = oomKillCount()
 	pi.Status = models.StatusRunning
 	pi.StartTime = time.Now()
 
 	logger.Infof("Process '%s' started (PID: %d)", pi.Title, pi.Pid())
 
 	if pi.watcher.onChanged != nil { // costrict.exe作为服务器运行时，启动协程监控子进程
-		go pi.watchProcess()
+		lifecycle.Go("process-watcher", func(ctx context.Context) {
+			pi.watchProcess()
+		})
 	}
 	return nil
 }
@@ -197,12 +307,141 @@ func (pi *ProcessInstance) StopProcess() error {
 		pi.process.Wait()
 		pi.process = nil
 	}
+	pi.releaseResourceLimits()
 
 	logger.Infof("Process '%s' (PID: %d, NAME: %s) stopped",
 		pi.Title, pid, pi.ProcessName)
 	return nil
 }
 
+/**
+ * StopProcessContext 请求进程优雅退出，ctx结束时仍未退出则强制杀死
+ * @param {context.Context} ctx - 控制等待进程优雅退出的时间
+ * @returns {error} 进程没有优雅退出而被强制杀死时返回错误
+ * @description
+ * - 先发送SIGTERM(Windows上为CTRL_BREAK)，发送失败则直接强制杀死
+ * - 等待期间持有进程锁，监控协程在进程退出后才能处理退出事件
+ */
+func (pi *ProcessInstance) StopProcessContext(ctx context.Context) error {
+	pi.mutex.Lock()
+	defer pi.mutex.Unlock()
+
+	if pi.Status != models.StatusRunning {
+		return nil
+	}
+	pi.Status = models.StatusStopped
+	pi.LastExitTime = time.Now()
+	pi.LastExitReason = "stopped by user"
+
+	pid := pi.Pid()
+	var result error
+	if pi.process != nil {
+		process := pi.process
+		done := make(chan struct{})
+		go func() {
+			process.Wait()
+			close(done)
+		}()
+		if err := utils.TerminateProcess(pid); err != nil {
+			logger.Warnf("Failed to terminate process '%s' (PID: %d): %v", pi.Title, pid, err)
+			result = fmt.Errorf("terminate process '%s' failed: %v", pi.Title, err)
+			process.Kill()
+		} else {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				logger.Warnf("Process '%s' (PID: %d) didn't exit in time, force killing", pi.Title, pid)
+				result = fmt.Errorf("process '%s' didn't exit in time, force killed", pi.Title)
+				process.Kill()
+			}
+		}
+		<-done
+		pi.process = nil
+	}
+	pi.releaseResourceLimits()
+	if result != nil {
+		return result
+	}
+
+	logger.Infof("Process '%s' (PID: %d, NAME: %s) stopped",
+		pi.Title, pid, pi.ProcessName)
+	return nil
+}
+
+/**
+ * WaitProcess 等待没有监测协程的进程(如once服务)退出
+ * @param {context.Context} ctx - 控制等待时间，ctx结束时进程仍未退出则强制杀死
+ * @returns {int} 进程的退出码，被信号杀死时为128+信号值
+ * @returns {error} 进程未运行、异常退出或者超时被杀死时返回错误
+ * @description
+ * - 等待期间不持有进程锁，GetDetail等可以正常调用
+ * - 超时时杀死进程所在的进程组(Windows上为进程树)，而不只是shell包装进程
+ * - 记录进程的退出时间、退出码和退出原因
+ */
+func (pi *ProcessInstance) WaitProcess(ctx context.Context) (int, error) {
+	pi.mutex.Lock()
+	process := pi.process
+	watched := pi.watcher.onChanged != nil
+	pi.mutex.Unlock()
+	if process == nil {
+		return -1, fmt.Errorf("process '%s' isn't running", pi.Title)
+	}
+	if watched {
+		return -1, fmt.Errorf("process '%s' is waited by its watcher", pi.Title)
+	}
+
+	type waitResult struct {
+		state *os.ProcessState
+		err   error
+	}
+	done := make(chan waitResult, 1)
+	go func() {
+		state, err := process.Wait()
+		done <- waitResult{state, err}
+	}()
+	var res waitResult
+	timeout := false
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		logger.Warnf("Process '%s' (PID: %d) didn't exit in time, force killing", pi.Title, process.Pid)
+		// 进程以SetNewPG启动，是进程组的组长，杀死整个组，避免shell包装启动的子进程残留
+		if err := utils.KillProcessGroup(process.Pid); err != nil {
+			process.Kill()
+		}
+		res = <-done
+		timeout = true
+	}
+
+	pi.mutex.Lock()
+	defer pi.mutex.Unlock()
+	if pi.Status != models.StatusRunning {
+		// 等待期间被StopProcess停止
+		return -1, fmt.Errorf("process '%s' %s", pi.Title, pi.LastExitReason)
+	}
+	pi.releaseResourceLimits()
+	pi.LastExitTime = time.Now()
+	pi.recordExitClass(res.state)
+	pi.Status = models.StatusExited
+	pi.process = nil
+	switch {
+	case timeout:
+		pi.LastExitReason = "killed after timeout"
+	case pi.ExitClass == models.ExitOOM:
+		pi.LastExitReason = "killed by OOM killer"
+	case res.err != nil:
+		pi.LastExitReason = fmt.Sprintf("exited with error: %v", res.err)
+	case pi.ExitClass != models.ExitNormal:
+		pi.LastExitReason = fmt.Sprintf("exited with error: %s", res.state)
+	default:
+		pi.LastExitReason = "exited normally"
+		logger.Infof("Process '%s' (PID: %d) exited normally", pi.Title, process.Pid)
+		return pi.ExitCode, nil
+	}
+	logger.Errorf("Process '%s' (PID: %d) %s", pi.Title, process.Pid, pi.LastExitReason)
+	return pi.ExitCode, fmt.Errorf("%s", pi.LastExitReason)
+}
+
 func (pi *ProcessInstance) CheckProcess() models.HealthyStatus {
 	pi.mutex.Lock()
 	defer pi.mutex.Unlock()
@@ -234,6 +473,53 @@ func getReason(status models.RunStatus) string {
 	}
 }
 
+/**
+ * classifyExit 根据Wait()的结果对进程退出原因进行分类
+ * @param {*os.ProcessState} state - 进程退出状态
+ * @returns {int} 退出码，被信号杀死时为128+信号值(与shell的约定一致)
+ * @returns {models.ExitClass} 退出原因分类
+ */
+func classifyExit(state *os.ProcessState) (int, models.ExitClass) {
+	if state == nil {
+		return -1, models.ExitError
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal()), models.ExitSignal
+	}
+	if state.ExitCode() == 0 {
+		return 0, models.ExitNormal
+	}
+	return state.ExitCode(), models.ExitError
+}
+
+// 读取OOM killer杀死进程的累计次数，测试时可替换
+var oomKillCount = readOOMKillCount
+
+// 记录进程的退出码和退出原因分类，包括是否被OOM killer杀死
+func (pi *ProcessInstance) recordExitClass(state *os.ProcessState) {
+	pi.ExitCode, pi.ExitClass = classifyExit(state)
+	if pi.isOOMKilled() {
+		pi.ExitClass = models.ExitOOM
+	}
+}
+
+/**
+ * isOOMKilled 判断被SIGKILL杀死的进程是否是被OOM killer杀死的
+ * @description
+ * - OOM killer使用SIGKILL杀进程，同时会增加OOM计数
+ * - 进程运行期间计数增加了，并且进程是被SIGKILL杀死的，则认为进程被OOM杀死
+ * - 这是启发式的判断，同一cgroup中的其它进程恰好被OOM杀死时可能误判
+ */
+func (pi *ProcessInstance) isOOMKilled() bool {
+	if pi.ExitClass != models.ExitSignal || pi.ExitCode != 128+int(syscall.SIGKILL) {
+		return false
+	}
+	if pi.oomKills < 0 {
+		return false
+	}
+	return oomKillCount() > pi.oomKills
+}
+
 /**
  * watchProcess 监控进程状态的协程
  * @param {ProcessInstance} pi - 进程实例
@@ -244,14 +530,28 @@ func getReason(status models.RunStatus) string {
  * - 更新进程状态并记录退出原因
  */
 func (pi *ProcessInstance) watchProcess() {
-	_, err := pi.process.Wait()
+	state, err := pi.process.Wait()
 
 	pi.mutex.Lock()
 	defer pi.mutex.Unlock()
 
+	pi.releaseResourceLimits()
 	if pi.watcher.onChanged == nil { //只有onChanged!=nil才会进入watchProcess，但存在中途修改的可能性
 		return
 	}
+	if pi.watcher.disabled {
+		// 停止监测期间退出，只记录退出信息，不回调也不重启
+		if pi.Status != models.StatusRunning {
+			return
+		}
+		pi.LastExitTime = time.Now()
+		pi.recordExitClass(state)
+		pi.LastExitReason = "exited while detached"
+		pi.Status = models.StatusExited
+		pi.process = nil
+		logger.Infof("Detached process '%s' exited", pi.Title)
+		return
+	}
 
 	if pi.Status == models.StatusStopped || pi.Status == models.StatusError {
 		logger.Infof("Process '%s' stopped by %s", pi.Title, getReason(pi.Status))
@@ -259,9 +559,16 @@ func (pi *ProcessInstance) watchProcess() {
 		return
 	}
 	pi.LastExitTime = time.Now()
-	if err != nil {
+	pi.recordExitClass(state)
+	if pi.ExitClass == models.ExitOOM {
+		logger.Warnf("Process '%s' (PID: %d) was killed by the OOM killer", pi.Title, pi.Pid())
+		pi.LastExitReason = "killed by OOM killer"
+	} else if err != nil {
 		logger.Errorf("Process '%s' (PID: %d) exited with error: %v", pi.Title, pi.Pid(), err)
 		pi.LastExitReason = fmt.Sprintf("exited with error: %v", err)
+	} else if pi.ExitClass != models.ExitNormal {
+		logger.Errorf("Process '%s' (PID: %d) exited with error: %s", pi.Title, pi.Pid(), state)
+		pi.LastExitReason = fmt.Sprintf("exited with error: %s", state)
 	} else {
 		logger.Infof("Process '%s' (PID: %d) exited normally", pi.Title, pi.Pid())
 		pi.LastExitReason = "exited normally"
@@ -296,7 +603,7 @@ func (pi *ProcessInstance) autoRestart() {
 		pi.mutex.Lock()
 		defer pi.mutex.Unlock()
 
-		if pi.watcher.onChanged == nil { //只有onChanged!=nil才会进入watchProcess，但存在中途修改的可能性
+		if pi.watcher.onChanged == nil || pi.watcher.disabled { //只有onChanged!=nil才会进入watchProcess，但存在中途修改的可能性
 			return
 		}
 		if pi.Status == models.StatusStopped || pi.Status == models.StatusError {
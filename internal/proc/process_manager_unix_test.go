@@ -0,0 +1,157 @@
+//go:build linux || darwin
+
+package proc
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/models"
+)
+
+// 替换OOM计数，进程运行期间计数增加时，被SIGKILL杀死的进程视为被OOM杀死
+func fakeOOMKillCount(t *testing.T) *atomic.Int64 {
+	t.Helper()
+	var count atomic.Int64
+	old := oomKillCount
+	oomKillCount = count.Load
+	t.Cleanup(func() { oomKillCount = old })
+	return &count
+}
+
+func TestWatchProcessDetectsOOM(t *testing.T) {
+	count := fakeOOMKillCount(t)
+	pi := NewProcessInstance("oom test", "sleep", "sleep", []string{"30"})
+	changed := make(chan struct{}, 1)
+	// 不自动重启的进程同样要识别OOM
+	pi.SetWatcher(0, func(*ProcessInstance) { changed <- struct{}{} })
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 加锁读取pid，监测协程在进程退出后会清除进程对象
+	pid := pi.GetDetail().Pid
+	count.Add(1)
+	syscall.Kill(pid, syscall.SIGKILL)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher isn't called after the process is killed")
+	}
+	if detail := pi.GetDetail(); detail.ExitClass != models.ExitOOM {
+		t.Errorf("exit class = %s, want oom", detail.ExitClass)
+	}
+}
+
+func TestWaitProcessDetectsOOM(t *testing.T) {
+	count := fakeOOMKillCount(t)
+	pi := NewProcessInstance("oom test", "sleep", "sleep", []string{"30"})
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	count.Add(1)
+	syscall.Kill(pi.Pid(), syscall.SIGKILL)
+	if _, err := pi.WaitProcess(context.Background()); err == nil {
+		t.Fatal("WaitProcess should fail for a killed process")
+	}
+	if detail := pi.GetDetail(); detail.ExitClass != models.ExitOOM || detail.LastExitReason != "killed by OOM killer" {
+		t.Errorf("exit class = %s, reason = %q", detail.ExitClass, detail.LastExitReason)
+	}
+}
+
+func TestWaitProcessSigkillWithoutOOM(t *testing.T) {
+	fakeOOMKillCount(t)
+	pi := NewProcessInstance("kill test", "sleep", "sleep", []string{"30"})
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	syscall.Kill(pi.Pid(), syscall.SIGKILL)
+	pi.WaitProcess(context.Background())
+	if detail := pi.GetDetail(); detail.ExitClass != models.ExitSignal {
+		t.Errorf("exit class = %s, want signal", detail.ExitClass)
+	}
+}
+
+func TestWaitProcessTimeoutKillsGroup(t *testing.T) {
+	dir := t.TempDir()
+	alive := filepath.Join(dir, "alive")
+	// shell启动的子进程稍后写文件，超时后只杀死shell时子进程会残留并写入文件
+	pi := NewProcessInstance("group test", "sh", "(sleep 1; touch "+alive+") & wait", nil)
+	pi.Shell = true
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := pi.WaitProcess(ctx); err == nil {
+		t.Fatal("WaitProcess() = nil, want timeout error")
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(alive); err == nil {
+		t.Error("child of the shell survives the timeout")
+	}
+}
+
+// 取一个非root用户，以其身份运行进程需要root权限
+func requireRootWithUser(t *testing.T) *user.User {
+	t.Helper()
+	if os.Getuid() != 0 {
+		t.Skip("running process as another user requires root")
+	}
+	u, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("user nobody doesn't exist: %v", err)
+	}
+	return u
+}
+
+// 创建所有用户可写的临时目录，nobody才能写入输出文件
+func writableTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	// t.TempDir的上级目录只有属主可进入
+	for _, d := range []string{filepath.Dir(dir), dir} {
+		if err := os.Chmod(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestProcessRunsAsUser(t *testing.T) {
+	u := requireRootWithUser(t)
+	dir := writableTempDir(t)
+	out := filepath.Join(dir, "id")
+	pi := NewProcessInstance("uid test", "sh", "sh", []string{"-c", "echo $(id -u) $(id -g) > " + out})
+	pi.User = "nobody"
+	if err := pi.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pi.WaitProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(data)), u.Uid+" "+u.Gid; got != want {
+		t.Errorf("process runs as uid/gid %s, want %s", got, want)
+	}
+}
+
+func TestSetCredentialUnknownUser(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := SetCredential(cmd, "costrict-no-such-user", ""); err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Errorf("err = %v, want user doesn't exist", err)
+	}
+	if err := SetCredential(cmd, "", "costrict-no-such-group"); err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Errorf("err = %v, want group doesn't exist", err)
+	}
+}
@@ -0,0 +1,319 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/rpc"
+
+	"golang.org/x/net/websocket"
+)
+
+const apiPrefix = "/costrict/api/v1"
+
+// APIError keeper API返回的错误响应
+type APIError struct {
+	StatusCode int
+	Code       string // 错误码，见models.ErrorResponse
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Costrict API returned error(%d): %s", e.StatusCode, e.Message)
+}
+
+// Client 类型化的keeper API客户端，封装路径拼接、错误和响应体的解析
+type Client struct {
+	http rpc.HTTPClient
+}
+
+/**
+ * Create typed client of keeper API
+ * @param {rpc.HTTPClient} client - Raw HTTP client, rpc.NewHTTPClient(nil) is used if nil
+ * @returns {Client} Returns the typed client
+ * @example
+ * client := keeper.NewClient(nil)
+ * services, err := client.ListServices()
+ */
+func NewClient(client rpc.HTTPClient) *Client {
+	if client == nil {
+		client = rpc.NewHTTPClient(nil)
+	}
+	return &Client{http: client}
+}
+
+func (c *Client) Close() error {
+	return c.http.Close()
+}
+
+// decode 检查响应状态，成功时把响应体解析到result(result为nil时忽略响应体)
+func decode(resp *rpc.HTTPResponse, err error, result interface{}) error {
+	if err != nil {
+		return fmt.Errorf("failed to call costrict API: %w", err)
+	}
+	if resp.Error != "" || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Code: resp.Code, Message: resp.Error}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Body, result); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) get(path string, result interface{}) error {
+	resp, err := c.http.Get(apiPrefix+path, nil)
+	return decode(resp, err, result)
+}
+
+// getRaw 检查响应状态，成功时返回未解析的响应体
+func (c *Client) getRaw(path string, params map[string]interface{}) ([]byte, error) {
+	resp, err := c.http.Get(apiPrefix+path, params)
+	if err := decode(resp, err, nil); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) post(path string, data interface{}, result interface{}) error {
+	resp, err := c.http.Post(apiPrefix+path, data)
+	return decode(resp, err, result)
+}
+
+func (c *Client) delete(path string, result interface{}) error {
+	resp, err := c.http.Delete(apiPrefix+path, nil)
+	return decode(resp, err, result)
+}
+
+func (c *Client) ListServices() ([]models.ServiceDetail, error) {
+	var services []models.ServiceDetail
+	err := c.get("/services", &services)
+	return services, err
+}
+
+// ListServicesFormatted 获取服务端按format(json/table)格式化好的服务列表
+func (c *Client) ListServicesFormatted(format string) ([]byte, error) {
+	return c.getRaw("/services", map[string]interface{}{"format": format})
+}
+
+func (c *Client) GetService(name string) (*models.ServiceDetail, error) {
+	var detail models.ServiceDetail
+	if err := c.get(fmt.Sprintf("/services/%s", name), &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *Client) StartService(name string, force bool) (*models.ServiceDetail, error) {
+	var detail models.ServiceDetail
+	path := fmt.Sprintf("/services/%s/start", name)
+	if force {
+		path += "?force=true"
+	}
+	if err := c.post(path, nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *Client) StopService(name string) error {
+	return c.post(fmt.Sprintf("/services/%s/stop", name), nil, nil)
+}
+
+func (c *Client) RestartService(name string) (*models.ServiceDetail, error) {
+	var detail models.ServiceDetail
+	if err := c.post(fmt.Sprintf("/services/%s/restart", name), nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *Client) DetachService(name string) (*models.ServiceDetail, error) {
+	var detail models.ServiceDetail
+	if err := c.post(fmt.Sprintf("/services/%s/detach", name), nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *Client) AttachService(name string) (*models.ServiceDetail, error) {
+	var detail models.ServiceDetail
+	if err := c.post(fmt.Sprintf("/services/%s/attach", name), nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *Client) ResetRestartCount(name string) (*models.ServiceDetail, error) {
+	var detail models.ServiceDetail
+	if err := c.post(fmt.Sprintf("/services/%s/reset-restarts", name), nil, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+func (c *Client) RestartServicesByTag(tag string) ([]models.BatchResult, error) {
+	var results []models.BatchResult
+	err := c.post("/services/batch/restart", models.BatchRequest{Tag: tag}, &results)
+	return results, err
+}
+
+func (c *Client) OperateGroup(group, action string) ([]models.BatchResult, error) {
+	var results []models.BatchResult
+	err := c.post(fmt.Sprintf("/groups/%s/%s", url.PathEscape(group), action), nil, &results)
+	return results, err
+}
+
+func (c *Client) OpenServiceTunnel(name string) (*models.TunnelDetail, error) {
+	var tun models.TunnelDetail
+	if err := c.post(fmt.Sprintf("/services/%s/open", name), nil, &tun); err != nil {
+		return nil, err
+	}
+	return &tun, nil
+}
+
+func (c *Client) ReopenServiceTunnel(name string) (*models.TunnelDetail, error) {
+	var tun models.TunnelDetail
+	if err := c.post(fmt.Sprintf("/services/%s/reopen", name), nil, &tun); err != nil {
+		return nil, err
+	}
+	return &tun, nil
+}
+
+func (c *Client) CloseServiceTunnel(name string) error {
+	return c.post(fmt.Sprintf("/services/%s/close", name), nil, nil)
+}
+
+func (c *Client) OpenTunnel(appName string, port int) (*models.TunnelDetail, error) {
+	var tun models.TunnelDetail
+	req := &models.TunnelRequest{AppName: appName, LocalPort: port}
+	if err := c.post("/tunnels", req, &tun); err != nil {
+		return nil, err
+	}
+	return &tun, nil
+}
+
+func (c *Client) CloseTunnel(appName string, port int) error {
+	return c.delete(fmt.Sprintf("/tunnels/%s/%d", appName, port), nil)
+}
+
+/**
+ * Upgrade a component via the bulk upgrade API
+ * @param {string} name - Component name
+ * @param {string} version - Target version, the newest version if empty
+ * @returns {models.ComponentUpgradeResult} Returns upgrade result of the component
+ */
+func (c *Client) UpgradeComponent(name, version string) (*models.ComponentUpgradeResult, error) {
+	req := models.ComponentUpgradeRequest{Names: []string{name}}
+	if version != "" {
+		req.Versions = map[string]string{name: version}
+	}
+	results, err := c.UpgradeComponents(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no upgrade result of component '%s'", name)
+	}
+	return &results[0], nil
+}
+
+func (c *Client) ListComponents() ([]models.ComponentDetail, error) {
+	var components []models.ComponentDetail
+	err := c.get("/components", &components)
+	return components, err
+}
+
+/**
+ * Upgrade components via the bulk upgrade API
+ * @param {models.ComponentUpgradeRequest} req - Components to upgrade and their target versions, all if empty
+ * @returns {[]models.ComponentUpgradeResult} Returns upgrade result of each component
+ */
+func (c *Client) UpgradeComponents(req models.ComponentUpgradeRequest) ([]models.ComponentUpgradeResult, error) {
+	var results []models.ComponentUpgradeResult
+	err := c.post("/components/upgrade", req, &results)
+	return results, err
+}
+
+/**
+ * Subscribe state change events of the costrict server
+ * @param {rpc.HTTPConfig} config - Client configuration, rpc.DefaultHTTPConfig() is used if nil
+ * @returns {<-chan models.Event} Returns channel of events, closed when the connection is closed
+ * @returns {func()} Returns function which closes the subscription
+ * @returns {error} Returns error if the events endpoint can't be connected
+ */
+func SubscribeEvents(config *rpc.HTTPConfig) (<-chan models.Event, func(), error) {
+	ws, err := rpc.DialWebSocket(config, apiPrefix+"/events")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to subscribe events: %w", err)
+	}
+	events := make(chan models.Event, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(events)
+		for {
+			var event models.Event
+			if err := websocket.JSON.Receive(ws, &event); err != nil {
+				return
+			}
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			ws.Close()
+		})
+	}
+	return events, cancel, nil
+}
+
+func (c *Client) Check() (*models.CheckResponse, error) {
+	var result models.CheckResponse
+	if err := c.post("/check", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) State() (*models.ServerState, error) {
+	var state models.ServerState
+	if err := c.get("/state", &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (c *Client) SetMaintenance(on bool) error {
+	mode := "off"
+	if on {
+		mode = "on"
+	}
+	return c.post("/maintenance", models.MaintenanceRequest{Mode: mode}, nil)
+}
+
+func (c *Client) SyncConfig() (*models.ConfigSyncResult, error) {
+	var result models.ConfigSyncResult
+	if err := c.post("/config/sync", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Reload() (*models.SpecDiff, error) {
+	var diff models.SpecDiff
+	if err := c.post("/reload", nil, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
@@ -0,0 +1,202 @@
+package keeper
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/rpc"
+)
+
+/**
+ * Start a mock keeper API server
+ * @param {map[string]http.HandlerFunc} routes - Handlers keyed by "METHOD path", path excludes the API prefix
+ * @returns {Client} Returns a client connected to the mock server
+ * @returns {*[]string} Returns requests received by the server, as "METHOD path?query"
+ */
+func newMockKeeper(t *testing.T, routes map[string]http.HandlerFunc) (*Client, *[]string) {
+	t.Helper()
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+		key := r.Method + " " + path
+		if r.URL.RawQuery != "" {
+			requests = append(requests, key+"?"+r.URL.RawQuery)
+		} else {
+			requests = append(requests, key)
+		}
+		handler, ok := routes[key]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, models.ErrorResponse{Code: "not_found", Error: "no route " + key})
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	client := NewClient(rpc.NewHTTPClient(&rpc.HTTPConfig{
+		Address: strings.TrimPrefix(srv.URL, "http://"),
+		Network: "tcp",
+		Timeout: 5 * time.Second,
+		BaseURL: "http://localhost",
+	}))
+	t.Cleanup(func() { client.Close() })
+	return client, &requests
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func respond(v interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func TestClientServices(t *testing.T) {
+	client, requests := newMockKeeper(t, map[string]http.HandlerFunc{
+		"GET /services": respond([]models.ServiceDetail{
+			{Name: "svc-a", Port: 8001, Status: models.StatusRunning},
+			{Name: "svc-b", Port: 8002, Status: models.StatusStopped},
+		}),
+		"GET /services/svc-a":        respond(models.ServiceDetail{Name: "svc-a", Port: 8001}),
+		"POST /services/svc-a/start": respond(models.ServiceDetail{Name: "svc-a", Status: models.StatusRunning}),
+		"POST /services/svc-a/stop":  respond(struct{}{}),
+	})
+
+	services, err := client.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Name != "svc-a" || services[1].Port != 8002 {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	detail, err := client.GetService("svc-a")
+	if err != nil || detail.Port != 8001 {
+		t.Fatalf("GetService = %+v, %v", detail, err)
+	}
+	detail, err = client.StartService("svc-a", true)
+	if err != nil || detail.Status != models.StatusRunning {
+		t.Fatalf("StartService = %+v, %v", detail, err)
+	}
+	if err := client.StopService("svc-a"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"GET /services",
+		"GET /services/svc-a",
+		"POST /services/svc-a/start?force=true",
+		"POST /services/svc-a/stop",
+	}
+	if strings.Join(*requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected requests %v, got %v", expected, *requests)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	client, _ := newMockKeeper(t, map[string]http.HandlerFunc{
+		"POST /services/svc-a/start": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusConflict, models.ErrorResponse{Code: "service.disabled", Error: "service is disabled"})
+		},
+		"GET /services/svc-b": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	})
+
+	_, err := client.StartService("svc-a", false)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusConflict || apiErr.Code != "service.disabled" || apiErr.Message != "service is disabled" {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+	// 没有响应体的错误使用HTTP状态作为错误信息
+	if _, err := client.GetService("svc-b"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected APIError with status 500, got %v", err)
+	}
+}
+
+func TestClientCheck(t *testing.T) {
+	client, requests := newMockKeeper(t, map[string]http.HandlerFunc{
+		"POST /check": respond(models.CheckResponse{OverallStatus: "healthy", TotalChecks: 3, PassedChecks: 3}),
+	})
+	result, err := client.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OverallStatus != "healthy" || result.PassedChecks != 3 {
+		t.Errorf("unexpected check result: %+v", result)
+	}
+	if len(*requests) != 1 || (*requests)[0] != "POST /check" {
+		t.Errorf("unexpected requests: %v", *requests)
+	}
+}
+
+func TestClientUpgradeComponent(t *testing.T) {
+	var received models.ComponentUpgradeRequest
+	client, _ := newMockKeeper(t, map[string]http.HandlerFunc{
+		"POST /components/upgrade": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&received)
+			writeJSON(w, http.StatusOK, []models.ComponentUpgradeResult{
+				{Name: "alpha", OldVersion: "1.0.0", NewVersion: "1.2.0"},
+			})
+		},
+	})
+	result, err := client.UpgradeComponent("alpha", "1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NewVersion != "1.2.0" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(received.Names) != 1 || received.Names[0] != "alpha" || received.Versions["alpha"] != "1.2.0" {
+		t.Errorf("unexpected request: %+v", received)
+	}
+}
+
+func TestClientOperateGroup(t *testing.T) {
+	var escaped string
+	client, requests := newMockKeeper(t, map[string]http.HandlerFunc{
+		"POST /groups/ai-tools/start": respond([]models.BatchResult{
+			{Name: "svc-a", Status: "success"},
+			{Name: "svc-b", Status: "failed", Error: "port in use"},
+		}),
+		"POST /groups/ai tools/v2/stop": func(w http.ResponseWriter, r *http.Request) {
+			escaped = r.URL.EscapedPath()
+			writeJSON(w, http.StatusOK, []models.BatchResult{})
+		},
+	})
+
+	results, err := client.OperateGroup("ai-tools", "start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Status != "failed" || results[1].Error != "port in use" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// 组名中的特殊字符被转义，不会改变请求的路径层次
+	if _, err := client.OperateGroup("ai tools/v2", "stop"); err != nil {
+		t.Fatal(err)
+	}
+	if want := apiPrefix + "/groups/ai%20tools%2Fv2/stop"; escaped != want {
+		t.Errorf("escaped path = %q, want %q", escaped, want)
+	}
+
+	_, err = client.OperateGroup("missing", "restart")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, want 404 APIError", err)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("requests = %v", *requests)
+	}
+}
@@ -2,6 +2,9 @@ package rpc
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/models"
 	"encoding/json"
@@ -31,6 +34,16 @@ type HTTPConfig struct {
 	Network string        //unix,tcp...
 	Timeout time.Duration // 默认超时时间
 	BaseURL string        // 基础URL
+	Token   string        // 访问keeper API的令牌，未配置认证时为空
+}
+
+// 访问keeper API的令牌，命令行程序加载配置后通过SetApiToken设置
+var apiToken string
+
+// SetApiToken 设置DefaultHTTPConfig使用的API令牌
+// rpc作为客户端库不自行加载配置，避免调用方的目录等设置被覆盖
+func SetApiToken(token string) {
+	apiToken = token
 }
 
 // DefaultHTTPConfig 返回默认HTTP客户端配置
@@ -40,6 +53,7 @@ func DefaultHTTPConfig() *HTTPConfig {
 		Network: "unix",
 		Timeout: 5 * time.Second,
 		BaseURL: "http://localhost",
+		Token:   apiToken,
 	}
 	// 检查socket文件是否存在
 	if _, err := os.Stat(c.Address); os.IsNotExist(err) {
@@ -58,25 +72,37 @@ type HTTPResponse struct {
 	StatusCode int                 `json:"status_code"`
 	Headers    map[string][]string `json:"headers"`
 	Body       []byte              `json:"body"`
+	Code       string              `json:"code"` // 错误码，见models.ErrorResponse
 	Error      string              `json:"error"`
 }
 
-// buildURL 构建完整的URL
+// buildURL 构建完整的URL，path是已转义的路径，可以带有查询参数
 func buildURL(baseURL, path string, params map[string]interface{}) (string, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", fmt.Errorf("invalid base URL: %w", err)
 	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
 
-	// 添加路径
+	// 添加路径，保留path中已转义的字符(如名字中的/转义成的%2F)
 	if u.Path == "" {
-		u.Path = path
+		u.Path = ref.Path
+		u.RawPath = ref.RawPath
 	} else {
 		// 确保路径以/结尾，然后拼接
+		rawPath := u.EscapedPath()
 		if !strings.HasSuffix(u.Path, "/") {
 			u.Path += "/"
+			rawPath += "/"
 		}
-		u.Path += path
+		u.Path += ref.Path
+		u.RawPath = rawPath + ref.EscapedPath()
+	}
+	if ref.RawQuery != "" {
+		u.RawQuery = ref.RawQuery
 	}
 
 	// 添加查询参数
@@ -118,17 +144,49 @@ func serializeData(data interface{}) (io.Reader, error) {
 	return bytes.NewReader(jsonData), nil
 }
 
+// readBody 读取响应体，按Content-Encoding解压gzip/deflate压缩的响应
+// 空的响应体(如204)即使声明了压缩也不解压
+func readBody(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		// HTTP的deflate是zlib格式，但有些服务端发送的是不带zlib头的原始deflate数据
+		if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			defer zr.Close()
+			reader = zr
+		} else {
+			fr := flate.NewReader(bytes.NewReader(data))
+			defer fr.Close()
+			reader = fr
+		}
+	default:
+		return data, nil
+	}
+	return io.ReadAll(reader)
+}
+
 // deserializeResponse 反序列化响应数据
 func deserializeResponse(resp *http.Response) (*HTTPResponse, error) {
 	httpResp := &HTTPResponse{
 		StatusCode: resp.StatusCode,
 		Headers:    resp.Header,
 	}
-	body, err := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	body, err := readBody(resp)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
-	defer resp.Body.Close()
 	httpResp.Body = body
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		return httpResp, nil
@@ -142,6 +200,7 @@ func deserializeResponse(resp *http.Response) (*HTTPResponse, error) {
 		if err := json.Unmarshal(body, &errBody); err != nil {
 			httpResp.Error = err.Error()
 		} else {
+			httpResp.Code = errBody.Code
 			httpResp.Error = errBody.Error
 		}
 	}
@@ -156,7 +215,7 @@ func deserializeResponse(resp *http.Response) (*HTTPResponse, error) {
  */
 func getSocketPath(socketName string, socketDir string) string {
 	if socketDir == "" {
-		socketDir = filepath.Join(env.CostrictDir, "run")
+		socketDir = env.GetRunDir()
 	}
 	return filepath.Join(socketDir, socketName)
 }
@@ -103,6 +103,7 @@ func (c *httpClient) Get(path string, params map[string]interface{}) (*HTTPRespo
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	c.setHeaders(req)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -169,6 +170,7 @@ func (c *httpClient) Post(path string, data interface{}) (*HTTPResponse, error)
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	c.setHeaders(req)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -235,6 +237,7 @@ func (c *httpClient) Put(path string, data interface{}) (*HTTPResponse, error) {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	c.setHeaders(req)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -300,6 +303,7 @@ func (c *httpClient) Patch(path string, data interface{}) (*HTTPResponse, error)
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	c.setHeaders(req)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -352,6 +356,7 @@ func (c *httpClient) Delete(path string, params map[string]interface{}) (*HTTPRe
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	c.setHeaders(req)
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -389,3 +394,17 @@ func (c *httpClient) Close() error {
 	logger.Debugf("HTTP client connection closed")
 	return nil
 }
+
+// setHeaders 设置所有请求共用的头：声明接受压缩的响应，配置了API令牌时携带令牌
+func (c *httpClient) setHeaders(req *http.Request) {
+	// 显式设置后transport不再自动解压，由deserializeResponse统一处理gzip和deflate
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	c.setAuth(req)
+}
+
+// setAuth 配置了API令牌时，在请求中携带令牌
+func (c *httpClient) setAuth(req *http.Request) {
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+}
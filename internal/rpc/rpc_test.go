@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/env"
+)
+
+func TestDefaultHTTPConfigToken(t *testing.T) {
+	env.CostrictDir = t.TempDir()
+	env.RunDir = ""
+	// 配置文件中的目录设置不应被客户端库加载
+	configPath := filepath.Join(env.CostrictDir, "config", "costrict.json")
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"api":{"token":"file-token"},"dirs":{"run":"custom-run"}}`), 0644)
+
+	SetApiToken("cli-token")
+	t.Cleanup(func() { SetApiToken("") })
+	cfg := DefaultHTTPConfig()
+	if cfg.Token != "cli-token" {
+		t.Errorf("token = %q, want cli-token", cfg.Token)
+	}
+	if env.RunDir != "" {
+		t.Errorf("DefaultHTTPConfig loaded the config file, run dir = %q", env.RunDir)
+	}
+}
+
+func TestHTTPClientSendsToken(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(&HTTPConfig{
+		Address: strings.TrimPrefix(srv.URL, "http://"),
+		Network: "tcp",
+		Timeout: 5 * time.Second,
+		BaseURL: "http://localhost",
+		Token:   "secret",
+	})
+	defer client.Close()
+	if _, err := client.Post("/costrict/api/v1/services/x/restart", nil); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", auth)
+	}
+}
+
+func TestBuildURL(t *testing.T) {
+	cases := []struct {
+		base   string
+		path   string
+		params map[string]interface{}
+		want   string
+	}{
+		{"http://localhost", "/api/services", nil, "http://localhost/api/services"},
+		{"http://localhost", "/api/services/a/start?force=true", nil, "http://localhost/api/services/a/start?force=true"},
+		{"http://localhost", "/api/services?force=true", map[string]interface{}{"format": "json"},
+			"http://localhost/api/services?force=true&format=json"},
+		{"http://localhost", "/api/groups/a%2Fb/start", nil, "http://localhost/api/groups/a%2Fb/start"},
+		{"http://localhost/prefix", "api/groups/a%20b", nil, "http://localhost/prefix/api/groups/a%20b"},
+	}
+	for _, c := range cases {
+		got, err := buildURL(c.base, c.path, c.params)
+		if err != nil {
+			t.Errorf("buildURL(%q, %q) failed: %v", c.base, c.path, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("buildURL(%q, %q) = %q, want %q", c.base, c.path, got, c.want)
+		}
+	}
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server) HTTPClient {
+	t.Helper()
+	client := NewHTTPClient(&HTTPConfig{
+		Address: strings.TrimPrefix(srv.URL, "http://"),
+		Network: "tcp",
+		Timeout: 5 * time.Second,
+		BaseURL: "http://localhost",
+	})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestHTTPClientDecodesResponse(t *testing.T) {
+	const body = `{"name":"costrict","status":"running"}`
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw-deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+	}
+	var acceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		encoding := r.URL.Query().Get("encoding")
+		if encoding == "" {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", strings.TrimPrefix(encoding, "raw-"))
+		if r.URL.Query().Get("empty") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		cw := compress[encoding](w)
+		cw.Write([]byte(body))
+		cw.Close()
+	}))
+	defer srv.Close()
+	client := newTestClient(t, srv)
+
+	for _, encoding := range []string{"", "gzip", "deflate", "raw-deflate"} {
+		resp, err := client.Get("/costrict/api/v1/services/costrict", map[string]interface{}{"encoding": encoding})
+		if err != nil {
+			t.Fatalf("encoding %q: %v", encoding, err)
+		}
+		if string(resp.Body) != body {
+			t.Errorf("encoding %q: body = %q, want %q", encoding, resp.Body, body)
+		}
+	}
+	if !strings.Contains(acceptEncoding, "gzip") {
+		t.Errorf("Accept-Encoding = %q, want gzip", acceptEncoding)
+	}
+
+	// 声明了压缩的空响应体不解压
+	resp, err := client.Get("/costrict/api/v1/services/costrict", map[string]interface{}{"encoding": "gzip", "empty": "1"})
+	if err != nil {
+		t.Fatalf("empty gzip body: %v", err)
+	}
+	if resp.StatusCode != http.StatusNoContent || len(resp.Body) != 0 {
+		t.Errorf("empty gzip body: status = %d, body = %q", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+/**
+ * Open a WebSocket connection to the costrict server
+ * @param {HTTPConfig} config - Client configuration, DefaultHTTPConfig() is used if nil
+ * @param {string} path - API endpoint path, such as "/costrict/api/v1/events"
+ * @returns {websocket.Conn} Returns the connection, the caller closes it
+ * @returns {error} Returns error if the server can't be connected or the handshake fails
+ * @description
+ * - Connects through the same unix socket/tcp address as HTTPClient, and carries the API token
+ */
+func DialWebSocket(config *HTTPConfig, path string) (*websocket.Conn, error) {
+	if config == nil {
+		config = DefaultHTTPConfig()
+	}
+	location, err := buildURL(strings.Replace(config.BaseURL, "http", "ws", 1), path, nil)
+	if err != nil {
+		return nil, err
+	}
+	wsConfig, err := websocket.NewConfig(location, config.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket config: %w", err)
+	}
+	if config.Token != "" {
+		wsConfig.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+	conn, err := net.DialTimeout(config.Network, config.Address, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := websocket.NewClient(wsConfig, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %w", err)
+	}
+	return ws, nil
+}
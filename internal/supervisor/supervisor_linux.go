@@ -0,0 +1,118 @@
+//go:build linux
+
+package supervisor
+
+import (
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"costrict-keeper/internal/logger"
+)
+
+/**
+ * Check if the process is supervised by systemd with Type=notify
+ * @returns {bool} Returns true if NOTIFY_SOCKET is set
+ */
+func Detected() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+/**
+ * Register the process with the supervisor
+ * @param {chan<- os.Signal} quit - Channel stop requests are delivered to
+ * @description
+ * - Nothing to do for systemd, which stops the process by SIGTERM
+ */
+func Start(quit chan<- os.Signal) {
+}
+
+/**
+ * Tell the supervisor that startup is finished
+ * @description
+ * - Sends READY=1 to systemd, does nothing if not supervised
+ */
+func Ready() {
+	notify("READY=1")
+}
+
+/**
+ * Tell the supervisor that the process is alive
+ * @description
+ * - Sends WATCHDOG=1 to systemd, should be called every WatchdogInterval()/2
+ */
+func Watchdog() {
+	notify("WATCHDOG=1")
+}
+
+/**
+ * Get the watchdog timeout required by the supervisor
+ * @returns {time.Duration} Returns WATCHDOG_USEC of systemd, 0 if the watchdog isn't enabled for this process
+ */
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+/**
+ * Tell the supervisor that the process is shutting down
+ * @description
+ * - Sends STOPPING=1 to systemd
+ */
+func Stopping() {
+	notify("STOPPING=1")
+}
+
+/**
+ * Tell the supervisor that shutdown is finished, the process exits right after
+ * @description
+ * - Nothing to do for systemd, which watches the process exit
+ */
+func Stopped() {
+}
+
+// systemd传给keeper的通知相关环境变量，不能被服务进程继承
+var notifyEnvs = []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"}
+
+/**
+ * Get the environment of child processes
+ * @returns {[]string} Returns environment of the keeper without the variables of systemd notify
+ * @description
+ * - A child speaking sd_notify would otherwise send READY/WATCHDOG on behalf of the keeper
+ */
+func ChildEnv() []string {
+	var envs []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(notifyEnvs, name) {
+			envs = append(envs, kv)
+		}
+	}
+	return envs
+}
+
+// 按sd_notify协议向NOTIFY_SOCKET发送状态，'@'开头的是抽象套接字，由net包转换
+func notify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		logger.Warnf("Connect to notify socket '%s' failed: %v", socket, err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		logger.Warnf("Send '%s' to notify socket '%s' failed: %v", state, socket, err)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !linux && !windows
+
+package supervisor
+
+import (
+	"os"
+	"time"
+)
+
+// Detected 当前平台不支持进程管理器的就绪通知
+func Detected() bool {
+	return false
+}
+
+func Start(quit chan<- os.Signal) {
+}
+
+func Ready() {
+}
+
+func Watchdog() {
+}
+
+func WatchdogInterval() time.Duration {
+	return 0
+}
+
+func Stopping() {
+}
+
+func Stopped() {
+}
+
+func ChildEnv() []string {
+	return nil
+}
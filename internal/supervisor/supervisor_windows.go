@@ -0,0 +1,134 @@
+//go:build windows
+
+package supervisor
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"costrict-keeper/internal/logger"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// 启动过程中向SCM报告的预计剩余时间，超过时SCM认为服务启动失败
+const startWaitHint = 5 * time.Minute
+
+// 进程退出前等待SCM收到Stopped状态的最长时间
+const stopWaitTimeout = 5 * time.Second
+
+/**
+ * Handler of the Windows service control manager
+ * @property {chan<- os.Signal} quit - Stop requests of SCM are delivered to it as SIGTERM
+ * @property {chan struct{}} ready - Closed when startup is finished
+ * @property {chan struct{}} stopped - Closed when shutdown is finished
+ * @property {chan struct{}} done - Closed when svc.Run returns
+ */
+type scmHandler struct {
+	quit    chan<- os.Signal
+	ready   chan struct{}
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+var handler *scmHandler
+var readyOnce, stoppedOnce sync.Once
+
+/**
+ * Check if the process is started by the Windows service control manager
+ * @returns {bool} Returns true if running as a Windows service
+ */
+func Detected() bool {
+	yes, err := svc.IsWindowsService()
+	return err == nil && yes
+}
+
+/**
+ * Register the process with the service control manager
+ * @param {chan<- os.Signal} quit - Channel stop requests are delivered to
+ * @description
+ * - Must be called early, SCM fails the service if it doesn't connect in 30 seconds
+ * - Reports StartPending until Ready() is called
+ */
+func Start(quit chan<- os.Signal) {
+	if !Detected() {
+		return
+	}
+	handler = &scmHandler{
+		quit:    quit,
+		ready:   make(chan struct{}),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(handler.done)
+		if err := svc.Run("costrict", handler); err != nil {
+			logger.Errorf("Run as Windows service failed: %v", err)
+		}
+	}()
+}
+
+// Ready 向SCM报告服务已运行
+func Ready() {
+	if handler != nil {
+		readyOnce.Do(func() { close(handler.ready) })
+	}
+}
+
+// Watchdog SCM没有看门狗机制，什么也不做
+func Watchdog() {
+}
+
+// WatchdogInterval SCM没有看门狗机制，总是返回0
+func WatchdogInterval() time.Duration {
+	return 0
+}
+
+// ChildEnv SCM不通过环境变量与服务通信，子进程继承keeper的全部环境变量
+func ChildEnv() []string {
+	return nil
+}
+
+// Stopping 停止过程由Execute在收到停止请求时报告，什么也不做
+func Stopping() {
+}
+
+// Stopped 向SCM报告服务已停止，并等待SCM收到状态
+func Stopped() {
+	if handler == nil {
+		return
+	}
+	stoppedOnce.Do(func() { close(handler.stopped) })
+	select {
+	case <-handler.done:
+	case <-time.After(stopWaitTimeout):
+	}
+}
+
+func (h *scmHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.StartPending, WaitHint: uint32(startWaitHint / time.Millisecond)}
+	ready := h.ready
+	for {
+		select {
+		case <-ready:
+			s <- svc.Status{State: svc.Running, Accepts: accepts}
+			ready = nil
+		case <-h.stopped:
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending, WaitHint: uint32(stopWaitTimeout / time.Millisecond)}
+				select {
+				case h.quit <- syscall.SIGTERM:
+				default:
+				}
+			}
+		}
+	}
+}
@@ -3,7 +3,6 @@ package tun
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 
 	"costrict-keeper/internal/config"
@@ -18,6 +18,7 @@ import (
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/models"
 	"costrict-keeper/internal/proc"
+	"costrict-keeper/internal/supervisor"
 	"costrict-keeper/internal/utils"
 )
 
@@ -56,16 +57,45 @@ type TunnelCache struct {
 	Status      models.RunStatus  `json:"status"`      // tunnel status(running/stopped/error/exited)
 	CreatedTime time.Time         `json:"createdTime"` // creation time
 	Pid         int               `json:"pid"`         // process ID of the tunnel
+	ReopenCount int               `json:"reopenCount"` // times the tunnel was reopened
+}
+
+// 隧道状态发生变化(打开/关闭/重启)时调用的回调，用于刷新对外导出的信息
+var changedHook func()
+
+/**
+ * Set callback which is called when tunnel state is saved or removed
+ * @param {func()} hook - Callback function
+ */
+func SetChangedHook(hook func()) {
+	changedHook = hook
+}
+
+func notifyChanged() {
+	if changedHook != nil {
+		changedHook()
+	}
 }
 
 type TunnelInstance struct {
 	name        string                // service name
 	pairs       []models.PortPair     // Port pairs
-	status      models.RunStatus      // tunnel status(running/stopped/error/exited)
+	status      models.RunStatus      // tunnel status(running/stopped/error/exited/pending)
 	createdTime time.Time             // creation time
 	pi          *proc.ProcessInstance // Process cotun.exe
+	retryCount  int                   // 隧道管理服务不可达时，连续重试的次数
+	nextRetry   time.Time             // pending状态下，下次重试打开隧道的时间
+	reopenCount int                   // 隧道被重新打开的次数，用于发现不稳定的隧道
+	histMutex   sync.Mutex            // 保护history，检查和查询详情在不同协程
+	history     []models.HealthRecord // 最近的健康检查结果，最旧的在前
 }
 
+const (
+	retryBaseInterval = 5 * time.Second // pending隧道首次重试的间隔
+	retryMaxInterval  = 5 * time.Minute // pending隧道重试间隔的上限
+	healthHistorySize = 20              // 保留的健康检查结果条数
+)
+
 /**
  * Create new tunnel instance with default values
  * @param {string} name - Application name for the tunnel
@@ -75,6 +105,7 @@ type TunnelInstance struct {
  * - Creates new tunnel with specified name and port
  * - Initializes default values: mapping port 0, HTTP protocol, stopped status
  * - Sets creation time to current time and PID to 0
+ * - Restores reopen count from the cache file left by the previous run
  * - Tunnel is not started yet, just created with initial configuration
  * @example
  * tun := CreateTunnel("myapp", []int{8080})
@@ -90,9 +121,37 @@ func CreateTunnel(appName string, ports []int) *TunnelInstance {
 		status:      "exited",
 		createdTime: time.Now().Local(),
 	}
+	tun.reopenCount = tun.loadReopenCount()
 	return tun
 }
 
+func (tun *TunnelInstance) loadReopenCount() int {
+	data, err := os.ReadFile(tun.getCacheFname())
+	if err != nil {
+		return 0
+	}
+	var cache TunnelCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return 0
+	}
+	return cache.ReopenCount
+}
+
+/**
+ * Get times the tunnel was reopened
+ */
+func (tun *TunnelInstance) GetReopenCount() int {
+	return tun.reopenCount
+}
+
+/**
+ * Set times the tunnel was reopened, used to carry the count over to a new instance of the same tunnel
+ * @param {int} count - Reopen count
+ */
+func (tun *TunnelInstance) SetReopenCount(count int) {
+	tun.reopenCount = count
+}
+
 /**
  * Get title string for tunnel instance
  * @returns {string} Returns formatted title string
@@ -116,6 +175,7 @@ func (ti *TunnelInstance) toJSON() (string, error) {
 		Status:      ti.status,
 		CreatedTime: ti.createdTime,
 		Pairs:       ti.pairs,
+		ReopenCount: ti.reopenCount,
 	}
 	if ti.pi != nil {
 		cache.Pid = ti.pi.Pid()
@@ -140,7 +200,7 @@ func (ti *TunnelInstance) toJSON() (string, error) {
  * // Returns: /path/to/costrict/cache/tunnels/myapp-8080.json
  */
 func (tun *TunnelInstance) getCacheFname() string {
-	return filepath.Join(env.CostrictDir, "cache", "tunnels", fmt.Sprintf("%s.json", tun.name))
+	return filepath.Join(env.GetCacheDir(), "tunnels", fmt.Sprintf("%s.json", tun.name))
 }
 
 /**
@@ -177,22 +237,18 @@ func (tun *TunnelInstance) allocMappingPort() error {
 		return fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", config.Cloud().TunManagerUrl+"/ports", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	authKey, authValue := config.GetAuthHeader()
-	req.Header.Set(authKey, authValue)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Do(req)
+	targetURL := config.Cloud().TunManagerUrl + "/ports"
+	resp, err := config.DoCloudRequest(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", targetURL, bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
 	if err != nil {
-		logger.Errorf("allocMappingPort failed - URL: %s, Body: %s, Error: %v", req.URL.String(), string(jsonBody), err)
+		logger.Errorf("allocMappingPort failed - URL: %s, Body: %s, Error: %v", targetURL, string(jsonBody), err)
 		return fmt.Errorf("failed to request manager: %w", err)
 	}
 	defer resp.Body.Close()
@@ -202,7 +258,7 @@ func (tun *TunnelInstance) allocMappingPort() error {
 		if err != nil {
 			logger.Errorf("Failed to read response body: %v", err)
 		} else {
-			logger.Errorf("Failed to request URL: %s, Body: %s, Status Code: %d, Response Body: %s", req.URL.String(), string(jsonBody), resp.StatusCode, string(bodyBytes))
+			logger.Errorf("Failed to request URL: %s, Body: %s, Status Code: %d, Response Body: %s", targetURL, string(jsonBody), resp.StatusCode, string(bodyBytes))
 		}
 		return fmt.Errorf("manager returned error status code: %d", resp.StatusCode)
 	}
@@ -224,6 +280,31 @@ func (tun *TunnelInstance) GetPid() int {
 	return tun.pi.Pid()
 }
 
+/**
+ * Get detail of the tunnel process
+ * @returns {*models.ProcessDetail} Returns nil if the tunnel has never been opened
+ */
+func (tun *TunnelInstance) GetProcessDetail() *models.ProcessDetail {
+	if tun.pi == nil {
+		return nil
+	}
+	detail := tun.pi.GetDetail()
+	return &detail
+}
+
+/**
+ * Get tunnel mapping for knowledge export
+ * @returns {models.TunnelKnowledge} Returns status and port pairs of the tunnel
+ * @description
+ * - Unlike GetDetail, doesn't check process health, so it's safe to call from process watcher callbacks
+ */
+func (tun *TunnelInstance) GetKnowledge() models.TunnelKnowledge {
+	return models.TunnelKnowledge{
+		Status: tun.status,
+		Pairs:  tun.pairs,
+	}
+}
+
 func (tun *TunnelInstance) GetDetail() models.TunnelDetail {
 	detail := models.TunnelDetail{
 		Name:        tun.name,
@@ -232,9 +313,17 @@ func (tun *TunnelInstance) GetDetail() models.TunnelDetail {
 		Pairs:       tun.pairs,
 		Pid:         0,
 		Healthy:     models.Healthy,
+		ReopenCount: tun.reopenCount,
+	}
+	detail.HealthHistory = tun.GetHealthHistory()
+	if tun.status == models.StatusRunning {
+		detail.Uptime = time.Since(tun.createdTime).Round(time.Second).String()
 	}
 	if tun.pi != nil {
-		detail.Pid = tun.pi.Pid()
+		process := tun.pi.GetDetail()
+		detail.Pid = process.Pid
+		detail.RestartCount = process.RestartCount
+		detail.MaxRestartCount = process.MaxRestartCount
 		detail.Healthy = tun.GetHealthy()
 	}
 	return detail
@@ -271,9 +360,18 @@ func (tun *TunnelInstance) OpenTunnel(ctx context.Context) error {
 	tun.status = models.StatusError
 
 	if err := tun.allocMappingPort(); err != nil {
-		logger.Errorf("Allocate mapping port failed: %v", err)
+		// 隧道管理服务不可达，不影响服务本地访问，标记为pending，由监测流程退避重试
+		tun.status = models.StatusPending
+		interval := retryBaseInterval << min(tun.retryCount, 6)
+		if interval > retryMaxInterval {
+			interval = retryMaxInterval
+		}
+		tun.retryCount++
+		tun.nextRetry = time.Now().Add(interval)
+		logger.Errorf("Allocate mapping port failed: %v, tunnel (%s) will retry in %v", err, tun.name, interval)
 		return err
 	}
+	tun.retryCount = 0
 
 	tun.pi, err = tun.createProcessInstance()
 	if err != nil {
@@ -281,7 +379,7 @@ func (tun *TunnelInstance) OpenTunnel(ctx context.Context) error {
 		return err
 	}
 	if env.Daemon {
-		tun.pi.SetWatcher(3, func(pi *proc.ProcessInstance) {
+		tun.pi.SetWatcher(config.App().Tunnel.MaxRestart, func(pi *proc.ProcessInstance) {
 			switch pi.Status {
 			case models.StatusExited, models.StatusError:
 				tun.status = models.StatusError
@@ -317,6 +415,10 @@ func (tun *TunnelInstance) OpenTunnel(ctx context.Context) error {
  */
 func (tun *TunnelInstance) CloseTunnel() error {
 	if tun.pi == nil {
+		if tun.status == models.StatusPending {
+			tun.status = models.StatusStopped
+			tun.removeTunnelFile()
+		}
 		return nil
 	}
 	logger.Infof("Tunnel '%s' (PID: %d) will be closed", tun.getTitle(), tun.pi.Pid())
@@ -327,21 +429,97 @@ func (tun *TunnelInstance) CloseTunnel() error {
 	return nil
 }
 
+/**
+ * Retry opening tunnel which is pending because the tunnel manager was unreachable
+ * @param {context.Context} ctx - Context for the tunnel process
+ * @returns {error} Returns error if retry fails, nil if retried successfully or not due yet
+ * @description
+ * - Does nothing unless the tunnel is pending and its backoff interval has elapsed
+ */
+func (tun *TunnelInstance) RetryPending(ctx context.Context) error {
+	if tun.status != models.StatusPending || time.Now().Before(tun.nextRetry) {
+		return nil
+	}
+	logger.Infof("Retry opening tunnel (%s), attempt: %d", tun.name, tun.retryCount)
+	return tun.OpenTunnel(ctx)
+}
+
+/**
+ * Check if tunnel is pending, waiting for the tunnel manager to be reachable
+ */
+func (tun *TunnelInstance) IsPending() bool {
+	return tun.status == models.StatusPending
+}
+
+/**
+ * Check tunnel health and record the result into the health history
+ * @returns {models.HealthyStatus} Returns health status of the tunnel
+ * @description
+ * - Marks the tunnel as exited if its process isn't running
+ */
 func (tun *TunnelInstance) CheckTunnel() models.HealthyStatus {
 	if tun.status != models.StatusRunning {
+		tun.recordHealth(models.Unavailable, fmt.Sprintf("tunnel status is %s", tun.status))
 		return models.Unavailable
 	}
 	if tun.pi == nil {
+		tun.recordHealth(models.Unavailable, "tunnel process is missing")
 		return models.Unavailable
 	}
 	if status := tun.pi.CheckProcess(); status != models.Healthy {
 		tun.status = models.StatusExited
 		tun.removeTunnelFile()
+		tun.recordHealth(status, "tunnel process isn't running")
 		return status
 	}
+	tun.recordHealth(models.Healthy, "")
 	return models.Healthy
 }
 
+/**
+ * Append a health check result, the oldest one is dropped when the history is full
+ * @param {models.HealthyStatus} status - Result of the check
+ * @param {string} reason - Why the tunnel isn't healthy
+ */
+func (tun *TunnelInstance) recordHealth(status models.HealthyStatus, reason string) {
+	tun.histMutex.Lock()
+	defer tun.histMutex.Unlock()
+	if len(tun.history) >= healthHistorySize {
+		tun.history = append(tun.history[:0], tun.history[len(tun.history)-healthHistorySize+1:]...)
+	}
+	tun.history = append(tun.history, models.HealthRecord{
+		Time:    time.Now(),
+		Healthy: status,
+		Reason:  reason,
+	})
+}
+
+/**
+ * Get recent health check results of the tunnel
+ * @returns {[]models.HealthRecord} Returns a copy of the history, oldest first
+ */
+func (tun *TunnelInstance) GetHealthHistory() []models.HealthRecord {
+	tun.histMutex.Lock()
+	defer tun.histMutex.Unlock()
+	if len(tun.history) == 0 {
+		return nil
+	}
+	return append([]models.HealthRecord(nil), tun.history...)
+}
+
+/**
+ * Restore health history, used to carry the history over when the tunnel is recreated
+ * @param {[]models.HealthRecord} history - Health check results, oldest first
+ */
+func (tun *TunnelInstance) SetHealthHistory(history []models.HealthRecord) {
+	tun.histMutex.Lock()
+	defer tun.histMutex.Unlock()
+	if len(history) > healthHistorySize {
+		history = history[len(history)-healthHistorySize:]
+	}
+	tun.history = append([]models.HealthRecord(nil), history...)
+}
+
 func (tun *TunnelInstance) GetHealthy() models.HealthyStatus {
 	if tun.status != models.StatusRunning {
 		return models.Unavailable
@@ -371,6 +549,7 @@ func (tun *TunnelInstance) GetHealthy() models.HealthyStatus {
  * - Uses text/template to process command and arguments from config
  * - Generates command line with substituted template variables
  * - Returns new ProcessInstance with generated command and args
+ * - The process doesn't inherit the notify variables of the supervisor
  * - Template variables include: RemoteAddr, MappingPort, LocalPort, ProcessName, ProcessPath
  * @throws
  * - Command line generation errors
@@ -394,7 +573,9 @@ func (tun *TunnelInstance) createProcessInstance() (*proc.ProcessInstance, error
 		logger.Errorf("Tunnel startup settings are incorrect, setting: %+v", cfg.Tunnel)
 		return nil, err
 	}
-	return proc.NewProcessInstance("tunnel "+tun.name, name, command, cmdArgs), nil
+	pi := proc.NewProcessInstance("tunnel "+tun.name, name, command, cmdArgs)
+	pi.Env = supervisor.ChildEnv()
+	return pi, nil
 }
 
 /**
@@ -415,7 +596,7 @@ func (tun *TunnelInstance) createProcessInstance() (*proc.ProcessInstance, error
  */
 func (tun *TunnelInstance) saveTunnel() error {
 	err := func() error {
-		tunnelsDir := filepath.Join(env.CostrictDir, "cache", "tunnels")
+		tunnelsDir := filepath.Join(env.GetCacheDir(), "tunnels")
 		if err := os.MkdirAll(tunnelsDir, 0755); err != nil {
 			return fmt.Errorf("failed to create cache directory: %w", err)
 		}
@@ -425,7 +606,7 @@ func (tun *TunnelInstance) saveTunnel() error {
 			return fmt.Errorf("failed to serialize tunnel info: %w", err)
 		}
 		filePath := tun.getCacheFname()
-		if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		if err := utils.WriteFileAtomic(filePath, []byte(data), 0644); err != nil {
 			return fmt.Errorf("failed to write tunnel info file: %w", err)
 		}
 		return nil
@@ -433,6 +614,7 @@ func (tun *TunnelInstance) saveTunnel() error {
 	if err != nil {
 		logger.Errorf("Save tunnel failed: %v", err)
 	}
+	notifyChanged()
 	return err
 }
 
@@ -458,5 +640,6 @@ func (tun *TunnelInstance) removeTunnelFile() error {
 			return err
 		}
 	}
+	notifyChanged()
 	return nil
 }
@@ -0,0 +1,50 @@
+package tun
+
+import (
+	"testing"
+
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+)
+
+func TestCheckTunnelHistoryBounded(t *testing.T) {
+	env.CostrictDir = t.TempDir()
+	env.CacheDir = ""
+	tun := CreateTunnel("history-svc", []int{18080})
+	if detail := tun.GetDetail(); len(detail.HealthHistory) != 0 {
+		t.Fatalf("history of a new tunnel should be empty: %+v", detail.HealthHistory)
+	}
+
+	checks := healthHistorySize + 5
+	for i := 0; i < checks; i++ {
+		// 最后几次检查时隧道状态为running但没有进程
+		if i == checks-3 {
+			tun.status = models.StatusRunning
+		}
+		if status := tun.CheckTunnel(); status != models.Unavailable {
+			t.Fatalf("check %d = %s, want unavailable", i, status)
+		}
+	}
+	history := tun.GetDetail().HealthHistory
+	if len(history) != healthHistorySize {
+		t.Fatalf("history size = %d, want %d", len(history), healthHistorySize)
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Time.Before(history[i-1].Time) {
+			t.Fatalf("history isn't ordered oldest first: %+v", history)
+		}
+	}
+	// 保留的是最近的结果
+	if last := history[len(history)-1]; last.Healthy != models.Unavailable || last.Reason != "tunnel process is missing" {
+		t.Errorf("last record = %+v, want the latest check", last)
+	}
+	if first := history[0]; first.Reason != "tunnel status is exited" {
+		t.Errorf("first record = %+v", first)
+	}
+
+	// 返回的是副本，修改不影响隧道的历史
+	history[0].Reason = "modified"
+	if tun.GetHealthHistory()[0].Reason == "modified" {
+		t.Error("history returned by GetDetail shares memory with the tunnel")
+	}
+}
@@ -0,0 +1,93 @@
+//go:build linux || darwin
+
+package tun
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+)
+
+// 模拟隧道管理服务，up为false时返回503
+func newFakeTunman(t *testing.T, up *atomic.Bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req PortAllocationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(PortAllocationResponse{
+			AppName:     req.AppName,
+			ClientPort:  req.ClientPort,
+			MappingPort: 40002,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// 使用临时的.costrict目录，隧道管理服务指向tunmanUrl，隧道进程用sleep代替
+func setupTunnelEnv(t *testing.T, tunmanUrl string) {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	files := map[string]string{
+		filepath.Join("share", "auth.json"): `{"id":"test-user","machine_id":"test-machine","base_url":"http://127.0.0.1:1"}`,
+		filepath.Join("config", "costrict.json"): `{"cloud":{"tunman_url":"` + tunmanUrl + `"},` +
+			`"tunnel":{"command":"sleep","args":["30"]}}`,
+	}
+	for name, content := range files {
+		fname := filepath.Join(env.CostrictDir, name)
+		os.MkdirAll(filepath.Dir(fname), 0755)
+		if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPendingTunnelOpensWhenManagerComesUp(t *testing.T) {
+	var up atomic.Bool
+	srv := newFakeTunman(t, &up)
+	setupTunnelEnv(t, srv.URL)
+
+	tun := CreateTunnel("pending-svc", []int{18080})
+	t.Cleanup(func() { tun.CloseTunnel() })
+	if err := tun.OpenTunnel(context.Background()); err == nil {
+		t.Fatal("OpenTunnel should fail while the tunnel manager is down")
+	}
+	if !tun.IsPending() || tun.GetDetail().Status != models.StatusPending {
+		t.Fatalf("tunnel status = %s, want pending", tun.GetDetail().Status)
+	}
+	// 退避间隔未到，不会重试
+	if err := tun.RetryPending(context.Background()); err != nil || !tun.IsPending() {
+		t.Fatalf("retry before the backoff interval: err = %v, status = %s", err, tun.status)
+	}
+
+	up.Store(true)
+	tun.nextRetry = time.Now()
+	if err := tun.RetryPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	detail := tun.GetDetail()
+	if detail.Status != models.StatusRunning || detail.Pairs[0].MappingPort != 40002 {
+		t.Errorf("tunnel isn't opened after the manager comes up: %+v", detail)
+	}
+}
@@ -3,36 +3,64 @@ package utils
 import (
 	"bytes"
 	"fmt"
-	"html/template"
 	"strings"
+	"text/template"
 )
 
-func GetCommandLine(command string, args []string, data interface{}) (string, []string, error) {
-	cmdTemplate, err := template.New("command").Parse(command)
+// 命令模板中可用的函数，如：{{quote .ProcessPath}}
+var commandFuncs = template.FuncMap{
+	"quote": ShellQuote,
+}
+
+func renderTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(commandFuncs).Parse(text)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to parse command template: %w", err)
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
 	}
+	return buf.String(), nil
+}
 
-	var cmdBuf bytes.Buffer
-	if err := cmdTemplate.Execute(&cmdBuf, data); err != nil {
-		return "", nil, fmt.Errorf("failed to execute command template: %w", err)
+func GetCommandLine(command string, args []string, data interface{}) (string, []string, error) {
+	cmd, err := renderTemplate("command", command, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to render command template: %w", err)
 	}
 
 	// 处理Args模板
 	var processedArgs []string
 	for _, arg := range args {
-		argTemplate, err := template.New("arg").Parse(arg)
+		argStr, err := renderTemplate("arg", arg, data)
 		if err != nil {
-			return "", nil, fmt.Errorf("failed to parse arg template '%s': %w", arg, err)
+			return "", nil, fmt.Errorf("failed to render arg template '%s': %w", arg, err)
 		}
+		processedArgs = append(processedArgs, strings.TrimSpace(argStr))
+	}
 
-		var argBuf bytes.Buffer
-		if err := argTemplate.Execute(&argBuf, data); err != nil {
-			return "", nil, fmt.Errorf("failed to execute arg template '%s': %w", arg, err)
-		}
+	return cmd, processedArgs, nil
+}
 
-		processedArgs = append(processedArgs, strings.TrimSpace(argBuf.String()))
+/**
+ * Build shell script from command and args templates
+ * @param {string} command - Command template, interpreted by the shell, so it may use pipes, env interpolation, etc.
+ * @param {[]string} args - Args templates, each rendered arg is quoted and appended to the command as a single word
+ * @param {interface{}} data - Data for templates
+ * @returns {string} Returns the script to be run by ShellCommand
+ * @description
+ * - Values rendered into the command template are NOT quoted automatically,
+ *   use {{quote .X}} in the template when the value may contain spaces or shell metacharacters
+ */
+func GetShellScript(command string, args []string, data interface{}) (string, error) {
+	cmd, cmdArgs, err := GetCommandLine(command, args, data)
+	if err != nil {
+		return "", err
 	}
-
-	return cmdBuf.String(), processedArgs, nil
+	words := []string{cmd}
+	for _, arg := range cmdArgs {
+		words = append(words, ShellQuote(arg))
+	}
+	return strings.Join(words, " "), nil
 }
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// ShellQuote 把字符串转义为sh的单个单词
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellCommand 创建通过sh -c执行脚本的命令
+func ShellCommand(ctx context.Context, script string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
@@ -0,0 +1,61 @@
+//go:build !windows
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type commandArgs struct {
+	LocalPort int
+	Value     string
+}
+
+func TestGetShellScriptPipe(t *testing.T) {
+	script, err := GetShellScript("echo port={{.LocalPort}} | tr a-z A-Z", nil, commandArgs{LocalPort: 8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ShellCommand(context.Background(), script).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "PORT=8080" {
+		t.Errorf("output = %q, want PORT=8080", got)
+	}
+}
+
+func TestGetShellScriptQuotesArgs(t *testing.T) {
+	args := []string{"a b", "it's", "x | y", "$HOME", "{{.Value}}"}
+	script, err := GetShellScript(`printf '%s\n'`, args, commandArgs{Value: "`id`;"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ShellCommand(context.Background(), script).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a b", "it's", "x | y", "$HOME", "`id`;"}
+	if got := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+}
+
+func TestGetCommandLineDirectExec(t *testing.T) {
+	command, args, err := GetCommandLine("printf", []string{`%s\n`, "x | tr a-z A-Z", "{{.LocalPort}}"}, commandArgs{LocalPort: 8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(command, args...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 直接执行时管道符只是普通参数
+	if got := string(output); got != "x | tr a-z A-Z\n8080\n" {
+		t.Errorf("output = %q", got)
+	}
+}
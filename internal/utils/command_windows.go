@@ -0,0 +1,60 @@
+//go:build windows
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// cmd.exe的元字符，需要用^转义
+const cmdMetaChars = `()%!^"<>&|`
+
+// ShellQuote 把字符串转义为cmd的单个单词
+// 先按CommandLineToArgvW的规则加引号，让程序收到完整的参数，再用^转义cmd的元字符，
+// 包括引号本身，这样cmd不会进入引号模式，&、|、%等都不会被cmd解释
+func ShellQuote(s string) string {
+	var b strings.Builder
+	for _, r := range quoteArg(s) {
+		if strings.ContainsRune(cmdMetaChars, r) {
+			b.WriteByte('^')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// 按CommandLineToArgvW的规则给参数加引号：引号前的反斜杠加倍，引号转义为\"
+func quoteArg(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for _, r := range s {
+		switch r {
+		case '\\':
+			slashes++
+			continue
+		case '"':
+			b.WriteString(strings.Repeat(`\`, slashes*2+1))
+		default:
+			b.WriteString(strings.Repeat(`\`, slashes))
+		}
+		slashes = 0
+		b.WriteRune(r)
+	}
+	b.WriteString(strings.Repeat(`\`, slashes*2))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ShellCommand 创建通过cmd /c执行脚本的命令
+// cmd.exe不认识Go默认的参数转义规则，因此直接指定完整的命令行
+func ShellCommand(ctx context.Context, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CmdLine: `cmd.exe /S /C "` + script + `"`,
+	}
+	return cmd
+}
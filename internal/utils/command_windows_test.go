@@ -0,0 +1,60 @@
+//go:build windows
+
+package utils
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"abc":       `^"abc^"`,
+		"a b":       `^"a b^"`,
+		"a&b|c":     `^"a^&b^|c^"`,
+		"%PATH%":    `^"^%PATH^%^"`,
+		`say "hi"`:  `^"say \^"hi\^"^"`,
+		`C:\dir\`:   `^"C:\dir\\^"`,
+		"(x)<y>^z!": `^"^(x^)^<y^>^^z^!^"`,
+	}
+	for input, want := range cases {
+		if got := ShellQuote(input); got != want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestGetShellScriptPipe(t *testing.T) {
+	script, err := GetShellScript("echo port={{.LocalPort}}| findstr port", nil, struct{ LocalPort int }{8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ShellCommand(context.Background(), script).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "port=8080" {
+		t.Errorf("output = %q, want port=8080", got)
+	}
+}
+
+func TestGetShellScriptQuotesArgs(t *testing.T) {
+	args := []string{"a b", `say "hi"`, "x & echo injected", "%PATH%", `C:\dir\`}
+	script, err := GetShellScript(ShellQuote(os.Args[0]), args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := ShellCommand(context.Background(), script)
+	cmd.Env = append(os.Environ(), "COSTRICT_TEST_PRINT_ARGS=1")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(output), "\r\n", "\n"), "\n"), "\n")
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("args = %q, want %q", got, args)
+	}
+}
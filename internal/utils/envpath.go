@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// setx截断超过1024个字符的值，超过时不写入，避免破坏用户的PATH
+const maxSetxLength = 1024
+
+/**
+ *	规范化PATH中的一个路径，用于比较两个路径是否相同
+ *	去掉首尾空白和结尾的路径分隔符，windows上不区分大小写
+ */
+func normalizePathElem(elem string, fold bool) string {
+	elem = strings.TrimSpace(elem)
+	if len(elem) > 1 {
+		elem = strings.TrimRight(elem, `/\`)
+	}
+	if fold {
+		elem = strings.ToLower(elem)
+	}
+	return elem
+}
+
+/**
+ *	检查路径列表(如PATH)中是否已经包含路径dir，按路径元素比较而不是子串
+ */
+func pathListContains(list, dir, sep string, fold bool) bool {
+	key := normalizePathElem(dir, fold)
+	for _, elem := range strings.Split(list, sep) {
+		if normalizePathElem(elem, fold) == key {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ *	把dir加入路径列表，同时去掉列表中重复的和空的路径
+ *	@returns {string} 返回新的路径列表，保持原有路径的顺序
+ *	@returns {bool} 列表有变化时返回true
+ */
+func mergePathList(list, dir, sep string, fold bool) (string, bool) {
+	var elems []string
+	seen := make(map[string]bool)
+	for _, elem := range append(strings.Split(list, sep), dir) {
+		key := normalizePathElem(elem, fold)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		elems = append(elems, strings.TrimSpace(elem))
+	}
+	merged := strings.Join(elems, sep)
+	return merged, merged != list
+}
+
+/**
+ *	根据用户的shell选择设置环境变量的启动脚本
+ *	zsh使用~/.zshrc，bash或未设置SHELL时使用~/.bashrc，其它shell(sh/dash/ksh)使用~/.profile
+ */
+func getShellRcFile(home, shell string) string {
+	if shell == "" {
+		return filepath.Join(home, ".bashrc")
+	}
+	switch filepath.Base(shell) {
+	case "zsh":
+		return filepath.Join(home, ".zshrc")
+	case "bash":
+		return filepath.Join(home, ".bashrc")
+	default:
+		return filepath.Join(home, ".profile")
+	}
+}
+
+/**
+ *	检查启动脚本中的PATH设置(PATH=...或export PATH=...)是否已经包含路径dir
+ *	支持引号，以及$HOME、${HOME}、~开头的路径
+ */
+func rcExportsPath(content, dir, home string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		if !strings.HasPrefix(line, "PATH=") {
+			continue
+		}
+		value := strings.Trim(strings.TrimPrefix(line, "PATH="), `"'`)
+		for _, elem := range strings.Split(value, ":") {
+			elem = strings.Trim(elem, `"'`)
+			switch {
+			case strings.HasPrefix(elem, "${HOME}"):
+				elem = home + strings.TrimPrefix(elem, "${HOME}")
+			case strings.HasPrefix(elem, "$HOME"):
+				elem = home + strings.TrimPrefix(elem, "$HOME")
+			case strings.HasPrefix(elem, "~"):
+				elem = home + strings.TrimPrefix(elem, "~")
+			}
+			if elem != "" && filepath.Clean(elem) == filepath.Clean(dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/**
+ *	在windows上设置PATH变量，让新安装的程序可以被执行
+ *	只修改用户级的PATH，不能把进程的PATH(系统PATH+用户PATH)写回，否则每次安装PATH都会变长
+ *	用户PATH超过setx的长度限制时只记录警告，不影响安装，需要用户手工把安装目录加入PATH
+ */
+func windowsSetPATH(installDir string) error {
+	if paths := os.Getenv("PATH"); !pathListContains(paths, installDir, ";", true) {
+		os.Setenv("PATH", paths+";"+installDir)
+	}
+	userPath, err := getWindowsUserPath()
+	if err != nil {
+		log.Printf("Failed to read user PATH: %v\n", err)
+		return err
+	}
+	newPath, changed := mergePathList(userPath, installDir, ";", true)
+	if !changed {
+		return nil
+	}
+	if len(newPath) > maxSetxLength {
+		log.Printf("User PATH would exceed %d characters, skip updating it, add '%s' to PATH manually\n",
+			maxSetxLength, installDir)
+		return nil
+	}
+	cmd := exec.Command("setx", "PATH", newPath)
+	// cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true} // 隐藏命令窗口
+	return cmd.Run()
+}
+
+// reg query输出中的一个值：名字、类型、数据
+var regValuePattern = regexp.MustCompile(`^\s+(\S+)\s+(REG_\w+)\s+(.*)$`)
+
+/**
+ *	读取注册表HKCU\Environment中用户级的PATH，未设置时返回空串
+ */
+func getWindowsUserPath() (string, error) {
+	output, err := exec.Command("reg", "query", `HKCU\Environment`).Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		m := regValuePattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m != nil && strings.EqualFold(m[1], "Path") {
+			return strings.TrimSpace(m[3]), nil
+		}
+	}
+	return "", nil
+}
+
+/**
+ *	在linux上设置PATH变量，让新安装的程序可以被执行
+ *	按用户的shell写入对应的启动脚本，脚本中已经设置过该路径时不重复添加
+ */
+func linuxSetPATH(installDir string) error {
+	if paths := os.Getenv("PATH"); !pathListContains(paths, installDir, ":", false) {
+		if err := os.Setenv("PATH", paths+":"+installDir); err != nil {
+			log.Printf("Failed to set PATH for current process: %v\n", err)
+			return err
+		}
+	}
+	// 获取当前用户的主目录
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("Failed to get user home directory: %v\n", err)
+		return err
+	}
+	rcPath := getShellRcFile(homeDir, os.Getenv("SHELL"))
+	data, err := os.ReadFile(rcPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to read %s: %v\n", rcPath, err)
+		return err
+	}
+	if rcExportsPath(string(data), installDir, homeDir) {
+		log.Printf("The path is already in PATH of %s.\n", rcPath)
+		return nil
+	}
+	envLine := fmt.Sprintf("export PATH=$PATH:%s\n", installDir)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		envLine = "\n" + envLine
+	}
+	// 将环境变量追加到启动脚本
+	file, err := os.OpenFile(rcPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Failed to open %s for appending: %v\n", rcPath, err)
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(envLine); err != nil {
+		log.Printf("Failed to write environment variable to %s: %v\n", rcPath, err)
+		return err
+	}
+	log.Printf("Environment variable added to %s successfully.\n", rcPath)
+	return nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMergePathList(t *testing.T) {
+	cases := []struct {
+		list, dir string
+		fold      bool
+		want      string
+		changed   bool
+	}{
+		{"/usr/bin:/bin", "/opt/costrict/bin", false, "/usr/bin:/bin:/opt/costrict/bin", true},
+		// 已经存在，格式略有不同也视为同一个路径
+		{"/usr/bin:/opt/costrict/bin/:/bin", "/opt/costrict/bin", false, "/usr/bin:/opt/costrict/bin/:/bin", false},
+		{"/usr/bin: /opt/costrict/bin", "/opt/costrict/bin", false, "/usr/bin:/opt/costrict/bin", true},
+		// 去掉重复的和空的路径，保持原有顺序
+		{"/usr/bin::/bin:/usr/bin", "/bin", false, "/usr/bin:/bin", true},
+		{"", "/opt/costrict/bin", false, "/opt/costrict/bin", true},
+		// windows上不区分大小写
+		{`C:\Tools;C:\Users\me\.costrict\bin`, `c:\users\ME\.costrict\bin\`, true, `C:\Tools;C:\Users\me\.costrict\bin`, false},
+		{`C:\Tools;c:\tools\`, `D:\bin`, true, `C:\Tools;D:\bin`, true},
+		// 区分大小写的系统上是不同路径
+		{"/opt/Bin", "/opt/bin", false, "/opt/Bin:/opt/bin", true},
+	}
+	for _, c := range cases {
+		sep := ":"
+		if c.fold {
+			sep = ";"
+		}
+		got, changed := mergePathList(c.list, c.dir, sep, c.fold)
+		if got != c.want || changed != c.changed {
+			t.Errorf("mergePathList(%q, %q) = %q, %v, want %q, %v", c.list, c.dir, got, changed, c.want, c.changed)
+		}
+	}
+}
+
+func TestRcExportsPath(t *testing.T) {
+	home := "/home/me"
+	dir := "/home/me/.costrict/bin"
+	cases := []struct {
+		content string
+		want    bool
+	}{
+		{"export PATH=$PATH:/home/me/.costrict/bin\n", true},
+		{"  export PATH=\"$PATH:/home/me/.costrict/bin/\"\n", true},
+		{"PATH='/home/me/.costrict/bin':$PATH", true},
+		{"export PATH=$PATH:$HOME/.costrict/bin", true},
+		{"export PATH=${HOME}/.costrict/bin:$PATH", true},
+		{"export PATH=~/.costrict/bin:$PATH", true},
+		{"alias ll='ls -l'\nexport PATH=$PATH:/usr/local/go/bin\n", false},
+		// 子串匹配会误判的情况
+		{"export PATH=$PATH:/home/me/.costrict/bin2", false},
+		{"# export PATH=$PATH:/home/me/.costrict/bin", false},
+		{"echo /home/me/.costrict/bin", false},
+		{"export GOPATH=/home/me/.costrict/bin", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := rcExportsPath(c.content, dir, home); got != c.want {
+			t.Errorf("rcExportsPath(%q) = %v, want %v", c.content, got, c.want)
+		}
+	}
+}
+
+func TestGetShellRcFile(t *testing.T) {
+	home := "/home/me"
+	cases := map[string]string{
+		"":              ".bashrc",
+		"/bin/bash":     ".bashrc",
+		"/usr/bin/zsh":  ".zshrc",
+		"/bin/sh":       ".profile",
+		"/usr/bin/dash": ".profile",
+	}
+	for shell, want := range cases {
+		if got := getShellRcFile(home, shell); got != filepath.Join(home, want) {
+			t.Errorf("getShellRcFile(%q) = %s, want %s", shell, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"costrict-keeper/internal/env"
+)
+
+// Windows风格的环境变量引用 %VAR%
+var windowsEnvPattern = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%`)
+
+/**
+ * Expand a user-supplied path from configuration
+ * @param {string} path - Path to expand, empty stays empty
+ * @returns {string} Returns the cleaned absolute path
+ * @description
+ * - Environment variables are expanded: $VAR and ${VAR}, also %VAR% on Windows
+ * - A leading ~ is replaced with the home directory of the user
+ * - Relative paths are relative to CostrictDir, not the working directory,
+ *   since keeper may be started from anywhere
+ */
+func ExpandPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		path = windowsEnvPattern.ReplaceAllStringFunc(path, func(s string) string {
+			if v, ok := os.LookupEnv(s[1 : len(s)-1]); ok {
+				return v
+			}
+			return s
+		})
+	}
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(env.CostrictDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+/**
+ * Check if files can be created in directory
+ * @param {string} dir - Directory to check, created if it doesn't exist
+ * @returns {error} Returns error wrapping the permission error if the directory isn't writable
+ * @description
+ * - Creates and removes a temporary file, which is more reliable than checking the mode bits
+ *   (ACLs, read-only mounts, running as another user)
+ */
+func CheckDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return fmt.Errorf("directory '%s' isn't writable: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".writable.*.tmp")
+	if err != nil {
+		return fmt.Errorf("directory '%s' isn't writable: %w", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+	return nil
+}
+
+/**
+ * Write data to file atomically
+ * @param {string} fname - Path of the file to write
+ * @param {[]byte} data - File content
+ * @param {os.FileMode} perm - Permission of the file
+ * @returns {error} Returns error if write fails, the previous file is left intact then
+ * @description
+ * - Writes data to a temporary file in the same directory, then renames it into place,
+ *   so a crash in the middle never leaves a truncated file
+ * - Used for JSON state files which are loaded on next start
+ */
+func WriteFileAtomic(fname string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(fname)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := renameFile(tmpName, fname); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// 把临时文件替换为目标文件，测试时替换以模拟写入中途失败
+var renameFile = os.Rename
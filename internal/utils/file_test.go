@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"costrict-keeper/internal/env"
+)
+
+func readString(t *testing.T, fname string) string {
+	t.Helper()
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "state.json")
+	if err := WriteFileAtomic(fname, []byte(`{"v":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(fname, []byte(`{"v":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, fname); got != `{"v":2}` {
+		t.Errorf("content = %s, want {\"v\":2}", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(fname))
+	if len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicInterrupted(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "state.json")
+	if err := WriteFileAtomic(fname, []byte(`{"v":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟新内容写入临时文件后、替换目标文件前中断
+	old := renameFile
+	renameFile = func(string, string) error { return errors.New("interrupted") }
+	err := WriteFileAtomic(fname, []byte(`{"v":2, "truncated`), 0644)
+	renameFile = old
+	if err == nil {
+		t.Fatal("interrupted write should fail")
+	}
+	if got := readString(t, fname); got != `{"v":1}` {
+		t.Errorf("previous file is damaged: %s", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(fname))
+	if len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+
+	// 进程崩溃时残留的临时文件不影响以后的写入
+	stale := filepath.Join(filepath.Dir(fname), ".state.json.123.tmp")
+	os.WriteFile(stale, []byte(`{"v":`), 0644)
+	if err := WriteFileAtomic(fname, []byte(`{"v":3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, fname); got != `{"v":3}` {
+		t.Errorf("content = %s, want {\"v\":3}", got)
+	}
+}
+
+// 创建只读目录，当前用户(如root)不受权限位限制时跳过测试
+func readOnlyDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	probe := filepath.Join(dir, "probe")
+	if err := os.WriteFile(probe, nil, 0644); err == nil {
+		os.Remove(probe)
+		t.Skip("permission bits aren't enforced for the current user")
+	}
+	return dir
+}
+
+func TestCheckDirWritable(t *testing.T) {
+	if err := CheckDirWritable(filepath.Join(t.TempDir(), "sub", "dir")); err != nil {
+		t.Errorf("writable directory: %v", err)
+	}
+
+	// 路径中间是普通文件，目录无法创建
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	if err := CheckDirWritable(filepath.Join(file, "bin")); err == nil {
+		t.Error("directory below a regular file should not be writable")
+	}
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		dir := readOnlyDir(t)
+		err := CheckDirWritable(dir)
+		if !errors.Is(err, os.ErrPermission) {
+			t.Errorf("err = %v, want permission error", err)
+		}
+	})
+}
+
+func TestUpgradePackageUnwritableDir(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	check := func(t *testing.T, cfg UpgradeConfig) {
+		cfg.BaseUrl = server.URL
+		requests.Store(0)
+		_, upgraded, err := NewUpgrader("demo", cfg).UpgradePackage(nil)
+		if err == nil || upgraded {
+			t.Fatalf("upgrade should fail, upgraded=%v err=%v", upgraded, err)
+		}
+		if n := requests.Load(); n != 0 {
+			t.Errorf("%d requests were sent before the directory check", n)
+		}
+	}
+	t.Run("InstallDir", func(t *testing.T) {
+		base := t.TempDir()
+		os.WriteFile(filepath.Join(base, "bin"), nil, 0644)
+		check(t, UpgradeConfig{BaseDir: base})
+	})
+	t.Run("PackageDir", func(t *testing.T) {
+		base := t.TempDir()
+		check(t, UpgradeConfig{BaseDir: base, PackageDir: filepath.Join(readOnlyDir(t), "package")})
+	})
+	t.Run("TargetPath", func(t *testing.T) {
+		check(t, UpgradeConfig{BaseDir: t.TempDir(), TargetPath: filepath.Join(readOnlyDir(t), "demo")})
+	})
+}
+
+func TestExpandPath(t *testing.T) {
+	costrictDir := t.TempDir()
+	saved := env.CostrictDir
+	env.CostrictDir = costrictDir
+	t.Cleanup(func() { env.CostrictDir = saved })
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	logRoot := t.TempDir()
+	t.Setenv("COSTRICT_LOG_ROOT", logRoot)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"empty", "", ""},
+		{"home", "~", home},
+		{"home subdir", "~/logs/costrict.log", filepath.Join(home, "logs", "costrict.log")},
+		{"env", "$COSTRICT_LOG_ROOT/costrict.log", filepath.Join(logRoot, "costrict.log")},
+		{"env braces", "${COSTRICT_LOG_ROOT}/costrict.log", filepath.Join(logRoot, "costrict.log")},
+		{"relative", "logs/costrict.log", filepath.Join(costrictDir, "logs", "costrict.log")},
+		{"dot relative", "./logs/../cache", filepath.Join(costrictDir, "cache")},
+		{"absolute", filepath.Join(logRoot, "a", "..", "b"), filepath.Join(logRoot, "b")},
+	}
+	// %VAR%只在Windows上展开，其他平台上是普通的相对路径
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct{ name, path, want string }{
+			"windows env", `%COSTRICT_LOG_ROOT%\costrict.log`, filepath.Join(logRoot, "costrict.log")})
+	} else {
+		tests = append(tests, struct{ name, path, want string }{
+			"windows env", "%COSTRICT_LOG_ROOT%/costrict.log", filepath.Join(costrictDir, "%COSTRICT_LOG_ROOT%", "costrict.log")})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandPath(tt.path); got != tt.want {
+				t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
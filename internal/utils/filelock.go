@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+/**
+ * Acquire an exclusive lock shared by all processes, blocks until the lock is acquired
+ * @param {string} path - Path of the lock file, created if not exist
+ * @returns {func()} Returns function to release the lock
+ * @returns {error} Returns error if the lock file can't be opened or locked
+ * @description
+ * - Used to protect files modified by both the CLI and the server
+ * - The lock is released automatically when the process exits
+ * @example
+ * unlock, err := LockFile(fname + ".lock")
+ * if err != nil {
+ *     return err
+ * }
+ * defer unlock()
+ */
+func LockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file '%s' failed: %v", path, err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock '%s' failed: %v", path, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+/**
+ * Probe health endpoint of a service according to its protocol
+ * @param {string} protocol - Service protocol: http/https/grpc, http if empty
+ * @param {string} addr - Address of the service in "host:port" form
+ * @param {string} path - Health endpoint path for http/https, service name for grpc (may be empty)
+ * @param {time.Duration} timeout - Timeout of the probe
+ * @returns {error} Returns nil if the service is healthy
+ * @description
+ * - http/https: GET the path, 2xx means healthy; certificates aren't verified
+ *   since the service is local and usually self-signed
+ * - grpc: calls grpc.health.v1.Health/Check over plaintext HTTP/2, SERVING means healthy
+ */
+func ProbeHealth(protocol, addr, path string, timeout time.Duration) error {
+	switch protocol {
+	case "", "http", "https":
+		return probeHTTP(protocol, addr, path, timeout)
+	case "grpc":
+		return probeGRPC(addr, strings.TrimPrefix(path, "/"), timeout)
+	default:
+		return fmt.Errorf("unsupported protocol '%s'", protocol)
+	}
+}
+
+// 所有HTTP健康检查共用的Transport，复用连接，避免每次检查都新建连接及其读写协程
+var probeTransport = &http.Transport{
+	TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+	MaxIdleConnsPerHost: 1,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+func probeHTTP(scheme, addr, path string, timeout time.Duration) error {
+	if scheme == "" {
+		scheme = "http"
+	}
+	client := &http.Client{Timeout: timeout, Transport: probeTransport}
+	resp, err := client.Get(fmt.Sprintf("%s://%s%s", scheme, addr, path))
+	if err != nil {
+		return err
+	}
+	// 读完应答体才能让连接回到空闲池被复用
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unhealthy status: %s", resp.Status)
+	}
+	return nil
+}
+
+// TCP握手检查时最多读取的应答字节数，超出仍不匹配即认为不健康
+const maxHandshakeReply = 4096
+
+// 已编译的握手应答正则表达式，避免每次探测都重新编译
+var expectCache sync.Map
+
+/**
+ * Compile the expected reply of a TCP handshake, compiled expressions are cached
+ * @param {string} expect - Regular expression the reply must match
+ * @returns {*regexp.Regexp} Returns the compiled expression
+ * @returns {error} Returns error if expect isn't a valid regular expression
+ * @description
+ * - Used to validate health_check.expect when the specification is loaded
+ */
+func CompileExpect(expect string) (*regexp.Regexp, error) {
+	if re, ok := expectCache.Load(expect); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expect)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expect '%s': %v", expect, err)
+	}
+	expectCache.Store(expect, re)
+	return re, nil
+}
+
+/**
+ * Probe a TCP service with a send/expect handshake
+ * @param {string} addr - Address of the service in "host:port" form
+ * @param {string} send - Bytes sent after connected, nothing is sent if empty
+ * @param {string} expect - Regular expression the reply must match, the reply isn't read if empty
+ * @param {time.Duration} timeout - Timeout of the whole handshake, including connecting
+ * @returns {error} Returns nil if the service replies as expected
+ * @description
+ * - Reads until the received data matches expect, the peer closes the connection,
+ *   the timeout expires or maxHandshakeReply bytes are received
+ * - Distinguishes a service which accepts connections but doesn't speak its protocol
+ */
+func ProbeTCP(addr, send, expect string, timeout time.Duration) error {
+	var re *regexp.Regexp
+	if expect != "" {
+		var err error
+		if re, err = CompileExpect(expect); err != nil {
+			return err
+		}
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if send != "" {
+		if _, err := conn.Write([]byte(send)); err != nil {
+			return err
+		}
+	}
+	if re == nil {
+		return nil
+	}
+	reply := make([]byte, 0, 512)
+	buf := make([]byte, 512)
+	for len(reply) < maxHandshakeReply {
+		n, err := conn.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if re.Match(reply) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reply %q doesn't match '%s': %v", reply, expect, err)
+		}
+	}
+	return fmt.Errorf("reply doesn't match '%s' within %d bytes", expect, maxHandshakeReply)
+}
+
+/**
+ * Check if an URL is reachable
+ * @param {string} url - URL to be checked
+ * @param {time.Duration} timeout - Timeout of the request
+ * @returns {int} Returns HTTP status code of the response
+ * @returns {error} Returns error if no HTTP response is received
+ * @description
+ * - Sends HEAD request, any HTTP response means reachable whatever its status code,
+ *   since the URL is usually an API prefix rather than a real resource
+ */
+func ProbeURL(url string, timeout time.Duration) (int, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Head(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+/**
+ * Call grpc.health.v1.Health/Check of the service over plaintext HTTP/2
+ * @description
+ * - SERVING means healthy, other statuses and RPC errors (such as NOT_FOUND of an unknown service) mean unhealthy
+ * @private
+ */
+func probeGRPC(addr, service string, timeout time.Duration) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc service isn't serving, status: %s", resp.GetStatus())
+	}
+	return nil
+}
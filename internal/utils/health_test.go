@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestProbeHTTPReusesConnection(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	defer probeTransport.CloseIdleConnections()
+
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	for i := 0; i < 10; i++ {
+		if err := ProbeHealth("http", addr, "/healthz", time.Second); err != nil {
+			t.Fatalf("probe %d: %v", i, err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("10 probes opened %d connections, want 1", n)
+	}
+}
+
+func TestProbeHTTPUnhealthyStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	defer probeTransport.CloseIdleConnections()
+
+	err := ProbeHealth("", strings.TrimPrefix(srv.URL, "http://"), "/healthz", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want unhealthy status 503", err)
+	}
+}
+
+/**
+ * Start an in-process gRPC server with the standard health service
+ * @returns {string} Returns address of the server
+ * @returns {health.Server} Returns the health service, serving status of services can be set by tests
+ */
+func newGRPCHealthServer(t *testing.T) (string, *health.Server) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String(), hs
+}
+
+func TestProbeGRPC(t *testing.T) {
+	addr, hs := newGRPCHealthServer(t)
+	hs.SetServingStatus("indexer", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("syncer", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	tests := []struct {
+		service string
+		healthy bool
+	}{
+		{"", true}, // 空服务名检查整个服务器，health.Server默认为SERVING
+		{"indexer", true},
+		{"syncer", false},
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		err := ProbeHealth("grpc", addr, "/"+tt.service, time.Second)
+		if (err == nil) != tt.healthy {
+			t.Errorf("probe %q: err = %v, want healthy %v", tt.service, err, tt.healthy)
+		}
+	}
+}
+
+func TestCompileExpect(t *testing.T) {
+	re1, err := CompileExpect(`^\+PONG`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re2, err := CompileExpect(`^\+PONG`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re1 != re2 {
+		t.Error("expect is compiled again instead of using the cached one")
+	}
+	if _, err := CompileExpect("(["); err == nil {
+		t.Error("invalid expect is compiled")
+	}
+}
@@ -8,16 +8,58 @@ import (
 
 // checks if a port is connectable on localhost
 func CheckPortConnectable(port int) bool {
+	return CheckHostPortConnectable("", port)
+}
+
+/**
+ * Check if a port is connectable on the specified host
+ * @param {string} host - Host the service binds to, empty or "localhost" means loopback
+ * @param {int} port - Port to check
+ * @returns {bool} Returns true if any address of the host is connectable
+ * @description
+ * - Loopback is checked on both 127.0.0.1 and ::1, so that services bound
+ *   only to IPv6 loopback are not misreported as down
+ * - Other hosts are resolved and every address family is tried
+ */
+func CheckHostPortConnectable(host string, port int) bool {
+	return GetConnectableAddress(host, port) != ""
+}
+
+/**
+ * Get the first connectable address of host:port
+ * @param {string} host - Host the service binds to, empty or "localhost" means loopback
+ * @param {int} port - Port to check
+ * @returns {string} Returns connectable address in "host:port" form, empty if none connectable
+ * @example
+ * addr := GetConnectableAddress("", 9001) // "127.0.0.1:9001" or "[::1]:9001"
+ * url := fmt.Sprintf("http://%s/metrics", addr)
+ */
+func GetConnectableAddress(host string, port int) string {
 	timeout := time.Second
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", fmt.Sprintf("%d", port)), timeout)
-	if err != nil {
-		return false
-	}
-	if conn != nil {
+	for _, ip := range getHostAddresses(host) {
+		addr := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			continue
+		}
 		conn.Close()
-		return true
+		return addr
+	}
+	return ""
+}
+
+func getHostAddresses(host string) []string {
+	if host == "" || host == "localhost" {
+		return []string{"127.0.0.1", "::1"}
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return []string{host}
 	}
-	return false
+	return ips
 }
 
 func isPortAllocated(port int) bool {
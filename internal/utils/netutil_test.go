@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 只在IPv6环回地址上侦听，不支持IPv6的环境跳过测试
+func listenIPv6Loopback(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback isn't available: %v", err)
+	}
+	return ln
+}
+
+func TestConnectableOnIPv6LoopbackOnly(t *testing.T) {
+	ln := listenIPv6Loopback(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	for _, host := range []string{"", "localhost", "::1"} {
+		if !CheckHostPortConnectable(host, port) {
+			t.Errorf("port %d should be connectable on host '%s'", port, host)
+		}
+	}
+	addr := GetConnectableAddress("", port)
+	if addr != net.JoinHostPort("::1", strconv.Itoa(port)) {
+		t.Fatalf("connectable address = '%s', want [::1]:%d", addr, port)
+	}
+	if err := ProbeHealth("http", addr, "/healthz", time.Second); err != nil {
+		t.Errorf("http probe over IPv6 failed: %v", err)
+	}
+	if CheckHostPortConnectable("127.0.0.1", port) {
+		t.Errorf("port %d shouldn't be connectable on IPv4 loopback", port)
+	}
+}
@@ -1,8 +1,15 @@
 package utils
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"costrict-keeper/internal/env"
 )
 
 type VersionOverview struct {
@@ -47,30 +54,62 @@ type PackageList struct {
 
 func (u *Upgrader) GetRemotePlatforms() (PackageOverview, error) {
 	//	<base-url>/<package>/platforms.json
-	urlStr := fmt.Sprintf("%s/%s/platforms.json", u.BaseUrl, u.packageName)
+	path := fmt.Sprintf("/%s/platforms.json", u.packageName)
 
-	bytes, err := GetBytes(urlStr, nil)
+	bytes, err := u.getRemoteBytes(path)
 	if err != nil {
 		return PackageOverview{}, err
 	}
 	plats := &PackageOverview{}
 	if err = json.Unmarshal(bytes, plats); err != nil {
-		return *plats, fmt.Errorf("GetRemotePlatforms('%s') unmarshal error: %v", urlStr, err)
+		return *plats, fmt.Errorf("GetRemotePlatforms('%s') unmarshal error: %v", path, err)
 	}
 	return *plats, nil
 }
 
 func (u *Upgrader) GetRemotePackages() (PackageList, error) {
 	//	<base-url>/packages.json
-	urlStr := fmt.Sprintf("%s/packages.json", u.BaseUrl)
+	path := "/packages.json"
 
-	bytes, err := GetBytes(urlStr, nil)
+	bytes, err := u.getRemoteBytes(path)
 	if err != nil {
 		return PackageList{}, err
 	}
 	pkgs := &PackageList{}
 	if err = json.Unmarshal(bytes, pkgs); err != nil {
-		return *pkgs, fmt.Errorf("GetRemotePackages('%s') unmarshal error: %v", urlStr, err)
+		return *pkgs, fmt.Errorf("GetRemotePackages('%s') unmarshal error: %v", path, err)
 	}
 	return *pkgs, nil
 }
+
+/**
+ * 获取远程文件内容，启用缓存时优先使用本地缓存
+ * @param {string} path - 远程文件相对于BaseUrl的路径，如/packages.json/platforms.json/platform.json
+ * @returns {[]byte} 返回文件内容
+ * @returns {error} 返回错误对象，成功时返回nil
+ * @description
+ * - CacheTTL为0时直接从云端获取，BaseUrl连接失败时依次尝试各镜像
+ * - 缓存保存在缓存目录(默认.costrict/cache)的remote子目录，以BaseUrl下的URL的散列值命名
+ * - 缓存未过期时直接返回缓存内容，否则从云端获取并刷新缓存
+ * - 缓存写入失败不影响结果
+ */
+func (u *Upgrader) getRemoteBytes(path string) ([]byte, error) {
+	if u.CacheTTL <= 0 {
+		return u.fetchBytes(path)
+	}
+	sum := sha1.Sum([]byte(u.BaseUrl + path))
+	cacheFile := filepath.Join(env.GetCacheDir(), "remote", hex.EncodeToString(sum[:])+".json")
+	if info, err := os.Stat(cacheFile); err == nil && time.Since(info.ModTime()) < u.CacheTTL {
+		if bytes, err := os.ReadFile(cacheFile); err == nil {
+			return bytes, nil
+		}
+	}
+	bytes, err := u.fetchBytes(path)
+	if err != nil {
+		return bytes, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err == nil {
+		WriteFileAtomic(cacheFile, bytes, 0644)
+	}
+	return bytes, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/env"
+)
+
+func TestRemoteCacheUsesCacheDir(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"packageName":"test"}`))
+	}))
+	defer srv.Close()
+	env.CostrictDir = t.TempDir()
+	env.CacheDir = t.TempDir()
+	t.Cleanup(func() { env.CacheDir = "" })
+
+	u := NewUpgrader("test", UpgradeConfig{BaseUrl: srv.URL, BaseDir: env.CostrictDir, CacheTTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		if _, err := u.getRemoteBytes("/platform.json"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, the second read should hit the cache", n)
+	}
+	if files, _ := filepath.Glob(filepath.Join(env.CacheDir, "remote", "*.json")); len(files) != 1 {
+		t.Errorf("cache files in the configured cache dir = %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(env.CostrictDir, "cache")); !os.IsNotExist(err) {
+		t.Errorf("cache is written under CostrictDir: %v", err)
+	}
+}
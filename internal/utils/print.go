@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +23,24 @@ func PrintFormat(dataList []*orderedmap.OrderedMap) error {
 	if len(dataList) == 0 {
 		return fmt.Errorf("data list is empty")
 	}
+	text, err := FormatTable(dataList)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, text)
+	return nil
+}
+
+/**
+ * Format ordered map data as table text
+ * @param dataList slice of ordered maps to format
+ * @return string table text, empty if data list is empty
+ * @return error if formatting fails
+ */
+func FormatTable(dataList []*orderedmap.OrderedMap) (string, error) {
+	if len(dataList) == 0 {
+		return "", nil
+	}
 	// Get all keys
 	keys := dataList[0].Keys()
 
@@ -48,7 +67,7 @@ func PrintFormat(dataList []*orderedmap.OrderedMap) error {
 						// Convert struct to JSON string
 						jsonBytes, err := json.Marshal(elem)
 						if err != nil {
-							return err
+							return "", err
 						}
 						jsonList = append(jsonList, string(jsonBytes))
 					} else {
@@ -63,9 +82,8 @@ func PrintFormat(dataList []*orderedmap.OrderedMap) error {
 		rows = append(rows, row)
 	}
 
-	// Format and print data
+	// Format data
 	tt := table.NewWriter()
-	tt.SetOutputMirror(os.Stdout)
 	tt.AppendHeader(header)
 	tt.AppendRows(rows)
 	tt.Style().Options.DrawBorder = false
@@ -73,8 +91,7 @@ func PrintFormat(dataList []*orderedmap.OrderedMap) error {
 	tt.Style().Options.SeparateFooter = false
 	tt.Style().Options.SeparateHeader = false
 	tt.Style().Options.SeparateRows = false
-	tt.Render()
-	return nil
+	return tt.Render(), nil
 }
 
 /**
@@ -193,3 +210,20 @@ func FormatDuration(layout string, startTime string, endTime string) (string, er
 		return fmt.Sprintf("%ds", seconds), nil
 	}
 }
+
+/**
+ * Format byte size as human readable string
+ * @param {uint64} size - Size in bytes
+ * @returns {string} Returns size with unit, such as "12KB", "3MB"
+ */
+func FormatSize(size uint64) string {
+	if size < 1024 {
+		return strconv.FormatUint(size, 10) + "B"
+	} else if size < 1024*1024 {
+		return strconv.FormatUint(size/1024, 10) + "KB"
+	} else if size < 1024*1024*1024 {
+		return strconv.FormatUint(size/(1024*1024), 10) + "MB"
+	} else {
+		return strconv.FormatUint(size/(1024*1024*1024), 10) + "GB"
+	}
+}
@@ -1,12 +1,27 @@
 package utils
 
 import (
+	"costrict-keeper/internal/env"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// 优雅终止进程时，等待进程自行退出的时间，超时后强制杀死
+var killGracePeriod = time.Second
+
+/**
+ * Set grace period before a process is force killed
+ * @param {time.Duration} period - Time to wait for the process to exit after termination request
+ */
+func SetKillGracePeriod(period time.Duration) {
+	if period > 0 {
+		killGracePeriod = period
+	}
+}
+
 // ------------------------------------------------------------------------------
 //
 //	进程名processName：
@@ -21,6 +36,31 @@ func Path2ProcessName(processPath string) string {
 	return strings.ToLower(base[:len(base)-len(ext)])
 }
 
+/**
+ * Check if the process is a keeper-managed binary
+ * @param {int} pid - Process ID to check
+ * @returns {bool} Returns true if the executable of the process is located under CostrictDir/bin
+ * @description
+ * - Process name alone is not enough: an unrelated program of the user may share the name of a component
+ * - Processes whose executable path can't be determined are treated as unmanaged
+ */
+func IsManagedProcess(pid int) bool {
+	procPath, err := GetProcessPath(pid)
+	if err != nil {
+		return false
+	}
+	return isPathUnderDir(procPath, filepath.Join(env.CostrictDir, "bin"))
+}
+
+// 判断路径path是否位于目录dir之下
+func isPathUnderDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
 /**
  *	根据进程名和PID查找并打开进程
  */
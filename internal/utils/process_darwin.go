@@ -53,6 +53,21 @@ func IsProcessRunning(pid int) (bool, error) {
 	return true, nil
 }
 
+// GetProcessPath 根据PID获取进程的可执行文件路径
+func GetProcessPath(pid int) (string, error) {
+	// 在Darwin系统上，comm字段为可执行文件的完整路径
+	cmd := exec.Command("ps", "-p", fmt.Sprintf("%d", pid), "-o", "comm=")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get process path for PID %d: %v", pid, err)
+	}
+	exePath := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(exePath) {
+		return "", fmt.Errorf("no absolute path found for PID %d", pid)
+	}
+	return exePath, nil
+}
+
 // 根据PID获取进程名
 func GetProcessName(pid int) (string, error) {
 	// 在Darwin系统上，使用ps命令获取进程名
@@ -54,6 +54,16 @@ func IsProcessRunning(pid int) (bool, error) {
 	return true, nil
 }
 
+// GetProcessPath 根据PID获取进程的可执行文件路径
+func GetProcessPath(pid int) (string, error) {
+	exePath, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return "", fmt.Errorf("failed to read exe link for PID %d: %v", pid, err)
+	}
+	// 可执行文件被升级替换后，链接目标会带上" (deleted)"后缀
+	return strings.TrimSuffix(exePath, " (deleted)"), nil
+}
+
 // GetProcessName 根据PID获取进程名
 func GetProcessName(pid int) (string, error) {
 	// 读取/proc/<pid>/cmdline文件
@@ -3,6 +3,7 @@
 package utils
 
 import (
+	"fmt"
 	"os/exec"
 )
 
@@ -18,11 +19,29 @@ func KillProcessByPID(pid int) error {
 	panic("KillProcessByPID not implemented for this platform")
 }
 
+// TerminateProcess 请求进程优雅退出
+// 默认实现，用于不支持的构建目标
+func TerminateProcess(pid int) error {
+	return fmt.Errorf("TerminateProcess not implemented for this platform")
+}
+
+// KillProcessGroup 强制杀死进程组
+// 默认实现，用于不支持的构建目标
+func KillProcessGroup(pid int) error {
+	return fmt.Errorf("KillProcessGroup not implemented for this platform")
+}
+
 // IsProcessRunning 检查进程是否正在运行
 func IsProcessRunning(pid int) (bool, error) {
 	panic("IsProcessRunning not implemented for this platform")
 }
 
+// GetProcessPath 根据PID获取进程的可执行文件路径
+// 默认实现，无法确定路径时，进程被视为非keeper管理的进程
+func GetProcessPath(pid int) (string, error) {
+	return "", fmt.Errorf("GetProcessPath not implemented for this platform")
+}
+
 // GetProcessName 根据PID获取进程名
 func GetProcessName(pid int) (string, error) {
 	panic("GetProcessName not implemented for this platform")
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsPathUnderDir(t *testing.T) {
+	bin := filepath.Join("/home", "user", ".costrict", "bin")
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(bin, "cotun"), true},
+		{filepath.Join(bin, "sub", "cotun"), true},
+		{filepath.Join("/home", "user", ".costrict", "cotun"), false},
+		{filepath.Join("/home", "user", ".costrict", "bin2", "cotun"), false},
+		{filepath.Join("/usr", "bin", "cotun"), false},
+		{filepath.Join(bin, "..", "cotun"), false},
+	}
+	for _, c := range cases {
+		if got := isPathUnderDir(c.path, bin); got != c.want {
+			t.Errorf("isPathUnderDir(%s) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
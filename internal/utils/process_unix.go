@@ -19,7 +19,7 @@ import (
  * @returns {error} Returns error if process killing fails, nil on success
  * @description
  * - Uses ps command to enumerate processes with compatible format for both Linux and Darwin
- * - Parses output to find target process PIDs
+ * - Parses output to find target process PIDs, only processes located under CostrictDir/bin are targeted
  * - Implements graceful termination: first SIGTERM, then SIGKILL if needed
  * - Handles permission issues for both root and normal users
  * @throws
@@ -70,6 +70,11 @@ func KillSpecifiedProcess(processName string) error {
 		if pid == selfPid {
 			continue
 		}
+		// 只处理keeper管理的程序(位于CostrictDir/bin下)，避免误杀同名的无关进程
+		if !IsManagedProcess(pid) {
+			log.Printf("Skip process %s (PID: %d): not located in the bin directory\n", processName, pid)
+			continue
+		}
 
 		// 优雅地杀死进程
 		if err := killProcessGracefully(pid, processName); err != nil {
@@ -88,7 +93,7 @@ func KillSpecifiedProcess(processName string) error {
  * @returns {error} Returns error if process killing fails, nil on success
  * @description
  * - First tries to terminate process with SIGTERM (graceful shutdown)
- * - If SIGTERM fails or times out, uses SIGKILL (forceful termination)
+ * - If SIGTERM fails or the process is still alive after killGracePeriod, uses SIGKILL (forceful termination)
  * - Handles permission errors appropriately
  * @throws
  * - Process not found errors
@@ -106,7 +111,8 @@ func killProcessGracefully(pid int, procName string) error {
 	err = process.Signal(syscall.SIGTERM)
 	if err == nil {
 		// 等待进程退出
-		for i := 0; i < 10; i++ {
+		deadline := time.Now().Add(killGracePeriod)
+		for time.Now().Before(deadline) {
 			// 检查进程是否还在运行
 			if err := process.Signal(syscall.Signal(0)); err != nil {
 				// 进程已退出
@@ -129,6 +135,21 @@ func killProcessGracefully(pid int, procName string) error {
 	return nil
 }
 
+// TerminateProcess 向进程发送SIGTERM，请求进程优雅退出，不等待进程退出
+func TerminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// KillProcessGroup 强制杀死以pid为组长的进程组，包括shell包装启动的子进程
+// 只有SetNewPG启动的进程是进程组的组长，其它进程返回错误，由调用者强制杀死
+func KillProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
 func FindProcesses(processName string) []int {
 	var pids []int
 
@@ -168,6 +189,9 @@ func FindProcesses(processName string) []int {
 			log.Printf("Failed to parse PID %s for process %s: %v\n", pidStr, procName, err)
 			continue
 		}
+		if !IsManagedProcess(pid) {
+			continue
+		}
 		pids = append(pids, pid)
 	}
 	return pids
@@ -0,0 +1,80 @@
+//go:build linux || darwin
+
+package utils
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/env"
+)
+
+// 把sleep程序拷贝为dir/name，返回可执行文件路径
+func copySleep(t *testing.T, dir, name string) string {
+	t.Helper()
+	src, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep isn't available")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, name)
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+func startProcess(t *testing.T, path string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(path, "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+func TestFindProcessesOnlyUnderBinDir(t *testing.T) {
+	old := env.CostrictDir
+	env.CostrictDir = t.TempDir()
+	defer func() { env.CostrictDir = old }()
+
+	name := "costrict-test-sleeper"
+	managed := startProcess(t, copySleep(t, filepath.Join(env.CostrictDir, "bin"), name))
+	unrelated := startProcess(t, copySleep(t, t.TempDir(), name))
+	time.Sleep(100 * time.Millisecond)
+
+	if !IsManagedProcess(managed.Process.Pid) {
+		t.Error("process under the bin directory should be managed")
+	}
+	if IsManagedProcess(unrelated.Process.Pid) {
+		t.Error("same-named process outside the bin directory shouldn't be managed")
+	}
+	pids := FindProcesses(name)
+	if !slices.Contains(pids, managed.Process.Pid) {
+		t.Errorf("managed process %d isn't found in %v", managed.Process.Pid, pids)
+	}
+	if slices.Contains(pids, unrelated.Process.Pid) {
+		t.Errorf("unrelated process %d shouldn't be matched", unrelated.Process.Pid)
+	}
+}
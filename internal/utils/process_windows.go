@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -18,7 +19,9 @@ const (
 	PROCESS_QUERY_INFORMATION = 0x0400
 	PROCESS_VM_READ           = 0x0010
 	PROCESS_TERMINATE         = 0x0001
-	STILL_ACTIVE              = 259 // 进程仍在运行的标志
+	PROCESS_QUERY_LIMITED     = 0x1000 // PROCESS_QUERY_LIMITED_INFORMATION
+	STILL_ACTIVE              = 259    // 进程仍在运行的标志
+	CTRL_BREAK_EVENT          = 1      // 控制台CTRL+BREAK事件
 )
 
 var (
@@ -30,14 +33,18 @@ var (
 	procGetModuleBaseNameW = psapi.NewProc("GetModuleBaseNameW")
 	procTerminateProcess   = kernel32.NewProc("TerminateProcess")
 	procGetExitCodeProcess = kernel32.NewProc("GetExitCodeProcess")
+
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+	procQueryFullImageNameW      = kernel32.NewProc("QueryFullProcessImageNameW")
 )
 
 // SetNewPG 设置进程属性，使子进程在父进程退出后继续运行
 // Windows系统实现
 func SetNewPG(cmd *exec.Cmd) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
 }
 
 // KillProcessByPID 根据PID杀死进程
@@ -115,6 +122,32 @@ func GetProcessName(pid int) (string, error) {
 	return processName, nil
 }
 
+// GetProcessPath 根据PID获取进程的可执行文件路径
+func GetProcessPath(pid int) (string, error) {
+	handle, _, err := procOpenProcess.Call(
+		uintptr(PROCESS_QUERY_LIMITED),
+		uintptr(0),
+		uintptr(pid),
+	)
+	if handle == 0 {
+		return "", fmt.Errorf("failed to open process with PID %d: %v", pid, err)
+	}
+	defer procCloseHandle.Call(handle)
+
+	var pathBuffer [1024]uint16
+	size := uint32(len(pathBuffer))
+	ret, _, err := procQueryFullImageNameW.Call(
+		handle,
+		uintptr(0),
+		uintptr(unsafe.Pointer(&pathBuffer[0])),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if ret == 0 {
+		return "", fmt.Errorf("failed to query image name for PID %d: %v", pid, err)
+	}
+	return syscall.UTF16ToString(pathBuffer[:size]), nil
+}
+
 // IsProcessRunning 检查进程是否正在运行 使用 GetExitCodeProcess 检查进程是否正在运行
 func IsProcessRunning(pid int) (bool, error) {
 	// 打开进程句柄
@@ -145,14 +178,70 @@ func IsProcessRunning(pid int) (bool, error) {
 	return exitCode == STILL_ACTIVE, nil
 }
 
+// TerminateProcess 向进程组发送CTRL_BREAK，请求进程优雅退出，不等待进程退出
+// 只有SetNewPG启动的进程是进程组的组长，其它进程返回错误，由调用者强制杀死
+func TerminateProcess(pid int) error {
+	ret, _, err := procGenerateConsoleCtrlEvent.Call(uintptr(CTRL_BREAK_EVENT), uintptr(pid))
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+// KillProcessGroup 强制杀死进程及其全部子进程，Windows上按进程树而不是进程组杀死
+func KillProcessGroup(pid int) error {
+	output, err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("taskkill failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+/**
+ * Kill process gracefully with CTRL_BREAK first, then TerminateProcess if needed
+ * @param {int} pid - Process ID to kill
+ * @param {string} procName - Process name for logging
+ * @returns {error} Returns error if process killing fails, nil on success
+ * @description
+ * - Sends CTRL_BREAK to the process group of the process, giving it a chance to clean up.
+ *   Processes started by SetNewPG are leaders of their own group, so the group ID equals the PID
+ * - Waits up to killGracePeriod, checking IsProcessRunning
+ * - Falls back to KillProcessByPID (TerminateProcess) if the process is still alive
+ * @throws
+ * - Process terminate errors
+ */
+func killProcessGracefully(pid int, procName string) error {
+	log.Printf("Attempting graceful termination of process %s (PID: %d)\n", procName, pid)
+	ret, _, err := procGenerateConsoleCtrlEvent.Call(uintptr(CTRL_BREAK_EVENT), uintptr(pid))
+	if ret != 0 {
+		deadline := time.Now().Add(killGracePeriod)
+		for time.Now().Before(deadline) {
+			if running, err := IsProcessRunning(pid); err != nil || !running {
+				log.Printf("Process %s (PID: %d) terminated gracefully\n", procName, pid)
+				return nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	} else {
+		log.Printf("Failed to send CTRL_BREAK to process %s (PID: %d): %v\n", procName, pid, err)
+	}
+
+	log.Printf("Graceful termination failed, force killing process %s (PID: %d)\n", procName, pid)
+	if err := KillProcessByPID(pid); err != nil {
+		return err
+	}
+	log.Printf("Process %s (PID: %d) force killed\n", procName, pid)
+	return nil
+}
+
 /**
  * Kill processes on Windows system
  * @param {string} processName - Name of the process to kill
  * @returns {error} Returns error if process killing fails, nil on success
  * @description
  * - Uses tasklist command to enumerate processes
- * - Parses output to find target process PIDs
- * - Kills each found process using taskkill command
+ * - Parses output to find target process PIDs, only processes located under CostrictDir/bin are targeted
+ * - Terminates each found process gracefully, force kills it if it doesn't exit in time
  * @throws
  * - Command execution errors
  * - Process kill errors
@@ -198,9 +287,14 @@ func KillSpecifiedProcess(processName string) error {
 		if pid == selfPid {
 			continue
 		}
+		// 只处理keeper管理的程序(位于CostrictDir/bin下)，避免误杀同名的无关进程
+		if !IsManagedProcess(pid) {
+			log.Printf("Skip process %s (PID: %d): not located in the bin directory\n", processName, pid)
+			continue
+		}
 
-		// 使用Windows API杀死进程
-		if err := KillProcessByPID(pid); err != nil {
+		// 优雅地杀死进程
+		if err := killProcessGracefully(pid, processName); err != nil {
 			log.Printf("Failed to kill process %s (PID: %d): %v\n", processName, pid, err)
 		} else {
 			log.Printf("Successfully killed process %s (PID: %d)\n", processName, pid)
@@ -244,6 +338,9 @@ func FindProcesses(processName string) []int {
 		if err != nil {
 			continue
 		}
+		if !IsManagedProcess(pid) {
+			continue
+		}
 		pids = append(pids, pid)
 	}
 	return pids
@@ -0,0 +1,59 @@
+//go:build windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 作为子进程运行时，收到CTRL_BREAK后写入标记文件并退出，或者逐行打印命令行参数
+func TestMain(m *testing.M) {
+	if marker := os.Getenv("COSTRICT_TEST_CTRL_BREAK_MARKER"); marker != "" {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt)
+		<-ch
+		os.WriteFile(marker, []byte("ok"), 0644)
+		os.Exit(0)
+	}
+	if os.Getenv("COSTRICT_TEST_PRINT_ARGS") != "" {
+		for _, arg := range os.Args[1:] {
+			fmt.Println(arg)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestKillProcessGracefullyCtrlBreak(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "exited")
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "COSTRICT_TEST_CTRL_BREAK_MARKER="+marker)
+	SetNewPG(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	// 等待子进程注册信号处理
+	time.Sleep(500 * time.Millisecond)
+
+	old := killGracePeriod
+	killGracePeriod = 5 * time.Second
+	defer func() { killGracePeriod = old }()
+	if err := killProcessGracefully(cmd.Process.Pid, "ctrl-break-helper"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("cooperative process should exit on CTRL_BREAK instead of being terminated")
+	}
+}
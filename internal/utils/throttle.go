@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"io"
+	"time"
+)
+
+/**
+ * Reader which limits the average read speed
+ * @property {io.Reader} r - Underlying reader
+ * @property {int64} rate - Max bytes per second
+ * @property {time.Time} start - Time of the first read
+ * @property {int64} total - Bytes read so far
+ */
+type throttledReader struct {
+	r     io.Reader
+	rate  int64
+	start time.Time
+	total int64
+}
+
+/**
+ * Wrap reader with a bandwidth cap
+ * @param {io.Reader} r - Reader to be throttled
+ * @param {int64} bytesPerSec - Max bytes per second, zero or negative means unlimited
+ * @returns {io.Reader} Returns r itself if unlimited, otherwise a throttled reader
+ * @description
+ * - Each read returns at most one second worth of data
+ * - Sleeps after a read until the average speed falls back under the cap
+ */
+func NewThrottledReader(r io.Reader, bytesPerSec int64) io.Reader {
+	if bytesPerSec <= 0 {
+		return r
+	}
+	return &throttledReader{r: r, rate: bytesPerSec}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if tr.start.IsZero() {
+		tr.start = time.Now()
+	}
+	if int64(len(p)) > tr.rate {
+		p = p[:tr.rate]
+	}
+	n, err := tr.r.Read(p)
+	tr.total += int64(n)
+	expected := time.Duration(float64(tr.total) / float64(tr.rate) * float64(time.Second))
+	if wait := expected - time.Since(tr.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
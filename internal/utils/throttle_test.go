@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThrottledReaderMinDuration(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 50*1024)
+	start := time.Now()
+	got, err := io.ReadAll(NewThrottledReader(bytes.NewReader(data), 100*1024))
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, want %d", len(got), len(data))
+	}
+	// 50KB以100KB/s读取，至少需要0.5秒
+	if elapsed < 490*time.Millisecond {
+		t.Errorf("throttled read took %v, want at least 500ms", elapsed)
+	}
+
+	if _, ok := NewThrottledReader(bytes.NewReader(data), 0).(*throttledReader); ok {
+		t.Error("zero rate should be unlimited")
+	}
+}
+
+func TestGetFileLimited(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4*1024) // 64KB
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		bytesPerSec int64
+		minDuration time.Duration
+	}{
+		{"unlimited", 0, 0},
+		{"capped", 128 * 1024, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savePath := filepath.Join(t.TempDir(), "pkg.bin")
+			start := time.Now()
+			if err := GetFileLimited(srv.URL, nil, savePath, tt.bytesPerSec); err != nil {
+				t.Fatal(err)
+			}
+			elapsed := time.Since(start)
+			got, err := os.ReadFile(savePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("downloaded %d bytes, want %d", len(got), len(data))
+			}
+			if elapsed < tt.minDuration-10*time.Millisecond {
+				t.Errorf("download took %v, want at least %v", elapsed, tt.minDuration)
+			}
+			if tt.bytesPerSec == 0 && elapsed > 400*time.Millisecond {
+				t.Errorf("unlimited download took %v", elapsed)
+			}
+		})
+	}
+}
@@ -1,22 +1,22 @@
 package utils
 
 import (
-	"bufio"
 	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 /**
@@ -42,18 +42,19 @@ type VersionNumber struct {
  *	包版本的描述&签名信息，用于验证包的正确性
  */
 type PackageVersion struct {
-	PackageName  string        `json:"packageName"`  //包名字
-	PackageType  PackageType   `json:"packageType"`  //包类型: exec/conf
-	FileName     string        `json:"fileName"`     //被打包的文件的相对路径(相对.costrict目录,为空则安装到默认路径)
-	Os           string        `json:"os"`           //操作系统名:linux/windows
-	Arch         string        `json:"arch"`         //硬件架构
-	Size         uint64        `json:"size"`         //包文件大小
-	Checksum     string        `json:"checksum"`     //Md5散列值
-	Sign         string        `json:"sign"`         //签名，使用私钥签的名，需要用对应公钥验证
-	ChecksumAlgo string        `json:"checksumAlgo"` //固定为“md5”
-	VersionId    VersionNumber `json:"versionId"`    //版本号，采用SemVer标准
-	Build        string        `json:"build"`        //构建信息：Tag/Branch信息 CommitID BuildTime
-	Description  string        `json:"description"`  //版本描述，含有更丰富的可读信息
+	PackageName   string        `json:"packageName"`             //包名字
+	PackageType   PackageType   `json:"packageType"`             //包类型: exec/conf
+	FileName      string        `json:"fileName"`                //被打包的文件的相对路径(相对.costrict目录,为空则安装到默认路径)
+	Os            string        `json:"os"`                      //操作系统名:linux/windows
+	Arch          string        `json:"arch"`                    //硬件架构
+	Size          uint64        `json:"size"`                    //包文件大小
+	Checksum      string        `json:"checksum"`                //Md5散列值
+	Sign          string        `json:"sign"`                    //签名，使用私钥签的名，需要用对应公钥验证
+	ChecksumAlgo  string        `json:"checksumAlgo"`            //固定为“md5”
+	VersionId     VersionNumber `json:"versionId"`               //版本号，采用SemVer标准
+	Build         string        `json:"build"`                   //构建信息：Tag/Branch信息 CommitID BuildTime
+	Description   string        `json:"description"`             //版本描述，含有更丰富的可读信息
+	PostInstalled bool          `json:"postInstalled,omitempty"` //安装后钩子是否已执行(仅本地记录)
 }
 
 /**
@@ -77,15 +78,23 @@ type PlatformInfo struct {
 }
 
 type UpgradeConfig struct {
-	PublicKey  string //用来验证包签名的公钥
-	BaseUrl    string //保存安装包的服务器的基地址
-	BaseDir    string //costrict数据所在的基路径
-	Os         string //操作系统名
-	Arch       string //硬件平台名
-	TargetPath string //指定安装目标路径(及文件名)
-	NoSetPath  bool   //不需要设置PATH。设置PATH可以让程序所在路径被自动搜索
+	PublicKey  string        //用来验证包签名的公钥
+	BaseUrl    string        //保存安装包的服务器的基地址
+	Mirrors    []string      //备用镜像服务器的基地址，BaseUrl连接失败时依次尝试
+	BaseDir    string        //costrict数据所在的基路径
+	PackageDir string        //安装包描述文件的保存路径，为空则为BaseDir/package
+	Os         string        //操作系统名
+	Arch       string        //硬件平台名
+	TargetPath string        //指定安装目标路径(及文件名)
+	NoSetPath  bool          //不需要设置PATH。设置PATH可以让程序所在路径被自动搜索
+	CacheTTL   time.Duration //远程包列表/平台信息的本地缓存有效期，为0则不使用缓存
+	Retries    int           //下载的包校验和/签名不匹配时重新下载的次数，为0则使用默认值，小于0则不重试
+	RateLimit  int64         //下载包的速度上限(字节/秒)，为0则不限速
 }
 
+// 下载的包校验失败时默认重新下载的次数
+const DEFAULT_VERIFY_RETRIES = 2
+
 type Upgrader struct {
 	UpgradeConfig
 
@@ -139,7 +148,7 @@ func GetBytes(urlStr string, params map[string]string) ([]byte, error) {
 
 	rsp, err := client.Do(req)
 	if err != nil {
-		return []byte{}, fmt.Errorf("GetBytes: %v", err)
+		return []byte{}, fmt.Errorf("GetBytes: %w", err)
 	}
 	defer rsp.Body.Close()
 	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
@@ -154,6 +163,13 @@ func GetBytes(urlStr string, params map[string]string) ([]byte, error) {
  *	从服务器获取一个文件
  */
 func GetFile(urlStr string, params map[string]string, savePath string) error {
+	return GetFileLimited(urlStr, params, savePath, 0)
+}
+
+/**
+ *	从服务器获取一个文件，下载速度不超过bytesPerSec(字节/秒)，为0则不限速
+ */
+func GetFileLimited(urlStr string, params map[string]string, savePath string, bytesPerSec int64) error {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
@@ -170,7 +186,7 @@ func GetFile(urlStr string, params map[string]string, savePath string) error {
 
 	rsp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("GetFile('%s') failed: %v", urlStr, err)
+		return fmt.Errorf("GetFile('%s') failed: %w", urlStr, err)
 	}
 	defer rsp.Body.Close()
 	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
@@ -190,13 +206,21 @@ func GetFile(urlStr string, params map[string]string, savePath string) error {
 	defer out.Close()
 
 	// 然后将响应流和文件流对接起来
-	_, err = io.Copy(out, rsp.Body)
+	_, err = io.Copy(out, NewThrottledReader(rsp.Body, bytesPerSec))
 	if err != nil {
 		return fmt.Errorf("GetFile('%s'): copy error: %v", urlStr, err)
 	}
 	return err
 }
 
+/**
+ *	判断错误是否为连接服务器失败(而不是服务器返回了错误)
+ */
+func isConnectError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
 //------------------------------------------------------------------------------
 //	VersionNumber
 //------------------------------------------------------------------------------
@@ -277,7 +301,7 @@ func (pkg *PackageVersion) Save(fname string) error {
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(fname, bytes, 0644); err != nil {
+	if err := WriteFileAtomic(fname, bytes, 0644); err != nil {
 		log.Printf("Save package file '%s' failed: %v\n", fname, err)
 		return err
 	}
@@ -311,20 +335,35 @@ func (u *Upgrader) GetLocalVersion(ver *VersionNumber) (pkg PackageVersion, err
 	return
 }
 
+/**
+ *	保存当前版本的本地包信息，用于记录安装后的本地状态
+ */
+func (u *Upgrader) SaveLocalVersion(pkg PackageVersion) error {
+	pkgFile := filepath.Join(u.packageDir, fmt.Sprintf("%s.json", u.packageName))
+	return pkg.Save(pkgFile)
+}
+
 /**
  *	从远程库获取包版本
  */
 func (u *Upgrader) GetRemoteVersions() (PlatformInfo, error) {
+	return u.GetPlatformVersions(u.Os, u.Arch)
+}
+
+/**
+ *	从远程库获取指定平台(os/arch)的包版本
+ */
+func (u *Upgrader) GetPlatformVersions(osName, arch string) (PlatformInfo, error) {
 	//	<base-url>/<package>/<os>/<arch>/platform.json
-	urlStr := fmt.Sprintf("%s/%s/%s/%s/platform.json", u.BaseUrl, u.packageName, u.Os, u.Arch)
+	path := fmt.Sprintf("/%s/%s/%s/platform.json", u.packageName, osName, arch)
 
-	bytes, err := GetBytes(urlStr, nil)
+	bytes, err := u.getRemoteBytes(path)
 	if err != nil {
 		return PlatformInfo{}, err
 	}
 	vers := &PlatformInfo{}
 	if err = json.Unmarshal(bytes, vers); err != nil {
-		return *vers, fmt.Errorf("GetRemoteVersions('%s') unmarshal error: %v", urlStr, err)
+		return *vers, fmt.Errorf("GetRemoteVersions('%s') unmarshal error: %v", path, err)
 	}
 	return *vers, nil
 }
@@ -431,11 +470,13 @@ func (u *Upgrader) GetPackage(specVer *VersionNumber) (PackageVersion, bool, err
 		return pkg, true, nil
 	}
 	//	获取云端升级包的描述信息
-	data, err := GetBytes(u.BaseUrl+addr.InfoUrl, nil)
+	data, err := u.fetchBytes(addr.InfoUrl)
 	if err != nil {
 		log.Printf("Get package info from '%s' failed: %v\n", addr.InfoUrl, err)
 		return pkg, false, err
 	}
+	//	不能沿用本地版本的字段(如PostInstalled)
+	pkg = PackageVersion{}
 	if err = json.Unmarshal(data, &pkg); err != nil {
 		log.Printf("Unmarshal package info from '%s' failed: %v\n", addr.InfoUrl, err)
 		return pkg, false, err
@@ -444,6 +485,10 @@ func (u *Upgrader) GetPackage(specVer *VersionNumber) (PackageVersion, bool, err
 		log.Printf("Invalid package file '%s': %v\n", addr.InfoUrl, err)
 		return pkg, false, err
 	}
+	if err = u.checkPlatform(pkg); err != nil {
+		log.Printf("Invalid package file '%s': %v\n", addr.InfoUrl, err)
+		return pkg, false, err
+	}
 	cacheDir := filepath.Join(u.packageDir, addr.VersionId.String())
 	if err = os.MkdirAll(cacheDir, 0775); err != nil {
 		log.Printf("Create cache directory '%s' failed: %v\n", cacheDir, err)
@@ -452,17 +497,12 @@ func (u *Upgrader) GetPackage(specVer *VersionNumber) (PackageVersion, bool, err
 	//	下载包
 	_, fname := filepath.Split(pkg.FileName)
 	cacheFname := filepath.Join(cacheDir, fname)
-	if err = GetFile(u.BaseUrl+addr.AppUrl, nil, cacheFname); err != nil {
-		log.Printf("Download package from '%s' to '%s' failed: %v\n", addr.AppUrl, cacheFname, err)
-		return pkg, false, err
-	}
-	//	验证下载文件的完整性，防止丢失、篡改等
-	if err := u.verifyIntegrity(pkg, cacheFname); err != nil {
+	if err := u.downloadPackage(pkg, addr.AppUrl, cacheFname); err != nil {
 		return pkg, false, err
 	}
 	//	把包描述文件保存到包文件目录
 	pkgFile = filepath.Join(u.packageDir, fmt.Sprintf("%s-%s.json", u.packageName, pkg.VersionId.String()))
-	if err := os.WriteFile(pkgFile, data, 0644); err != nil {
+	if err := WriteFileAtomic(pkgFile, data, 0644); err != nil {
 		log.Printf("Write package info file '%s' failed: %v\n", pkgFile, err)
 		return pkg, false, err
 	}
@@ -482,8 +522,19 @@ func (u *Upgrader) ActivatePackage(pkg PackageVersion) error {
 
 /**
  *	升级包
+ *	在下载前先检查安装目录和包目录是否可写，避免下载完成后才因权限不足而安装失败
  */
 func (u *Upgrader) UpgradePackage(specVer *VersionNumber) (PackageVersion, bool, error) {
+	installDir := u.installDir
+	if u.TargetPath != "" {
+		installDir = filepath.Dir(u.TargetPath)
+	}
+	for _, dir := range []string{installDir, u.packageDir} {
+		if err := CheckDirWritable(dir); err != nil {
+			log.Printf("Upgrade '%s' failed: %v\n", u.packageName, err)
+			return PackageVersion{}, false, err
+		}
+	}
 	pkg, upgraded, err := u.GetPackage(specVer)
 	if err != nil {
 		return pkg, false, err
@@ -573,17 +624,37 @@ func (u *Upgrader) RemovePackage(ver *VersionNumber) error {
  * }
  */
 func (u *Upgrader) CleanupOldVersions() error {
+	olds, err := u.GetOldVersions()
+	if err != nil {
+		return err
+	}
+	RemoveVersions(olds)
+	return nil
+}
+
+/**
+ * 获取超出保留数目的过老版本
+ * @returns {[]VersionSummary} 返回需要清除的版本列表
+ * @returns {error} 返回错误对象，成功时返回nil
+ * @description
+ * - 扫描版本描述文件package/x-{ver}.json文件，提取文件中保存的版本信息
+ * - 按包名分组，每个包保留最新的PACKAGE_RESERVE_NUM个版本，其余版本作为过老版本返回
+ * - 只收集信息，不删除任何文件，可用于预览清理结果
+ * @throws
+ * - 读取package目录失败
+ */
+func (u *Upgrader) GetOldVersions() ([]VersionSummary, error) {
 	// 检查package目录是否存在
 	if _, err := os.Stat(u.packageDir); os.IsNotExist(err) {
 		log.Printf("Cleanup: package directory '%s' does not exist\n", u.packageDir)
-		return err
+		return nil, err
 	}
 
 	// 读取package目录下的所有文件
 	files, err := os.ReadDir(u.packageDir)
 	if err != nil {
 		log.Printf("Cleanup: package directory '%s' read failed: %v\n", u.packageDir, err)
-		return err
+		return nil, err
 	}
 
 	// 按包名分组的版本信息
@@ -627,18 +698,23 @@ func (u *Upgrader) CleanupOldVersions() error {
 		packageVersions[pkg.PackageName] = append(packageVersions[pkg.PackageName], versionInfo)
 	}
 
-	// 对每个包的版本进行排序，并删除过老的版本
+	// 对每个包的版本进行排序，收集过老的版本
+	var olds []VersionSummary
 	for _, versions := range packageVersions {
 		// 按版本号从新到旧排序
 		sort.Slice(versions, func(i, j int) bool {
 			return CompareVersion(versions[i].Version, versions[j].Version) > 0
 		})
-		removeOldestVersions(versions, 3)
+		if len(versions) > PACKAGE_RESERVE_NUM {
+			olds = append(olds, versions[PACKAGE_RESERVE_NUM:]...)
+		}
 	}
-
-	return nil
+	return olds, nil
 }
 
+// 每个包保留的最新版本数目
+const PACKAGE_RESERVE_NUM = 3
+
 // VersionSummary 包版本的摘要，用于清理过老版本
 type VersionSummary struct {
 	PackageName string        // 包名
@@ -649,13 +725,10 @@ type VersionSummary struct {
 }
 
 /**
- *	删除过老版本，但保留开头即最新的reserveNum个版本
+ *	删除指定的版本，包括包描述文件、包数据文件，以及清空后的包目录
  */
-func removeOldestVersions(versions []VersionSummary, reserveNum int) {
-	// 如果版本数量超过保留数目，则删除过老的版本
-	for i := reserveNum; i < len(versions); i++ {
-		old := versions[i]
-
+func RemoveVersions(versions []VersionSummary) {
+	for _, old := range versions {
 		// 删除包描述文件
 		if err := os.Remove(old.DescPath); err != nil {
 			log.Printf("Cleanup: remove description file '%s' failed: %v\n", old.DescPath, err)
@@ -681,6 +754,78 @@ func removeOldestVersions(versions []VersionSummary, reserveNum int) {
 	}
 }
 
+/**
+ *	下载包文件并验证完整性
+ *	@description
+ *	- 校验和/签名不匹配时(下载损坏)，删除缓存文件后重新下载，最多重试u.Retries次
+ *	- 网络错误不在这里重试，直接返回
+ */
+func (u *Upgrader) downloadPackage(pkg PackageVersion, path, cacheFname string) error {
+	retries := u.Retries
+	if retries == 0 {
+		retries = DEFAULT_VERIFY_RETRIES
+	}
+	for attempt := 0; ; attempt++ {
+		if err := u.fetchFile(path, cacheFname); err != nil {
+			log.Printf("Download package from '%s' to '%s' failed: %v\n", path, cacheFname, err)
+			return err
+		}
+		//	验证下载文件的完整性，防止丢失、篡改等
+		err := u.verifyIntegrity(pkg, cacheFname)
+		if err == nil {
+			return nil
+		}
+		os.Remove(cacheFname)
+		if attempt >= retries {
+			return err
+		}
+		log.Printf("Package '%s' is corrupted, download again (%d/%d)\n", cacheFname, attempt+1, retries)
+	}
+}
+
+/**
+ *	从BaseUrl获取文件内容，连接失败时依次尝试各镜像
+ */
+func (u *Upgrader) fetchBytes(path string) ([]byte, error) {
+	var data []byte
+	err := u.tryMirrors(path, func(urlStr string) error {
+		var err error
+		data, err = GetBytes(urlStr, nil)
+		return err
+	})
+	return data, err
+}
+
+/**
+ *	从BaseUrl下载文件，连接失败时依次尝试各镜像
+ */
+func (u *Upgrader) fetchFile(path, savePath string) error {
+	return u.tryMirrors(path, func(urlStr string) error {
+		return GetFileLimited(urlStr, nil, savePath, u.RateLimit)
+	})
+}
+
+/**
+ *	依次用BaseUrl及各镜像的地址执行fetch，直到成功或遇到非连接错误
+ *	@description
+ *	- 只有连接失败才换下一个镜像，服务器返回的错误(如404)直接返回
+ *	- 镜像上的包同样要通过校验和/签名验证，被篡改的镜像无法注入非法的包
+ */
+func (u *Upgrader) tryMirrors(path string, fetch func(urlStr string) error) error {
+	bases := append([]string{u.BaseUrl}, u.Mirrors...)
+	var err error
+	for i, base := range bases {
+		err = fetch(base + path)
+		if err == nil || !isConnectError(err) {
+			return err
+		}
+		if i+1 < len(bases) {
+			log.Printf("Connect to '%s' failed, try mirror '%s': %v\n", base, bases[i+1], err)
+		}
+	}
+	return err
+}
+
 func (u *Upgrader) checkLocalPackage(ver VersionNumber) (PackageVersion, error) {
 	pkgFile := filepath.Join(u.packageDir, fmt.Sprintf("%s-%s.json", u.packageName, ver.String()))
 	var pkg PackageVersion
@@ -695,6 +840,62 @@ func (u *Upgrader) checkLocalPackage(ver VersionNumber) (PackageVersion, error)
 	return pkg, nil
 }
 
+/**
+ *	已安装文件的校验结果
+ */
+type VerifyReport struct {
+	Version     VersionNumber //已安装的版本
+	FilePath    string        //已安装文件的路径
+	Expected    string        //包描述文件中记录的校验和
+	Actual      string        //重新计算的已安装文件的校验和
+	ChecksumOK  bool          //校验和是否一致
+	SignatureOK bool          //包描述文件的签名是否有效
+}
+
+/**
+ *	校验已安装的当前版本，确认安装的文件与签名的包描述信息一致
+ *	@returns {VerifyReport} 返回校验的详细结果
+ *	@returns {error} 未安装、文件无法读取、校验和不一致或签名无效时返回错误
+ *	@description
+ *	- 重新计算已安装文件的校验和，与<pkg>.json中记录的比较
+ *	- 用可信公钥重新验证<pkg>.json中的签名，两项检查都会执行，便于同时报告
+ */
+func (u *Upgrader) VerifyInstalled() (VerifyReport, error) {
+	var report VerifyReport
+	pkg, err := u.GetLocalVersion(nil)
+	if err != nil {
+		return report, fmt.Errorf("package '%s' isn't installed: %w", u.packageName, err)
+	}
+	report.Version = pkg.VersionId
+	report.FilePath = u.getDataPath(pkg)
+	report.Expected = pkg.Checksum
+
+	var errs []string
+	_, md5str, err := CalcFileMd5(report.FilePath)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("read '%s' failed: %v", report.FilePath, err))
+	} else {
+		report.Actual = md5str
+		report.ChecksumOK = md5str == pkg.Checksum
+		if !report.ChecksumOK {
+			errs = append(errs, fmt.Sprintf("checksum mismatch, expected: %s, actual: %s", pkg.Checksum, md5str))
+		}
+	}
+	sig, err := hex.DecodeString(pkg.Sign)
+	if err == nil {
+		err = VerifySign([]byte(u.PublicKey), sig, []byte(pkg.Checksum))
+	}
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("invalid signature: %v", err))
+	} else {
+		report.SignatureOK = true
+	}
+	if len(errs) > 0 {
+		return report, errors.New(strings.Join(errs, "; "))
+	}
+	return report, nil
+}
+
 func (u *Upgrader) verifyIntegrity(pkg PackageVersion, fname string) error {
 	_, md5str, err := CalcFileMd5(fname)
 	if err != nil {
@@ -718,10 +919,31 @@ func (u *Upgrader) verifyIntegrity(pkg PackageVersion, fname string) error {
 	return nil
 }
 
+/**
+ *	检查包描述信息中的平台是否与目标平台一致
+ *	服务器配置错误时可能返回其它平台的包，比如在Linux上安装Windows的程序
+ *	包描述信息或升级配置中未指定的字段不检查
+ */
+func (u *Upgrader) checkPlatform(pkg PackageVersion) error {
+	if pkg.Os != "" && u.Os != "" && pkg.Os != u.Os {
+		return fmt.Errorf("package '%s' %s is built for os '%s', expected '%s'",
+			pkg.PackageName, pkg.VersionId.String(), pkg.Os, u.Os)
+	}
+	if pkg.Arch != "" && u.Arch != "" && pkg.Arch != u.Arch {
+		return fmt.Errorf("package '%s' %s is built for arch '%s', expected '%s'",
+			pkg.PackageName, pkg.VersionId.String(), pkg.Arch, u.Arch)
+	}
+	return nil
+}
+
 /**
  *	激活版本ver的包，令其成为当前版本
  */
 func (u *Upgrader) activatePackage(pkg PackageVersion) error {
+	if err := u.checkPlatform(pkg); err != nil {
+		log.Printf("Activate package '%s' failed: %v\n", u.packageName, err)
+		return err
+	}
 	_, fname := filepath.Split(pkg.FileName)
 	cacheDir := filepath.Join(u.packageDir, pkg.VersionId.String())
 	cacheFname := filepath.Join(cacheDir, fname)
@@ -734,21 +956,30 @@ func (u *Upgrader) activatePackage(pkg PackageVersion) error {
 	return pkg.Save(pkgFile)
 }
 
+/**
+ *	获取包数据文件的安装路径
+ */
+func (u *Upgrader) getDataPath(pkg PackageVersion) string {
+	if u.TargetPath != "" {
+		return u.TargetPath
+	}
+	dir, fname := filepath.Split(pkg.FileName)
+	if dir != "" {
+		return filepath.Join(u.BaseDir, pkg.FileName)
+	}
+	return filepath.Join(u.installDir, fname)
+}
+
 /**
  *	保存包数据文件
+ *	可执行程序的权限为0755，配置文件为0644，显式设置权限，不受umask和旧文件权限的影响
  */
 func (u *Upgrader) savePackageData(pkg PackageVersion, cacheFname string) error {
-	var dataPath string
-	if u.TargetPath != "" {
-		dataPath = u.TargetPath
-	} else {
-		dir, fname := filepath.Split(pkg.FileName)
-		if dir != "" {
-			dataPath = filepath.Join(u.BaseDir, pkg.FileName)
-		} else {
-			dataPath = filepath.Join(u.installDir, fname)
-		}
+	mode := os.FileMode(0644)
+	if pkg.PackageType == PackageTypeExec {
+		mode = 0755
 	}
+	dataPath := u.getDataPath(pkg)
 	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
 		return err
 	}
@@ -763,7 +994,7 @@ func (u *Upgrader) savePackageData(pkg PackageVersion, cacheFname string) error
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dataPath)
+	dstFile, err := os.OpenFile(dataPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 	if err != nil {
 		return err
 	}
@@ -772,114 +1003,18 @@ func (u *Upgrader) savePackageData(pkg PackageVersion, cacheFname string) error
 	if _, err := io.Copy(dstFile, srcFile); err != nil {
 		return err
 	}
-	if pkg.PackageType != PackageTypeExec {
-		return nil
-	}
-	return os.Chmod(dataPath, 0755)
-}
-
-/**
- *	在windows上设置PATH变量，让新安装的程序可以被执行
- */
-func windowsSetPATH(installDir string) error {
-	paths := os.Getenv("PATH")
-	if !strings.Contains(paths, installDir) {
-		newPath := fmt.Sprintf("%s;%s", paths, installDir)
-		cmd := exec.Command("setx", "PATH", newPath)
-		// cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true} // 隐藏命令窗口
-		if err := cmd.Run(); err != nil {
-			return err
-		}
-		os.Setenv("PATH", newPath)
-	}
-	return nil
-}
-
-/**
- *	在linux上设置PATH变量，让新安装的程序可以被执行
- */
-func linuxSetPATH(installDir string) error {
-	currentPath := os.Getenv("PATH")
-	// 检查是否已经包含该路径
-	currentPathStr := strings.TrimSpace(currentPath)
-	if strings.Contains(currentPathStr, installDir) {
-		log.Println("The path is already in PATH.")
-		return nil
-	}
-	// 将新路径添加到 PATH
-	newPathStr := fmt.Sprintf("%s:%s", currentPathStr, installDir)
-	err := os.Setenv("PATH", newPathStr)
-	if err != nil {
-		log.Printf("Failed to set PATH for current process: %v\n", err)
-		return err
-	}
-	// 获取当前用户的主目录
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Printf("Failed to get user home directory: %v\n", err)
-		return err
-	}
-	envLine := fmt.Sprintf("export PATH=$PATH:%s", installDir)
-
-	bashrcPath := homeDir + "/.bashrc"
-	// 检查是否已经包含该环境变量
-	file, err := os.Open(bashrcPath)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Failed to open ~/.bashrc: %v\n", err)
-			return err
-		}
-		// 文件不存在，创建一个空文件
-		file, err = os.Create(bashrcPath)
-		if err != nil {
-			log.Printf("Failed to create ~/.bashrc: %v\n", err)
-			return err
-		}
-		file.Close()
-	} else {
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			if strings.Contains(scanner.Text(), envLine) {
-				file.Close()
-				log.Println("Environment variable already exists in ~/.bashrc.")
-				return nil
-			}
-		}
-		file.Close()
-		if err := scanner.Err(); err != nil {
-			log.Printf("Failed to read ~/.bashrc: %v\n", err)
-			return err
-		}
-	}
-	// 将环境变量追加到 ~/.bashrc 文件
-	file, err = os.OpenFile(bashrcPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Printf("Failed to open ~/.bashrc for appending: %v\n", err)
-		return err
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(envLine + "\n")
-	if err != nil {
-		log.Printf("Failed to write environment variable to ~/.bashrc: %v\n", err)
-		return err
-	}
-
-	log.Println("Environment variable added to ~/.bashrc successfully.")
-	return nil
+	return os.Chmod(dataPath, mode)
 }
 
 /**
  *	安装包数据
+ *	配置包只把文件拷贝到安装目录，只有可执行程序包才需要把安装目录加入PATH(修改~/.bashrc或用户环境变量)
  */
 func (u *Upgrader) installPackage(pkg PackageVersion, cacheFname string) error {
 	if err := u.savePackageData(pkg, cacheFname); err != nil {
 		return err
 	}
-	if pkg.PackageType != PackageTypeExec {
-		return nil
-	}
-	if u.NoSetPath {
+	if pkg.PackageType != PackageTypeExec || u.NoSetPath {
 		return nil
 	}
 	if runtime.GOOS == "windows" {
@@ -981,5 +1116,8 @@ func (u *Upgrader) correct() {
 		u.BaseDir = getCostrictDir()
 	}
 	u.installDir = filepath.Join(u.BaseDir, "bin")
-	u.packageDir = filepath.Join(u.BaseDir, "package")
+	u.packageDir = u.PackageDir
+	if u.packageDir == "" {
+		u.packageDir = filepath.Join(u.BaseDir, "package")
+	}
 }
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCheckPlatform(t *testing.T) {
+	u := NewUpgrader("demo", UpgradeConfig{BaseDir: t.TempDir(), Os: "linux", Arch: "amd64"})
+	cases := []struct {
+		os, arch string
+		ok       bool
+	}{
+		{"linux", "amd64", true},
+		{"", "", true}, // 未指定的字段不检查
+		{"windows", "amd64", false},
+		{"linux", "arm64", false},
+	}
+	for _, c := range cases {
+		err := u.checkPlatform(PackageVersion{PackageName: "demo", Os: c.os, Arch: c.arch})
+		if (err == nil) != c.ok {
+			t.Errorf("checkPlatform(%s/%s) = %v, want ok=%v", c.os, c.arch, err, c.ok)
+		}
+	}
+}
+
+func TestUpgradePackagePlatformMismatch(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ver := VersionNumber{Major: 1, Minor: 0, Micro: 0}
+	files := map[string]any{
+		"/demo/linux/amd64/platform.json": PlatformInfo{
+			PackageName: "demo", Os: "linux", Arch: "amd64",
+			Newest: VersionAddr{
+				VersionId: ver,
+				AppUrl:    "/demo/linux/amd64/1.0.0/demo",
+				InfoUrl:   "/demo/linux/amd64/1.0.0/package.json",
+			},
+		},
+		// 服务器配置错误，返回了Windows的包
+		"/demo/linux/amd64/1.0.0/package.json": PackageVersion{
+			PackageName: "demo", PackageType: PackageTypeExec, FileName: "demo.exe",
+			Os: "windows", Arch: "amd64", VersionId: ver,
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(data)
+	}))
+	defer server.Close()
+
+	base := t.TempDir()
+	u := NewUpgrader("demo", UpgradeConfig{BaseUrl: server.URL, BaseDir: base, Os: "linux", Arch: "amd64", NoSetPath: true})
+	_, upgraded, err := u.UpgradePackage(nil)
+	if err == nil || upgraded || !strings.Contains(err.Error(), "built for os 'windows'") {
+		t.Fatalf("mismatched package should be rejected, upgraded=%v err=%v", upgraded, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range requested {
+		if strings.HasSuffix(path, "/demo") {
+			t.Errorf("package of another platform is downloaded: %v", requested)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(base, "bin")); len(entries) != 0 {
+		t.Errorf("nothing should be installed: %v", entries)
+	}
+}
+
+/**
+ * Start a server publishing version 1.0.0 of the package for the current platform
+ * @param {PackageVersion} pkg - Descriptor of the package, checksum and signature are filled in
+ * @param {string} content - Content of the package file
+ * @returns {string} Returns base URL of the server
+ * @returns {[]byte} Returns public key to verify the package
+ */
+func newPackageServer(t *testing.T, pkg PackageVersion, content string) (string, []byte) {
+	t.Helper()
+	pubKey, priKey := GenKeys()
+	pkg.VersionId = VersionNumber{Major: 1}
+	pkg.Os, pkg.Arch = runtime.GOOS, runtime.GOARCH
+	pkg.Checksum = fmt.Sprintf("%x", md5.Sum([]byte(content)))
+	pkg.ChecksumAlgo = "md5"
+	sign, err := Sign(priKey, []byte(pkg.Checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.Sign = hex.EncodeToString(sign)
+
+	dir := fmt.Sprintf("/%s/%s/%s", pkg.PackageName, runtime.GOOS, runtime.GOARCH)
+	addr := VersionAddr{
+		VersionId: pkg.VersionId,
+		AppUrl:    dir + "/1.0.0/" + pkg.FileName,
+		InfoUrl:   dir + "/1.0.0/package.json",
+	}
+	platform, _ := json.Marshal(PlatformInfo{
+		PackageName: pkg.PackageName, Os: runtime.GOOS, Arch: runtime.GOARCH,
+		Newest: addr, Versions: []VersionAddr{addr},
+	})
+	info, _ := json.Marshal(pkg)
+	files := map[string][]byte{
+		dir + "/platform.json": platform,
+		addr.InfoUrl:           info,
+		addr.AppUrl:            []byte(content),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, pubKey
+}
+
+func TestUpgradeConfPackage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("PATH of windows is set in the registry")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/bash")
+	t.Setenv("PATH", os.Getenv("PATH"))
+
+	install := func(pkg PackageVersion) string {
+		url, pubKey := newPackageServer(t, pkg, pkg.PackageName+" content")
+		base := t.TempDir()
+		u := NewUpgrader(pkg.PackageName, UpgradeConfig{BaseUrl: url, BaseDir: base, PublicKey: string(pubKey)})
+		if _, upgraded, err := u.UpgradePackage(nil); err != nil || !upgraded {
+			t.Fatalf("install %s failed, upgraded=%v err=%v", pkg.PackageName, upgraded, err)
+		}
+		return base
+	}
+
+	base := install(PackageVersion{PackageName: "settings", PackageType: PackageTypeConf, FileName: "settings.json"})
+	st, err := os.Stat(filepath.Join(base, "bin", "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0644 {
+		t.Errorf("mode of conf package = %o, want 644", st.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(home, ".bashrc")); !os.IsNotExist(err) {
+		t.Errorf("conf package shouldn't modify .bashrc: %v", err)
+	}
+
+	// 对照：可执行程序包设置为0755，并把安装目录加入PATH
+	base = install(PackageVersion{PackageName: "tool", PackageType: PackageTypeExec, FileName: "tool"})
+	if st, err := os.Stat(filepath.Join(base, "bin", "tool")); err != nil || st.Mode().Perm() != 0755 {
+		t.Errorf("exec package isn't installed with mode 755: %v %v", st, err)
+	}
+	if rc, _ := os.ReadFile(filepath.Join(home, ".bashrc")); !strings.Contains(string(rc), filepath.Join(base, "bin")) {
+		t.Errorf("install directory of exec package isn't added to PATH: %s", rc)
+	}
+}
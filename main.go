@@ -22,6 +22,7 @@ import (
 	"costrict-keeper/cmd/root"
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/logger"
+	"costrict-keeper/internal/rpc"
 	"os"
 )
 
@@ -30,6 +31,7 @@ func main() {
 	isServerMode := len(os.Args) > 1 && os.Args[1] == "server"
 	config.LoadConfig(true)
 	cfg := config.App()
+	rpc.SetApiToken(cfg.Api.Token)
 	logger.InitLogger(cfg.Log.Path, cfg.Log.Level, isServerMode, cfg.Log.MaxSize, cfg.Log.Backup)
 
 	if err := root.RootCmd.Execute(); err != nil {
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/logger"
@@ -8,8 +9,16 @@ import (
 	"costrict-keeper/internal/utils"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 )
 
+// 批量升级时并发下载安装包的最大数量
+const upgradeWorkers = 3
+
 var ErrComponentNotFound = errors.New("component not found")
 
 type ComponentInstance struct {
@@ -17,6 +26,7 @@ type ComponentInstance struct {
 	local       *utils.PackageVersion
 	remote      *utils.PlatformInfo
 	installed   bool
+	incomplete  bool // 安装后钩子执行失败，组件未完整安装
 	needUpgrade bool
 }
 
@@ -54,6 +64,7 @@ func (ci *ComponentInstance) GetDetail() models.ComponentDetail {
 		Local:       models.PackageDetail{},
 		Remote:      models.PackageRepo{},
 		Installed:   ci.installed,
+		Incomplete:  ci.incomplete,
 		NeedUpgrade: ci.needUpgrade,
 	}
 	if ci.local != nil {
@@ -91,9 +102,12 @@ func (ci *ComponentInstance) GetDetail() models.ComponentDetail {
  */
 func (ci *ComponentInstance) fetchComponentInfo() error {
 	u := utils.NewUpgrader(ci.spec.Name, utils.UpgradeConfig{
-		BaseUrl: config.Cloud().UpgradeUrl,
-		BaseDir: env.CostrictDir,
+		BaseUrl:    ci.upgradeUrl(),
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		Mirrors:    config.App().Component.Mirrors,
 	})
+	notified := ci.needUpgrade
 	ci.needUpgrade = false
 	ci.installed = false
 	local, err := u.GetLocalVersion(nil)
@@ -106,11 +120,26 @@ func (ci *ComponentInstance) fetchComponentInfo() error {
 		ci.remote = &remote
 		if utils.CompareVersion(local.VersionId, remote.Newest.VersionId) < 0 {
 			ci.needUpgrade = true
+			if !notified {
+				GetEventBus().Publish(models.EventUpgradeAvailable, ci.spec.Name, ci.GetDetail())
+			}
 		}
 	}
 	return nil
 }
 
+/**
+ * Get the base URL the component is upgraded from
+ * @returns {string} Returns upgrade_url of the component spec, or the global cloud.upgrade_url if unset
+ * @private
+ */
+func (ci *ComponentInstance) upgradeUrl() string {
+	if ci.spec.UpgradeUrl != "" {
+		return ci.spec.UpgradeUrl
+	}
+	return config.Cloud().UpgradeUrl
+}
+
 /**
  * Upgrade component to latest version
  * @param {ComponentInstance} component - Component instance to upgrade
@@ -126,22 +155,48 @@ func (ci *ComponentInstance) fetchComponentInfo() error {
  * - Configuration errors
  * @private
  */
-func (ci *ComponentInstance) upgradeComponent() error {
-	// 解析版本号 - 由于新结构体中没有版本信息，使用默认版本
+func (ci *ComponentInstance) upgradeComponent(specVer *utils.VersionNumber) error {
+	// specVer为nil时升级到最新版本
 	u := utils.NewUpgrader(ci.spec.Name, utils.UpgradeConfig{
-		BaseUrl: config.Cloud().UpgradeUrl,
-		BaseDir: env.CostrictDir,
+		BaseUrl:    ci.upgradeUrl(),
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		PublicKey:  config.App().Component.PublicKey,
+		Mirrors:    config.App().Component.Mirrors,
+		Retries:    config.App().Component.VerifyRetries,
+		RateLimit:  config.App().Component.RateLimit,
+		NoSetPath:  config.App().Component.NoSetPath,
 	})
-	pkg, upgraded, err := u.UpgradePackage(nil)
+	rec := models.UpgradeRecord{Component: ci.spec.Name}
+	if ci.local != nil {
+		rec.From = ci.local.VersionId.String()
+	}
+	pkg, upgraded, err := u.UpgradePackage(specVer)
 	if err != nil {
 		logger.Errorf("The '%s' upgrade failed: %v", ci.spec.Name, err)
+		info := models.FailureInfo{Error: err.Error()}
+		if specVer != nil {
+			info.Version = specVer.String()
+		}
+		GetNotifyManager().Notify(models.EventUpgradeFailed, ci.spec.Name, info)
+		rec.To = info.Version
+		rec.Result = models.UpgradeFailed
+		rec.Error = err.Error()
+		rec.Time = time.Now()
+		RecordUpgrade(rec)
 		return err
 	}
 	ci.local = &pkg
+	ci.installed = true
 	if !upgraded {
 		logger.Infof("The '%s' version is up to date\n", ci.spec.Name)
 	} else {
 		logger.Infof("The '%s' is upgraded to version %s\n", ci.spec.Name, pkg.VersionId.String())
+		rec.To = pkg.VersionId.String()
+		rec.Result = models.UpgradeSuccess
+		rec.Time = time.Now()
+		RecordUpgrade(rec)
+		ci.postInstall(u)
 	}
 	vers, err := u.GetRemoteVersions()
 	if err != nil {
@@ -149,9 +204,70 @@ func (ci *ComponentInstance) upgradeComponent() error {
 		return err
 	}
 	ci.remote = &vers
+	ci.needUpgrade = utils.CompareVersion(pkg.VersionId, vers.Newest.VersionId) < 0
+	return err
+}
+
+/**
+ * Download and verify the newest package of the component without activating it
+ * @returns {error} Returns error if the package can't be downloaded or verified
+ * @private
+ */
+func (ci *ComponentInstance) fetchPackage() error {
+	u := utils.NewUpgrader(ci.spec.Name, utils.UpgradeConfig{
+		BaseUrl:    ci.upgradeUrl(),
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		PublicKey:  config.App().Component.PublicKey,
+		Mirrors:    config.App().Component.Mirrors,
+		Retries:    config.App().Component.VerifyRetries,
+		RateLimit:  config.App().Component.RateLimit,
+	})
+	_, _, err := u.GetPackage(nil)
 	return err
 }
 
+/**
+ * Run post-install hook of the component once for the installed version
+ * @param {utils.Upgrader} u - Upgrader of the component, used to record the hook result
+ * @description
+ * - Does nothing if the component has no hook or the hook already ran for this version
+ * - Records in the local package metadata that the hook ran, so it isn't repeated on restart
+ * - Marks the component incomplete if the hook fails, the hook is retried on next start
+ * @private
+ */
+func (ci *ComponentInstance) postInstall(u *utils.Upgrader) {
+	ci.incomplete = false
+	if ci.spec.PostInstall == "" || ci.local == nil || ci.local.PostInstalled {
+		return
+	}
+	if err := ci.runPostInstall(); err != nil {
+		logger.Errorf("The '%s' post-install hook failed: %v", ci.spec.Name, err)
+		ci.incomplete = true
+		return
+	}
+	ci.local.PostInstalled = true
+	if err := u.SaveLocalVersion(*ci.local); err != nil {
+		logger.Errorf("Record post-install of '%s' failed: %v", ci.spec.Name, err)
+	}
+	logger.Infof("The '%s' post-install hook is done for version %s", ci.spec.Name, ci.local.VersionId.String())
+}
+
+func (ci *ComponentInstance) runPostInstall() error {
+	args := newServiceArgs(ci.spec.Name, 0)
+	script, err := utils.GetShellScript(ci.spec.PostInstall, nil, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	output, err := utils.ShellCommand(ctx, script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w, output: %s", err, string(output))
+	}
+	return nil
+}
+
 /**
  * Remove specified component
  */
@@ -161,7 +277,8 @@ func (ci *ComponentInstance) removeComponent() error {
 		return fmt.Errorf("component '%s' is not installed", ci.spec.Name)
 	}
 	u := utils.NewUpgrader(ci.spec.Name, utils.UpgradeConfig{
-		BaseDir: env.CostrictDir,
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
 	})
 	// Remove the package
 	if err := u.RemovePackage(nil); err != nil {
@@ -190,6 +307,12 @@ func (cm *ComponentManager) Init() error {
 			spec: cpn,
 		}
 		ci.fetchComponentInfo()
+		if ci.installed {
+			ci.postInstall(utils.NewUpgrader(cpn.Name, utils.UpgradeConfig{
+				BaseDir:    env.CostrictDir,
+				PackageDir: env.GetPackageDir(),
+			}))
+		}
 		componentManager.components[cpn.Name] = &ci
 	}
 	componentManager.self.spec = config.Spec().Manager.Component
@@ -218,7 +341,79 @@ func (cm *ComponentManager) UpgradeComponent(name string) error {
 	if !cpn.needUpgrade {
 		return nil
 	}
-	return cpn.upgradeComponent()
+	return cpn.upgradeComponent(nil)
+}
+
+/**
+* Upgrade components in batch
+* @param {models.ComponentUpgradeRequest} req - Components to upgrade and their target versions
+* @returns {[]models.ComponentUpgradeResult} Returns upgrade result of each component
+* @description
+* - Upgrades all components if no names are specified, components not targeted are untouched
+* - Components without target version are upgraded to the newest version if they need upgrade
+* - Continues with other components if one fails
+* - Progress of each component is published as upgrade_progress events
+ */
+func (cm *ComponentManager) UpgradeComponents(req models.ComponentUpgradeRequest) []models.ComponentUpgradeResult {
+	names := req.Names
+	if len(names) == 0 {
+		for name := range cm.components {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	results := []models.ComponentUpgradeResult{}
+	for i, name := range names {
+		result := models.ComponentUpgradeResult{Name: name}
+		cpn, ok := cm.components[name]
+		if ok && cpn.local != nil {
+			result.OldVersion = cpn.local.VersionId.String()
+		}
+		var err error
+		switch version := req.Versions[name]; {
+		case !ok:
+			err = ErrComponentNotFound
+		case version != "":
+			reportUpgrade(name, models.UpgradeActivating, i+1, len(names), nil)
+			err = cm.upgradeComponentTo(cpn, version)
+		case cpn.needUpgrade:
+			err = cpn.upgradeNewest(i+1, len(names))
+		}
+		if err != nil {
+			result.Error = err.Error()
+			reportUpgrade(name, models.UpgradeFailure, i+1, len(names), err)
+		} else {
+			reportUpgrade(name, models.UpgradeDone, i+1, len(names), nil)
+		}
+		if ok && cpn.local != nil {
+			result.NewVersion = cpn.local.VersionId.String()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 下载并校验最新版本后再安装，按阶段报告进度
+func (ci *ComponentInstance) upgradeNewest(index, total int) error {
+	reportUpgrade(ci.spec.Name, models.UpgradeDownloading, index, total, nil)
+	if err := ci.fetchPackage(); err != nil {
+		return err
+	}
+	reportUpgrade(ci.spec.Name, models.UpgradeVerified, index, total, nil)
+	reportUpgrade(ci.spec.Name, models.UpgradeActivating, index, total, nil)
+	return ci.upgradeComponent(nil)
+}
+
+// 升级组件到指定版本
+func (cm *ComponentManager) upgradeComponentTo(cpn *ComponentInstance, version string) error {
+	var ver utils.VersionNumber
+	if err := ver.Parse(version); err != nil {
+		return fmt.Errorf("invalid version '%s': %w", version, err)
+	}
+	if cpn.local != nil && utils.CompareVersion(cpn.local.VersionId, ver) == 0 {
+		return nil
+	}
+	return cpn.upgradeComponent(&ver)
 }
 
 /**
@@ -308,63 +503,242 @@ func (cm *ComponentManager) GetComponent(name string) *ComponentInstance {
 	return nil
 }
 
+/**
+ * Verify installed component against its signed package metadata
+ * @param {string} name - Component name
+ * @returns {models.ComponentVerifyResult} Returns details of the checks, passed is false if any check fails
+ * @returns {error} Returns ErrComponentNotFound if the component isn't defined
+ * @description
+ * - Recomputes checksum of the installed file and compares it with <pkg>.json
+ * - Re-verifies the signature of <pkg>.json against the trusted public key
+ */
+func (cm *ComponentManager) VerifyComponent(name string) (models.ComponentVerifyResult, error) {
+	if cm.GetComponent(name) == nil {
+		return models.ComponentVerifyResult{}, ErrComponentNotFound
+	}
+	return VerifyPackage(name), nil
+}
+
+/**
+ * Verify installed package against its signed package metadata
+ * @param {string} name - Package name
+ * @returns {models.ComponentVerifyResult} Returns details of the checks
+ * @description
+ * - Doesn't require the component to be defined in system specification,
+ *   so the CLI can verify packages without a running keeper
+ */
+func VerifyPackage(name string) models.ComponentVerifyResult {
+	u := utils.NewUpgrader(name, utils.UpgradeConfig{
+		PublicKey:  config.App().Component.PublicKey,
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+	})
+	report, err := u.VerifyInstalled()
+	result := models.ComponentVerifyResult{
+		Name:             name,
+		FilePath:         report.FilePath,
+		ExpectedChecksum: report.Expected,
+		ActualChecksum:   report.Actual,
+		ChecksumOK:       report.ChecksumOK,
+		SignatureOK:      report.SignatureOK,
+		Passed:           err == nil,
+	}
+	if report.FilePath != "" {
+		result.Version = report.Version.String()
+	}
+	if err != nil {
+		result.Error = err.Error()
+		logger.Warnf("Verify component '%s' failed: %v", name, err)
+	}
+	return result
+}
+
 /**
  * Upgrade all components that need updates
+ * @param {bool} includeSelf - Whether the manager itself is upgraded too
  * @returns {error} Returns nil (always returns nil for backward compatibility)
  * @description
- * - Iterates through all managed components
+ * - Iterates through all managed components, configs first
  * - Checks if each component needs upgrade (needUpgrade flag)
- * - Calls upgradeComponent for each component that needs upgrade
+ * - Downloads packages by a bounded worker pool, then activates them one by one,
+ *   since activation may modify shared files such as shell profile
+ * - Reports progress of each component in logs and upgrade_progress events
+ * - Upgrades the manager itself only if includeSelf is true, the new binary
+ *   is validated before activation and takes effect after the manager restarts
  * - Logs upgrade operations and results
  * - Continues processing even if some upgrades fail
  * @example
  * manager := GetComponentManager()
- * if err := manager.UpgradeAll(); err != nil {
+ * if err := manager.UpgradeAll(false); err != nil {
  *     logger.Error("Some upgrades failed")
  * }
  */
-func (cm *ComponentManager) UpgradeAll() error {
-	for _, cpn := range cm.configs {
-		if cpn.needUpgrade {
-			cpn.upgradeComponent()
+func (cm *ComponentManager) UpgradeAll(includeSelf bool) error {
+	pending := append(pendingUpgrades(cm.configs), pendingUpgrades(cm.components)...)
+	total := len(pending)
+	if total > 0 {
+		logger.Infof("Upgrading %d components", total)
+	}
+	fetched := fetchPackages(pending)
+	for i, cpn := range pending {
+		if !fetched[i] {
+			continue
 		}
+		reportUpgrade(cpn.spec.Name, models.UpgradeActivating, i+1, total, nil)
+		if err := cpn.upgradeComponent(nil); err != nil {
+			reportUpgrade(cpn.spec.Name, models.UpgradeFailure, i+1, total, err)
+			continue
+		}
+		reportUpgrade(cpn.spec.Name, models.UpgradeDone, i+1, total, nil)
 	}
-	for _, cpn := range cm.components {
-		if cpn.needUpgrade {
-			cpn.upgradeComponent()
+	if includeSelf && cm.self.needUpgrade {
+		if err := cm.upgradeSelf(); err != nil {
+			logger.Errorf("The '%s' self-upgrade failed: %v", cm.self.spec.Name, err)
 		}
 	}
 	u := utils.NewUpgrader("", utils.UpgradeConfig{
-		BaseDir: env.CostrictDir,
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
 	})
 	u.CleanupOldVersions()
 	return nil
 }
 
+// 需要升级的组件，按名称排序以便进度顺序稳定
+func pendingUpgrades(cpns map[string]*ComponentInstance) []*ComponentInstance {
+	var pending []*ComponentInstance
+	for _, cpn := range cpns {
+		if cpn.needUpgrade {
+			pending = append(pending, cpn)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].spec.Name < pending[j].spec.Name
+	})
+	return pending
+}
+
 /**
- * Check components for updates and upgrade if needed
- * @returns {error} Returns error if check or upgrade fails, nil on success
+ * Download packages of components by a bounded worker pool
+ * @param {[]*ComponentInstance} pending - Components to be upgraded
+ * @returns {[]bool} Returns whether the package of each component is downloaded and verified
+ * @private
+ */
+func fetchPackages(pending []*ComponentInstance) []bool {
+	total := len(pending)
+	fetched := make([]bool, total)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(upgradeWorkers, total); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				cpn := pending[i]
+				reportUpgrade(cpn.spec.Name, models.UpgradeDownloading, i+1, total, nil)
+				if err := cpn.fetchPackage(); err != nil {
+					reportUpgrade(cpn.spec.Name, models.UpgradeFailure, i+1, total, err)
+					GetNotifyManager().Notify(models.EventUpgradeFailed, cpn.spec.Name, models.FailureInfo{Error: err.Error()})
+					continue
+				}
+				fetched[i] = true
+				reportUpgrade(cpn.spec.Name, models.UpgradeVerified, i+1, total, nil)
+			}
+		}()
+	}
+	for i := range pending {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return fetched
+}
+
+// 记录升级进度，并推送给事件订阅者
+func reportUpgrade(name string, stage models.UpgradeStage, index, total int, err error) {
+	progress := models.UpgradeProgress{
+		Stage: stage,
+		Index: index,
+		Total: total,
+	}
+	if err != nil {
+		progress.Error = err.Error()
+		logger.Errorf("Upgrade [%d/%d] '%s' %s: %v", index, total, name, stage, err)
+	} else {
+		logger.Infof("Upgrade [%d/%d] '%s' %s", index, total, name, stage)
+	}
+	GetEventBus().Publish(models.EventUpgradeProgress, name, progress)
+}
+
+/**
+ * Upgrade the manager itself to the newest version
+ * @returns {error} Returns error if the new package can't be fetched, validated or activated
  * @description
- * - Checks all components for available updates
- * - Upgrades components that have newer versions available
- * - Uses mutex to prevent concurrent check operations
- * - Logs upgrade operations and results
- * @throws
- * - Component check errors
- * - Component upgrade errors
+ * - Downloads the newest package, its checksum and signature are verified by the upgrader
+ * - Runs 'version' command of the downloaded binary to make sure it is executable
+ * - Activates the package only after validation passed, the running process is not touched
+ * @private
+ */
+func (cm *ComponentManager) upgradeSelf() error {
+	u := utils.NewUpgrader(cm.self.spec.Name, utils.UpgradeConfig{
+		BaseUrl:    cm.self.upgradeUrl(),
+		BaseDir:    env.CostrictDir,
+		PackageDir: env.GetPackageDir(),
+		PublicKey:  config.App().Component.PublicKey,
+		Mirrors:    config.App().Component.Mirrors,
+		Retries:    config.App().Component.VerifyRetries,
+		RateLimit:  config.App().Component.RateLimit,
+	})
+	pkg, fetched, err := u.GetPackage(nil)
+	if err != nil {
+		return err
+	}
+	if !fetched {
+		return nil
+	}
+	if err := validateSelfPackage(pkg); err != nil {
+		GetNotifyManager().Notify(models.EventSelfUpgradeInvalid, cm.self.spec.Name, models.FailureInfo{
+			Version: pkg.VersionId.String(),
+			Error:   err.Error(),
+		})
+		return fmt.Errorf("validate version %s: %w", pkg.VersionId.String(), err)
+	}
+	ver := pkg.VersionId
+	return cm.self.upgradeComponent(&ver)
+}
+
+// 运行下载的新版本程序，确认其可以正常执行
+func validateSelfPackage(pkg utils.PackageVersion) error {
+	if pkg.PackageType != utils.PackageTypeExec {
+		return fmt.Errorf("unexpected package type '%s'", pkg.PackageType)
+	}
+	_, fname := filepath.Split(pkg.FileName)
+	binPath := filepath.Join(env.GetPackageDir(), pkg.VersionId.String(), fname)
+	if err := os.Chmod(binPath, 0755); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := utils.ShellCommand(ctx, fmt.Sprintf("\"%s\" version", binPath)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("run '%s' failed: %w, output: %s", binPath, err, string(output))
+	}
+	return nil
+}
+
+/**
+ * Check components for updates
+ * @returns {int} Returns number of components which need upgrade
+ * @description
+ * - Checks all components, including self and configurations, for available updates
+ * - Each component is checked exactly once
+ * - Logs components which have newer versions available
  */
 func (cm *ComponentManager) CheckComponents() int {
 	logger.Info("Starting component update check...")
 
 	upgradeCount := 0
-	components := []*ComponentInstance{&cm.self}
-	for _, cpn := range cm.components {
-		components = append(components, cpn)
-	}
-	for _, cpn := range cm.configs {
-		components = append(components, cpn)
-	}
-	for _, cpn := range components {
+	for _, cpn := range cm.GetComponents(true, true) {
 		// Refresh component information to get latest version
 		if err := cpn.fetchComponentInfo(); err != nil {
 			logger.Errorf("Failed to fetch component info for %s: %v", cpn.spec.Name, err)
@@ -372,12 +746,17 @@ func (cm *ComponentManager) CheckComponents() int {
 		}
 		// Check if upgrade is needed
 		if cpn.needUpgrade {
+			// 未安装的组件没有本地版本
+			from := "none"
+			if cpn.installed {
+				from = cpn.local.VersionId.String()
+			}
 			logger.Infof("Component %s needs upgrade from %s to %s", cpn.spec.Name,
-				cpn.local.VersionId.String(), cpn.remote.Newest.VersionId.String())
+				from, cpn.remote.Newest.VersionId.String())
 			upgradeCount++
 		}
 	}
 
-	logger.Infof("Component update check completed. %d components upgraded.", upgradeCount)
+	logger.Infof("Component update check completed. %d components need upgrade.", upgradeCount)
 	return upgradeCount
 }
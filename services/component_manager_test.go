@@ -0,0 +1,356 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/utils"
+)
+
+/**
+ * Create a component manager with the given components, packages are upgraded from srvUrl
+ * @param {string} srvUrl - Base URL of the upgrade server
+ * @param {...string} names - Names of components
+ */
+func newTestComponentManager(t *testing.T, srvUrl string, names ...string) *ComponentManager {
+	t.Helper()
+	componentManager = nil
+	t.Cleanup(func() { componentManager = nil })
+	cm := GetComponentManager()
+	for _, name := range names {
+		ci := &ComponentInstance{spec: models.ComponentSpecification{Name: name, UpgradeUrl: srvUrl}}
+		ci.fetchComponentInfo()
+		cm.components[name] = ci
+	}
+	return cm
+}
+
+// 读取已安装的组件文件内容
+func readInstalled(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(env.CostrictDir, "bin", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUpgradeComponentsSubset(t *testing.T) {
+	setupTestEnv(t, "")
+	var pkgs []testPackage
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		for _, ver := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+			pkgs = append(pkgs, testPackage{name: name, version: ver, content: name + " " + ver})
+		}
+	}
+	srv := newUpgradeServer(t, pkgs...)
+	cm := newTestComponentManager(t, srv.URL, "alpha", "beta", "gamma")
+
+	// 先把所有组件安装为1.0.0
+	install := models.ComponentUpgradeRequest{
+		Versions: map[string]string{"alpha": "1.0.0", "beta": "1.0.0", "gamma": "1.0.0"},
+	}
+	for _, r := range cm.UpgradeComponents(install) {
+		if r.Error != "" || r.NewVersion != "1.0.0" {
+			t.Fatalf("install %s failed: %+v", r.Name, r)
+		}
+	}
+
+	results := cm.UpgradeComponents(models.ComponentUpgradeRequest{
+		Names:    []string{"alpha", "beta"},
+		Versions: map[string]string{"beta": "1.1.0"},
+	})
+	expected := []models.ComponentUpgradeResult{
+		{Name: "alpha", OldVersion: "1.0.0", NewVersion: "1.2.0"},
+		{Name: "beta", OldVersion: "1.0.0", NewVersion: "1.1.0"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), results)
+	}
+	for i, r := range results {
+		if r != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], r)
+		}
+	}
+	if got := readInstalled(t, "alpha"); got != "alpha 1.2.0" {
+		t.Errorf("alpha isn't upgraded to the newest version: %q", got)
+	}
+	if got := readInstalled(t, "beta"); got != "beta 1.1.0" {
+		t.Errorf("beta isn't upgraded to the specified version: %q", got)
+	}
+	// 未指定的组件不受影响
+	if got := readInstalled(t, "gamma"); got != "gamma 1.0.0" {
+		t.Errorf("gamma shouldn't be upgraded: %q", got)
+	}
+	if v := cm.components["gamma"].local.VersionId.String(); v != "1.0.0" {
+		t.Errorf("gamma version shouldn't change: %s", v)
+	}
+}
+
+func TestUpgradeComponentsUnknown(t *testing.T) {
+	setupTestEnv(t, "")
+	srv := newUpgradeServer(t, testPackage{name: "alpha", version: "1.0.0", content: "alpha"})
+	cm := newTestComponentManager(t, srv.URL, "alpha")
+
+	results := cm.UpgradeComponents(models.ComponentUpgradeRequest{Names: []string{"missing"}})
+	if len(results) != 1 || results[0].Error != ErrComponentNotFound.Error() {
+		t.Fatalf("unknown component should fail: %+v", results)
+	}
+	if cm.components["alpha"].installed {
+		t.Error("alpha shouldn't be installed")
+	}
+}
+
+func TestCheckComponentsOnce(t *testing.T) {
+	setupTestEnv(t, "")
+	srv := newUpgradeServer(t,
+		testPackage{name: "costrict", version: "1.0.0", content: "costrict"},
+		testPackage{name: "alpha", version: "1.0.0", content: "alpha"},
+		testPackage{name: "settings", version: "1.0.0", content: "settings"})
+	requests := map[string]int{}
+	var mu sync.Mutex
+	handler := srv.Config.Handler
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+	cm := newTestComponentManager(t, srv.URL, "alpha")
+	cm.self.spec = models.ComponentSpecification{Name: "costrict", UpgradeUrl: srv.URL}
+	cm.configs["settings"] = &ComponentInstance{spec: models.ComponentSpecification{Name: "settings", UpgradeUrl: srv.URL}}
+	clear(requests)
+
+	// 三个组件都未安装，都需要升级
+	if count := cm.CheckComponents(); count != 3 {
+		t.Fatalf("expected 3 components need upgrade, got %d", count)
+	}
+	for _, name := range []string{"costrict", "alpha", "settings"} {
+		path := fmt.Sprintf("/%s/%s/%s/platform.json", name, runtime.GOOS, runtime.GOARCH)
+		if requests[path] != 1 {
+			t.Errorf("component '%s' should be checked once, checked %d times", name, requests[path])
+		}
+	}
+}
+
+func TestUpgradeComponentCorruptDownload(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		corrupted int
+		succeeded bool
+	}{
+		{"default retries", 0, 1, true},
+		{"retries exhausted", 1, 2, false},
+		{"no retry", -1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestEnv(t, "")
+			config.App().Component.VerifyRetries = tt.retries
+			srv := newUpgradeServer(t, testPackage{name: "alpha", version: "1.0.0", content: "alpha 1.0.0"})
+			appPath := fmt.Sprintf("/alpha/%s/%s/1.0.0/alpha", runtime.GOOS, runtime.GOARCH)
+			downloads := 0
+			handler := srv.Config.Handler
+			srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == appPath {
+					downloads++
+					// 前几次下载返回损坏的内容，长度不变
+					if downloads <= tt.corrupted {
+						w.Write([]byte("alpha 0.0.0"))
+						return
+					}
+				}
+				handler.ServeHTTP(w, r)
+			})
+			cm := newTestComponentManager(t, srv.URL, "alpha")
+
+			results := cm.UpgradeComponents(models.ComponentUpgradeRequest{Names: []string{"alpha"}})
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %+v", results)
+			}
+			if tt.succeeded {
+				if results[0].Error != "" {
+					t.Fatalf("upgrade failed: %s", results[0].Error)
+				}
+				if got := readInstalled(t, "alpha"); got != "alpha 1.0.0" {
+					t.Errorf("installed content = %q, want the correct package", got)
+				}
+				if downloads != tt.corrupted+1 {
+					t.Errorf("downloaded %d times, want %d", downloads, tt.corrupted+1)
+				}
+				return
+			}
+			if results[0].Error == "" {
+				t.Fatal("upgrade should fail when the package stays corrupted")
+			}
+			if downloads != tt.corrupted {
+				t.Errorf("downloaded %d times, want %d", downloads, tt.corrupted)
+			}
+			if _, err := os.Stat(filepath.Join(env.CostrictDir, "bin", "alpha")); err == nil {
+				t.Error("corrupted package is installed")
+			}
+		})
+	}
+}
+
+func TestUpgradeComponentMirrorFailover(t *testing.T) {
+	// 侦听后立即关闭，得到一个无法连接的地址
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "http://" + ln.Addr().String()
+	ln.Close()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	tests := []struct {
+		name      string
+		primary   string
+		succeeded bool
+	}{
+		{"primary unreachable", unreachable, true},
+		{"primary returns 404", notFound.URL, false}, // 服务器返回的错误不换镜像
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestEnv(t, "")
+			mirror := newUpgradeServer(t, testPackage{name: "alpha", version: "1.0.0", content: "alpha 1.0.0"})
+			mirrored := 0
+			handler := mirror.Config.Handler
+			mirror.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mirrored++
+				handler.ServeHTTP(w, r)
+			})
+			config.App().Component.Mirrors = []string{mirror.URL}
+			cm := newTestComponentManager(t, tt.primary, "alpha")
+
+			results := cm.UpgradeComponents(models.ComponentUpgradeRequest{
+				Names:    []string{"alpha"},
+				Versions: map[string]string{"alpha": "1.0.0"},
+			})
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %+v", results)
+			}
+			if !tt.succeeded {
+				if results[0].Error == "" {
+					t.Error("upgrade should fail")
+				}
+				if mirrored != 0 {
+					t.Errorf("mirror is used %d times although the primary server is reachable", mirrored)
+				}
+				return
+			}
+			if results[0].Error != "" {
+				t.Fatalf("upgrade through the mirror failed: %s", results[0].Error)
+			}
+			if got := readInstalled(t, "alpha"); got != "alpha 1.0.0" {
+				t.Errorf("installed content = %q", got)
+			}
+			if mirrored == 0 {
+				t.Error("mirror isn't used")
+			}
+		})
+	}
+}
+
+func TestVerifyComponent(t *testing.T) {
+	setupTestEnv(t, "")
+	srv := newUpgradeServer(t, testPackage{name: "alpha", version: "1.0.0", content: "alpha 1.0.0"})
+	cm := newTestComponentManager(t, srv.URL, "alpha")
+	for _, r := range cm.UpgradeComponents(models.ComponentUpgradeRequest{Names: []string{"alpha"}}) {
+		if r.Error != "" {
+			t.Fatalf("install %s failed: %+v", r.Name, r)
+		}
+	}
+
+	result, err := cm.VerifyComponent("alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed || !result.ChecksumOK || !result.SignatureOK || result.Version != "1.0.0" {
+		t.Errorf("good install should pass: %+v", result)
+	}
+
+	// 篡改已安装的文件，校验和不一致，但包描述文件的签名仍然有效
+	os.WriteFile(filepath.Join(env.CostrictDir, "bin", "alpha"), []byte("alpha tampered"), 0755)
+	result, _ = cm.VerifyComponent("alpha")
+	if result.Passed || result.ChecksumOK || !result.SignatureOK {
+		t.Errorf("tampered file should fail the checksum check: %+v", result)
+	}
+	if result.ExpectedChecksum == result.ActualChecksum || result.Error == "" {
+		t.Errorf("details of the mismatch aren't reported: %+v", result)
+	}
+
+	// 可信公钥换掉后，原有签名无法通过验证
+	pubKey, _ := utils.GenKeys()
+	config.App().Component.PublicKey = string(pubKey)
+	result, _ = cm.VerifyComponent("alpha")
+	if result.Passed || result.SignatureOK {
+		t.Errorf("signature by an untrusted key should fail: %+v", result)
+	}
+
+	if _, err := cm.VerifyComponent("missing"); err != ErrComponentNotFound {
+		t.Errorf("unknown component: err = %v, want ErrComponentNotFound", err)
+	}
+}
+
+func TestComponentUpgradeUrlOverride(t *testing.T) {
+	setupTestEnv(t, "")
+	override := newUpgradeServer(t,
+		testPackage{name: "mirrored", version: "1.0.0", content: "mirrored 1.0.0"},
+		testPackage{name: "mirrored", version: "1.1.0", content: "mirrored 1.1.0"},
+	)
+	// 全局升级地址只记录请求的路径
+	var mu sync.Mutex
+	var globalPaths []string
+	global := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		globalPaths = append(globalPaths, r.URL.Path)
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer global.Close()
+	config.Cloud().UpgradeUrl = global.URL
+
+	cm := newTestComponentManager(t, override.URL, "mirrored")
+	plain := &ComponentInstance{spec: models.ComponentSpecification{Name: "plain"}}
+	plain.fetchComponentInfo()
+	cm.components["plain"] = plain
+
+	mirrored := cm.components["mirrored"]
+	if mirrored.remote == nil || mirrored.remote.Newest.VersionId.String() != "1.1.0" {
+		t.Fatalf("versions of mirrored aren't fetched from the override: %+v", mirrored.remote)
+	}
+	results := cm.UpgradeComponents(models.ComponentUpgradeRequest{Names: []string{"mirrored"}})
+	if len(results) != 1 || results[0].Error != "" || results[0].NewVersion != "1.1.0" {
+		t.Fatalf("upgrade from the override failed: %+v", results)
+	}
+	if got := readInstalled(t, "mirrored"); got != "mirrored 1.1.0" {
+		t.Errorf("installed mirrored = %q, want the package of the override", got)
+	}
+
+	// 未指定upgrade_url的组件仍使用全局地址，指定了的组件从不访问全局地址
+	mu.Lock()
+	defer mu.Unlock()
+	if len(globalPaths) == 0 {
+		t.Error("component without override doesn't fetch from the global upgrade URL")
+	}
+	for _, path := range globalPaths {
+		if !strings.HasPrefix(path, "/plain/") {
+			t.Errorf("global upgrade URL is requested for %s", path)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+//go:build linux || darwin
+
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/utils"
+)
+
+// 模拟管理程序的脚本，执行version命令时以code退出
+func selfScript(code string) string {
+	return "#!/bin/sh\necho costrict\nexit " + code + "\n"
+}
+
+/**
+ * Create a component manager whose self component is installed as version 1.0.0
+ * @param {string} newest - Content of the newest (1.1.0) package of the manager
+ */
+func newSelfUpgradeManager(t *testing.T, newest string) *ComponentManager {
+	t.Helper()
+	setupTestEnv(t, "")
+	srv := newUpgradeServer(t,
+		testPackage{name: "costrict", version: "1.0.0", content: selfScript("0")},
+		testPackage{name: "costrict", version: "1.1.0", content: newest},
+		testPackage{name: "alpha", version: "1.0.0", content: "alpha"})
+	cm := newTestComponentManager(t, srv.URL, "alpha")
+	cm.self.spec = models.ComponentSpecification{Name: "costrict", UpgradeUrl: srv.URL}
+	ver := utils.VersionNumber{Major: 1}
+	if err := cm.self.upgradeComponent(&ver); err != nil {
+		t.Fatal(err)
+	}
+	if !cm.self.needUpgrade {
+		t.Fatal("self should need upgrade")
+	}
+	return cm
+}
+
+func TestUpgradeAllIncludeSelf(t *testing.T) {
+	cm := newSelfUpgradeManager(t, selfScript("0"))
+	cm.UpgradeAll(true)
+	if v := cm.self.local.VersionId.String(); v != "1.1.0" {
+		t.Fatalf("self should be upgraded to 1.1.0, got %s", v)
+	}
+	if got := readInstalled(t, "costrict"); got != selfScript("0") {
+		t.Fatalf("new version of self isn't installed: %q", got)
+	}
+	if !cm.components["alpha"].installed {
+		t.Fatal("components should be upgraded too")
+	}
+}
+
+func TestUpgradeAllExcludeSelf(t *testing.T) {
+	cm := newSelfUpgradeManager(t, selfScript("0"))
+	cm.UpgradeAll(false)
+	if v := cm.self.local.VersionId.String(); v != "1.0.0" {
+		t.Fatalf("self shouldn't be upgraded, got %s", v)
+	}
+	if !cm.components["alpha"].installed {
+		t.Fatal("components should be upgraded")
+	}
+}
+
+func TestUpgradeAllInvalidSelf(t *testing.T) {
+	// 新版本无法正常执行，不能激活
+	cm := newSelfUpgradeManager(t, selfScript("1"))
+	cm.UpgradeAll(true)
+	if v := cm.self.local.VersionId.String(); v != "1.0.0" {
+		t.Fatalf("invalid self package shouldn't be activated, got %s", v)
+	}
+	if got := readInstalled(t, "costrict"); got != selfScript("0") {
+		t.Fatalf("installed self shouldn't change: %q", got)
+	}
+}
+
+func TestPostInstallOncePerVersion(t *testing.T) {
+	setupTestEnv(t, "")
+	srv := newUpgradeServer(t,
+		testPackage{name: "alpha", version: "1.0.0", content: "alpha 1.0.0"},
+		testPackage{name: "alpha", version: "1.1.0", content: "alpha 1.1.0"})
+	marker := filepath.Join(t.TempDir(), "hook")
+	spec := models.ComponentSpecification{
+		Name:        "alpha",
+		UpgradeUrl:  srv.URL,
+		PostInstall: fmt.Sprintf("echo run >> '%s'", marker),
+	}
+	runs := func() int {
+		data, _ := os.ReadFile(marker)
+		return strings.Count(string(data), "run")
+	}
+
+	ci := &ComponentInstance{spec: spec}
+	ci.fetchComponentInfo()
+	if err := ci.upgradeComponent(&utils.VersionNumber{Major: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if runs() != 1 {
+		t.Fatalf("hook should run after install, ran %d times", runs())
+	}
+
+	// 重启后不再执行已经执行过的钩子
+	componentManager = nil
+	t.Cleanup(func() { componentManager = nil })
+	setupTestSpec(t, models.SystemSpecification{Components: []models.ComponentSpecification{spec}})
+	cm := GetComponentManager()
+	cm.Init()
+	if runs() != 1 {
+		t.Fatalf("hook shouldn't run again after restart, ran %d times", runs())
+	}
+
+	// 新版本再执行一次
+	if err := cm.UpgradeComponent("alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if runs() != 2 {
+		t.Fatalf("hook should run once for the new version, ran %d times", runs())
+	}
+	if cm.components["alpha"].incomplete {
+		t.Fatal("component shouldn't be incomplete")
+	}
+}
+
+func TestPostInstallFailure(t *testing.T) {
+	setupTestEnv(t, "")
+	srv := newUpgradeServer(t, testPackage{name: "alpha", version: "1.0.0", content: "alpha"})
+	ci := &ComponentInstance{spec: models.ComponentSpecification{Name: "alpha", UpgradeUrl: srv.URL, PostInstall: "exit 3"}}
+	ci.fetchComponentInfo()
+	if err := ci.upgradeComponent(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !ci.incomplete || ci.local.PostInstalled {
+		t.Fatal("component should be incomplete when the hook fails")
+	}
+}
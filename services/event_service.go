@@ -0,0 +1,79 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"costrict-keeper/internal/models"
+)
+
+// 每个订阅者缓存的事件数量，订阅者处理不及时的时候丢弃新事件，避免阻塞发布者
+const eventQueueSize = 64
+
+/**
+ * Event bus which broadcasts status-change events to subscribers
+ * @property {map[chan models.Event]struct{}} subscribers - Event queues of subscribers
+ */
+type EventBus struct {
+	lock        sync.Mutex
+	subscribers map[chan models.Event]struct{}
+}
+
+var eventBus = &EventBus{
+	subscribers: make(map[chan models.Event]struct{}),
+}
+
+/**
+ * Get event bus singleton instance
+ * @returns {EventBus} Returns the singleton EventBus instance
+ */
+func GetEventBus() *EventBus {
+	return eventBus
+}
+
+/**
+ * Subscribe status-change events
+ * @returns {<-chan models.Event} Returns queue of events
+ * @returns {func()} Returns function to cancel the subscription
+ */
+func (eb *EventBus) Subscribe() (<-chan models.Event, func()) {
+	ch := make(chan models.Event, eventQueueSize)
+
+	eb.lock.Lock()
+	eb.subscribers[ch] = struct{}{}
+	eb.lock.Unlock()
+
+	return ch, func() {
+		eb.lock.Lock()
+		defer eb.lock.Unlock()
+		if _, ok := eb.subscribers[ch]; ok {
+			delete(eb.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+/**
+ * Publish event to all subscribers
+ * @param {models.EventType} typ - Event type
+ * @param {string} name - Name of the service/component
+ * @param {interface{}} data - Detail of the service/tunnel/component
+ * @description
+ * - Never blocks, the event is dropped for subscribers whose queue is full
+ */
+func (eb *EventBus) Publish(typ models.EventType, name string, data interface{}) {
+	event := models.Event{
+		Type: typ,
+		Name: name,
+		Time: time.Now(),
+		Data: data,
+	}
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	for ch := range eb.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
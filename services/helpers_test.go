@@ -0,0 +1,248 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/proc"
+	"costrict-keeper/internal/utils"
+)
+
+// 作为测试服务的子进程运行时，延迟一段时间后侦听参数指定的端口，模拟启动较慢的服务
+func TestMain(m *testing.M) {
+	// 作为另一个进程记录升级历史，模拟与服务端同时升级的CLI
+	if dir := os.Getenv("COSTRICT_TEST_RECORD_UPGRADES"); dir != "" {
+		env.CostrictDir = dir
+		for i := 0; i < historyRecordsPerProcess; i++ {
+			RecordUpgrade(models.UpgradeRecord{Component: "child", To: fmt.Sprint(i), Result: models.UpgradeSuccess})
+		}
+		os.Exit(0)
+	}
+	// 模拟以非0退出码失败的once服务
+	if code := os.Getenv("COSTRICT_TEST_EXIT_CODE"); code != "" {
+		n, _ := strconv.Atoi(code)
+		os.Exit(n)
+	}
+	if delay := os.Getenv("COSTRICT_TEST_SERVE_DELAY"); delay != "" {
+		d, _ := time.ParseDuration(delay)
+		time.Sleep(d)
+		ln, err := net.Listen("tcp", "127.0.0.1:"+os.Args[len(os.Args)-1])
+		if err != nil {
+			os.Exit(1)
+		}
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				os.Exit(1)
+			}
+			conn.Close()
+		}
+	}
+	os.Exit(m.Run())
+}
+
+/**
+ * Create a service that runs the test binary, which listens on the allocated port after the delay
+ * @param {time.Duration} delay - Delay before the service starts listening
+ */
+func newDelayedService(t *testing.T, name string, delay time.Duration) *ServiceInstance {
+	t.Helper()
+	t.Setenv("COSTRICT_TEST_SERVE_DELAY", delay.String())
+	svc := &ServiceInstance{
+		spec: models.ServiceSpecification{
+			Name:    name,
+			Startup: models.StartupAlways,
+			Command: os.Args[0],
+			Args:    []string{"-test.run=^$", "{{.LocalPort}}"},
+		},
+		child: true,
+	}
+	t.Cleanup(func() {
+		if svc.proc != nil {
+			svc.StopService()
+		}
+		if svc.port > 0 {
+			utils.FreePort(svc.port)
+		}
+	})
+	return svc
+}
+
+/**
+ * Create a fresh service manager singleton with the keeper itself as the self service
+ * @param {...*ServiceInstance} services - Services managed by the manager
+ */
+func newTestServiceManager(t *testing.T, services ...*ServiceInstance) *ServiceManager {
+	t.Helper()
+	serviceManager = nil
+	t.Cleanup(func() { serviceManager = nil })
+	sm := GetServiceManager()
+	sm.self = &ServiceInstance{
+		spec:   models.ServiceSpecification{Name: "costrict", Startup: models.StartupAlways},
+		proc:   proc.NewProcessInstance("costrict", "costrict", os.Args[0], nil),
+		status: models.StatusRunning,
+	}
+	for _, svc := range services {
+		sm.services[svc.spec.Name] = svc
+	}
+	return sm
+}
+
+// 测试使用的云端访问令牌
+const testAccessToken = "test-access-token"
+
+/**
+ * Use a temporary .costrict directory and load the given app configuration
+ * @param {string} appConfig - Content of config/costrict.json, default config is used if empty
+ * @description
+ * - auth.json points the cloud base URL at an unreachable address, so tests never touch the network
+ */
+func setupTestEnv(t *testing.T, appConfig string) {
+	t.Helper()
+	env.CostrictDir = t.TempDir()
+	env.LogDir, env.CacheDir, env.PackageDir, env.RunDir = "", "", "", ""
+	files := map[string]string{
+		filepath.Join("share", "auth.json"): `{"id":"test-user","machine_id":"test-machine","access_token":"` +
+			testAccessToken + `","base_url":"http://127.0.0.1:1"}`,
+	}
+	if appConfig != "" {
+		files[filepath.Join("config", "costrict.json")] = appConfig
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(env.CostrictDir, name), content)
+	}
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/**
+ * Package version published by the test upgrade server
+ * @property {string} name - Package name
+ * @property {string} version - Version of the package, such as "1.2.0"
+ * @property {string} content - Content of the package file
+ */
+type testPackage struct {
+	name    string
+	version string
+	content string
+}
+
+/**
+ * Start an upgrade server publishing the given packages for the current platform
+ * @param {...testPackage} pkgs - Packages to publish, the highest version of each package is the newest
+ * @returns {httptest.Server} Returns the server, it is closed when the test ends
+ * @description
+ * - Packages are signed with a generated key, which is set as component.public_key
+ * - Installing packages doesn't modify PATH of the user
+ * - Must be called after setupTestEnv
+ */
+func newUpgradeServer(t *testing.T, pkgs ...testPackage) *httptest.Server {
+	t.Helper()
+	pubKey, priKey := utils.GenKeys()
+	config.App().Component.PublicKey = string(pubKey)
+	config.App().Component.NoSetPath = true
+
+	files := map[string][]byte{}
+	platforms := map[string]*utils.PlatformInfo{}
+	for _, p := range pkgs {
+		var ver utils.VersionNumber
+		if err := ver.Parse(p.version); err != nil {
+			t.Fatal(err)
+		}
+		dir := fmt.Sprintf("/%s/%s/%s", p.name, runtime.GOOS, runtime.GOARCH)
+		checksum := fmt.Sprintf("%x", md5.Sum([]byte(p.content)))
+		sign, err := utils.Sign(priKey, []byte(checksum))
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, _ := json.Marshal(utils.PackageVersion{
+			PackageName:  p.name,
+			PackageType:  utils.PackageTypeExec,
+			FileName:     p.name,
+			Os:           runtime.GOOS,
+			Arch:         runtime.GOARCH,
+			Size:         uint64(len(p.content)),
+			Checksum:     checksum,
+			Sign:         hex.EncodeToString(sign),
+			ChecksumAlgo: "md5",
+			VersionId:    ver,
+		})
+		addr := utils.VersionAddr{
+			VersionId: ver,
+			AppUrl:    fmt.Sprintf("%s/%s/%s", dir, p.version, p.name),
+			InfoUrl:   fmt.Sprintf("%s/%s/package.json", dir, p.version),
+		}
+		files[addr.AppUrl] = []byte(p.content)
+		files[addr.InfoUrl] = info
+		plat := platforms[dir]
+		if plat == nil {
+			plat = &utils.PlatformInfo{PackageName: p.name, Os: runtime.GOOS, Arch: runtime.GOARCH}
+			platforms[dir] = plat
+		}
+		plat.Versions = append(plat.Versions, addr)
+	}
+	for dir, plat := range platforms {
+		sort.Slice(plat.Versions, func(i, j int) bool {
+			return utils.CompareVersion(plat.Versions[i].VersionId, plat.Versions[j].VersionId) < 0
+		})
+		plat.Newest = plat.Versions[len(plat.Versions)-1]
+		files[dir+"/platform.json"], _ = json.Marshal(plat)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+/**
+ * Write the system specification to the temporary .costrict directory and load it
+ * @param {models.SystemSpecification} spec - Specification to load
+ * @description
+ * - Must be called after setupTestEnv
+ */
+func setupTestSpec(t *testing.T, spec models.SystemSpecification) {
+	t.Helper()
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(env.CostrictDir, "share", "system-spec.json"), string(data))
+	if _, err := config.ReloadSpec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestFile(t *testing.T, fname, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
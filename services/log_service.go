@@ -6,7 +6,8 @@ import (
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
 	"costrict-keeper/internal/logger"
-	"crypto/tls"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,11 +15,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 type LogService struct {
-	logUrl string
+	logUrl  string
+	headers map[string]string // 每个上传请求都附加的HTTP头
 }
 
 type UploadLogArgs struct {
@@ -29,13 +32,56 @@ type UploadLogArgs struct {
 	LastLineNo  int64  `json:"end_line_no"`
 }
 
+/**
+ * Result of uploading log files
+ * @property {[]string} Uploaded - Files uploaded successfully
+ * @property {map[string]string} Failed - Files which failed to upload and the reasons
+ */
+type LogUploadReport struct {
+	Uploaded []string
+	Failed   map[string]string
+}
+
+func (r *LogUploadReport) addFailed(fname string, err error) {
+	if r.Failed == nil {
+		r.Failed = make(map[string]string)
+	}
+	r.Failed[fname] = err.Error()
+}
+
+/**
+ * Combine failures of the report into one error
+ * @returns {error} Returns nil if all files were uploaded
+ */
+func (r *LogUploadReport) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	var items []string
+	for fname, reason := range r.Failed {
+		items = append(items, fmt.Sprintf("%s: %s", fname, reason))
+	}
+	sort.Strings(items)
+	return fmt.Errorf("failed to upload %d files: %s", len(r.Failed), strings.Join(items, "; "))
+}
+
 func NewLogService() *LogService {
 	return &LogService{
-		logUrl: config.Cloud().LogUrl,
+		logUrl:  config.Cloud().LogUrl,
+		headers: config.App().Log.UploadHeaders,
 	}
 }
 
-func uploadBuffer(r io.Reader, filePath string, targetURL string) error {
+// 生成请求ID，用于在日志收集网关中追踪上传请求
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func (ls *LogService) uploadBuffer(r io.Reader, filePath string, targetURL string) error {
 	au := config.GetAuthConfig()
 	args := &UploadLogArgs{
 		ClientID: au.MachineID,
@@ -63,21 +109,25 @@ func uploadBuffer(r io.Reader, filePath string, targetURL string) error {
 	}
 	multipartWriter.Close()
 
-	// 创建请求
-	request, err := http.NewRequest("POST", targetURL, body)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
-	}
-	request.Header.Set("Content-Type", multipartWriter.FormDataContentType())
-	request.Header.Set("Authorization", "Bearer "+config.GetAuthConfig().AccessToken)
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	response, err := client.Do(request)
+	// 创建请求，令牌失效重试时需要重新创建请求，同一次上传使用相同的请求ID
+	payload := body.Bytes()
+	requestId := newRequestId()
+	response, err := config.DoCloudRequest(func() (*http.Request, error) {
+		request, err := http.NewRequest("POST", targetURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+		for k, v := range ls.headers {
+			request.Header.Set(k, v)
+		}
+		if request.Header.Get("X-Request-Id") == "" {
+			request.Header.Set("X-Request-Id", requestId)
+		}
+		return request, nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer response.Body.Close()
 
@@ -87,14 +137,61 @@ func uploadBuffer(r io.Reader, filePath string, targetURL string) error {
 	return nil
 }
 
-func uploadFile(filePath string, targetURL string) error {
+func (ls *LogService) uploadFile(filePath string, targetURL string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
 	}
 	defer file.Close()
 
-	return uploadBuffer(file, filePath, targetURL)
+	return ls.uploadBuffer(file, filePath, targetURL)
+}
+
+// 单行日志的最大长度，超长的行(如一行的巨大堆栈)被截断，避免读取失败或者占用过多内存
+const maxLogLineLength = 1024 * 1024
+
+// 被截断的日志行末尾附加的标记
+const truncatedMarker = "...[truncated]"
+
+/**
+ * Read lines from reader, lines longer than maxLogLineLength are truncated
+ * @param {io.Reader} r - Reader of the log file
+ * @param {func(string)} fn - Called with each line, line ending excluded
+ * @returns {error} Returns error if reading fails
+ * @description
+ * - Truncated lines end with truncatedMarker, the rest of the line is skipped without being buffered
+ */
+func scanLogLines(r io.Reader, fn func(line string)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var line []byte
+	truncated := false
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !truncated {
+			if room := maxLogLineLength - len(line); len(chunk) > room {
+				line = append(line, chunk[:room]...)
+				truncated = true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if isPrefix {
+			continue
+		}
+		if truncated {
+			fn(strings.ToValidUTF8(string(line), "") + truncatedMarker)
+		} else {
+			fn(string(line))
+		}
+		line = line[:0]
+		truncated = false
+	}
 }
 
 func getFileErrors(filePath string) ([]string, error) {
@@ -107,20 +204,16 @@ func getFileErrors(filePath string) ([]string, error) {
 	// 创建一个切片来存储包含 'ERROR' 的行
 	var errorLines []string
 
-	// 使用 bufio.Scanner 逐行读取文件
-	scanner := bufio.NewScanner(file)
-	const maxCapacity = 2 * 1024 * 1024
-	scanner.Buffer(make([]byte, 64*1024), maxCapacity)
-	for scanner.Scan() {
-		line := scanner.Text()
+	// 逐行读取文件，超长的行被截断而不是令整个文件读取失败
+	err = scanLogLines(file, func(line string) {
 		// 检查行是否包含 'ERROR'
 		if strings.Contains(line, "ERROR") {
 			errorLines = append(errorLines, line)
 		}
-	}
+	})
 
 	// 检查是否在读取文件时发生错误
-	if err := scanner.Err(); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
 
@@ -128,19 +221,34 @@ func getFileErrors(filePath string) ([]string, error) {
 }
 
 func (ls *LogService) UploadErrors() error {
-	directory := filepath.Join(env.CostrictDir, "logs")
+	report, err := ls.UploadErrorsReport()
+	if err != nil {
+		return err
+	}
+	return report.Err()
+}
+
+/**
+ * Upload error lines of log files in the logs directory, and report each file
+ * @returns {LogUploadReport} Returns uploaded and failed files, files without new errors aren't listed
+ * @returns {error} Returns error if the logs directory can't be read
+ * @description
+ * - Error lines already uploaded last time (cached in "<name>.last-errors") are skipped
+ */
+func (ls *LogService) UploadErrorsReport() (LogUploadReport, error) {
+	var report LogUploadReport
+	directory := env.GetLogDir()
 
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
-		return fmt.Errorf("directory '%s' not exist", directory)
+		return report, fmt.Errorf("directory '%s' not exist", directory)
 	}
 
 	// 读取目录下的所有文件
 	files, err := os.ReadDir(directory)
 	if err != nil {
-		return fmt.Errorf("directory '%s' read failed: %v", directory, err)
+		return report, fmt.Errorf("directory '%s' read failed: %v", directory, err)
 	}
 
-	var lastErr error
 	// 遍历所有文件，上传日志文件
 	for _, file := range files {
 		if file.IsDir() {
@@ -154,7 +262,7 @@ func (ls *LogService) UploadErrors() error {
 		filePath := filepath.Join(directory, file.Name())
 		lines, err := getFileErrors(filePath)
 		if err != nil {
-			lastErr = err
+			report.addFailed(file.Name(), err)
 			continue
 		}
 		if len(lines) == 0 {
@@ -163,26 +271,27 @@ func (ls *LogService) UploadErrors() error {
 		//	上次上传过的错误日志已经缓存到".last-errors"为后缀的文件中，如果内容没变，则跳过该文件
 		newErrorContent := strings.Join(lines, "\n")
 		fname := fmt.Sprintf("%s.last-errors", strings.TrimSuffix(file.Name(), ".log"))
-		lastErrorFile := filepath.Join(env.CostrictDir, "logs", fname)
+		lastErrorFile := filepath.Join(env.GetLogDir(), fname)
 		lastErrorContent, err := os.ReadFile(lastErrorFile)
 		if err == nil && string(lastErrorContent) == newErrorContent {
 			continue
 		}
 		buf := bytes.NewReader([]byte(newErrorContent))
-		err = uploadBuffer(buf, fname, ls.logUrl)
+		err = ls.uploadBuffer(buf, fname, ls.logUrl)
 		if err != nil {
 			logger.Warnf("Failed to upload '%s', size: %d, error: %v", fname, len(newErrorContent), err)
-			lastErr = err
+			report.addFailed(fname, err)
 			continue
 		}
 		logger.Debugf("Successfully uploaded '%s', size: %d", fname, len(newErrorContent))
+		report.Uploaded = append(report.Uploaded, fname)
 		//	上传成功后，把上传成功的内容写到"<filenamee>.last-errors"文件中
 		err = os.WriteFile(lastErrorFile, []byte(newErrorContent), 0664)
 		if err != nil {
-			lastErr = err
+			report.addFailed(fname, err)
 		}
 	}
-	return lastErr
+	return report, nil
 }
 
 /**
@@ -205,7 +314,7 @@ func (ls *LogService) UploadFile(filePath string) error {
 		logger.Warnf("Failed to upload log file '%s'", filePath)
 		return fmt.Errorf("log file is not exist: %s", filePath)
 	}
-	if err := uploadFile(filePath, ls.logUrl); err != nil {
+	if err := ls.uploadFile(filePath, ls.logUrl); err != nil {
 		logger.Warnf("Failed to upload log file '%s', error: %v", filePath, err.Error())
 		return err
 	}
@@ -230,20 +339,45 @@ func (ls *LogService) UploadFile(filePath string) error {
 * - File upload errors (UploadFile)
  */
 func (ls *LogService) UploadDirectory(directory string) error {
+	report, err := ls.UploadDirectoryReport(directory)
+	if err != nil {
+		return err
+	}
+	// 如果有上传错误，返回错误信息
+	if len(report.Failed) > 0 {
+		var failed []string
+		for fname := range report.Failed {
+			failed = append(failed, fname)
+		}
+		sort.Strings(failed)
+		return fmt.Errorf("部分文件上传失败: %s", strings.Join(failed, "; "))
+	}
+	// 如果没有日志文件，返回提示信息
+	if len(report.Uploaded) == 0 {
+		return fmt.Errorf("指定的目录中没有找到日志文件: %s", directory)
+	}
+	return nil
+}
+
+/**
+ * Upload log files of the directory, and report each file
+ * @param {string} directory - Path to the directory containing log files to upload
+ * @returns {LogUploadReport} Returns uploaded and failed files
+ * @returns {error} Returns error if the directory can't be read
+ */
+func (ls *LogService) UploadDirectoryReport(directory string) (LogUploadReport, error) {
+	var report LogUploadReport
 	// 检查目录是否存在
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
-		return fmt.Errorf("指定的目录不存在: %s", directory)
+		return report, fmt.Errorf("指定的目录不存在: %s", directory)
 	}
 
 	// 读取目录下的所有文件
 	files, err := os.ReadDir(directory)
 	if err != nil {
-		return fmt.Errorf("读取目录失败: %v", err)
+		return report, fmt.Errorf("读取目录失败: %v", err)
 	}
 
-	var uploadedFiles []string
-	var uploadErrors []string
-
 	// 遍历所有文件，上传日志文件
 	for _, file := range files {
 		if file.IsDir() {
@@ -258,22 +392,11 @@ func (ls *LogService) UploadDirectory(directory string) error {
 		filePath := filepath.Join(directory, file.Name())
 		err := ls.UploadFile(filePath)
 		if err != nil {
-			uploadErrors = append(uploadErrors, filePath)
+			report.addFailed(filePath, err)
 			continue
 		}
 
-		uploadedFiles = append(uploadedFiles, filePath)
+		report.Uploaded = append(report.Uploaded, filePath)
 	}
-
-	// 如果有上传错误，返回错误信息
-	if len(uploadErrors) > 0 {
-		return fmt.Errorf("部分文件上传失败: %s", strings.Join(uploadErrors, "; "))
-	}
-
-	// 如果没有日志文件，返回提示信息
-	if len(uploadedFiles) == 0 {
-		return fmt.Errorf("指定的目录中没有找到日志文件: %s", directory)
-	}
-
-	return nil
+	return report, nil
 }
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestUploadAttachesConfiguredHeaders(t *testing.T) {
+	setupTestEnv(t, `{"log":{"upload_headers":{"X-Tenant-Id":"tenant-1"}}}`)
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ls := NewLogService()
+	if err := ls.uploadBuffer(strings.NewReader("ERROR something failed\n"), "app.log", srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("X-Tenant-Id"); v != "tenant-1" {
+		t.Errorf("X-Tenant-Id = '%s', want tenant-1", v)
+	}
+	if v := got.Get("X-Request-Id"); !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(v) {
+		t.Errorf("X-Request-Id = '%s', want a generated request id", v)
+	}
+	if v := got.Get("Authorization"); v != "Bearer "+testAccessToken {
+		t.Errorf("Authorization = '%s'", v)
+	}
+	if !strings.HasPrefix(got.Get("Content-Type"), "multipart/form-data") {
+		t.Errorf("Content-Type = '%s'", got.Get("Content-Type"))
+	}
+}
+
+func TestUploadKeepsConfiguredRequestId(t *testing.T) {
+	setupTestEnv(t, `{"log":{"upload_headers":{"X-Request-Id":"fixed-id"}}}`)
+	var requestId string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = r.Header.Get("X-Request-Id")
+	}))
+	defer srv.Close()
+
+	if err := NewLogService().uploadBuffer(strings.NewReader("x"), "app.log", srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if requestId != "fixed-id" {
+		t.Errorf("X-Request-Id = '%s', want the configured one", requestId)
+	}
+}
+
+func TestScanLogLinesTruncatesLongLine(t *testing.T) {
+	exact := strings.Repeat("a", maxLogLineLength)
+	// 多字节字符跨越截断位置，截断后不能留下半个字符
+	long := strings.Repeat("b", maxLogLineLength-1) + "中" + strings.Repeat("c", 4*1024*1024)
+	var lines []string
+	err := scanLogLines(strings.NewReader("first\n"+exact+"\n"+long+"\r\nlast"), func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4", len(lines))
+	}
+	if lines[0] != "first" || lines[3] != "last" {
+		t.Errorf("lines around the long line = %q, %q", lines[0], lines[3])
+	}
+	if lines[1] != exact {
+		t.Errorf("line of max length is changed, length %d", len(lines[1]))
+	}
+	if want := strings.Repeat("b", maxLogLineLength-1) + truncatedMarker; lines[2] != want {
+		t.Errorf("long line is truncated to length %d, want %d", len(lines[2]), len(want))
+	}
+}
+
+func TestGetFileErrorsLongLine(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "service.log")
+	// 一行数兆字节的堆栈不应令整个文件读取失败
+	stack := "ERROR panic: " + strings.Repeat("goroutine 1 [running]: ", 200*1024)
+	content := "INFO started\nERROR first\n" + stack + "\nINFO ok\nERROR last\n"
+	if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := getFileErrors(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d error lines, want 3", len(lines))
+	}
+	if lines[0] != "ERROR first" || lines[2] != "ERROR last" {
+		t.Errorf("error lines = %q, %q", lines[0], lines[2])
+	}
+	if len(lines[1]) != maxLogLineLength+len(truncatedMarker) || !strings.HasPrefix(lines[1], "ERROR panic: ") ||
+		!strings.HasSuffix(lines[1], truncatedMarker) {
+		t.Errorf("stack line isn't truncated, length %d", len(lines[1]))
+	}
+}
@@ -1,18 +1,25 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/utils"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
 var (
@@ -32,14 +39,9 @@ var (
 		[]string{"service"},
 	)
 
-	requestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "service_request_duration_seconds",
-			Help:    "Duration of service requests",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"service"},
-	)
+	// 请求耗时直方图，桶在配置加载后才能确定，首次使用时创建
+	requestDuration     *prometheus.HistogramVec
+	requestDurationOnce sync.Once
 
 	serviceHealthStatus = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -65,18 +67,96 @@ var (
 		[]string{"service"},
 	)
 
+	// 从服务采集的白名单指标，以服务名为前缀重新暴露
+	scrapedMetrics = &scrapedCollector{metrics: make(map[string]map[string]*scrapedMetric)}
+
+	serviceOOMCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "service_oom_total",
+			Help: "Total times the service process was killed by the OOM killer",
+		},
+		[]string{"service"},
+	)
+
+	serviceAlertCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "service_unhealthy_alert_total",
+			Help: "Total times an unavailable service was alerted instead of restarted",
+		},
+		[]string{"service"},
+	)
+
 	// 本地计数器，用于快速获取总请求数
 	totalRequests int64 = 0
 	totalErrors   int64 = 0
 )
 
+type pushableCollector struct {
+	name      string
+	collector prometheus.Collector
+}
+
+// 可推送到pushgateway的指标，按指标名索引
+func pushableCollectors() []pushableCollector {
+	return []pushableCollector{
+		{"service_request_total", requestCount},
+		{"service_error_total", errorCount},
+		{"service_request_duration_seconds", getRequestDuration()},
+		{"service_health_status", serviceHealthStatus},
+		{"component_version_info", componentVersionInfo},
+		{"service_uptime_seconds", serviceUpTime},
+		{"service_scraped_metrics", scrapedMetrics},
+		{"service_oom_total", serviceOOMCount},
+		{"service_unhealthy_alert_total", serviceAlertCount},
+	}
+}
+
 func init() {
 	prometheus.MustRegister(requestCount)
 	prometheus.MustRegister(errorCount)
-	prometheus.MustRegister(requestDuration)
 	prometheus.MustRegister(serviceHealthStatus)
 	prometheus.MustRegister(componentVersionInfo)
 	prometheus.MustRegister(serviceUpTime)
+	prometheus.MustRegister(scrapedMetrics)
+	prometheus.MustRegister(serviceOOMCount)
+	prometheus.MustRegister(serviceAlertCount)
+	prometheus.MustRegister(requestDurationCollector{})
+}
+
+// 请求耗时直方图的采集器，在init中注册，采集时才创建直方图
+// 不描述任何指标，作为unchecked collector注册，注册时不需要知道桶
+type requestDurationCollector struct{}
+
+func (requestDurationCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (requestDurationCollector) Collect(ch chan<- prometheus.Metric) {
+	getRequestDuration().Collect(ch)
+}
+
+/**
+ * Get the request duration histogram, create it on first call
+ * @returns {*prometheus.HistogramVec} Returns the histogram
+ * @description
+ * - Buckets come from metrics.buckets config, prometheus.DefBuckets if not configured
+ * - Buckets can't be changed once created, changing the config needs a restart
+ * - Exported through requestDurationCollector, which is registered in init
+ */
+func getRequestDuration() *prometheus.HistogramVec {
+	requestDurationOnce.Do(func() {
+		buckets := prometheus.DefBuckets
+		if len(config.App().Metrics.Buckets) > 0 {
+			buckets = config.App().Metrics.Buckets
+		}
+		requestDuration = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "service_request_duration_seconds",
+				Help:    "Duration of service requests",
+				Buckets: buckets,
+			},
+			[]string{"service"},
+		)
+	})
+	return requestDuration
 }
 
 /**
@@ -144,14 +224,18 @@ func collectMetricsFromComponents() error {
  * @description
  * - Constructs service metrics endpoint URL
  * - Makes HTTP request to service metrics endpoint
- * - Processes and records service-specific metrics
+ * - Re-exposes metrics whitelisted by metrics.whitelist config
  * @throws
  * - HTTP request errors
  * - Response parsing errors
  */
 func collectServiceMetrics(service models.ServiceSpecification) error {
-	// Construct metrics URL
-	url := fmt.Sprintf("http://localhost:%d%s", service.Port, service.Metrics)
+	// Construct metrics URL, using the address family the service really listens on
+	addr := utils.GetConnectableAddress(service.Host, service.Port)
+	if addr == "" {
+		return fmt.Errorf("service %s isn't connectable on port %d", service.Name, service.Port)
+	}
+	url := fmt.Sprintf("http://%s%s", addr, service.Metrics)
 
 	// Create HTTP client with timeout
 	tr := &http.Transport{
@@ -179,13 +263,183 @@ func collectServiceMetrics(service models.ServiceSpecification) error {
 		return fmt.Errorf("failed to read response body from service %s: %v", service.Name, err)
 	}
 
-	// For now, just log the metrics content
-	// In a real implementation, you would parse the metrics and update Prometheus counters
 	logger.Debugf("Metrics from service %s: %s", service.Name, string(body))
 
+	var whitelist []string
+	if cfg := config.App(); cfg != nil {
+		whitelist = cfg.Metrics.Whitelist
+	}
+	return ingestServiceMetrics(service.Name, bytes.NewReader(body), whitelist)
+}
+
+/**
+ * Parse Prometheus text exposition of a service and re-expose whitelisted metrics
+ * @param {string} serviceName - Name of the service the metrics belong to
+ * @param {io.Reader} r - Prometheus text format content
+ * @param {[]string} whitelist - Metric names allowed to be ingested
+ * @returns {error} Returns error if parsing fails, nil on success
+ * @description
+ * - Metrics not in whitelist are dropped
+ * - All samples of a metric are summed up, original labels are discarded,
+ *   so each metric only produces one series
+ * - Metrics keep their original type and name, prefixed by the service name,
+ *   e.g. http_requests_total of codebase-syncer becomes codebase_syncer_http_requests_total
+ * - Histogram buckets are merged by upper bound, summary quantiles can't be merged and are dropped
+ * @throws
+ * - Text format parsing errors
+ * @example
+ * err := ingestServiceMetrics("codebase-syncer", resp.Body, []string{"http_requests_total"})
+ */
+func ingestServiceMetrics(serviceName string, r io.Reader, whitelist []string) error {
+	if len(whitelist) == 0 {
+		return nil
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return fmt.Errorf("failed to parse metrics from service %s: %v", serviceName, err)
+	}
+	metrics := make(map[string]*scrapedMetric)
+	for _, name := range whitelist {
+		mf, ok := families[name]
+		if !ok {
+			continue
+		}
+		m := &scrapedMetric{help: mf.GetHelp(), typ: mf.GetType()}
+		for _, sample := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_HISTOGRAM:
+				m.sum += sample.GetHistogram().GetSampleSum()
+				m.count += sample.GetHistogram().GetSampleCount()
+				if m.buckets == nil {
+					m.buckets = make(map[float64]uint64)
+				}
+				for _, b := range sample.GetHistogram().GetBucket() {
+					m.buckets[b.GetUpperBound()] += b.GetCumulativeCount()
+				}
+			case dto.MetricType_SUMMARY:
+				m.sum += sample.GetSummary().GetSampleSum()
+				m.count += sample.GetSummary().GetSampleCount()
+			case dto.MetricType_COUNTER:
+				m.value += sample.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				m.value += sample.GetGauge().GetValue()
+			default:
+				m.value += sample.GetUntyped().GetValue()
+			}
+		}
+		metrics[name] = m
+	}
+	scrapedMetrics.set(serviceName, metrics)
 	return nil
 }
 
+/**
+ * A metric scraped from a service, aggregated over its original labels
+ * @property {string} help - Help text of the original metric
+ * @property {dto.MetricType} typ - Type of the original metric
+ * @property {float64} value - Value of counter/gauge/untyped metric
+ * @property {uint64} count - Sample count of histogram/summary
+ * @property {float64} sum - Sample sum of histogram/summary
+ * @property {map[float64]uint64} buckets - Cumulative counts of histogram keyed by upper bound
+ */
+type scrapedMetric struct {
+	help    string
+	typ     dto.MetricType
+	value   float64
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+/**
+ * Collector re-exposing metrics scraped from services
+ * @property {map[string]map[string]*scrapedMetric} metrics - Metrics keyed by service and metric name
+ * @description
+ * - Unchecked collector: names of scraped metrics are only known after scraping,
+ *   so Describe sends nothing
+ */
+type scrapedCollector struct {
+	lock    sync.Mutex
+	metrics map[string]map[string]*scrapedMetric
+}
+
+// 替换服务采集到的全部指标，服务不再输出的指标随之消失
+func (sc *scrapedCollector) set(serviceName string, metrics map[string]*scrapedMetric) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	sc.metrics[serviceName] = metrics
+}
+
+func (sc *scrapedCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (sc *scrapedCollector) Collect(ch chan<- prometheus.Metric) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	for serviceName, metrics := range sc.metrics {
+		for name, m := range metrics {
+			desc := prometheus.NewDesc(scrapedMetricName(serviceName, name), m.help, nil, nil)
+			var metric prometheus.Metric
+			var err error
+			switch m.typ {
+			case dto.MetricType_COUNTER:
+				metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.value)
+			case dto.MetricType_GAUGE:
+				metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.value)
+			case dto.MetricType_HISTOGRAM:
+				metric, err = prometheus.NewConstHistogram(desc, m.count, m.sum, m.buckets)
+			case dto.MetricType_SUMMARY:
+				metric, err = prometheus.NewConstSummary(desc, m.count, m.sum, nil)
+			default:
+				metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.value)
+			}
+			if err != nil {
+				logger.Warnf("Expose metric '%s' of service '%s' failed: %v", name, serviceName, err)
+				continue
+			}
+			ch <- metric
+		}
+	}
+}
+
+/**
+ * Get values of metrics scraped from a service
+ * @param {string} serviceName - Name of the service
+ * @returns {map[string]float64} Returns values keyed by original metric name, histograms and
+ *   summaries are reported as <name>_sum and <name>_count, nil if nothing is scraped
+ */
+func (sc *scrapedCollector) values(serviceName string) map[string]float64 {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	metrics := sc.metrics[serviceName]
+	if len(metrics) == 0 {
+		return nil
+	}
+	values := make(map[string]float64)
+	for name, m := range metrics {
+		switch m.typ {
+		case dto.MetricType_HISTOGRAM, dto.MetricType_SUMMARY:
+			values[name+"_sum"] = m.sum
+			values[name+"_count"] = float64(m.count)
+		default:
+			values[name] = m.value
+		}
+	}
+	return values
+}
+
+// 以服务名为前缀的指标名，服务名中不能用于指标名的字符替换为'_'
+func scrapedMetricName(serviceName, name string) string {
+	prefix := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, serviceName)
+	return prefix + "_" + name
+}
+
 /**
  * Push collected metrics to Prometheus Pushgateway
  * @param {string} addr - Pushgateway address
@@ -205,13 +459,17 @@ func pushMetricsToGateway(addr string) error {
 
 	// Create a pusher to push metrics to the pushgateway
 	pusher := push.New(addr, "costrict")
+	configurePusher(pusher, &config.App().Metrics)
 
-	// Add default metrics
-	pusher.Collector(requestCount)
-	pusher.Collector(requestDuration)
-	pusher.Collector(serviceHealthStatus)
-	pusher.Collector(componentVersionInfo)
-	pusher.Collector(serviceUpTime)
+	// Add configured metrics
+	collectors, err := selectPushCollectors(config.App().Metrics.PushCollectors)
+	if err != nil {
+		logger.Errorf("Invalid metrics.push_collectors: %v", err)
+		return err
+	}
+	for _, c := range collectors {
+		pusher.Collector(c)
+	}
 
 	// Push metrics to gateway
 	if err := pusher.Add(); err != nil {
@@ -223,6 +481,69 @@ func pushMetricsToGateway(addr string) error {
 	return nil
 }
 
+/**
+ * Select collectors pushed to the pushgateway
+ * @param {[]string} names - Metric names, all pushable metrics if empty
+ * @returns {[]prometheus.Collector} Returns collectors in the order of names
+ * @returns {error} Returns error listing names which aren't pushable metrics
+ * @description
+ * - Lets operators trim the cardinality of pushed metrics
+ */
+func selectPushCollectors(names []string) ([]prometheus.Collector, error) {
+	var collectors []prometheus.Collector
+	if len(names) == 0 {
+		for _, pc := range pushableCollectors() {
+			collectors = append(collectors, pc.collector)
+		}
+		return collectors, nil
+	}
+	var unknown []string
+	for _, name := range names {
+		found := false
+		for _, pc := range pushableCollectors() {
+			if pc.name == name {
+				collectors = append(collectors, pc.collector)
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown metrics: %s", strings.Join(unknown, ", "))
+	}
+	return collectors, nil
+}
+
+/**
+ * Apply auth, headers and grouping labels to pusher
+ * @param {*push.Pusher} pusher - Pusher to configure
+ * @param {*config.MetricsConfig} cfg - Metrics configuration
+ * @description
+ * - Uses basic-auth if username is configured, otherwise bearer token if configured,
+ *   so costrict can report to pushgateways behind auth proxies
+ * - Groups metrics by instance=machineID, so different clients don't overwrite each other
+ */
+func configurePusher(pusher *push.Pusher, cfg *config.MetricsConfig) {
+	header := http.Header{}
+	for k, v := range cfg.PushHeaders {
+		header.Set(k, v)
+	}
+	if cfg.PushUsername != "" {
+		pusher.BasicAuth(cfg.PushUsername, cfg.PushPassword)
+	} else if cfg.PushToken != "" {
+		header.Set("Authorization", "Bearer "+cfg.PushToken)
+	}
+	if len(header) > 0 {
+		pusher.Header(header)
+	}
+	if machineID := config.GetMachineID(); machineID != "" {
+		pusher.Grouping("instance", machineID)
+	}
+}
+
 /**
  * Collect and push metrics periodically
  * @param {string} pushGatewayAddr - Pushgateway address
@@ -283,7 +604,7 @@ func IncrementRequestCount(serviceName string) {
  * - Used by API handlers to track request latency
  */
 func RecordRequestDuration(serviceName string, duration float64) {
-	requestDuration.WithLabelValues(serviceName).Observe(duration)
+	getRequestDuration().WithLabelValues(serviceName).Observe(duration)
 }
 
 /**
@@ -298,6 +619,22 @@ func UpdateServiceUptime(serviceName string, uptime float64) {
 	serviceUpTime.WithLabelValues(serviceName).Set(uptime)
 }
 
+/**
+ * Increment OOM kill counter for a specific service
+ * @param {string} serviceName - Name of the service
+ */
+func IncrementServiceOOM(serviceName string) {
+	serviceOOMCount.WithLabelValues(serviceName).Inc()
+}
+
+/**
+ * Increment the counter of alerts raised instead of restarting an unavailable service
+ * @param {string} serviceName - Name of the service
+ */
+func IncrementServiceAlert(serviceName string) {
+	serviceAlertCount.WithLabelValues(serviceName).Inc()
+}
+
 /**
  * Increment error counter for a specific service
  * @param {string} serviceName - Name of the service
@@ -310,6 +647,49 @@ func IncrementErrorCount(serviceName string) {
 	totalErrors++
 }
 
+/**
+ * Gather current values of a metric vector
+ * @param {prometheus.Collector} c - Counter/gauge vector
+ * @param {string} label - Label whose value is used as the key
+ * @param {string} extra - Label whose value is appended to the key as "key/value", ignored if empty
+ * @returns {map[string]float64} Returns values keyed by label value
+ * @description
+ * - Reads series already existing in the vector, doesn't create new series
+ */
+func gatherValues(c prometheus.Collector, label, extra string) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+		var key, sub string
+		for _, lp := range m.GetLabel() {
+			switch lp.GetName() {
+			case label:
+				key = lp.GetValue()
+			case extra:
+				sub = lp.GetValue()
+			}
+		}
+		if extra != "" {
+			key += "/" + sub
+		}
+		switch {
+		case m.Counter != nil:
+			values[key] += m.GetCounter().GetValue()
+		case m.Gauge != nil:
+			values[key] += m.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
 /**
  * Get total request count
  * @returns {int64} Returns total request count
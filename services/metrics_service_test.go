@@ -0,0 +1,264 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/proc"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const sampleExposition = `# HELP http_requests_total Total HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="/a"} 3
+http_requests_total{method="POST",path="/b"} 4
+# HELP queue_length Items waiting in queue.
+# TYPE queue_length gauge
+queue_length{queue="x"} 2
+queue_length{queue="y"} 5
+# HELP request_seconds Request latency.
+# TYPE request_seconds histogram
+request_seconds_bucket{path="/a",le="0.1"} 1
+request_seconds_bucket{path="/a",le="1"} 2
+request_seconds_bucket{path="/a",le="+Inf"} 2
+request_seconds_sum{path="/a"} 0.6
+request_seconds_count{path="/a"} 2
+request_seconds_bucket{path="/b",le="0.1"} 0
+request_seconds_bucket{path="/b",le="1"} 1
+request_seconds_bucket{path="/b",le="+Inf"} 3
+request_seconds_sum{path="/b"} 7.5
+request_seconds_count{path="/b"} 3
+# TYPE go_goroutines gauge
+go_goroutines 42
+`
+
+func gatherScraped(t *testing.T) map[string]*dto.MetricFamily {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(scrapedMetrics)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]*dto.MetricFamily)
+	for _, mf := range families {
+		result[mf.GetName()] = mf
+	}
+	return result
+}
+
+func TestIngestServiceMetrics(t *testing.T) {
+	whitelist := []string{"http_requests_total", "queue_length", "request_seconds"}
+	if err := ingestServiceMetrics("test-svc", strings.NewReader(sampleExposition), whitelist); err != nil {
+		t.Fatal(err)
+	}
+	defer scrapedMetrics.set("test-svc", nil)
+
+	families := gatherScraped(t)
+	counter := families["test_svc_http_requests_total"]
+	if counter == nil || counter.GetType() != dto.MetricType_COUNTER {
+		t.Fatalf("counter isn't re-exposed as counter: %v", counter)
+	}
+	if len(counter.GetMetric()) != 1 || counter.GetMetric()[0].GetCounter().GetValue() != 7 {
+		t.Errorf("counter should be aggregated to one series of 7: %v", counter.GetMetric())
+	}
+	if len(counter.GetMetric()[0].GetLabel()) != 0 {
+		t.Errorf("original labels should be dropped: %v", counter.GetMetric()[0].GetLabel())
+	}
+	gauge := families["test_svc_queue_length"]
+	if gauge == nil || gauge.GetType() != dto.MetricType_GAUGE || gauge.GetMetric()[0].GetGauge().GetValue() != 7 {
+		t.Errorf("gauge isn't re-exposed: %v", gauge)
+	}
+	hist := families["test_svc_request_seconds"]
+	if hist == nil || hist.GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("histogram isn't re-exposed as histogram: %v", hist)
+	}
+	h := hist.GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 5 || h.GetSampleSum() != 8.1 {
+		t.Errorf("histogram count/sum = %d/%v, want 5/8.1", h.GetSampleCount(), h.GetSampleSum())
+	}
+	for _, b := range h.GetBucket() {
+		if b.GetUpperBound() == 1 && b.GetCumulativeCount() != 3 {
+			t.Errorf("bucket le=1 = %d, want 3", b.GetCumulativeCount())
+		}
+	}
+	if _, ok := families["test_svc_go_goroutines"]; ok {
+		t.Error("metric not in whitelist should be dropped")
+	}
+
+	values := scrapedMetrics.values("test-svc")
+	if values["http_requests_total"] != 7 || values["request_seconds_count"] != 5 {
+		t.Errorf("snapshot values = %v", values)
+	}
+}
+
+func TestIngestServiceMetricsWithoutWhitelist(t *testing.T) {
+	if err := ingestServiceMetrics("other-svc", strings.NewReader(sampleExposition), nil); err != nil {
+		t.Fatal(err)
+	}
+	if values := scrapedMetrics.values("other-svc"); values != nil {
+		t.Errorf("nothing should be ingested without whitelist: %v", values)
+	}
+}
+
+func TestRecordOOMCountsEachExitOnce(t *testing.T) {
+	svc := &ServiceInstance{spec: models.ServiceSpecification{Name: "oom-svc", Startup: models.StartupNone}}
+	counter := serviceOOMCount.WithLabelValues("oom-svc")
+	before := testutil.ToFloat64(counter)
+	exitTime := time.Now()
+	svc.recordOOM(models.ExitOOM, exitTime)
+	svc.recordOOM(models.ExitOOM, exitTime)
+	svc.recordOOM(models.ExitSignal, exitTime.Add(time.Second))
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("oom counted %v times, want 1", got)
+	}
+	svc.recordOOM(models.ExitOOM, exitTime.Add(2*time.Second))
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("oom counted %v times, want 2", got)
+	}
+}
+
+// 启动模拟的pushgateway，返回收到的指标名
+func pushedMetricNames(t *testing.T, appConfig string) (map[string]bool, error) {
+	t.Helper()
+	var mutex sync.Mutex
+	names := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			var mf dto.MetricFamily
+			if err := dec.Decode(&mf); err != nil {
+				break
+			}
+			names[mf.GetName()] = true
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	setupTestEnv(t, appConfig)
+	IncrementErrorCount("push-svc")
+	err := pushMetricsToGateway(srv.URL)
+	mutex.Lock()
+	defer mutex.Unlock()
+	return names, err
+}
+
+func TestPushConfiguredCollectors(t *testing.T) {
+	names, err := pushedMetricNames(t, `{"metrics":{"push_collectors":["service_error_total"]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !names["service_error_total"] || len(names) != 1 {
+		t.Errorf("pushed metrics = %v, want only service_error_total", names)
+	}
+}
+
+func TestPushDefaultCollectorsIncludeErrors(t *testing.T) {
+	names, err := pushedMetricNames(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !names["service_error_total"] {
+		t.Errorf("service_error_total isn't pushed by default: %v", names)
+	}
+}
+
+func TestPushUnknownCollector(t *testing.T) {
+	if _, err := selectPushCollectors([]string{"service_error_total", "no_such_metric"}); err == nil ||
+		!strings.Contains(err.Error(), "no_such_metric") {
+		t.Errorf("err = %v, want unknown metric no_such_metric", err)
+	}
+}
+
+func TestMetricsSnapshotCounters(t *testing.T) {
+	setupTestEnv(t, "")
+	cm := newTestComponentManager(t, "http://127.0.0.1:1")
+	cm.self.spec = models.ComponentSpecification{Name: "costrict"}
+	var svcs []*ServiceInstance
+	for _, name := range []string{"snapshot-a", "snapshot-b"} {
+		svcs = append(svcs, &ServiceInstance{
+			spec:   models.ServiceSpecification{Name: name, Startup: models.StartupAlways},
+			proc:   proc.NewProcessInstance(name, name, name, nil),
+			status: models.StatusStopped,
+		})
+	}
+	s := &Server{
+		cfg:       config.App(),
+		service:   newTestServiceManager(t, svcs...),
+		component: cm,
+		tunnel:    GetTunnelManager(),
+		startTime: time.Now(),
+	}
+	for i := 0; i < 3; i++ {
+		IncrementRequestCount("snapshot-a")
+	}
+	IncrementErrorCount("snapshot-a")
+	IncrementRequestCount("snapshot-b")
+
+	// 按API返回的JSON检查
+	data, err := json.Marshal(s.GetMetricsSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot struct {
+		Timestamp string `json:"timestamp"`
+		Services  []struct {
+			Name     string  `json:"name"`
+			Requests float64 `json:"requests"`
+			Errors   float64 `json:"errors"`
+		} `json:"services"`
+		Components []map[string]interface{} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Timestamp == "" {
+		t.Error("timestamp is missing")
+	}
+	counters := map[string][2]float64{}
+	for _, svc := range snapshot.Services {
+		counters[svc.Name] = [2]float64{svc.Requests, svc.Errors}
+	}
+	if got := counters["snapshot-a"]; got != [2]float64{3, 1} {
+		t.Errorf("snapshot-a requests/errors = %v, want [3 1]", got)
+	}
+	if got := counters["snapshot-b"]; got != [2]float64{1, 0} {
+		t.Errorf("snapshot-b requests/errors = %v, want [1 0]", got)
+	}
+	if snapshot.Components == nil {
+		t.Error("components should be an array, not null")
+	}
+}
+
+func TestRequestDurationRegistered(t *testing.T) {
+	setupTestEnv(t, "")
+	RecordRequestDuration("duration-svc", 0.2)
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "service_request_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetLabel()[0].GetValue() == "duration-svc" && m.GetHistogram().GetSampleCount() == 1 {
+				return
+			}
+		}
+	}
+	t.Error("request duration of duration-svc isn't exported by the default registry")
+}
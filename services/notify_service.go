@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/logger"
+	"costrict-keeper/internal/models"
+)
+
+// 等待发送的通知数量，通知接收方处理不及时的时候丢弃新通知，避免阻塞调用者
+const notifyQueueSize = 16
+
+/**
+ * Notifier delivers critical events to external systems
+ */
+type Notifier interface {
+	Notify(ctx context.Context, n models.Notification) error
+}
+
+/**
+ * Notifier which POSTs notifications as JSON to a webhook URL
+ * @property {string} url - Webhook URL
+ * @property {map[string]string} headers - Extra HTTP headers sent with the request
+ * @property {http.Client} client - HTTP client with request timeout
+ */
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+/**
+ * Create webhook notifier
+ * @param {string} url - Webhook URL
+ * @param {map[string]string} headers - Extra HTTP headers sent with the request
+ * @param {time.Duration} timeout - Timeout of each request
+ * @returns {WebhookNotifier} Returns the webhook notifier
+ */
+func NewWebhookNotifier(url string, headers map[string]string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+/**
+ * POST notification to the webhook
+ * @param {context.Context} ctx - Context of the request
+ * @param {models.Notification} n - Notification
+ * @returns {error} Returns error if the request fails or the webhook doesn't return 2xx
+ */
+func (wn *WebhookNotifier) Notify(ctx context.Context, n models.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wn.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wn.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+/**
+ * Notify manager which sends critical events through the notifier in background
+ * @property {Notifier} notifier - Notifier set by SetNotifier, the configured webhook is used if nil
+ * @property {WebhookNotifier} webhook - Notifier of the configured webhook, rebuilt when notify config changes
+ * @property {config.NotifyConfig} webhookCfg - Notify config the webhook notifier was built from
+ * @property {chan models.Notification} queue - Notifications waiting to be sent
+ */
+type NotifyManager struct {
+	lock       sync.Mutex
+	notifier   Notifier
+	webhook    *WebhookNotifier
+	webhookCfg config.NotifyConfig
+	queue      chan models.Notification
+}
+
+var notifyManager *NotifyManager
+var notifyOnce sync.Once
+
+/**
+ * Get notify manager singleton instance
+ * @returns {NotifyManager} Returns the singleton NotifyManager instance
+ * @description
+ * - Starts the background sender on first call
+ */
+func GetNotifyManager() *NotifyManager {
+	notifyOnce.Do(func() {
+		nm := &NotifyManager{
+			queue: make(chan models.Notification, notifyQueueSize),
+		}
+		go nm.run()
+		notifyManager = nm
+	})
+	return notifyManager
+}
+
+/**
+ * Replace the notifier, nil restores the configured webhook
+ * @param {Notifier} notifier - New notifier
+ */
+func (nm *NotifyManager) SetNotifier(notifier Notifier) {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	nm.notifier = notifier
+}
+
+/**
+ * Get the notifier to send notifications with
+ * @returns {Notifier} Returns nil if notification is disabled
+ * @description
+ * - notify.webhook is read at notify time, so changes applied by /reload take effect
+ *   without restarting, the webhook notifier is rebuilt only when the config changes
+ * @private
+ */
+func (nm *NotifyManager) getNotifier() Notifier {
+	nm.lock.Lock()
+	defer nm.lock.Unlock()
+	if nm.notifier != nil {
+		return nm.notifier
+	}
+	cfg := config.App().Notify
+	if cfg.Webhook == "" {
+		return nil
+	}
+	if nm.webhook == nil || !reflect.DeepEqual(cfg, nm.webhookCfg) {
+		nm.webhook = NewWebhookNotifier(cfg.Webhook, cfg.Headers, time.Duration(cfg.Timeout)*time.Second)
+		nm.webhookCfg = cfg
+	}
+	return nm.webhook
+}
+
+/**
+ * Send critical event to the notifier
+ * @param {models.EventType} typ - Event type
+ * @param {string} name - Name of the service/component
+ * @param {interface{}} data - Detail of the event
+ * @description
+ * - Best-effort and never blocks, the notification is dropped if the queue is full
+ */
+func (nm *NotifyManager) Notify(typ models.EventType, name string, data interface{}) {
+	if nm.getNotifier() == nil {
+		return
+	}
+	n := models.Notification{
+		MachineID: config.GetMachineID(),
+		Event: models.Event{
+			Type: typ,
+			Name: name,
+			Time: time.Now(),
+			Data: data,
+		},
+	}
+	select {
+	case nm.queue <- n:
+	default:
+		logger.Warnf("Notification queue is full, drop '%s' event of '%s'", typ, name)
+	}
+}
+
+func (nm *NotifyManager) run() {
+	for n := range nm.queue {
+		notifier := nm.getNotifier()
+		if notifier == nil {
+			continue
+		}
+		if err := notifier.Notify(context.Background(), n); err != nil {
+			logger.Warnf("Send '%s' notification of '%s' failed: %v", n.Type, n.Name, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+)
+
+// 创建新的通知管理器单例，不受其它测试的影响
+func newTestNotifyManager(t *testing.T) *NotifyManager {
+	t.Helper()
+	notifyOnce = sync.Once{}
+	notifyManager = nil
+	return GetNotifyManager()
+}
+
+/**
+ * Start a webhook receiver which forwards received notifications to the returned channel
+ * @returns {string} Returns URL of the webhook
+ */
+func newWebhookReceiver(t *testing.T) (string, chan models.Notification) {
+	t.Helper()
+	received := make(chan models.Notification, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n models.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "missing header", http.StatusUnauthorized)
+			return
+		}
+		received <- n
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, received
+}
+
+func waitNotification(t *testing.T, received chan models.Notification) models.Notification {
+	t.Helper()
+	select {
+	case n := <-received:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification isn't received")
+		return models.Notification{}
+	}
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	url1, received1 := newWebhookReceiver(t)
+	url2, received2 := newWebhookReceiver(t)
+	setupTestEnv(t, `{"notify":{"webhook":"`+url1+`","headers":{"X-Token":"secret"}}}`)
+	nm := newTestNotifyManager(t)
+
+	nm.Notify(models.EventCrashLoop, "codebase-syncer", map[string]int{"restarts": 3})
+	n := waitNotification(t, received1)
+	if n.Type != models.EventCrashLoop || n.Name != "codebase-syncer" || n.MachineID != "test-machine" {
+		t.Errorf("received notification = %+v", n)
+	}
+
+	// 重新加载配置后，通知发往新的webhook
+	writeTestFile(t, filepath.Join(env.CostrictDir, "config", "costrict.json"),
+		`{"notify":{"webhook":"`+url2+`","headers":{"X-Token":"secret"}}}`)
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	nm.Notify(models.EventUpgradeFailed, "alpha", nil)
+	if n := waitNotification(t, received2); n.Type != models.EventUpgradeFailed || n.Name != "alpha" {
+		t.Errorf("received notification = %+v", n)
+	}
+	select {
+	case n := <-received1:
+		t.Errorf("old webhook still receives notifications: %+v", n)
+	default:
+	}
+
+	// 去掉webhook后不再发送通知
+	writeTestFile(t, filepath.Join(env.CostrictDir, "config", "costrict.json"), `{}`)
+	if err := config.LoadConfig(true); err != nil {
+		t.Fatal(err)
+	}
+	if nm.getNotifier() != nil {
+		t.Error("notification should be disabled without webhook")
+	}
+}
@@ -6,22 +6,110 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"costrict-keeper/internal/config"
 	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/lifecycle"
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/supervisor"
 	"costrict-keeper/internal/utils"
 )
 
+// 检查上游依赖可达性时，每个依赖的访问超时
+const dependencyProbeTimeout = 3 * time.Second
+
+// 排空模式：不再启动服务/打开隧道，已运行的服务不受影响，用于平滑升级前的准备
+var draining atomic.Bool
+
+/**
+ * Enter or leave drain mode
+ * @param {bool} on - True to enter drain mode, false to leave it
+ * @description
+ * - In drain mode, start/open API return 503 and broken services aren't recovered
+ * - Running services and tunnels are kept untouched
+ */
+func SetDraining(on bool) {
+	if draining.Swap(on) == on {
+		return
+	}
+	if on {
+		logger.Infof("Enter drain mode, no new services or tunnels are accepted")
+	} else {
+		logger.Infof("Leave drain mode")
+	}
+}
+
+// IsDraining 是否处于排空模式
+func IsDraining() bool {
+	return draining.Load()
+}
+
+// 维护模式：暂停自动恢复服务和半夜鸡叫，已运行的服务不受影响，超过最长持续时间后自动退出
+var maintenance struct {
+	mutex sync.Mutex
+	until time.Time
+}
+
+/**
+ * Enter or leave maintenance mode
+ * @param {bool} on - True to enter maintenance mode, false to leave it
+ * @description
+ * - In maintenance mode, broken services aren't recovered and the midnight rooster is suppressed
+ * - Running services and tunnels are kept untouched
+ * - Maintenance mode is cleared automatically after interval.maintenance seconds as a safety
+ */
+func SetMaintenance(on bool) {
+	maintenance.mutex.Lock()
+	defer maintenance.mutex.Unlock()
+	if !on {
+		if !maintenance.until.IsZero() {
+			logger.Infof("Leave maintenance mode")
+		}
+		maintenance.until = time.Time{}
+		return
+	}
+	maintenance.until = time.Now().Add(time.Duration(config.App().Interval.Maintenance) * time.Second)
+	logger.Infof("Enter maintenance mode until %s", maintenance.until.Format(time.RFC3339))
+}
+
+// GetMaintenance 获取维护模式状态，超过最长持续时间的维护模式会被自动清除
+func GetMaintenance() models.MaintenanceState {
+	maintenance.mutex.Lock()
+	defer maintenance.mutex.Unlock()
+	if maintenance.until.IsZero() {
+		return models.MaintenanceState{}
+	}
+	if time.Now().After(maintenance.until) {
+		logger.Warnf("Maintenance mode expired at %s, leave it automatically", maintenance.until.Format(time.RFC3339))
+		maintenance.until = time.Time{}
+		return models.MaintenanceState{}
+	}
+	return models.MaintenanceState{Enabled: true, Until: maintenance.until}
+}
+
+// IsMaintenance 是否处于维护模式
+func IsMaintenance() bool {
+	return GetMaintenance().Enabled
+}
+
 type Server struct {
 	cfg               *config.AppConfig
 	service           *ServiceManager
 	component         *ComponentManager
+	tunnel            *TunnelManager
 	startTime         time.Time
 	nextMidnightCheck time.Time
+	safeMode          bool                    // 安全模式：不升级组件，用已安装的程序启动服务
+	onceTasks         []models.OnceTaskResult // 启动时运行的once服务的结果
 }
 
 /**
@@ -39,6 +127,7 @@ func NewServer(cfg *config.AppConfig) *Server {
 		cfg:       cfg,
 		service:   GetServiceManager(),
 		component: GetComponentManager(),
+		tunnel:    GetTunnelManager(),
 		startTime: time.Now(),
 	}
 }
@@ -71,12 +160,38 @@ func (s *Server) Components() *ComponentManager {
 	return s.component
 }
 
+/**
+ * Get tunnel manager instance
+ * @returns {TunnelManager} Returns the manager of ad-hoc tunnels
+ */
+func (s *Server) Tunnels() *TunnelManager {
+	return s.tunnel
+}
+
+/**
+ * Enter or leave safe mode, must be called before Init
+ * @param {bool} on - True to enter safe mode
+ * @description
+ * - In safe mode, components aren't upgraded at startup and the midnight rooster is disabled,
+ *   services are started with whatever binaries are currently installed
+ * - Used to recover a fleet hit by a bad release, which would otherwise crash in an upgrade loop
+ */
+func (s *Server) SetSafeMode(on bool) {
+	s.safeMode = on
+}
+
 func (s *Server) Init() error {
 	s.cleanRemains()
+	s.sweepCaches()
 	if err := s.component.Init(); err != nil {
 		return err
 	}
-	s.component.UpgradeAll()
+	if s.safeMode {
+		logger.Warn("Safe mode: component upgrades are skipped")
+	} else {
+		// 升级自身需要重启后才生效，只在明确配置时才升级自身
+		s.component.UpgradeAll(s.cfg.Component.SelfUpgrade)
+	}
 	if err := s.service.Init(); err != nil {
 		return err
 	}
@@ -85,25 +200,56 @@ func (s *Server) Init() error {
 
 /**
  * Start all services and upgrade components
+ * @returns {error} Returns error if a "once" service with abort policy fails
  * @description
- * - Stops all currently running services
- * - Upgrades all components to latest versions
+ * - Runs "once" services first, all of them share service.once_timeout, a failed one aborts
+ *   the remaining startup if its onFailure policy is abort, otherwise the error is only logged
+ * - Once services that get no time left are recorded as timeout without being run
+ * - Results of "once" services are recorded and reported by GetState
  * - Starts all services with background context
  * - Used for initial server startup and full restart
  * @example
  * server := NewServer(cfg)
- * server.StartAllService()
+ * if err := server.StartAllService(); err != nil {
+ *     return err
+ * }
  */
-func (s *Server) StartAllService() {
+func (s *Server) StartAllService() error {
+	// once服务在supervisor.Ready()之前运行，共用一个超时时间，启动耗时不会随once服务数量增加而超过进程管理器的启动超时
+	deadline := time.Now().Add(time.Duration(s.cfg.Service.OnceTimeout) * time.Second)
 	for _, spec := range config.Spec().Services {
-		if spec.Startup != "once" {
+		if spec.Startup != models.StartupOnce {
+			continue
+		}
+		if !spec.MatchPlatform(runtime.GOOS, runtime.GOARCH) {
+			logger.Infof("Service [%s] is skipped, it doesn't run on %s/%s", spec.Name, runtime.GOOS, runtime.GOARCH)
 			continue
 		}
-		if err := RunTool(&spec); err != nil {
+		var result models.OnceTaskResult
+		var err error
+		if timeout := time.Until(deadline); timeout > 0 {
+			result, err = RunTool(&spec, timeout)
+		} else {
+			err = fmt.Errorf("not run, once_timeout (%ds) is used up", s.cfg.Service.OnceTimeout)
+			result = models.OnceTaskResult{
+				Name:      spec.Name,
+				Result:    models.OnceTimeout,
+				ExitCode:  -1,
+				Error:     err.Error(),
+				StartTime: time.Now(),
+			}
+		}
+		s.onceTasks = append(s.onceTasks, result)
+		if err != nil {
+			if spec.OnFailure == models.OnFailureAbort {
+				logger.Errorf("Run [%s] error: %v, abort startup", spec.Name, err)
+				return fmt.Errorf("once service [%s] failed, startup aborted: %w", spec.Name, err)
+			}
 			logger.Errorf("Run [%s] error: %v", spec.Name, err)
 		}
 	}
 	s.service.StartAll(context.Background())
+	return nil
 }
 
 func (s *Server) cleanRemains() {
@@ -113,6 +259,41 @@ func (s *Server) cleanRemains() {
 	}
 }
 
+/**
+ * Remove cache files of services and tunnels which are no longer configured
+ * @description
+ * - Files in cache/services and cache/tunnels are named after services, a file whose name
+ *   matches neither the manager nor any configured service is left by a removed service
+ * - Runs at startup before any tunnel is opened, so caches of ad-hoc tunnels of last run are stale too
+ * - Logs each removed file
+ * @private
+ */
+func (s *Server) sweepCaches() {
+	names := map[string]bool{config.Spec().Manager.Service.Name: true}
+	for _, spec := range config.Spec().Services {
+		names[spec.Name] = true
+	}
+	for _, sub := range []string{"services", "tunnels"} {
+		cacheDir := filepath.Join(env.GetCacheDir(), sub)
+		entries, err := os.ReadDir(cacheDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			fname := entry.Name()
+			if entry.IsDir() || filepath.Ext(fname) != ".json" || names[strings.TrimSuffix(fname, ".json")] {
+				continue
+			}
+			path := filepath.Join(cacheDir, fname)
+			if err := os.Remove(path); err != nil {
+				logger.Warnf("Remove stale cache file '%s' failed: %v", path, err)
+				continue
+			}
+			logger.Infof("Removed stale cache file '%s'", path)
+		}
+	}
+}
+
 /**
  * Stop all services and tunnels gracefully
  * @param {context.Context} ctx - Context for cancellation and timeout
@@ -133,27 +314,56 @@ func (s *Server) cleanRemains() {
  * }
  */
 func (s *Server) StopAllService(ctx context.Context) {
-	s.service.StopAll()
+	if failed := s.service.StopAll(ctx); len(failed) > 0 {
+		logger.Warnf("Services force killed at shutdown: %s", strings.Join(failed, ", "))
+	}
+	s.tunnel.CloseAll()
 }
 
 /**
  * Start monitoring services, tunnels, and processes
  * @description
- * - Creates ticker with configured monitoring interval
- * - Periodically checks service health status
+ * - Divides the configured monitoring interval into one-second slots
+ * - Periodically checks service health status, each service in its own slot
  * - Periodically checks tunnel connectivity
  * - Periodically checks process status
- * - Runs indefinitely until server shutdown
+ * - Runs until ctx is cancelled
  * @example
- * go server.StartMonitoring()
+ * lifecycle.Go("monitoring", server.StartMonitoring)
  */
-func (s *Server) StartMonitoring() {
-	interval := time.Duration(s.cfg.Interval.Monitoring) * time.Second
-	ticker := time.NewTicker(interval)
+func (s *Server) StartMonitoring(ctx context.Context) {
+	// 每秒推进一个时隙，每个服务在监控周期内的固定时隙被检查，避免所有服务同时检查
+	slots := s.cfg.Interval.Monitoring
+	if slots < 1 {
+		slots = 1
+	}
+	slot := 0
+	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
+	// pending隧道的重试检查更频繁，实际重试间隔由各隧道的退避时间决定
+	retryTicker := time.NewTicker(5 * time.Second)
+	defer retryTicker.Stop()
+	// systemd启用了看门狗时，按超时时间的一半从监控循环发送心跳，监控循环卡住时systemd会重启keeper
+	var watchdog <-chan time.Time
+	if interval := supervisor.WatchdogInterval(); interval > 0 {
+		watchdogTicker := time.NewTicker(interval / 2)
+		defer watchdogTicker.Stop()
+		watchdog = watchdogTicker.C
+	}
 
-	for range ticker.C {
-		s.service.RecoverServices()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.service.RecoverServices(slot, slots)
+			slot = (slot + 1) % slots
+		case <-retryTicker.C:
+			s.service.RetryPendingTunnels(ctx)
+			s.service.RetryExport()
+		case <-watchdog:
+			supervisor.Watchdog()
+		}
 	}
 }
 
@@ -164,23 +374,31 @@ func (s *Server) StartMonitoring() {
  * - Creates ticker with configured metrics report interval
  * - Periodically calls ReportMetrics to send metrics
  * - Logs errors if metrics reporting fails
- * - Runs indefinitely until server shutdown
+ * - Runs until ctx is cancelled
  * @example
- * go server.StartReportMetrics()
+ * lifecycle.Go("metrics-report", server.StartReportMetrics)
  */
-func (s *Server) StartReportMetrics() {
+func (s *Server) StartReportMetrics(ctx context.Context) {
 	interval := s.cfg.Interval.MetricsReport
 	if interval <= 0 {
 		logger.Info("Metrics reporting is disabled (interval <= 0)")
 		return
 	}
+	if _, err := selectPushCollectors(s.cfg.Metrics.PushCollectors); err != nil {
+		logger.Warnf("Invalid metrics.push_collectors: %v", err)
+	}
 
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		if err := s.ReportMetrics(); err != nil {
-			logger.Errorf("Metrics reporting error: %v", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ReportMetrics(); err != nil {
+				logger.Errorf("Metrics reporting error: %v", err)
+			}
 		}
 	}
 }
@@ -192,11 +410,11 @@ func (s *Server) StartReportMetrics() {
  * - Creates ticker with configured log report interval
  * - Periodically calls ReportLogs to send logs
  * - Logs errors if log reporting fails
- * - Runs indefinitely until server shutdown
+ * - Runs until ctx is cancelled
  * @example
- * go server.StartLogReporting()
+ * lifecycle.Go("log-report", server.StartLogReporting)
  */
-func (s *Server) StartLogReporting() {
+func (s *Server) StartLogReporting(ctx context.Context) {
 	interval := s.cfg.Interval.LogReport
 	if interval <= 0 {
 		logger.Info("Log reporting is disabled (interval <= 0)")
@@ -210,53 +428,132 @@ func (s *Server) StartLogReporting() {
 	if err := ls.UploadErrors(); err != nil {
 		logger.Warnf("Collect and upload the error logs failed: %v", err)
 	}
-	for range ticker.C {
-		if err := ls.UploadErrors(); err != nil {
-			logger.Warnf("Collect and upload the error logs failed: %v", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ls.UploadErrors(); err != nil {
+				logger.Warnf("Collect and upload the error logs failed: %v", err)
+			}
 		}
 	}
 }
 
+// 半夜鸡叫重新核对系统时间的间隔，系统时间跳变(NTP校正、休眠唤醒)后最多延迟这么久就能发现
+const midnightRecheckInterval = time.Minute
+
 /**
  * Start midnight rooster mechanism for automatic upgrade checking
  * @description
- * - Starts a goroutine that schedules upgrade checks between 3-5 AM
+ * - Schedules upgrade checks between 3-5 AM
  * - Randomly selects a time within the 3-5 AM window each day
  * - Checks for component upgrades and exits if upgrades are needed
- * - Uses time.Ticker for daily scheduling
+ * - Compares the wall clock with the scheduled time every minute instead of trusting
+ *   a single long timer, so it isn't fooled by clock jumps
  * - Logs scheduling and check operations
- * - Runs indefinitely until server shutdown or upgrade detected
+ * - Runs until ctx is cancelled or upgrade detected
  * @example
  * // This is typically called during server startup
- * server.StartMidnightRooster()
+ * lifecycle.Go("midnight-rooster", server.StartMidnightRooster)
  */
-func (s *Server) StartMidnightRooster() {
-	// 每天午夜检查一次，计算到明天3-5点之间的随机时间
-	ticker := time.NewTicker(24 * time.Hour)
+func (s *Server) StartMidnightRooster(ctx context.Context) {
+	if s.safeMode {
+		logger.Warn("Safe mode: midnight rooster is disabled")
+		return
+	}
+	ticker := time.NewTicker(midnightRecheckInterval)
 	defer ticker.Stop()
 
 	logger.Info("Starting midnight rooster mechanism for upgrade checking")
 
-	// 立即执行第一次检查
-	s.scheduleMidnightCheck()
+	// 计算到明天3-5点之间的随机时间
+	s.scheduleMidnightCheck(time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.midnightCheckDue(time.Now()) {
+				s.performMidnightCheck()
+			}
+		}
+	}
+}
+
+/**
+ * Check if the scheduled upgrade check should run now
+ * @param {time.Time} now - Current wall clock time
+ * @returns {bool} Returns true if the check should be performed now
+ * @description
+ * - Reschedules the next check whenever the scheduled time is reached
+ * - Skips the check if it's reached much later than scheduled and out of the configured
+ *   window, e.g. resumed from sleep at noon, the check then runs in tomorrow's window
+ * - Reschedules if the clock jumped backward so far that the scheduled time is
+ *   more than two days away
+ * @private
+ */
+func (s *Server) midnightCheckDue(now time.Time) bool {
+	checkTime := s.nextMidnightCheck
+	if now.Before(checkTime) {
+		if checkTime.Sub(now) > 48*time.Hour {
+			logger.Warnf("Clock jumped backward, upgrade check scheduled at %s is rescheduled",
+				checkTime.Format("2006-01-02 15:04:05"))
+			s.scheduleMidnightCheck(now)
+		}
+		return false
+	}
+	s.scheduleMidnightCheck(now)
+	// 正常情况下最多晚一个核对间隔，晚太久且已不在时间窗口内，说明时钟跳变了
+	late := now.Sub(checkTime) > 2*midnightRecheckInterval
+	if late && !inMidnightWindow(now, s.cfg.Midnight.StartHour, s.cfg.Midnight.EndHour) {
+		logger.Warnf("Skip upgrade check scheduled at %s, current time %s is out of the window, the clock may have jumped",
+			checkTime.Format("2006-01-02 15:04:05"), now.Format("2006-01-02 15:04:05"))
+		return false
+	}
+	return true
+}
+
+/**
+ * Check if time is in the midnight rooster window
+ * @param {time.Time} t - Time to check
+ * @param {int} startHour - Start hour of the window
+ * @param {int} endHour - End hour of the window(exclusive)
+ * @returns {bool} Returns true if startHour <= hour of t < endHour
+ * @description
+ * - The window wraps past midnight if endHour <= startHour, e.g. 23:00-02:00
+ */
+func inMidnightWindow(t time.Time, startHour, endHour int) bool {
+	if startHour < endHour {
+		return t.Hour() >= startHour && t.Hour() < endHour
+	}
+	return t.Hour() >= startHour || t.Hour() < endHour
+}
 
-	for range ticker.C {
-		s.scheduleMidnightCheck()
+/**
+ * Get the length of the midnight rooster window in minutes
+ * @param {int} startHour - Start hour of the window
+ * @param {int} endHour - End hour of the window(exclusive)
+ * @returns {int} Returns minutes from startHour to endHour, wrapping past midnight
+ */
+func midnightWindowMinutes(startHour, endHour int) int {
+	hours := (endHour - startHour + 24) % 24
+	if hours == 0 {
+		hours = 24
 	}
+	return hours * 60
 }
 
 /**
  * Schedule upgrade check for random time between 3-5 AM
+ * @param {time.Time} now - Current wall clock time
  * @description
- * - Calculates random time between 3:00-5:00 AM
- * - Sets up timer for the calculated time
- * - When timer expires, performs upgrade check
- * - If upgrades are needed, exits the application
+ * - Calculates random time between 3:00-5:00 AM of tomorrow
+ * - The check is performed by StartMidnightRooster when the time is reached
  * @private
  */
-func (s *Server) scheduleMidnightCheck() {
-	now := time.Now()
-
+func (s *Server) scheduleMidnightCheck(now time.Time) {
 	// 计算明天的日期
 	tomorrow := now.Add(24 * time.Hour)
 
@@ -268,7 +565,7 @@ func (s *Server) scheduleMidnightCheck() {
 	baseTime := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), startHour, 0, 0, 0, tomorrow.Location())
 
 	// 在配置的时间范围内随机选择一个时间
-	maxMinutes := (endHour - startHour) * 60
+	maxMinutes := midnightWindowMinutes(startHour, endHour)
 	randomMinutes := rand.Intn(maxMinutes) // 0 到 (maxMinutes-1) 分钟
 	checkTime := baseTime.Add(time.Duration(randomMinutes) * time.Minute)
 	// 保存下一次半夜鸡叫的时间
@@ -279,14 +576,6 @@ func (s *Server) scheduleMidnightCheck() {
 
 	logger.Infof("Scheduled upgrade check for %s (in %v), time range: %d:00-%d:00",
 		checkTime.Format("2006-01-02 15:04:05"), waitDuration, startHour, endHour)
-
-	// 设置定时器
-	timer := time.NewTimer(waitDuration)
-
-	go func() {
-		<-timer.C
-		s.performMidnightCheck()
-	}()
 }
 
 /**
@@ -298,6 +587,10 @@ func (s *Server) scheduleMidnightCheck() {
  * @private
  */
 func (s *Server) performMidnightCheck() {
+	if IsMaintenance() {
+		logger.Info("Skip midnight upgrade check in maintenance mode")
+		return
+	}
 	logger.Info("Performing midnight upgrade check...")
 
 	// 检查所有组件是否需要升级
@@ -420,6 +713,18 @@ func (s *Server) Check() models.CheckResponse {
  * }
  */
 func (s *Server) CheckExcessiveProcesses() error {
+	if unexpected := s.findExcessiveProcesses(); len(unexpected) > 0 {
+		return fmt.Errorf("%v", unexpected)
+	}
+	return nil
+}
+
+/**
+ * Find processes of components which aren't managed by costrict
+ * @returns {[]int} Returns sorted PIDs of unexpected processes
+ * @private
+ */
+func (s *Server) findExcessiveProcesses() []int {
 	var all []int
 	var exp []int
 
@@ -430,6 +735,7 @@ func (s *Server) CheckExcessiveProcesses() error {
 			exp = append(exp, tun.GetPid())
 		}
 	}
+	exp = append(exp, s.tunnel.GetPids()...)
 	for _, cpn := range s.component.components {
 		pids := utils.FindProcesses(cpn.spec.Name)
 		all = append(all, pids...)
@@ -458,12 +764,114 @@ func (s *Server) CheckExcessiveProcesses() error {
 		unexpected = append(unexpected, all[i])
 		i++
 	}
+	return unexpected
+}
 
-	if len(unexpected) > 0 {
-		return fmt.Errorf("%v", unexpected)
+/**
+ * Get processes managed by costrict
+ * @returns {models.ProcessesResponse} Returns service and tunnel processes, plus excessive PIDs
+ * @description
+ * - Lists child processes of services, tunnels of services and ad-hoc tunnels
+ * - Excessive PIDs are found the same way as CheckExcessiveProcesses
+ * - Used to debug process leaks
+ */
+func (s *Server) GetProcesses() models.ProcessesResponse {
+	response := models.ProcessesResponse{
+		Processes: []models.ManagedProcess{},
+		Excessive: []int{},
+	}
+	for _, svc := range s.service.GetInstances(true) {
+		if svc.child {
+			response.Processes = append(response.Processes, managedProcess("service", svc.GetName(), svc.proc.GetDetail()))
+		}
+		if tun := svc.GetTunnel(); tun != nil {
+			if detail := tun.GetProcessDetail(); detail != nil {
+				response.Processes = append(response.Processes, managedProcess("tunnel", svc.GetName(), *detail))
+			}
+		}
 	}
+	response.Processes = append(response.Processes, s.tunnel.GetProcesses()...)
+	response.Excessive = append(response.Excessive, s.findExcessiveProcesses()...)
+	return response
+}
 
-	return nil
+/**
+ * Kill excessive processes of components
+ * @param {models.ProcessCleanupRequest} req - PIDs to kill, or all excessive processes
+ * @returns {[]models.ProcessCleanupResult} Returns result of each PID
+ * @description
+ * - Excessive processes are detected again, PIDs which are no longer excessive are refused,
+ *   so a stale list can't kill a process which has just been taken over
+ * - Each PID must be a binary of a component under CostrictDir/bin, checked by process name and path
+ * - The keeper itself is never killed
+ */
+func (s *Server) CleanupProcesses(req models.ProcessCleanupRequest) []models.ProcessCleanupResult {
+	excessive := s.findExcessiveProcesses()
+	pids := req.Pids
+	if req.All {
+		pids = excessive
+	}
+	results := []models.ProcessCleanupResult{}
+	for _, pid := range pids {
+		result := models.ProcessCleanupResult{Pid: pid}
+		name, err := s.killExcessiveProcess(pid, excessive)
+		result.Name = name
+		if err != nil {
+			result.Error = err.Error()
+			logger.Warnf("Cleanup process %d failed: %v", pid, err)
+		} else {
+			result.Killed = true
+			logger.Infof("Excessive process %d of '%s' is killed", pid, result.Name)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 确认pid是组件的多余进程后杀死它，返回进程所属的组件名
+func (s *Server) killExcessiveProcess(pid int, excessive []int) (string, error) {
+	if pid == os.Getpid() {
+		return "", fmt.Errorf("refuse to kill the keeper itself")
+	}
+	if !slices.Contains(excessive, pid) {
+		return "", fmt.Errorf("process %d isn't an excessive process", pid)
+	}
+	procName, err := utils.GetProcessName(pid)
+	if err != nil {
+		return "", err
+	}
+	procName = utils.Path2ProcessName(procName)
+	name := ""
+	for _, cpn := range s.component.components {
+		if strings.EqualFold(cpn.spec.Name, procName) {
+			name = cpn.spec.Name
+			break
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("process %d (%s) isn't a component binary", pid, procName)
+	}
+	if !utils.IsManagedProcess(pid) {
+		return name, fmt.Errorf("process %d (%s) isn't located in the costrict bin directory", pid, procName)
+	}
+	return name, utils.KillProcessByPID(pid)
+}
+
+func managedProcess(kind, owner string, detail models.ProcessDetail) models.ManagedProcess {
+	mp := models.ManagedProcess{
+		Kind:         kind,
+		Owner:        owner,
+		Pid:          detail.Pid,
+		Name:         detail.ProcessName,
+		Title:        detail.Title,
+		Status:       detail.Status,
+		RestartCount: detail.RestartCount,
+		StartTime:    detail.StartTime,
+	}
+	if detail.Status == models.StatusRunning && !detail.StartTime.IsZero() {
+		mp.Uptime = time.Since(detail.StartTime).Round(time.Second).String()
+	}
+	return mp
 }
 
 func configToString(v interface{}) string {
@@ -476,7 +884,11 @@ func configToString(v interface{}) string {
 
 func (s *Server) GetState() models.ServerState {
 	state := models.ServerState{
-		StartTime: s.startTime,
+		StartTime:   s.startTime,
+		SafeMode:    s.safeMode,
+		Maintenance: GetMaintenance(),
+		ExportError: s.service.GetExportError(),
+		OnceTasks:   s.onceTasks,
 	}
 
 	// 半夜鸡叫设置
@@ -485,6 +897,9 @@ func (s *Server) GetState() models.ServerState {
 		NextCheckTime: s.nextMidnightCheck,
 		LastCheckTime: time.Now(), // 简化处理
 	}
+	if s.safeMode {
+		state.MidnightRooster.Status = "disabled"
+	}
 	// 端口分配记录
 	min, max, allocs := utils.GetPortAllocates()
 	state.PortAlloc.Max = max
@@ -497,10 +912,18 @@ func (s *Server) GetState() models.ServerState {
 	state.Env.ListenPort = env.ListenPort
 	state.Env.Version = env.Version
 
+	// 后台协程统计，用于诊断协程泄漏
+	state.Workers = models.WorkerState{
+		Total:      lifecycle.Count(),
+		Goroutines: runtime.NumGoroutine(),
+		Workers:    lifecycle.Workers(),
+	}
+
+	// GET请求无需认证，只返回脱敏后的配置，避免泄漏api.token等凭据
 	state.Config = models.ServerConfig{
-		SystemSpec: configToString(config.Spec()),
-		Auth:       configToString(config.GetAuthConfig()),
-		Software:   configToString(config.App()),
+		SystemSpec: configToString(config.EffectiveSpec()),
+		Auth:       configToString(config.EffectiveAuth()),
+		Software:   configToString(config.Effective()),
 		Cloud:      configToString(config.Cloud()),
 	}
 	return state
@@ -527,22 +950,87 @@ func (s *Server) ReportMetrics() error {
 	return nil
 }
 
+/**
+ * Check reachability of upstream dependencies configured in cloud section
+ * @returns {models.DependenciesResponse} Returns reachability and latency of each dependency
+ * @description
+ * - Probes upgrade server, tunnel manager, log endpoint and pushgateway concurrently,
+ *   endpoints which aren't configured are skipped
+ * - Each probe has a short timeout, so the check completes quickly even if network is down
+ * - Used to distinguish "keeper broken" from "network to cloud broken"
+ */
+func (s *Server) CheckDependencies() models.DependenciesResponse {
+	cloud := config.Cloud()
+	deps := []models.DependencyStatus{}
+	for _, dep := range []models.DependencyStatus{
+		{Name: "upgrade", Url: cloud.UpgradeUrl},
+		{Name: "tunnel_manager", Url: cloud.TunManagerUrl},
+		{Name: "log", Url: cloud.LogUrl},
+		{Name: "pushgateway", Url: cloud.PushgatewayUrl},
+	} {
+		if dep.Url != "" {
+			deps = append(deps, dep)
+		}
+	}
+	var wg sync.WaitGroup
+	for i := range deps {
+		wg.Add(1)
+		go func(dep *models.DependencyStatus) {
+			defer wg.Done()
+			start := time.Now()
+			code, err := utils.ProbeURL(dep.Url, dependencyProbeTimeout)
+			dep.Latency = time.Since(start).Milliseconds()
+			if err != nil {
+				dep.Error = err.Error()
+				return
+			}
+			dep.Reachable = true
+			dep.StatusCode = code
+		}(&deps[i])
+	}
+	wg.Wait()
+
+	response := models.DependenciesResponse{
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Reachable:    true,
+		Dependencies: deps,
+	}
+	for _, dep := range deps {
+		if !dep.Reachable {
+			response.Reachable = false
+		}
+	}
+	return response
+}
+
+func (s *Server) getHealthStatus() string {
+	if IsDraining() {
+		return "DRAINING"
+	}
+	if IsMaintenance() {
+		return "MAINTENANCE"
+	}
+	return "UP"
+}
+
 /**
 * Get health check response for the server
+* @param {bool} deep - Also check end-to-end reachability of the tunnels, which is more expensive
 * @returns {models.HealthResponse} Returns health check response with server status and metrics
 * @description
 * - Calculates server uptime from start time
 * - Collects service statistics (active services count)
 * - Collects tunnel statistics (active tunnels count)
+* - In deep mode, probes tunnels of remote accessible services through the mapping port
 * - Collects component statistics (total and upgraded components count)
 * - Builds comprehensive health response with all metrics
 * - Used for health check endpoint and monitoring
 * @example
 * server := NewServer(cfg)
-* health := server.GetHealthz()
+* health := server.GetHealthz(false)
 * fmt.Printf("Server status: %s, Uptime: %s\n", health.Status, health.Uptime)
  */
-func (s *Server) GetHealthz() models.HealthResponse {
+func (s *Server) GetHealthz(deep bool) models.HealthResponse {
 	// 计算服务运行时间
 	uptime := time.Since(s.startTime)
 
@@ -550,7 +1038,7 @@ func (s *Server) GetHealthz() models.HealthResponse {
 	activeServices := 0
 	activeTunnels := 0
 	for _, svc := range s.service.GetInstances(false) {
-		if svc.status == models.StatusRunning {
+		if svc.getStatus() == models.StatusRunning {
 			activeServices++
 			tun := svc.GetTunnel()
 			if tun != nil {
@@ -561,6 +1049,11 @@ func (s *Server) GetHealthz() models.HealthResponse {
 			}
 		}
 	}
+	for _, detail := range s.tunnel.GetTunnels() {
+		if detail.Status == models.StatusRunning {
+			activeTunnels += len(detail.Pairs)
+		}
+	}
 
 	// 获取组件统计信息
 	components := s.component.GetComponents(true, true)
@@ -574,10 +1067,11 @@ func (s *Server) GetHealthz() models.HealthResponse {
 
 	// 构建响应
 	response := models.HealthResponse{
-		Version:   env.Version,
-		StartTime: s.startTime.Format(time.RFC3339),
-		Status:    "UP",
-		Uptime:    uptime.String(),
+		Version:     env.Version,
+		StartTime:   s.startTime.Format(time.RFC3339),
+		Status:      s.getHealthStatus(),
+		Uptime:      uptime.String(),
+		ExportError: s.service.GetExportError(),
 		Metrics: models.Metrics{
 			TotalRequests:      GetTotalRequestCount(),
 			ErrorRequests:      GetTotalErrorCount(),
@@ -587,6 +1081,109 @@ func (s *Server) GetHealthz() models.HealthResponse {
 			UpgradedComponents: upgradedComponents,
 		},
 	}
+	if deep {
+		tunnels := probeTunnels(s.service.GetInstances(false))
+		reachable := 0
+		for _, t := range tunnels {
+			if t.Reachable {
+				reachable++
+			}
+		}
+		response.Metrics.TunnelReachable = &reachable
+		response.Tunnels = tunnels
+	}
 
 	return response
 }
+
+/**
+ * Get services, components and tunnels which aren't healthy
+ * @returns {[]models.UnhealthyItem} Returns unhealthy items, empty if all are healthy
+ * @description
+ * - Computed from the state recorded by monitoring, neither probes services nor fetches
+ *   remote versions, so monitors can poll it cheaply and call /check only when it's not empty
+ * - Components are unhealthy if they aren't installed or the post-install hook failed
+ */
+func (s *Server) GetUnhealthy() []models.UnhealthyItem {
+	items := []models.UnhealthyItem{}
+	for _, svc := range s.service.GetInstances(false) {
+		if healthy, reason := svc.getCachedHealthy(); healthy != models.Healthy {
+			items = append(items, models.UnhealthyItem{
+				Kind:    "service",
+				Name:    svc.GetName(),
+				Healthy: healthy,
+				Reason:  reason,
+			})
+		}
+	}
+	for _, cpn := range s.component.GetComponents(false, true) {
+		item := models.UnhealthyItem{
+			Kind:    "component",
+			Name:    cpn.spec.Name,
+			Healthy: models.Unavailable,
+		}
+		if !cpn.installed {
+			item.Reason = "component isn't installed"
+		} else if cpn.incomplete {
+			item.Healthy = models.Incomplete
+			item.Reason = "post-install hook failed"
+		} else {
+			continue
+		}
+		items = append(items, item)
+	}
+	// 服务和组件按类型、名称排序，使结果稳定，隧道已经排好序
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind > items[j].Kind
+		}
+		return items[i].Name < items[j].Name
+	})
+	return append(items, s.tunnel.GetUnhealthy()...)
+}
+
+/**
+ * Get snapshot of current metrics as structured data
+ * @returns {models.MetricsSnapshot} Returns metrics snapshot
+ * @description
+ * - Reuses the key metrics of GetHealthz
+ * - Adds per-service counters/gauges and component versions
+ * - Reads the registered collectors directly, so it doesn't depend on /metrics being scraped
+ */
+func (s *Server) GetMetricsSnapshot() models.MetricsSnapshot {
+	health := s.GetHealthz(false)
+	requests := gatherValues(requestCount, "service", "")
+	errors := gatherValues(errorCount, "service", "")
+	ooms := gatherValues(serviceOOMCount, "service", "")
+	uptimes := gatherValues(serviceUpTime, "service", "")
+
+	snapshot := models.MetricsSnapshot{
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Uptime:     health.Uptime,
+		Metrics:    health.Metrics,
+		Services:   []models.ServiceMetrics{},
+		Components: []models.ComponentVersion{},
+	}
+	for _, svc := range s.service.GetInstances(true) {
+		name := svc.GetName()
+		sm := models.ServiceMetrics{
+			Name:     name,
+			Healthy:  svc.GetHealthy(),
+			Requests: requests[name],
+			Errors:   errors[name],
+			OOMKills: ooms[name],
+			Uptime:   uptimes[name],
+		}
+		sm.Scraped = scrapedMetrics.values(name)
+		snapshot.Services = append(snapshot.Services, sm)
+	}
+	for _, cpn := range s.component.GetComponents(true, true) {
+		detail := cpn.GetDetail()
+		snapshot.Components = append(snapshot.Components, models.ComponentVersion{
+			Name:      detail.Name,
+			Version:   detail.Local.Version,
+			Installed: detail.Installed,
+		})
+	}
+	return snapshot
+}
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/proc"
+	"costrict-keeper/internal/tun"
+)
+
+/**
+ * Create a server whose service manager manages no service
+ * @description
+ * - Must be called after setupTestEnv
+ */
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	return &Server{
+		cfg:     config.App(),
+		service: newTestServiceManager(t),
+	}
+}
+
+/**
+ * Create a "once" service spec which runs the test binary and exits with 0,
+ * or a command which can't be started if fail is true
+ */
+func onceSpec(name string, fail bool, onFailure string) models.ServiceSpecification {
+	spec := models.ServiceSpecification{
+		Name:      name,
+		Startup:   models.StartupOnce,
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=^$"},
+		OnFailure: onFailure,
+	}
+	if fail {
+		spec.Command = filepath.Join(os.TempDir(), "costrict-no-such-command")
+		spec.Args = nil
+	}
+	return spec
+}
+
+func TestStartAllServiceOnceContinue(t *testing.T) {
+	setupTestEnv(t, "")
+	setupTestSpec(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			onceSpec("prepare", true, ""),
+			onceSpec("migrate", false, models.OnFailureContinue),
+		},
+	})
+	s := newTestServer(t)
+
+	if err := s.StartAllService(); err != nil {
+		t.Fatalf("StartAllService() = %v, want nil with continue policy", err)
+	}
+	if len(s.onceTasks) != 2 {
+		t.Fatalf("%d once tasks run, want 2", len(s.onceTasks))
+	}
+	if r := s.onceTasks[0]; r.Name != "prepare" || r.Result != models.OnceFailed {
+		t.Errorf("first task = %s/%s, want prepare/%s", r.Name, r.Result, models.OnceFailed)
+	}
+	if r := s.onceTasks[1]; r.Name != "migrate" || r.Result != models.OnceSuccess {
+		t.Errorf("second task = %s/%s, want migrate/%s", r.Name, r.Result, models.OnceSuccess)
+	}
+}
+
+func TestStartAllServiceOnceAbort(t *testing.T) {
+	setupTestEnv(t, "")
+	setupTestSpec(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			onceSpec("prepare", true, models.OnFailureAbort),
+			onceSpec("migrate", false, ""),
+		},
+	})
+	s := newTestServer(t)
+
+	if err := s.StartAllService(); err == nil {
+		t.Fatal("StartAllService() = nil, want error with abort policy")
+	}
+	if len(s.onceTasks) != 1 || s.onceTasks[0].Name != "prepare" {
+		t.Errorf("once tasks = %+v, want only prepare to run", s.onceTasks)
+	}
+}
+
+func TestStartAllServiceOnceNonZeroExit(t *testing.T) {
+	setupTestEnv(t, "")
+	t.Setenv("COSTRICT_TEST_EXIT_CODE", "3")
+	setupTestSpec(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			onceSpec("migrate", false, models.OnFailureContinue),
+		},
+	})
+	s := newTestServer(t)
+	s.component = newTestComponentManager(t, "")
+	s.tunnel = GetTunnelManager()
+	s.startTime = time.Now()
+
+	if err := s.StartAllService(); err != nil {
+		t.Fatalf("StartAllService() = %v, want nil with continue policy", err)
+	}
+	tasks := s.GetState().OnceTasks
+	if len(tasks) != 1 {
+		t.Fatalf("state reports %d once tasks, want 1", len(tasks))
+	}
+	r := tasks[0]
+	if r.Name != "migrate" || r.Result != models.OnceFailed || r.ExitCode != 3 {
+		t.Errorf("task = %s/%s/%d, want migrate/%s/3", r.Name, r.Result, r.ExitCode, models.OnceFailed)
+	}
+	if r.Error == "" {
+		t.Error("task error isn't reported")
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	setupTestEnv(t, "")
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	// 侦听后立即关闭，得到一个无人侦听的端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downUrl := "http://" + ln.Addr().String() + "/logs"
+	ln.Close()
+
+	cloud := config.Cloud()
+	cloud.UpgradeUrl = up.URL + "/costrict"
+	cloud.TunManagerUrl = notFound.URL + "/tunnel-manager/api/v1"
+	cloud.LogUrl = downUrl
+	cloud.PushgatewayUrl = ""
+	s := newTestServer(t)
+
+	resp := s.CheckDependencies()
+	if resp.Reachable {
+		t.Error("dependencies should be unreachable when the log endpoint is down")
+	}
+	got := map[string]models.DependencyStatus{}
+	for _, dep := range resp.Dependencies {
+		got[dep.Name] = dep
+	}
+	if len(got) != 3 {
+		t.Fatalf("dependencies = %+v, want 3 configured ones", resp.Dependencies)
+	}
+	if _, ok := got["pushgateway"]; ok {
+		t.Error("unset pushgateway shouldn't be probed")
+	}
+	if dep := got["upgrade"]; !dep.Reachable || dep.StatusCode != http.StatusOK {
+		t.Errorf("upgrade = %+v, want reachable with 200", dep)
+	}
+	// 收到任何HTTP响应都认为可达
+	if dep := got["tunnel_manager"]; !dep.Reachable || dep.StatusCode != http.StatusNotFound {
+		t.Errorf("tunnel_manager = %+v, want reachable with 404", dep)
+	}
+	if dep := got["log"]; dep.Reachable || dep.Error == "" {
+		t.Errorf("log = %+v, want unreachable with error", dep)
+	}
+}
+
+func TestInitSafeModeSkipsUpgrade(t *testing.T) {
+	for _, safeMode := range []bool{true, false} {
+		t.Run(fmt.Sprintf("safe mode %v", safeMode), func(t *testing.T) {
+			setupTestEnv(t, "")
+			srv := newUpgradeServer(t,
+				testPackage{name: "costrict-safe-keeper", version: "1.0.0", content: "keeper"},
+				testPackage{name: "costrict-safe-alpha", version: "1.0.0", content: "alpha"})
+			downloads := 0
+			handler := srv.Config.Handler
+			srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// 版本描述之外的请求都是下载包
+				if !strings.HasSuffix(r.URL.Path, ".json") {
+					downloads++
+				}
+				handler.ServeHTTP(w, r)
+			})
+			setupTestSpec(t, models.SystemSpecification{
+				Manager: models.ManagerSpecification{
+					Component: models.ComponentSpecification{Name: "costrict-safe-keeper", UpgradeUrl: srv.URL},
+					Service:   models.ServiceSpecification{Name: "costrict-safe-keeper", Startup: models.StartupAlways},
+				},
+				Components: []models.ComponentSpecification{{Name: "costrict-safe-alpha", UpgradeUrl: srv.URL}},
+			})
+			cm := newTestComponentManager(t, srv.URL)
+			s := &Server{cfg: config.App(), service: newTestServiceManager(t), component: cm}
+			s.SetSafeMode(safeMode)
+
+			if err := s.Init(); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(filepath.Join(env.CostrictDir, "bin", "costrict-safe-alpha"))
+			if safeMode {
+				if downloads != 0 || err == nil {
+					t.Errorf("components are upgraded in safe mode, %d packages downloaded", downloads)
+				}
+				return
+			}
+			if downloads == 0 || err != nil {
+				t.Errorf("components aren't upgraded at startup: %v", err)
+			}
+		})
+	}
+}
+
+func TestSweepCaches(t *testing.T) {
+	setupTestEnv(t, "")
+	setupTestSpec(t, models.SystemSpecification{
+		Manager: models.ManagerSpecification{
+			Service: models.ServiceSpecification{Name: "costrict", Startup: models.StartupAlways},
+		},
+		Services: []models.ServiceSpecification{{Name: "codebase-syncer", Startup: models.StartupAlways}},
+	})
+	cacheDir := env.GetCacheDir()
+	kept := []string{
+		filepath.Join(cacheDir, "services", "costrict.json"),
+		filepath.Join(cacheDir, "services", "codebase-syncer.json"),
+		filepath.Join(cacheDir, "tunnels", "codebase-syncer.json"),
+		filepath.Join(cacheDir, "services", "notes.txt"), // 不是缓存文件
+	}
+	orphans := []string{
+		filepath.Join(cacheDir, "services", "removed-svc.json"),
+		filepath.Join(cacheDir, "tunnels", "removed-svc.json"),
+	}
+	for _, fname := range append(kept, orphans...) {
+		writeTestFile(t, fname, "{}")
+	}
+
+	newTestServer(t).sweepCaches()
+	for _, fname := range kept {
+		if _, err := os.Stat(fname); err != nil {
+			t.Errorf("'%s' shouldn't be removed: %v", fname, err)
+		}
+	}
+	for _, fname := range orphans {
+		if _, err := os.Stat(fname); !os.IsNotExist(err) {
+			t.Errorf("orphan cache file '%s' isn't removed", fname)
+		}
+	}
+}
+
+func TestInMidnightWindow(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2025, 1, 2, hour, min, 0, 0, time.Local) }
+	cases := []struct {
+		start, end int
+		t          time.Time
+		want       bool
+	}{
+		{3, 5, at(3, 0), true},
+		{3, 5, at(4, 59), true},
+		{3, 5, at(5, 0), false},
+		{3, 5, at(2, 59), false},
+		{23, 2, at(23, 30), true},
+		{23, 2, at(1, 59), true},
+		{23, 2, at(2, 0), false},
+		{23, 2, at(12, 0), false},
+	}
+	for _, c := range cases {
+		if got := inMidnightWindow(c.t, c.start, c.end); got != c.want {
+			t.Errorf("inMidnightWindow(%s, %d, %d) = %v, want %v", c.t.Format("15:04"), c.start, c.end, got, c.want)
+		}
+	}
+}
+
+func TestMidnightCheckClockJump(t *testing.T) {
+	s := &Server{cfg: &config.AppConfig{Midnight: config.MidnightRooster{StartHour: 3, EndHour: 5}}}
+	scheduled := time.Date(2025, 1, 2, 3, 30, 0, 0, time.Local)
+
+	// 正常到点
+	s.nextMidnightCheck = scheduled
+	if !s.midnightCheckDue(scheduled.Add(30 * time.Second)) {
+		t.Error("check should run when the scheduled time is reached")
+	}
+	if !s.nextMidnightCheck.After(scheduled.Add(20 * time.Hour)) {
+		t.Errorf("next check isn't rescheduled: %s", s.nextMidnightCheck)
+	}
+
+	// 时钟向前跳到窗口外(休眠到中午才唤醒)，不应立即检查
+	s.nextMidnightCheck = scheduled
+	noon := time.Date(2025, 1, 2, 12, 0, 0, 0, time.Local)
+	if s.midnightCheckDue(noon) {
+		t.Error("check should be skipped when the clock jumped out of the window")
+	}
+	if !s.nextMidnightCheck.After(noon) {
+		t.Errorf("next check isn't rescheduled after the jump: %s", s.nextMidnightCheck)
+	}
+
+	// 时钟向前跳但仍在窗口内，照常检查
+	s.nextMidnightCheck = scheduled
+	if !s.midnightCheckDue(scheduled.Add(time.Hour)) {
+		t.Error("check should run when the clock jumped within the window")
+	}
+
+	// 时钟大幅回拨，原定时间变得遥远，需要重新安排
+	s.nextMidnightCheck = scheduled
+	back := scheduled.Add(-10 * 24 * time.Hour)
+	if s.midnightCheckDue(back) {
+		t.Error("check should not run after the clock jumped backward")
+	}
+	if d := s.nextMidnightCheck.Sub(back); d <= 0 || d > 48*time.Hour {
+		t.Errorf("next check isn't rescheduled after the backward jump: %s", s.nextMidnightCheck)
+	}
+}
+
+func TestMidnightCheckWrapWindow(t *testing.T) {
+	s := &Server{cfg: &config.AppConfig{Midnight: config.MidnightRooster{StartHour: 23, EndHour: 2}}}
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 100; i++ {
+		s.scheduleMidnightCheck(now)
+		if !inMidnightWindow(s.nextMidnightCheck, 23, 2) {
+			t.Fatalf("scheduled time %s is out of the window", s.nextMidnightCheck)
+		}
+	}
+
+	// 跨过午夜后才到点，晚了但仍在窗口内
+	s.nextMidnightCheck = time.Date(2025, 1, 2, 23, 50, 0, 0, time.Local)
+	if !s.midnightCheckDue(time.Date(2025, 1, 3, 1, 0, 0, 0, time.Local)) {
+		t.Error("check should run in the window wrapping past midnight")
+	}
+}
+
+func TestGetUnhealthy(t *testing.T) {
+	setupTestEnv(t, "")
+	svc := func(name string, status models.RunStatus, startup models.StartupMode) *ServiceInstance {
+		return &ServiceInstance{
+			spec:   models.ServiceSpecification{Name: name, Startup: startup},
+			proc:   proc.NewProcessInstance(name, name, name, nil),
+			status: status,
+		}
+	}
+	healthy := svc("healthy", models.StatusRunning, models.StartupAlways)
+	failing := svc("failing", models.StatusRunning, models.StartupAlways)
+	failing.failedCount = 2
+	failing.unhealthy = "connection refused"
+	exited := svc("exited", models.StatusExited, models.StartupAlways)
+	crashed := svc("crashed", models.StatusExited, models.StartupAlways)
+	crashed.crashLoop = true
+	// 按需启动的服务退出是正常状态
+	idle := svc("idle", models.StatusExited, models.StartupOnce)
+
+	s := &Server{
+		cfg:       config.App(),
+		service:   newTestServiceManager(t, healthy, failing, exited, crashed, idle),
+		component: newTestComponentManager(t, "", "missing-cpn"),
+		tunnel:    &TunnelManager{tunnels: map[string]*tun.TunnelInstance{}},
+	}
+	items := s.GetUnhealthy()
+	expected := []struct {
+		kind, name string
+		healthy    models.HealthyStatus
+	}{
+		{"service", "crashed", models.Unavailable},
+		{"service", "exited", models.Unavailable},
+		{"service", "failing", models.Unhealthy},
+		{"component", "missing-cpn", models.Unavailable},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, got %+v", len(expected), items)
+	}
+	for i, e := range expected {
+		item := items[i]
+		if item.Kind != e.kind || item.Name != e.name || item.Healthy != e.healthy || item.Reason == "" {
+			t.Errorf("item %d = %+v, want %s %s %s with reason", i, item, e.kind, e.name, e.healthy)
+		}
+	}
+	if !strings.Contains(items[2].Reason, "connection refused") {
+		t.Errorf("reason of the failed health check is lost: %s", items[2].Reason)
+	}
+
+	// 全部恢复健康后返回空数组
+	failing.failedCount = 0
+	exited.status = models.StatusRunning
+	crashed.crashLoop = false
+	crashed.status = models.StatusRunning
+	delete(s.component.components, "missing-cpn")
+	if items := s.GetUnhealthy(); items == nil || len(items) != 0 {
+		t.Errorf("all healthy should return an empty array, got %#v", items)
+	}
+}
+
+func TestGetStateRedactsSecrets(t *testing.T) {
+	setupTestEnv(t, `{"api":{"token":"api-s3cr3t"},"metrics":{"push_password":"push-s3cr3t","push_token":"pt-s3cr3t"},`+
+		`"notify":{"headers":{"Authorization":"Bearer notify-s3cr3t"}}}`)
+	writeTestFile(t, filepath.Join(env.CostrictDir, "share", "auth.json"),
+		`{"id":"test-user","machine_id":"test-machine","access_token":"`+testAccessToken+
+			`","readonly_token":"ro-s3cr3t","base_url":"http://127.0.0.1:1"}`)
+	if err := config.LoadAuthConfig(); err != nil {
+		t.Fatal(err)
+	}
+	setupTestSpec(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			{Name: "syncer", Startup: models.StartupNone, Command: "syncer", Args: []string{"--token=svc-s3cr3t"}},
+		},
+	})
+	s := newTestServer(t)
+	s.startTime = time.Now()
+
+	// GET /state无需认证，响应中不能出现任何配置的凭据
+	data, err := json.Marshal(s.GetState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"api-s3cr3t", "push-s3cr3t", "pt-s3cr3t", "notify-s3cr3t", testAccessToken, "ro-s3cr3t", "svc-s3cr3t"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("state contains secret %q", secret)
+		}
+	}
+}
+
+func TestExportErrorSurfaced(t *testing.T) {
+	setupTestEnv(t, "")
+	setupTestSpec(t, models.SystemSpecification{})
+	s := newTestServer(t)
+	s.component = newTestComponentManager(t, "")
+	s.tunnel = GetTunnelManager()
+	s.startTime = time.Now()
+	sm := s.service
+
+	// 目标路径被目录占据，即使以root运行也无法写入
+	wellKnown := filepath.Join(env.CostrictDir, "share", ".well-known.json")
+	if err := os.MkdirAll(filepath.Join(wellKnown, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.export(); err == nil {
+		t.Fatal("export to an unwritable path succeeded")
+	}
+	if got := s.GetState().ExportError; got == "" {
+		t.Error("export error isn't surfaced in state")
+	}
+	if got := s.GetHealthz(false).ExportError; got == "" {
+		t.Error("export error isn't surfaced in healthz")
+	}
+
+	// 问题解决后，监测重试导出成功，错误被清除
+	if err := os.RemoveAll(wellKnown); err != nil {
+		t.Fatal(err)
+	}
+	sm.RetryExport()
+	if _, err := os.Stat(wellKnown); err != nil {
+		t.Errorf(".well-known.json isn't exported by retry: %v", err)
+	}
+	if got := s.GetState().ExportError; got != "" {
+		t.Errorf("export error = %q after a successful export, want empty", got)
+	}
+	if got := s.GetHealthz(false).ExportError; got != "" {
+		t.Errorf("healthz export error = %q after a successful export, want empty", got)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build linux || darwin
+
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/models"
+)
+
+func TestStartAllServiceOnceTimeoutShared(t *testing.T) {
+	setupTestEnv(t, `{"service":{"once_timeout":1}}`)
+	setupTestSpec(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			{Name: "slow", Startup: models.StartupOnce, Command: "sleep", Args: []string{"30"}},
+			onceSpec("next", false, ""),
+		},
+	})
+	s := newTestServer(t)
+
+	start := time.Now()
+	if err := s.StartAllService(); err != nil {
+		t.Fatal(err)
+	}
+	// 全部once服务共用once_timeout，启动时间不随once服务的数量增加
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("once tasks took %v, want about once_timeout", elapsed)
+	}
+	if len(s.onceTasks) != 2 {
+		t.Fatalf("%d once tasks recorded, want 2", len(s.onceTasks))
+	}
+	if r := s.onceTasks[0]; r.Name != "slow" || r.Result != models.OnceTimeout {
+		t.Errorf("first task = %s/%s, want slow/%s", r.Name, r.Result, models.OnceTimeout)
+	}
+	r := s.onceTasks[1]
+	if r.Name != "next" || r.Result != models.OnceTimeout || !strings.Contains(r.Error, "used up") {
+		t.Errorf("second task = %s/%s (%s), want next/%s without running", r.Name, r.Result, r.Error, models.OnceTimeout)
+	}
+}
@@ -3,10 +3,15 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"costrict-keeper/internal/config"
@@ -14,6 +19,7 @@ import (
 	"costrict-keeper/internal/logger"
 	"costrict-keeper/internal/models"
 	"costrict-keeper/internal/proc"
+	"costrict-keeper/internal/supervisor"
 	"costrict-keeper/internal/tun"
 	"costrict-keeper/internal/utils"
 )
@@ -22,6 +28,21 @@ const (
 	COSTRICT_NAME = "costrict"
 )
 
+// 服务的pre_start/post_stop钩子命令的最长执行时间
+const serviceHookTimeout = time.Minute
+
+// 服务进程已启动，但在等待时间内没有就绪(端口不可连接或健康检查失败)
+var ErrServiceNotReady = errors.New("service started but not ready")
+
+var ErrGroupNotFound = errors.New("group not found")
+
+// 只有运行中的服务可以脱离管理，只有脱离管理的服务可以重新接管
+var ErrServiceNotRunning = errors.New("service isn't running")
+var ErrServiceNotDetached = errors.New("service isn't detached")
+
+// 被禁用的服务只能强制启动
+var ErrServiceDisabled = errors.New("service is disabled")
+
 /**
  * Service instance information
  * @property {int} pid - Process ID
@@ -34,11 +55,20 @@ type ServiceInstance struct {
 	component   *ComponentInstance          //运行服务的组件，实现服务的具体逻辑
 	proc        *proc.ProcessInstance       //运行该服务的进程
 	tun         *tun.TunnelInstance         //支持该服务远程访问的隧道
-	status      models.RunStatus            //服务状态
+	status      models.RunStatus            //服务状态，由stateLock保护
 	startTime   string                      //服务启动时间
 	port        int                         //服务侦听的端口
 	failedCount int                         //健康检测失败，连续三次健康检测失败，需要重启服务
 	child       bool                        //被本进程直接管理控制的子服务
+	cascaded    bool                        //因依赖的服务停止而被级联停止
+	oomTime     time.Time                   //最近一次被OOM killer杀死的时间，避免重复统计
+	crashLoop   bool                        //自动重启次数用完，处于崩溃循环中，服务恢复健康后清除，由stateLock保护
+	lastPort    int                         //最近一次分配的端口，保存在缓存文件中，keeper重启后依然有效
+	alerted     bool                        //已按failure_action告警过本次不可用，服务恢复健康后清除，避免重复告警，由stateLock保护
+	health      models.HealthyStatus        //最近一次健康检查的结果，只由CheckService记录
+	unhealthy   string                      //最近一次健康检查发现的不健康原因，健康时为空
+	healthLock  sync.Mutex                  //保护health和unhealthy，监测协程写入，API请求读取
+	stateLock   sync.Mutex                  //保护status、crashLoop和alerted，进程监测回调与API请求同时读写
 }
 
 type ServiceCache struct {
@@ -47,18 +77,46 @@ type ServiceCache struct {
 	Port      int              `json:"port"`
 	Status    models.RunStatus `json:"status"`
 	StartTime string           `json:"startTime"`
+	LastPort  int              `json:"lastPort,omitempty"` //最近一次分配的端口，服务停止后依然保留，用于service.sticky_port
 }
 
+// 展开服务命令行模板({{.LocalPort}}等)使用的数据
 type ServiceArgs struct {
 	LocalPort   int
 	ProcessPath string
 	ProcessName string
+	LogLevel    string // keeper的日志级别(debug/info/warn/error)，让各组件的日志级别保持一致
+}
+
+/**
+ * Build data for expanding command templates of a service/component
+ * @param {string} name - Service/component name
+ * @param {int} port - Local port of the service, 0 if it has no port
+ * @returns {ServiceArgs} Returns template data
+ * @description
+ * - LogLevel is read from the current config each time, so a service restarted after
+ *   the keeper's level changed gets the new level
+ */
+func newServiceArgs(name string, port int) ServiceArgs {
+	processName := name
+	if runtime.GOOS == "windows" {
+		processName = fmt.Sprintf("%s.exe", name)
+	}
+	args := ServiceArgs{
+		LocalPort:   port,
+		ProcessName: processName,
+		ProcessPath: filepath.Join(env.CostrictDir, "bin", processName),
+		LogLevel:    strings.ToLower(config.App().Log.Level),
+	}
+	return args
 }
 
 type ServiceManager struct {
-	cm       *ComponentManager
-	self     *ServiceInstance
-	services map[string]*ServiceInstance
+	cm         *ComponentManager
+	self       *ServiceInstance
+	services   map[string]*ServiceInstance
+	exportLock sync.Mutex // 保护exportErr，导出在API和监测协程中都会发生
+	exportErr  error      // 最近一次导出.well-known.json的错误，成功后清除
 }
 
 var serviceManager *ServiceManager
@@ -86,6 +144,12 @@ func GetServiceManager() *ServiceManager {
 		cm:       GetComponentManager(),
 	}
 	serviceManager = sm
+	// 隧道打开/关闭后，刷新.well-known.json中的隧道映射信息
+	tun.SetChangedHook(func() {
+		if sm.self != nil {
+			sm.export()
+		}
+	})
 	return serviceManager
 }
 
@@ -102,6 +166,7 @@ func newService(spec *models.ServiceSpecification, cpn *ComponentInstance, child
 		child:     child,
 	}
 	svc.proc = createProcessInstance(&svc.spec, svc.port)
+	svc.lastPort = svc.loadLastPort()
 	if spec.Accessible == "remote" {
 		svc.tun = tun.CreateTunnel(spec.Name, []int{spec.Port})
 	}
@@ -120,7 +185,7 @@ func newService(spec *models.ServiceSpecification, cpn *ComponentInstance, child
  */
 func UpdateCostrictStatus(status string) {
 	svc := serviceManager.self
-	svc.status = models.RunStatus(status)
+	svc.setStatus(models.RunStatus(status))
 	svc.saveService()
 	serviceManager.export()
 }
@@ -134,32 +199,48 @@ func UpdateCostrictStatus(status string) {
  * - Includes service name, PID, port, status, and start time
  * - Includes service specification and tunnel information
  * - Used for API responses and detailed service views
+ * - Health is read from the state recorded by monitoring, the service isn't probed
  */
 func (svc *ServiceInstance) GetDetail() models.ServiceDetail {
+	return svc.detail(svc.proc.GetDetail())
+}
+
+// 根据进程详情的快照构造服务详情，不再读取进程实例，可以在持有进程锁的监测回调中调用
+func (svc *ServiceInstance) detail(process models.ProcessDetail) models.ServiceDetail {
+	status := svc.getStatus()
 	detail := &models.ServiceDetail{
 		Name:      svc.spec.Name,
+		Tags:      svc.spec.Tags,
+		Group:     svc.spec.Group,
 		Port:      svc.port,
-		Status:    svc.status,
+		Status:    status,
 		StartTime: svc.startTime,
 		Spec:      svc.spec,
+		Enabled:   svc.spec.IsEnabled(),
 	}
-	if svc.spec.Accessible == "remote" {
+	detail.Spec.Protocol = svc.protocol()
+	if svc.spec.Accessible == "remote" && svc.tun != nil {
 		tun := svc.tun.GetDetail()
 		detail.Tunnel = &tun
 	}
 	if !svc.child {
 		detail.Pid = os.Getpid()
 	} else {
-		detail.Pid = svc.proc.Pid()
+		detail.Pid = process.Pid
 	}
-	detail.Process = svc.proc.GetDetail()
+	detail.Process = process
+	detail.OOM = detail.Process.ExitClass == models.ExitOOM
 	if svc.component != nil {
 		cpn := svc.component.GetDetail()
 		detail.Component = &cpn
 	} else {
 		detail.Component = nil
 	}
-	detail.Healthy = svc.GetHealthy()
+	if status != models.StatusRunning && status != models.StatusDetached {
+		detail.Healthy, detail.Reason = models.Unavailable, svc.notRunningReason(process)
+	} else {
+		detail.Healthy, detail.Reason = svc.getCachedHealthy()
+	}
 	return *detail
 }
 
@@ -175,10 +256,43 @@ func (svc *ServiceInstance) GetProc() *proc.ProcessInstance {
 	return svc.proc
 }
 
+func (svc *ServiceInstance) GetName() string {
+	return svc.spec.Name
+}
+
+/**
+ * Check if service is labeled with the specified tag
+ * @param {string} tag - Tag to check, empty tag matches all services
+ * @returns {bool} Returns true if service has the tag
+ */
+func (svc *ServiceInstance) HasTag(tag string) bool {
+	if tag == "" {
+		return true
+	}
+	return slices.Contains(svc.spec.Tags, tag)
+}
+
+/**
+ * Check if service belongs to the specified group
+ * @param {string} group - Group to check, empty group matches all services
+ * @returns {bool} Returns true if service is in the group
+ */
+func (svc *ServiceInstance) InGroup(group string) bool {
+	return group == "" || svc.spec.Group == group
+}
+
 func (svc *ServiceInstance) GetTunnel() *tun.TunnelInstance {
 	return svc.tun
 }
 
+/**
+ * Check if the service is accessible remotely through a reverse tunnel
+ * @returns {bool} Returns true if accessible of the spec is "remote", even if the tunnel isn't created yet
+ */
+func (svc *ServiceInstance) IsRemoteAccessible() bool {
+	return svc.spec.Accessible == "remote"
+}
+
 func (svc *ServiceInstance) GetPid() int {
 	if svc.child {
 		return svc.proc.Pid()
@@ -196,9 +310,10 @@ func (svc *ServiceInstance) GetPid() int {
  * - Verifies process state is not exited
  * - Checks if service port is available
  * - Returns false if service is not found or unhealthy
+ * - Doesn't record the reason, only monitoring (CheckService) does
  */
 func (svc *ServiceInstance) GetHealthy() models.HealthyStatus {
-	if svc.status != models.StatusRunning {
+	if status := svc.getStatus(); status != models.StatusRunning && status != models.StatusDetached {
 		return models.Unavailable
 	}
 	running, err := utils.IsProcessRunning(svc.proc.Pid())
@@ -206,13 +321,98 @@ func (svc *ServiceInstance) GetHealthy() models.HealthyStatus {
 		return models.Unavailable
 	}
 	if svc.port > 0 {
-		if !utils.CheckPortConnectable(svc.port) {
+		if err := svc.probeHealth(); err != nil {
 			return models.Unhealthy
 		}
 	}
 	return models.Healthy
 }
 
+// 记录健康检查的结果及不健康的原因
+func (svc *ServiceInstance) recordHealth(health models.HealthyStatus, reason string) models.HealthyStatus {
+	svc.healthLock.Lock()
+	svc.health, svc.unhealthy = health, reason
+	svc.healthLock.Unlock()
+	return health
+}
+
+// 服务状态，进程监测回调会在其它协程中修改
+func (svc *ServiceInstance) getStatus() models.RunStatus {
+	svc.stateLock.Lock()
+	defer svc.stateLock.Unlock()
+	return svc.status
+}
+
+func (svc *ServiceInstance) setStatus(status models.RunStatus) {
+	svc.stateLock.Lock()
+	svc.status = status
+	svc.stateLock.Unlock()
+}
+
+// 进入崩溃循环，已经处于崩溃循环时返回false，避免重复通知
+func (svc *ServiceInstance) enterCrashLoop() bool {
+	svc.stateLock.Lock()
+	defer svc.stateLock.Unlock()
+	if svc.crashLoop {
+		return false
+	}
+	svc.crashLoop = true
+	return true
+}
+
+// 离开崩溃循环，返回之前是否处于崩溃循环
+func (svc *ServiceInstance) leaveCrashLoop() bool {
+	svc.stateLock.Lock()
+	defer svc.stateLock.Unlock()
+	crashLoop := svc.crashLoop
+	svc.crashLoop = false
+	return crashLoop
+}
+
+// 标记本次不可用已告警，已经告警过时返回false，避免重复告警
+func (svc *ServiceInstance) markAlerted() bool {
+	svc.stateLock.Lock()
+	defer svc.stateLock.Unlock()
+	if svc.alerted {
+		return false
+	}
+	svc.alerted = true
+	return true
+}
+
+// 服务恢复健康后清除告警标记，下次不可用时重新告警
+func (svc *ServiceInstance) clearAlerted() {
+	svc.stateLock.Lock()
+	svc.alerted = false
+	svc.stateLock.Unlock()
+}
+
+// 最近一次健康检查的结果及不健康的原因，还没有检查过时结果为空
+func (svc *ServiceInstance) checkedHealth() (models.HealthyStatus, string) {
+	svc.healthLock.Lock()
+	defer svc.healthLock.Unlock()
+	return svc.health, svc.unhealthy
+}
+
+// 服务不在运行状态的原因：进程退出的服务给出进程退出的原因
+func (svc *ServiceInstance) notRunningReason(process models.ProcessDetail) string {
+	switch status := svc.getStatus(); status {
+	case models.StatusExited, models.StatusError:
+		return exitedReason(process)
+	default:
+		return fmt.Sprintf("service is %s", status)
+	}
+}
+
+// 服务进程不存在的原因，取自进程最后一次退出的原因
+func exitedReason(process models.ProcessDetail) string {
+	reason := process.LastExitReason
+	if reason == "" {
+		return "process isn't running"
+	}
+	return "process exited: " + reason
+}
+
 /**
  * Get service knowledge information
  * @returns {ServiceKnowledge} Returns service knowledge structure
@@ -230,18 +430,24 @@ func (svc *ServiceInstance) getKnowledge() models.ServiceKnowledge {
 		version = svc.component.local.VersionId.String()
 		installed = svc.component.installed
 	}
+	var tunnel *models.TunnelKnowledge
+	if svc.spec.Accessible == "remote" && svc.tun != nil {
+		tk := svc.tun.GetKnowledge()
+		tunnel = &tk
+	}
 	return models.ServiceKnowledge{
 		Name:       svc.spec.Name,
 		Version:    version,
 		Installed:  installed,
 		Command:    svc.proc.Command,
-		Status:     string(svc.status),
+		Status:     string(svc.getStatus()),
 		Port:       svc.port,
-		Startup:    svc.spec.Startup,
-		Protocol:   svc.spec.Protocol,
+		Startup:    string(svc.spec.Startup),
+		Protocol:   svc.protocol(),
 		Metrics:    svc.spec.Metrics,
 		Healthy:    svc.spec.Healthy,
 		Accessible: svc.spec.Accessible,
+		Tunnel:     tunnel,
 	}
 }
 
@@ -262,7 +468,7 @@ func (svc *ServiceInstance) getKnowledge() models.ServiceKnowledge {
  */
 func (svc *ServiceInstance) saveService() {
 	// 确保缓存目录存在
-	cacheDir := filepath.Join(env.CostrictDir, "cache", "services")
+	cacheDir := filepath.Join(env.GetCacheDir(), "services")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		logger.Errorf("Service [%s] save info failed, error: %v", svc.spec.Name, err)
 		return
@@ -272,7 +478,8 @@ func (svc *ServiceInstance) saveService() {
 	cache.Name = svc.spec.Name
 	cache.Port = svc.port
 	cache.StartTime = svc.startTime
-	cache.Status = svc.status
+	cache.Status = svc.getStatus()
+	cache.LastPort = svc.lastPort
 	if svc.child {
 		cache.Pid = svc.proc.Pid()
 	} else {
@@ -286,8 +493,8 @@ func (svc *ServiceInstance) saveService() {
 	}
 
 	// 写入文件
-	cacheFile := filepath.Join(cacheDir, svc.spec.Name+".json")
-	if err := os.WriteFile(cacheFile, jsonData, 0644); err != nil {
+	cacheFile := svc.getCacheFname()
+	if err := utils.WriteFileAtomic(cacheFile, jsonData, 0644); err != nil {
 		logger.Errorf("Service [%s] save info failed, error: %v", svc.spec.Name, err)
 		return
 	}
@@ -295,13 +502,39 @@ func (svc *ServiceInstance) saveService() {
 	logger.Infof("Service [%s] info saved to %s", svc.spec.Name, cacheFile)
 }
 
+func (svc *ServiceInstance) getCacheFname() string {
+	return filepath.Join(env.GetCacheDir(), "services", svc.spec.Name+".json")
+}
+
+/**
+ * Load the port allocated to the service last time from its cache file
+ * @returns {int} Returns 0 if the cache file doesn't exist or is invalid
+ * @description
+ * - Falls back to port of cache files written before lastPort was recorded
+ */
+func (svc *ServiceInstance) loadLastPort() int {
+	data, err := os.ReadFile(svc.getCacheFname())
+	if err != nil {
+		return 0
+	}
+	var cache ServiceCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return 0
+	}
+	if cache.LastPort > 0 {
+		return cache.LastPort
+	}
+	return cache.Port
+}
+
 /**
  * Start individual service
  * @param {context.Context} ctx - Context for cancellation and timeout
  * @param {ServiceInstance} svc - Service instance to start
  * @returns {error} Returns error if start fails, nil on success
  * @description
- * - Allocates port for service from specification
+ * - Allocates port for service from specification, or the port allocated last time if service.sticky_port
+ *   is on and the specification doesn't fix the port
  * - Creates process instance for service
  * - Sets restart callback to update service information
  * - Starts process via process manager
@@ -316,111 +549,544 @@ func (svc *ServiceInstance) saveService() {
  * @private
  */
 func (svc *ServiceInstance) StartService(ctx context.Context) error {
+	port := svc.spec.Port
+	// 指定了端口的服务总是优先使用指定端口，被占用时临时换的端口不应一直沿用
+	if port == 0 && config.App().Service.StickyPort && svc.lastPort > 0 {
+		port = svc.lastPort
+	}
+	return svc.startService(ctx, port)
+}
+
+/**
+ * Restart service, preserving the allocated port
+ * @param {context.Context} ctx - Context for cancellation and timeout
+ * @returns {error} Returns error if start fails, nil on success
+ * @description
+ * - Clients may have cached the listening port of the service, so the port
+ *   allocated last time is requested again, a new one is picked only if it's taken
+ */
+func (svc *ServiceInstance) Restart(ctx context.Context) error {
+	port := svc.port
+	svc.StopService()
+	if port > 0 {
+		utils.FreePort(port)
+	} else {
+		port = svc.spec.Port
+	}
+	return svc.startService(ctx, port)
+}
+
+func (svc *ServiceInstance) startService(ctx context.Context, preferredPort int) error {
 	var err error
 
-	svc.port, err = utils.AllocPort(svc.spec.Port)
+	svc.port, err = utils.AllocPort(preferredPort)
 	if err != nil {
 		return err
 	}
+	if svc.port, err = svc.recheckPort(svc.port); err != nil {
+		return err
+	}
+	svc.lastPort = svc.port
 	svc.proc = createProcessInstance(&svc.spec, svc.port)
 	if svc.proc.Status == models.StatusError {
-		svc.status = models.StatusError
+		svc.setStatus(models.StatusError)
+		return err
+	}
+	if err := svc.runHook(ctx, "pre-start", svc.spec.PreStart); err != nil {
+		svc.setStatus(models.StatusError)
 		return err
 	}
-	if env.Daemon && svc.spec.Startup == "always" {
-		svc.proc.SetWatcher(3, func(pi *proc.ProcessInstance) {
-			switch pi.Status {
-			case models.StatusExited, models.StatusError:
-				svc.status = models.StatusError
-			default: //models.StatusStopped, models.StatusRunning
-				svc.status = pi.Status
+	if env.Daemon {
+		// 所有服务进程都需要监测退出原因(如OOM)，只有failure_action为restart的always服务自动重启
+		autoRestart := svc.spec.Startup == models.StartupAlways && svc.failureAction() == models.FailureRestart
+		maxRestart := 0
+		if autoRestart {
+			maxRestart = svc.maxRestart()
+		}
+		svc.proc.SetWatcher(maxRestart, func(pi *proc.ProcessInstance) {
+			svc.recordOOM(pi.ExitClass, pi.LastExitTime)
+			status := pi.Status
+			if status == models.StatusExited {
+				status = models.StatusError
 			}
+			svc.stateLock.Lock()
+			oldStatus := svc.status
+			svc.status = status
+			svc.stateLock.Unlock()
 			svc.saveService()
+			if oldStatus != status && (status == models.StatusRunning || oldStatus == models.StatusRunning) {
+				typ := models.EventServiceDown
+				if status == models.StatusRunning {
+					typ = models.EventServiceUp
+				}
+				// 回调时持有进程锁，在回调中取得详情的快照，发布事件放到回调返回后进行
+				detail := svc.detail(pi.DetailLocked())
+				go GetEventBus().Publish(typ, svc.spec.Name, detail)
+			}
+			// failure_action为alert的服务退出后不重启，只告警
+			if svc.spec.Startup == models.StartupAlways && pi.Status == models.StatusExited &&
+				svc.failureAction() == models.FailureAlert {
+				// 告警在回调返回后进行，传入回调中取得的详情快照
+				detail := svc.detail(pi.DetailLocked())
+				go svc.alertUnavailable(detail)
+			}
+			// max_restart为负数时不自动重启，退出不算崩溃循环
+			if autoRestart && pi.Status == models.StatusExited &&
+				maxRestart >= 0 && pi.RestartCount >= maxRestart && svc.enterCrashLoop() {
+				logger.Errorf("Service '%s' keeps crashing after %d restarts", svc.spec.Name, pi.RestartCount)
+				detail := svc.detail(pi.DetailLocked())
+				go GetNotifyManager().Notify(models.EventCrashLoop, svc.spec.Name, detail)
+			}
 		})
 	}
 	if err := svc.proc.StartProcess(ctx); err != nil {
-		svc.status = models.StatusError
+		svc.setStatus(models.StatusError)
 		return err
 	}
-	svc.status = models.StatusRunning
+	svc.setStatus(models.StatusRunning)
 	svc.startTime = time.Now().Format(time.RFC3339)
+	// 重新启动的服务等待下一次健康检查，不沿用上次检查的结果
+	svc.recordHealth("", "")
 	svc.OpenTunnel(ctx)
 
 	svc.saveService()
+	GetEventBus().Publish(models.EventServiceUp, svc.spec.Name, svc.GetDetail())
+	return svc.waitReady(ctx, time.Duration(config.App().Service.ReadyTimeout)*time.Second)
+}
+
+/**
+ * Count the exit of the service process if it was killed by the OOM killer
+ * @param {models.ExitClass} exitClass - Exit class of the process
+ * @param {time.Time} exitTime - Exit time of the process, the same exit is counted only once
+ * @private
+ */
+func (svc *ServiceInstance) recordOOM(exitClass models.ExitClass, exitTime time.Time) {
+	if exitClass != models.ExitOOM || exitTime.Equal(svc.oomTime) {
+		return
+	}
+	svc.oomTime = exitTime
+	IncrementServiceOOM(svc.spec.Name)
+	logger.Warnf("Service '%s' was killed by the OOM killer, consider raising its memory limit", svc.spec.Name)
+}
+
+/**
+ * Recheck the allocated port right before launching the service
+ * @param {int} port - Allocated port
+ * @returns {int} Returns the port to be used by the service
+ * @description
+ * - Other programs may start listening on the port after it's allocated, the service
+ *   would crash with a confusing bind error then
+ * - If the port is taken, it's freed and another port is allocated once
+ * @private
+ */
+func (svc *ServiceInstance) recheckPort(port int) (int, error) {
+	if !utils.CheckPortConnectable(port) {
+		return port, nil
+	}
+	logger.Warnf("Port %d allocated to service [%s] is already in use, allocate another one", port, svc.spec.Name)
+	utils.FreePort(port)
+	newPort, err := utils.AllocPort(0)
+	if err != nil {
+		return 0, err
+	}
+	if utils.CheckPortConnectable(newPort) {
+		utils.FreePort(newPort)
+		return 0, fmt.Errorf("port %d allocated to service [%s] is already in use", newPort, svc.spec.Name)
+	}
+	return newPort, nil
+}
+
+/**
+ * Wait until service is ready to accept connections
+ * @param {context.Context} ctx - Context for cancellation
+ * @param {time.Duration} timeout - Max time to wait, no wait if timeout <= 0
+ * @returns {error} Returns ErrServiceNotReady if the service isn't ready in time
+ * @description
+ * - Polls the service port, and the healthy endpoint if the spec declares one
+ * - Service remains running when it isn't ready in time, the caller decides what to do
+ * @private
+ */
+func (svc *ServiceInstance) waitReady(ctx context.Context, timeout time.Duration) error {
+	if timeout <= 0 || svc.port <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	for svc.probeReady() != nil {
+		if detail := svc.proc.GetDetail(); detail.Status != models.StatusRunning {
+			return fmt.Errorf("service [%s] exited before ready: %s", svc.spec.Name, detail.LastExitReason)
+		}
+		if time.Now().After(deadline) {
+			logger.Warnf("Service [%s] isn't ready within %v", svc.spec.Name, timeout)
+			return fmt.Errorf("%w: [%s] isn't ready within %v", ErrServiceNotReady, svc.spec.Name, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	logger.Infof("Service [%s] is ready", svc.spec.Name)
+	return nil
+}
+
+// 服务的协议，未指定时使用配置的默认协议
+func (svc *ServiceInstance) protocol() string {
+	if svc.spec.Protocol != "" {
+		return svc.spec.Protocol
+	}
+	return config.App().Service.Protocol
+}
+
+// 检查运行中的服务是否健康：显式指定了健康检查方式或TCP握手时使用该方式，否则只检查端口
+// 返回的错误说明服务不健康的原因
+func (svc *ServiceInstance) probeHealth() error {
+	if svc.spec.HealthCheck.Type != "" || svc.spec.HealthCheck.HasHandshake() {
+		return svc.probeReady()
+	}
+	if !utils.CheckHostPortConnectable(svc.spec.Host, svc.port) {
+		return fmt.Errorf("port %d not accepting connections", svc.port)
+	}
+	return nil
+}
+
+// 健康检查方式，未指定时根据是否声明了健康检查接口及服务协议决定
+func (svc *ServiceInstance) healthCheckType() string {
+	if svc.spec.HealthCheck.Type != "" {
+		return svc.spec.HealthCheck.Type
+	}
+	if svc.spec.HealthCheck.HasHandshake() {
+		return "tcp"
+	}
+	if svc.spec.Healthy == "" {
+		return "port"
+	}
+	if svc.protocol() == "grpc" {
+		return "grpc"
+	}
+	return "http"
+}
+
+// 检查服务是否就绪：端口可连接，并且按健康检查方式探测健康检查接口
+// grpc方式使用grpc.health.v1健康检查，healthy为被检查的服务名；tcp方式按send/expect进行握手
+func (svc *ServiceInstance) probeReady() error {
+	addr := utils.GetConnectableAddress(svc.spec.Host, svc.port)
+	if addr == "" {
+		return fmt.Errorf("port %d not accepting connections", svc.port)
+	}
+	if svc.healthCheckType() == "port" {
+		return nil
+	}
+	return svc.probeAddr(addr, time.Second)
+}
+
+// 按服务的健康检查方式探测指定的地址，除了服务自身的端口，也用于经隧道映射端口的端到端探测
+// port方式只检查地址可连接；tcp方式的超时优先使用health_check.timeout
+func (svc *ServiceInstance) probeAddr(addr string, timeout time.Duration) error {
+	switch svc.healthCheckType() {
+	case "port":
+		if err := utils.ProbeTCP(addr, "", "", timeout); err != nil {
+			return fmt.Errorf("port not accepting connections: %v", err)
+		}
+	case "grpc":
+		if err := utils.ProbeHealth("grpc", addr, svc.spec.Healthy, timeout); err != nil {
+			return fmt.Errorf("grpc health check failed: %v", err)
+		}
+	case "http":
+		scheme := "http"
+		if svc.protocol() == "https" {
+			scheme = "https"
+		}
+		if err := utils.ProbeHealth(scheme, addr, svc.spec.Healthy, timeout); err != nil {
+			return fmt.Errorf("%s health check failed: %v", scheme, err)
+		}
+	case "tcp":
+		hc := svc.spec.HealthCheck
+		if hc.Timeout > 0 {
+			timeout = time.Duration(hc.Timeout) * time.Second
+		}
+		if err := utils.ProbeTCP(addr, hc.Send, hc.Expect, timeout); err != nil {
+			return fmt.Errorf("tcp handshake failed: %v", err)
+		}
+	}
 	return nil
 }
 
 func (svc *ServiceInstance) StopService() {
-	svc.status = models.StatusStopped
+	svc.setStatus(models.StatusStopped)
 	svc.proc.StopProcess()
 	if svc.tun != nil {
 		svc.tun.CloseTunnel()
 	}
+	if err := svc.runHook(context.Background(), "post-stop", svc.spec.PostStop); err != nil {
+		logger.Warnf("%v", err)
+	}
 	svc.saveService()
+	GetEventBus().Publish(models.EventServiceDown, svc.spec.Name, svc.GetDetail())
+}
+
+/**
+ * Stop managing the service without killing its process
+ * @returns {error} Returns ErrServiceNotRunning if the service isn't running
+ * @description
+ * - The process watcher is disabled, the process isn't restarted after it exits
+ * - Recovery skips detached services, and the process is left running when costrict stops
+ */
+func (svc *ServiceInstance) Detach() error {
+	if svc.getStatus() != models.StatusRunning {
+		return ErrServiceNotRunning
+	}
+	svc.proc.DisableWatcher()
+	svc.setStatus(models.StatusDetached)
+	svc.saveService()
+	logger.Infof("Service [%s] is detached, process (PID: %d) keeps running", svc.spec.Name, svc.proc.Pid())
+	return nil
+}
+
+/**
+ * Re-adopt the process of a detached service
+ * @returns {error} Returns ErrServiceNotDetached if the service isn't detached,
+ *   or error of AttachProcess if the process exited while detached
+ * @description
+ * - If the process has exited, the service is marked as error and restarted by recovery
+ */
+func (svc *ServiceInstance) Attach() error {
+	if svc.getStatus() != models.StatusDetached {
+		return ErrServiceNotDetached
+	}
+	svc.failedCount = 0
+	if err := svc.proc.AttachProcess(); err != nil {
+		svc.setStatus(models.StatusError)
+		svc.saveService()
+		return err
+	}
+	svc.setStatus(models.StatusRunning)
+	svc.saveService()
+	logger.Infof("Service [%s] is attached (PID: %d)", svc.spec.Name, svc.proc.Pid())
+	return nil
+}
+
+/**
+ * Get max times the service process is auto restarted, exceeding it means the service is in crash loop
+ * @returns {int} Returns max_restart of the spec, or service.max_restart of app config if unset
+ */
+func (svc *ServiceInstance) maxRestart() int {
+	if svc.spec.MaxRestart != 0 {
+		return svc.spec.MaxRestart
+	}
+	return config.App().Service.MaxRestart
+}
+
+/**
+ * Reset the restart counter of the service process, so a tripped restart limit no longer blocks auto restart
+ * @description
+ * - Also clears the crash loop flag
+ * - An exited service isn't started here, recovery of the monitoring restarts it
+ */
+func (svc *ServiceInstance) ResetRestartCount() {
+	if svc.proc != nil {
+		svc.proc.ResetRestartCount()
+	}
+	if svc.leaveCrashLoop() {
+		logger.Infof("Service [%s] restart count is reset, leaving crash loop", svc.spec.Name)
+	}
 }
 
 func (svc *ServiceInstance) RecoverService() {
-	if svc.status == models.StatusStopped {
+	if status := svc.getStatus(); status == models.StatusStopped || status == models.StatusDetached {
+		return
+	}
+	if !svc.spec.IsEnabled() {
 		return
 	}
 	//只剩下三种状态 StatusExited, StatusRunning, StatusError
 	status := svc.CheckService()
 	switch status {
+	case models.Healthy:
+		svc.leaveCrashLoop()
+		svc.clearAlerted()
 	case models.Incomplete:
-		svc.ReopenTunnel(context.Background())
+		// pending的隧道由RetryPendingTunnels按退避间隔重试
+		if svc.tun == nil || !svc.tun.IsPending() {
+			svc.ReopenTunnel(context.Background())
+		}
 	case models.Unavailable:
+		switch svc.failureAction() {
+		case models.FailureNone:
+			logger.Debugf("Service '%s' is unavailable, failure action is none", svc.spec.Name)
+			return
+		case models.FailureAlert:
+			svc.alertUnavailable(svc.GetDetail())
+			return
+		}
 		if svc.failedCount > 2 {
 			logger.Warnf("Service '%s' failed detection three times, automatically restart", svc.spec.Name)
-		} else if svc.status == models.StatusError || svc.status == models.StatusExited {
+		} else if status := svc.getStatus(); status == models.StatusError || status == models.StatusExited {
 			logger.Warnf("Service '%s' is currently unavailable, automatically restart", svc.spec.Name)
 		}
 		svc.failedCount = 0
-		svc.StopService()
-		svc.StartService(context.Background())
+		svc.Restart(context.Background())
+	}
+}
+
+// 服务不可用时的处理方式，未指定时自动重启
+func (svc *ServiceInstance) failureAction() string {
+	switch svc.spec.FailureAction {
+	case models.FailureAlert, models.FailureNone:
+		return svc.spec.FailureAction
+	default:
+		return models.FailureRestart
+	}
+}
+
+/**
+ * Alert that the service is unavailable instead of restarting it
+ * @param {models.ServiceDetail} detail - Snapshot of the service taken when it became unavailable
+ * @description
+ * - Alerts once per outage, the flag is cleared when the service becomes healthy again
+ * - Publishes a service_unhealthy event, notifies the webhook and counts the alert
+ * @private
+ */
+func (svc *ServiceInstance) alertUnavailable(detail models.ServiceDetail) {
+	if !svc.markAlerted() {
+		return
 	}
+	logger.Errorf("Service '%s' is unavailable (status: %s), it isn't restarted since failure action is alert",
+		svc.spec.Name, detail.Status)
+	IncrementServiceAlert(svc.spec.Name)
+	GetEventBus().Publish(models.EventServiceUnhealthy, svc.spec.Name, detail)
+	GetNotifyManager().Notify(models.EventServiceUnhealthy, svc.spec.Name, detail)
+}
+
+/**
+ * Get health of the service from the state recorded by monitoring
+ * @returns {models.HealthyStatus} Returns health status
+ * @returns {string} Returns the reason if the service isn't healthy
+ * @description
+ * - Doesn't probe the service, so it's cheap enough for frequent polling
+ * - Running services report the result and reason recorded by the last CheckService
+ * - Services stopped or detached by the user, and once/none services which exited, are healthy
+ */
+func (svc *ServiceInstance) getCachedHealthy() (models.HealthyStatus, string) {
+	svc.stateLock.Lock()
+	status, crashLoop := svc.status, svc.crashLoop
+	svc.stateLock.Unlock()
+	if crashLoop {
+		return models.Unavailable, "service is in crash loop, auto restart is exhausted"
+	}
+	switch status {
+	case models.StatusError:
+		return models.Unavailable, "service status is error"
+	case models.StatusExited:
+		if svc.spec.Startup == models.StartupAlways {
+			return models.Unavailable, "service exited"
+		}
+	case models.StatusRunning:
+		health, reason := svc.checkedHealth()
+		if svc.failedCount > 0 {
+			reason = fmt.Sprintf("health check failed %d times: %s", svc.failedCount, reason)
+			if health == "" || health == models.Healthy {
+				health = models.Unhealthy
+			}
+		}
+		if health != "" && health != models.Healthy {
+			return health, reason
+		}
+		if svc.tun != nil {
+			if detail := svc.tun.GetKnowledge(); detail.Status != models.StatusRunning {
+				return models.Incomplete, fmt.Sprintf("tunnel status is %s", detail.Status)
+			}
+		}
+	}
+	return models.Healthy, ""
 }
 
 /**
  *	The test results are classified into three levels: normal, unhealthy, and unavailable.
  */
 func (svc *ServiceInstance) CheckService() models.HealthyStatus {
-	if svc.status != models.StatusRunning {
-		return models.Unavailable
+	if svc.getStatus() != models.StatusRunning {
+		return svc.recordHealth(models.Unavailable, svc.notRunningReason(svc.proc.GetDetail()))
 	}
+	reason := ""
 	if svc.port > 0 {
-		if !utils.CheckPortConnectable(svc.port) {
-			logger.Errorf("Service [%s] is unhealthy", svc.spec.Name)
+		if err := svc.probeHealth(); err != nil {
+			logger.Errorf("Service [%s] is unhealthy: %v", svc.spec.Name, err)
 			svc.failedCount++
+			reason = err.Error()
 		} else {
 			svc.failedCount = 0
 		}
 		if svc.failedCount >= 3 {
-			return models.Unavailable
+			return svc.recordHealth(models.Unavailable, reason)
 		}
 	}
 	if status := svc.proc.CheckProcess(); status != models.Healthy {
-		return models.Unavailable
+		return svc.recordHealth(models.Unavailable, exitedReason(svc.proc.GetDetail()))
 	}
 	if svc.tun != nil {
 		if status := svc.tun.CheckTunnel(); status != models.Healthy {
-			return models.Incomplete
+			return svc.recordHealth(models.Incomplete, "tunnel is unhealthy")
 		}
 	}
 	if svc.failedCount > 0 {
-		return models.Unhealthy
+		return svc.recordHealth(models.Unhealthy, reason)
 	}
-	return models.Healthy
+	return svc.recordHealth(models.Healthy, "")
 }
 
-func createProcessInstance(spec *models.ServiceSpecification, port int) *proc.ProcessInstance {
-	name := spec.Name
-	if runtime.GOOS == "windows" {
-		name = fmt.Sprintf("%s.exe", spec.Name)
+/**
+ * Run pre-start/post-stop hook of the service
+ * @param {context.Context} ctx - Context for cancellation, the hook is also bounded by serviceHookTimeout
+ * @param {string} stage - Name of the hook for logging: pre-start/post-stop
+ * @param {string} command - Hook command template, does nothing if empty
+ * @returns {error} Returns error with the hook output if the hook fails
+ * @description
+ * - Templates are expanded with the same data as the service command
+ * - Runs as user/user_group of the service, the same as the service process
+ * @private
+ */
+func (svc *ServiceInstance) runHook(ctx context.Context, stage string, command string) error {
+	if command == "" {
+		return nil
 	}
-	args := ServiceArgs{
-		LocalPort:   port,
-		ProcessName: name,
-		ProcessPath: filepath.Join(env.CostrictDir, "bin", name),
+	args := newServiceArgs(svc.spec.Name, svc.port)
+	script, err := utils.GetShellScript(command, nil, args)
+	if err != nil {
+		return fmt.Errorf("%s hook of service [%s] is invalid: %w", stage, svc.spec.Name, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, serviceHookTimeout)
+	defer cancel()
+	cmd := utils.ShellCommand(ctx, script)
+	cmd.Env = supervisor.ChildEnv()
+	// 钩子和服务进程以相同的用户/用户组运行，避免以keeper的用户创建服务无权访问的文件
+	if err := proc.SetCredential(cmd, svc.spec.User, svc.spec.UserGroup); err != nil {
+		return fmt.Errorf("%s hook of service [%s] can't run as the service user: %w", stage, svc.spec.Name, err)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s hook of service [%s] failed: %w, output: %s", stage, svc.spec.Name, err, string(output))
+	}
+	logger.Infof("The %s hook of service [%s] is done", stage, svc.spec.Name)
+	return nil
+}
+
+func createProcessInstance(spec *models.ServiceSpecification, port int) *proc.ProcessInstance {
+	args := newServiceArgs(spec.Name, port)
+	name := args.ProcessName
+	if spec.Shell {
+		script, err := utils.GetShellScript(spec.Command, spec.Args, args)
+		proc := proc.NewProcessInstance("service "+spec.Name, name, script, nil)
+		proc.Shell = true
+		// 不继承systemd的通知变量，避免服务冒充keeper发送READY/WATCHDOG
+		proc.Env = supervisor.ChildEnv()
+		proc.Limits = spec.Limits
+		proc.User = spec.User
+		proc.Group = spec.UserGroup
+		if err != nil {
+			proc.Status = models.StatusError
+			proc.LastExitReason = err.Error()
+		}
+		return proc
 	}
 	command, cmdArgs, err := utils.GetCommandLine(spec.Command, spec.Args, args)
 	if err != nil {
@@ -429,22 +1095,73 @@ func createProcessInstance(spec *models.ServiceSpecification, port int) *proc.Pr
 		proc.LastExitReason = err.Error()
 		return proc
 	}
-	return proc.NewProcessInstance("service "+spec.Name, name, command, cmdArgs)
+	proc := proc.NewProcessInstance("service "+spec.Name, name, command, cmdArgs)
+	proc.Env = supervisor.ChildEnv()
+	proc.Limits = spec.Limits
+	proc.User = spec.User
+	proc.Group = spec.UserGroup
+	return proc
 }
 
-func RunTool(spec *models.ServiceSpecification) error {
+/**
+ * Run a "once" service and wait until it exits
+ * @param {models.ServiceSpecification} spec - Specification of the service
+ * @param {time.Duration} timeout - Max run time, the process is killed if it runs longer
+ * @returns {models.OnceTaskResult} Returns result and exit code of the run
+ * @returns {error} Returns error if the process can't start, exits abnormally or times out
+ */
+func RunTool(spec *models.ServiceSpecification, timeout time.Duration) (models.OnceTaskResult, error) {
+	result := models.OnceTaskResult{
+		Name:      spec.Name,
+		Result:    models.OnceFailed,
+		ExitCode:  -1,
+		StartTime: time.Now(),
+	}
+	err := runTool(spec, timeout, &result)
+	result.Duration = time.Since(result.StartTime).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, err
+}
+
+func runTool(spec *models.ServiceSpecification, timeout time.Duration, result *models.OnceTaskResult) error {
 	proc := createProcessInstance(spec, spec.Port)
 	if proc.Status == models.StatusError {
 		return fmt.Errorf("%s", proc.LastExitReason)
 	}
-	return proc.StartProcess(context.Background())
+	if err := proc.StartProcess(context.Background()); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	code, err := proc.WaitProcess(ctx)
+	result.ExitCode = code
+	if proc.GetDetail().ExitClass == models.ExitOOM {
+		IncrementServiceOOM(spec.Name)
+		logger.Warnf("Service '%s' was killed by the OOM killer, consider raising its memory limit", spec.Name)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Result = models.OnceTimeout
+		return fmt.Errorf("not finished within %v, killed", timeout)
+	}
+	if err != nil {
+		return err
+	}
+	result.Result = models.OnceSuccess
+	return nil
 }
 
 func (svc *ServiceInstance) OpenTunnel(ctx context.Context) error {
 	if svc.spec.Accessible != "remote" {
 		return nil
 	}
+	old := svc.tun
 	svc.tun = tun.CreateTunnel(svc.spec.Name, []int{svc.port})
+	if old != nil {
+		svc.tun.SetReopenCount(old.GetReopenCount())
+		svc.tun.SetHealthHistory(old.GetHealthHistory())
+	}
 	if err := svc.tun.OpenTunnel(ctx); err != nil {
 		logger.Errorf("Start tunnel (%s:%d) failed: %v", svc.spec.Name, svc.port, err)
 		return err
@@ -463,8 +1180,15 @@ func (svc *ServiceInstance) CloseTunnel() error {
 func (svc *ServiceInstance) ReopenTunnel(ctx context.Context) error {
 	if svc.tun != nil {
 		svc.CloseTunnel()
+		svc.tun.SetReopenCount(svc.tun.GetReopenCount() + 1)
+	}
+	if err := svc.OpenTunnel(ctx); err != nil {
+		return err
 	}
-	return svc.OpenTunnel(ctx)
+	if svc.tun != nil {
+		GetEventBus().Publish(models.EventTunnelReopened, svc.spec.Name, svc.tun.GetDetail())
+	}
+	return nil
 }
 
 // -----------------------------------------------------------------------------
@@ -474,7 +1198,12 @@ func (svc *ServiceInstance) ReopenTunnel(ctx context.Context) error {
 // -----------------------------------------------------------------------------
 func (sm *ServiceManager) Init() error {
 	for _, spec := range config.Spec().Services {
-		if spec.Startup != "always" {
+		if spec.Startup != models.StartupAlways {
+			continue
+		}
+		if !spec.MatchPlatform(runtime.GOOS, runtime.GOARCH) {
+			logger.Infof("Service [%s] is skipped, it runs on os '%s' arch '%s' only, current platform is %s/%s",
+				spec.Name, spec.Os, spec.Arch, runtime.GOOS, runtime.GOARCH)
 			continue
 		}
 		cpn := sm.cm.GetComponent(spec.Name)
@@ -487,7 +1216,7 @@ func (sm *ServiceManager) Init() error {
 	}
 	sm.self = newService(&config.Spec().Manager.Service, sm.cm.GetSelf(), false)
 	if env.Daemon {
-		sm.self.status = models.StatusRunning
+		sm.self.setStatus(models.StatusRunning)
 		sm.self.port = env.ListenPort
 		sm.self.startTime = time.Now().Format(time.RFC3339)
 		sm.self.saveService()
@@ -540,7 +1269,8 @@ func (sm *ServiceManager) GetInstance(name string) *ServiceInstance {
  * @description
  * - Iterates through all managed services
  * - Starts services with startup mode "always" or "once"
- * - Skips services that are already running
+ * - Skips services that are already running, services which are disabled,
+ *   and services which don't run on the current platform
  * - Logs errors for individual service start failures
  * - Continues processing other services even if some fail
  * @example
@@ -552,8 +1282,16 @@ func (sm *ServiceManager) GetInstance(name string) *ServiceInstance {
 func (sm *ServiceManager) StartAll(ctx context.Context) error {
 	for _, svc := range sm.services {
 		// 只启动启动模式为 "always"和"once" 的服务
-		if svc.spec.Startup == "always" || svc.spec.Startup == "once" {
-			if svc.status == models.StatusRunning {
+		if svc.spec.Startup == models.StartupAlways || svc.spec.Startup == models.StartupOnce {
+			if status := svc.getStatus(); status == models.StatusRunning || status == models.StatusDetached {
+				continue
+			}
+			if !svc.spec.IsEnabled() {
+				logger.Infof("Service '%s' is disabled, not started", svc.spec.Name)
+				continue
+			}
+			if !svc.spec.MatchPlatform(runtime.GOOS, runtime.GOARCH) {
+				logger.Infof("Service '%s' doesn't run on %s/%s, not started", svc.spec.Name, runtime.GOOS, runtime.GOARCH)
 				continue
 			}
 			if err := svc.StartService(ctx); err != nil {
@@ -566,30 +1304,70 @@ func (sm *ServiceManager) StartAll(ctx context.Context) error {
 }
 
 /**
- * Stop all managed services
+ * Stop all managed services concurrently
+ * @param {context.Context} ctx - Context for cancellation, processes still running when it's done are force killed
+ * @returns {[]string} Returns names of services which didn't stop cleanly, sorted by name
  * @description
- * - Iterates through all managed services
- * - Stops each service regardless of current status
+ * - Processes of all services are asked to exit gracefully at the same time, each one
+ *   is force killed if it doesn't exit within service.kill_timeout, so a hung service
+ *   can't hold up the others
+ * - Tunnels are closed and the cache is saved one service after another once all processes exited
+ * - Stops each service regardless of current status, except detached services
  * - Exports service knowledge after stopping all services
- * - Used for graceful shutdown and service restart
  * @example
- * serviceManager := GetServiceManager()
- * serviceManager.StopAll()
+ * ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+ * defer cancel()
+ * failed := serviceManager.StopAll(ctx)
  */
-func (sm *ServiceManager) StopAll() {
+func (sm *ServiceManager) StopAll(ctx context.Context) []string {
+	var svcs []*ServiceInstance
 	for _, svc := range sm.services {
+		if svc.getStatus() == models.StatusDetached {
+			continue
+		}
+		svc.setStatus(models.StatusStopped)
+		svcs = append(svcs, svc)
+	}
+	slices.SortFunc(svcs, func(a, b *ServiceInstance) int {
+		return strings.Compare(a.spec.Name, b.spec.Name)
+	})
+
+	timeout := time.Duration(config.App().Service.KillTimeout) * time.Second
+	errs := make([]error, len(svcs))
+	var wg sync.WaitGroup
+	for i, svc := range svcs {
+		wg.Add(1)
+		go func(i int, svc *ServiceInstance) {
+			defer wg.Done()
+			stopCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			errs[i] = svc.proc.StopProcessContext(stopCtx)
+		}(i, svc)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, svc := range svcs {
+		if errs[i] != nil {
+			logger.Warnf("Service [%s] didn't stop cleanly: %v", svc.spec.Name, errs[i])
+			failed = append(failed, svc.spec.Name)
+		}
+		// 进程已经停止，StopService只关闭隧道、保存状态和发布事件
 		svc.StopService()
 	}
 	sm.export()
+	return failed
 }
 
 /**
  * Start specific service by name
  * @param {context.Context} ctx - Context for cancellation and timeout
  * @param {string} name - Name of the service to start
+ * @param {bool} force - Start the service even if it's disabled
  * @returns {error} Returns error if start fails, nil on success
  * @description
  * - Checks if service exists in service manager
+ * - Returns ErrServiceDisabled if service is disabled and force isn't set
  * - Returns error if service is already running
  * - Calls StartService to perform actual service start
  * - Logs error if service start fails
@@ -598,20 +1376,29 @@ func (sm *ServiceManager) StopAll() {
  * - Service already running errors
  * - Service start errors
  */
-func (sm *ServiceManager) StartService(ctx context.Context, name string) error {
+func (sm *ServiceManager) StartService(ctx context.Context, name string, force bool) error {
 	svc, ok := sm.services[name]
 	if !ok {
 		return fmt.Errorf("service %s not found", name)
 	}
-	if svc.status == models.StatusRunning {
+	if !force && !svc.spec.IsEnabled() {
+		return fmt.Errorf("%w: %s, use force to start it", ErrServiceDisabled, name)
+	}
+	if svc.getStatus() == models.StatusRunning {
 		return fmt.Errorf("service %s is already running", name)
 	}
-	if err := svc.StartService(ctx); err != nil {
+	if svc.getStatus() == models.StatusDetached {
+		return fmt.Errorf("service %s is detached, attach it first", name)
+	}
+	svc.cascaded = false
+	err := svc.StartService(ctx)
+	if err != nil && !errors.Is(err, ErrServiceNotReady) {
 		logger.Errorf("Start [%s] failed: %v", name, err)
 		return err
 	}
+	sm.cascadeStart(ctx, svc)
 	sm.export()
-	return nil
+	return err
 }
 
 /**
@@ -621,8 +1408,9 @@ func (sm *ServiceManager) StartService(ctx context.Context, name string) error {
  * @returns {error} Returns error if restart fails, nil on success
  * @description
  * - Checks if service exists in service manager
+ * - Returns ErrServiceDisabled if service is disabled and isn't running, start it with force instead
  * - Stops service if currently running
- * - Starts service with new configuration
+ * - Starts service with new configuration, keeping the port allocated last time if it's still free
  * - Logs error if service restart fails
  * @throws
  * - Service not found errors
@@ -635,15 +1423,19 @@ func (sm *ServiceManager) RestartService(ctx context.Context, name string) error
 		logger.Errorf("Restart [%s] failed: service not found", name)
 		return fmt.Errorf("service %s not found", name)
 	}
-	if svc.status == models.StatusRunning {
-		svc.StopService()
+	// 已用force启动的停用服务可以重启，未运行的停用服务只能用force启动
+	if !svc.spec.IsEnabled() && svc.getStatus() != models.StatusRunning {
+		return fmt.Errorf("%w: %s, use start with force to start it", ErrServiceDisabled, name)
 	}
-	if err := svc.StartService(ctx); err != nil {
+	svc.cascaded = false
+	err := svc.Restart(ctx)
+	if err != nil && !errors.Is(err, ErrServiceNotReady) {
 		logger.Errorf("Restart [%s] failed: %v", name, err)
 		return err
 	}
+	sm.cascadeStart(ctx, svc)
 	sm.export()
-	return nil
+	return err
 }
 
 /**
@@ -654,6 +1446,7 @@ func (sm *ServiceManager) RestartService(ctx context.Context, name string) error
  * - Checks if service exists in service manager
  * - Returns nil if service is not running
  * - Calls StopService to perform actual service stop
+ * - Stops dependent services as well if the service enables cascade_stop
  * - Logs error if service not found
  * @throws
  * - Service not found errors
@@ -668,21 +1461,287 @@ func (sm *ServiceManager) StopService(name string) error {
 		logger.Errorf("Stop [%s] failed: service not found", name)
 		return fmt.Errorf("service %s not found", name)
 	}
-	if svc.status != models.StatusRunning {
+	if status := svc.getStatus(); status != models.StatusRunning && status != models.StatusDetached {
 		return nil
 	}
 	svc.StopService()
+	sm.cascadeStop(svc)
+	sm.export()
+	return nil
+}
+
+/**
+ * Stop managing the service by name, its process keeps running
+ * @param {string} name - Name of the service
+ * @returns {error} Returns error if the service isn't found or isn't running
+ */
+func (sm *ServiceManager) DetachService(name string) error {
+	svc, ok := sm.services[name]
+	if !ok {
+		return fmt.Errorf("service %s not found", name)
+	}
+	if err := svc.Detach(); err != nil {
+		return err
+	}
+	sm.export()
+	return nil
+}
+
+/**
+ * Reset the restart counter of the service by name
+ * @param {string} name - Name of the service
+ * @returns {error} Returns error if the service isn't found
+ */
+func (sm *ServiceManager) ResetRestartCount(name string) error {
+	svc, ok := sm.services[name]
+	if !ok {
+		return fmt.Errorf("service %s not found", name)
+	}
+	svc.ResetRestartCount()
+	return nil
+}
+
+/**
+ * Re-adopt the process of the detached service by name
+ * @param {string} name - Name of the service
+ * @returns {error} Returns error if the service isn't found, isn't detached or its process exited
+ */
+func (sm *ServiceManager) AttachService(name string) error {
+	svc, ok := sm.services[name]
+	if !ok {
+		return fmt.Errorf("service %s not found", name)
+	}
+	if err := svc.Attach(); err != nil {
+		return err
+	}
 	sm.export()
 	return nil
 }
 
-func (sm *ServiceManager) RecoverServices() {
-	logger.Debugf("Recover broken services")
+/**
+ * Start all services in the group
+ * @param {context.Context} ctx - Context for cancellation and timeout
+ * @param {string} group - Group name
+ * @returns {[]models.BatchResult} Returns operation result of each service in the group
+ * @returns {error} Returns ErrGroupNotFound if no service belongs to the group
+ * @description
+ * - Services already running are skipped and reported as success
+ * - Services of other groups are untouched, except dependencies started by cascade
+ */
+func (sm *ServiceManager) StartGroup(ctx context.Context, group string) ([]models.BatchResult, error) {
+	return sm.operateGroup(group, func(svc *ServiceInstance) error {
+		if svc.getStatus() == models.StatusRunning {
+			return nil
+		}
+		return sm.StartService(ctx, svc.spec.Name, false)
+	})
+}
+
+/**
+ * Stop all services in the group
+ * @param {string} group - Group name
+ * @returns {[]models.BatchResult} Returns operation result of each service in the group
+ * @returns {error} Returns ErrGroupNotFound if no service belongs to the group
+ * @description
+ * - Services of other groups are untouched, except dependents stopped by cascade_stop
+ */
+func (sm *ServiceManager) StopGroup(group string) ([]models.BatchResult, error) {
+	return sm.operateGroup(group, func(svc *ServiceInstance) error {
+		return sm.StopService(svc.spec.Name)
+	})
+}
+
+/**
+ * Restart all services in the group
+ * @param {context.Context} ctx - Context for cancellation and timeout
+ * @param {string} group - Group name
+ * @returns {[]models.BatchResult} Returns operation result of each service in the group
+ * @returns {error} Returns ErrGroupNotFound if no service belongs to the group
+ */
+func (sm *ServiceManager) RestartGroup(ctx context.Context, group string) ([]models.BatchResult, error) {
+	return sm.operateGroup(group, func(svc *ServiceInstance) error {
+		return sm.RestartService(ctx, svc.spec.Name)
+	})
+}
+
+/**
+ * Run the operation on each service of the group in name order
+ * @private
+ */
+func (sm *ServiceManager) operateGroup(group string, operate func(svc *ServiceInstance) error) ([]models.BatchResult, error) {
+	var members []*ServiceInstance
+	for _, svc := range sm.services {
+		if group != "" && svc.spec.Group == group {
+			members = append(members, svc)
+		}
+	}
+	if len(members) == 0 {
+		return nil, ErrGroupNotFound
+	}
+	slices.SortFunc(members, func(a, b *ServiceInstance) int {
+		return strings.Compare(a.spec.Name, b.spec.Name)
+	})
+	results := []models.BatchResult{}
+	for _, svc := range members {
+		result := models.BatchResult{Name: svc.spec.Name, Status: "success"}
+		if err := operate(svc); errors.Is(err, ErrServiceNotReady) {
+			result.Status = "not_ready"
+			result.Error = err.Error()
+		} else if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+/**
+ * Get services depending on the specified service, in reverse topological order
+ * @param {string} name - Name of the dependency service
+ * @returns {[]*ServiceInstance} Returns direct and indirect dependents, deepest first
+ * @description
+ * - Walks depends_on declarations of all managed services
+ * - A dependent always appears before the services it depends on,
+ *   so stopping in this order never leaves a service running against a stopped dependency
+ * - Dependency cycles are tolerated, each service appears only once
+ * @private
+ */
+func (sm *ServiceManager) getDependents(name string) []*ServiceInstance {
+	var result []*ServiceInstance
+	visited := map[string]bool{name: true}
+
+	var visit func(dep string)
+	visit = func(dep string) {
+		for _, svc := range sm.services {
+			if visited[svc.spec.Name] || !slices.Contains(svc.spec.DependsOn, dep) {
+				continue
+			}
+			visited[svc.spec.Name] = true
+			visit(svc.spec.Name)
+			result = append(result, svc)
+		}
+	}
+	visit(name)
+	return result
+}
+
+/**
+ * Stop services depending on a stopped service
+ * @param {ServiceInstance} svc - The service which has been stopped
+ * @description
+ * - Does nothing unless the service enables cascade_stop
+ * - Stops running dependents in reverse topological order
+ * - Marks stopped dependents so that they can be restarted by cascadeStart
+ * @private
+ */
+func (sm *ServiceManager) cascadeStop(svc *ServiceInstance) {
+	if !svc.spec.CascadeStop {
+		return
+	}
+	for _, dep := range sm.getDependents(svc.spec.Name) {
+		if dep.getStatus() != models.StatusRunning {
+			continue
+		}
+		logger.Warnf("Cascade stop: service [%s] is stopped because its dependency [%s] is stopped",
+			dep.spec.Name, svc.spec.Name)
+		dep.StopService()
+		dep.cascaded = true
+	}
+}
+
+/**
+ * Restart services which were stopped by cascade when their dependency stopped
+ * @param {context.Context} ctx - Context for cancellation and timeout
+ * @param {ServiceInstance} svc - The service which has been started
+ * @description
+ * - Does nothing unless the service enables cascade_restart
+ * - Only dependents stopped by cascadeStop are restarted, services stopped manually stay stopped
+ * - Disabled dependents aren't restarted, even if they were started with force before
+ * - Dependents are started in topological order, dependencies first
+ * @private
+ */
+func (sm *ServiceManager) cascadeStart(ctx context.Context, svc *ServiceInstance) {
+	if !svc.spec.CascadeRestart {
+		return
+	}
+	deps := sm.getDependents(svc.spec.Name)
+	for i := len(deps) - 1; i >= 0; i-- {
+		dep := deps[i]
+		if !dep.cascaded || dep.getStatus() == models.StatusRunning {
+			continue
+		}
+		dep.cascaded = false
+		if !dep.spec.IsEnabled() {
+			logger.Infof("Cascade start: service [%s] is disabled, it isn't restarted", dep.spec.Name)
+			continue
+		}
+		logger.Infof("Cascade start: service [%s] is restarted because its dependency [%s] is started",
+			dep.spec.Name, svc.spec.Name)
+		if err := dep.StartService(ctx); err != nil {
+			logger.Errorf("Cascade start [%s] failed: %v", dep.spec.Name, err)
+		}
+	}
+}
+
+/**
+ * Recover broken services whose check falls in the given slot
+ * @param {int} slot - Current slot of the monitoring interval, in range [0, slots)
+ * @param {int} slots - Number of slots the monitoring interval is divided into
+ * @description
+ * - Each service is assigned a fixed slot by hashing its name, so health checks
+ *   of different services are spread over the interval instead of firing together
+ * - Every service is checked exactly once per interval
+ */
+func (sm *ServiceManager) RecoverServices(slot, slots int) {
+	if IsDraining() {
+		logger.Debugf("Skip recovering services in drain mode")
+		return
+	}
+	if IsMaintenance() {
+		logger.Debugf("Skip recovering services in maintenance mode")
+		return
+	}
 	for _, svc := range sm.services {
+		if staggerSlot(svc.spec.Name, slots) != slot {
+			continue
+		}
+		logger.Debugf("Recover service [%s] in slot %d/%d", svc.spec.Name, slot, slots)
 		svc.RecoverService()
 	}
 }
 
+// 根据服务名的哈希值计算服务在监控周期内的检查时隙，使各服务的检查错开
+func staggerSlot(name string, slots int) int {
+	if slots <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(slots))
+}
+
+/**
+ * Retry opening pending tunnels of running services
+ * @param {context.Context} ctx - Context for tunnel processes
+ * @description
+ * - Tunnels become pending when the tunnel manager is unreachable at service start,
+ *   each tunnel retries with its own backoff interval
+ */
+func (sm *ServiceManager) RetryPendingTunnels(ctx context.Context) {
+	if IsMaintenance() {
+		return
+	}
+	for _, svc := range sm.services {
+		if svc.getStatus() != models.StatusRunning || svc.tun == nil {
+			continue
+		}
+		if svc.tun.IsPending() {
+			svc.tun.RetryPending(ctx)
+		}
+	}
+}
+
 /**
  * Export service known to well-known.json file
  */
@@ -694,7 +1753,7 @@ func (sm *ServiceManager) exportKnowledge(outputPath string) error {
 	}
 	// 构建日志知识
 	logKnowledge := models.LogKnowledge{
-		Dir:   filepath.Join(env.CostrictDir, "logs"),
+		Dir:   env.GetLogDir(),
 		Level: config.App().Log.Level,
 	}
 
@@ -716,7 +1775,7 @@ func (sm *ServiceManager) exportKnowledge(outputPath string) error {
 		return fmt.Errorf("JSON 编码失败: %v", err)
 	}
 	// 写入文件
-	if err := os.WriteFile(outputPath, jsonData, 0644); err != nil {
+	if err := utils.WriteFileAtomic(outputPath, jsonData, 0644); err != nil {
 		return fmt.Errorf("写入文件失败: %v", err)
 	}
 	return nil
@@ -728,15 +1787,50 @@ func (sm *ServiceManager) exportKnowledge(outputPath string) error {
  * @description
  * - Calls exportKnowledge with default output file path
  * - Default path is .costrict/share/.well-known.json
- * - Logs error if export fails
+ * - Logs error if export fails, and records it for GetExportError until an export succeeds
  * - Used for automatic knowledge export
  * @private
  */
 func (sm *ServiceManager) export() error {
 	outputFile := filepath.Join(env.CostrictDir, "share", ".well-known.json")
-	if err := sm.exportKnowledge(outputFile); err != nil {
+	err := sm.exportKnowledge(outputFile)
+
+	sm.exportLock.Lock()
+	defer sm.exportLock.Unlock()
+	if err != nil {
 		logger.Errorf("Failed to export .well-known to file [%s]: %v", outputFile, err)
+		sm.exportErr = err
 		return err
 	}
+	if sm.exportErr != nil {
+		logger.Infof("Export .well-known to file [%s] recovered", outputFile)
+		sm.exportErr = nil
+	}
 	return nil
 }
+
+/**
+ * Get the error of the last .well-known.json export
+ * @returns {string} Returns the error message, empty if the last export succeeded
+ */
+func (sm *ServiceManager) GetExportError() string {
+	sm.exportLock.Lock()
+	defer sm.exportLock.Unlock()
+	if sm.exportErr == nil {
+		return ""
+	}
+	return sm.exportErr.Error()
+}
+
+/**
+ * Export .well-known.json again if the last export failed
+ * @description
+ * - Services are usually discovered through the file, so a failed export (such as a permission
+ *   problem) is retried by monitoring instead of waiting for the next service change
+ */
+func (sm *ServiceManager) RetryExport() {
+	if sm.GetExportError() == "" {
+		return
+	}
+	sm.export()
+}
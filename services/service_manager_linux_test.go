@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceDoesNotInheritNotifyEnv(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	dir := t.TempDir()
+	// 模拟systemd的通知套接字，服务及其钩子都不能向它发送状态
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "1")
+	t.Setenv("COSTRICT_TEST_INHERITED", "yes")
+
+	svc := newSleepService(t, "notifier")
+	svc.spec.Command = "sh"
+	svc.spec.Args = []string{"-c", "env > " + dir + "/service; sleep 60"}
+	svc.spec.PreStart = "env > " + dir + "/pre-start"
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer svc.StopService()
+
+	for _, name := range []string{"pre-start", "service"} {
+		var data []byte
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			data, err = os.ReadFile(filepath.Join(dir, name))
+			if err == nil && len(data) > 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("%s environment isn't written: %v", name, err)
+		}
+		envs := "\n" + string(data)
+		for _, v := range []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"} {
+			if strings.Contains(envs, "\n"+v+"=") {
+				t.Errorf("%s inherits %s", name, v)
+			}
+		}
+		// 其他环境变量照常继承
+		if !strings.Contains(envs, "\nCOSTRICT_TEST_INHERITED=yes\n") {
+			t.Errorf("%s doesn't inherit the environment of keeper", name)
+		}
+	}
+}
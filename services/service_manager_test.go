@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/proc"
+	"costrict-keeper/internal/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadyTimeoutDefault(t *testing.T) {
+	setupTestEnv(t, "")
+	if got := config.App().Service.ReadyTimeout; got <= 0 {
+		t.Errorf("ready_timeout default = %d, want > 0", got)
+	}
+}
+
+func TestStartServiceWaitsUntilReady(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":5}}`)
+	svc := newDelayedService(t, "slow-svc", 500*time.Millisecond)
+	start := time.Now()
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("StartService returned after %v, before the service is ready", elapsed)
+	}
+	if err := svc.probeReady(); err != nil {
+		t.Errorf("service isn't ready after StartService: %v", err)
+	}
+}
+
+func TestStartServiceNotReadyInTime(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":1}}`)
+	svc := newDelayedService(t, "slower-svc", 10*time.Second)
+	err := svc.StartService(context.Background())
+	if !errors.Is(err, ErrServiceNotReady) {
+		t.Fatalf("err = %v, want ErrServiceNotReady", err)
+	}
+	if svc.status != models.StatusRunning {
+		t.Errorf("status = %s, the service should keep running", svc.status)
+	}
+}
+
+/**
+ * Create running services whose port isn't listened, each check of them increases failedCount
+ * @param {int} count - Number of services, named svc-0, svc-1...
+ * @description
+ * - Failure action is none, so checks never restart the services
+ */
+func newUnreachableServices(t *testing.T, count int) []*ServiceInstance {
+	t.Helper()
+	// 侦听后立即关闭，得到一个无人侦听的端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	var svcs []*ServiceInstance
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("svc-%d", i)
+		svcs = append(svcs, &ServiceInstance{
+			spec: models.ServiceSpecification{
+				Name:          name,
+				Startup:       models.StartupAlways,
+				Host:          "127.0.0.1",
+				FailureAction: models.FailureNone,
+			},
+			proc:   proc.NewProcessInstance(name, name, name, nil),
+			status: models.StatusRunning,
+			port:   port,
+		})
+	}
+	return svcs
+}
+
+func TestRecoverServicesStaggered(t *testing.T) {
+	setupTestEnv(t, "")
+	svcs := newUnreachableServices(t, 12)
+	sm := newTestServiceManager(t, svcs...)
+
+	const slots = 10
+	used := 0
+	checked := 0
+	for slot := 0; slot < slots; slot++ {
+		sm.RecoverServices(slot, slots)
+		count := 0
+		for _, svc := range svcs {
+			if svc.failedCount > 0 {
+				count++
+			}
+		}
+		if slot == 0 && count == len(svcs) {
+			t.Fatal("all services are checked in the first slot")
+		}
+		if count > checked {
+			used++
+		}
+		checked = count
+	}
+	// 一个监控周期内每个服务恰好检查一次
+	for _, svc := range svcs {
+		if svc.failedCount != 1 {
+			t.Errorf("service '%s' is checked %d times in one interval", svc.spec.Name, svc.failedCount)
+		}
+	}
+	if used < 3 {
+		t.Errorf("checks are spread over %d slots only", used)
+	}
+}
+
+func TestRecoverServicesMaintenance(t *testing.T) {
+	setupTestEnv(t, "")
+	svcs := newUnreachableServices(t, 3)
+	sm := newTestServiceManager(t, svcs...)
+	t.Cleanup(func() { SetMaintenance(false) })
+
+	SetMaintenance(true)
+	sm.RecoverServices(0, 1)
+	for _, svc := range svcs {
+		if svc.failedCount != 0 {
+			t.Fatalf("service '%s' is checked in maintenance mode", svc.spec.Name)
+		}
+	}
+
+	// 超过最长持续时间后自动退出维护模式
+	maintenance.mutex.Lock()
+	maintenance.until = time.Now().Add(-time.Second)
+	maintenance.mutex.Unlock()
+	if IsMaintenance() {
+		t.Fatal("expired maintenance mode should be cleared")
+	}
+	sm.RecoverServices(0, 1)
+	for _, svc := range svcs {
+		if svc.failedCount != 1 {
+			t.Errorf("service '%s' isn't checked after maintenance mode", svc.spec.Name)
+		}
+	}
+}
+
+func TestRecheckPortOccupied(t *testing.T) {
+	setupTestEnv(t, "")
+	svc := &ServiceInstance{spec: models.ServiceSpecification{Name: "port-svc"}}
+
+	port, err := utils.AllocPort(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分配后端口未被占用，继续使用
+	got, err := svc.recheckPort(port)
+	if err != nil || got != port {
+		t.Fatalf("recheckPort(%d) = %d, %v, want the same port", port, got, err)
+	}
+
+	// 分配后端口被其它程序占用
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got, err = svc.recheckPort(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer utils.FreePort(got)
+	if got == port {
+		t.Fatalf("occupied port %d is used again", port)
+	}
+	if utils.CheckPortConnectable(got) {
+		t.Errorf("reallocated port %d is in use", got)
+	}
+	_, _, allocates := utils.GetPortAllocates()
+	for _, p := range allocates {
+		if p == port {
+			t.Errorf("occupied port %d isn't freed", port)
+		}
+	}
+}
+
+func TestGRPCHealthCheckCached(t *testing.T) {
+	setupTestEnv(t, "")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("indexer", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	// 服务进程只需要在运行，健康检查接口由进程内的gRPC服务提供
+	t.Setenv("COSTRICT_TEST_SERVE_DELAY", "1m")
+	p := proc.NewProcessInstance("grpc-svc", "grpc-svc", os.Args[0], []string{"-test.run=^$", "0"})
+	if err := p.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.StopProcess()
+	svc := &ServiceInstance{
+		spec: models.ServiceSpecification{
+			Name:        "grpc-svc",
+			Startup:     models.StartupAlways,
+			Host:        "127.0.0.1",
+			Healthy:     "indexer",
+			HealthCheck: models.HealthCheckSpec{Type: "grpc"},
+		},
+		proc:   p,
+		status: models.StatusRunning,
+		port:   ln.Addr().(*net.TCPAddr).Port,
+		child:  true,
+	}
+
+	if got := svc.CheckService(); got != models.Unhealthy {
+		t.Fatalf("CheckService() = %s, want %s for NOT_SERVING", got, models.Unhealthy)
+	}
+	detail := svc.GetDetail()
+	if detail.Healthy != models.Unhealthy || !strings.Contains(detail.Reason, "grpc health check failed") {
+		t.Errorf("detail health = %s (%s), want unhealthy by grpc health check", detail.Healthy, detail.Reason)
+	}
+
+	// 详情不探测服务，直到下一次检查才反映服务恢复
+	hs.SetServingStatus("indexer", healthpb.HealthCheckResponse_SERVING)
+	if detail := svc.GetDetail(); detail.Healthy != models.Unhealthy {
+		t.Errorf("detail health = %s before the next check, want the cached %s", detail.Healthy, models.Unhealthy)
+	}
+	if got := svc.CheckService(); got != models.Healthy {
+		t.Fatalf("CheckService() = %s, want %s for SERVING", got, models.Healthy)
+	}
+	if detail := svc.GetDetail(); detail.Healthy != models.Healthy || detail.Reason != "" {
+		t.Errorf("detail health = %s (%s), want healthy", detail.Healthy, detail.Reason)
+	}
+}
+
+func TestServiceArgsLogLevel(t *testing.T) {
+	setupTestEnv(t, `{"log":{"level":"DEBUG"}}`)
+	spec := &models.ServiceSpecification{
+		Name:    "level-svc",
+		Command: "level-svc",
+		Args:    []string{"--port={{.LocalPort}}", "--log-level={{.LogLevel}}"},
+	}
+	pi := createProcessInstance(spec, 8080)
+	if want := []string{"--port=8080", "--log-level=debug"}; strings.Join(pi.Args, " ") != strings.Join(want, " ") {
+		t.Errorf("args = %v, want %v", pi.Args, want)
+	}
+
+	// 运行时修改了keeper的日志级别，之后重建的进程使用新级别
+	config.App().Log.Level = "warn"
+	pi = createProcessInstance(spec, 8080)
+	if got := pi.Args[1]; got != "--log-level=warn" {
+		t.Errorf("arg = %s, want --log-level=warn", got)
+	}
+
+	spec.Shell = true
+	spec.Command = "echo {{.LogLevel}}"
+	spec.Args = nil
+	pi = createProcessInstance(spec, 0)
+	if !strings.Contains(pi.Command, "echo warn") {
+		t.Errorf("shell script isn't expanded: %s", pi.Command)
+	}
+}
+
+// 与当前平台不同的操作系统
+func otherOS() string {
+	if runtime.GOOS == "windows" {
+		return "linux"
+	}
+	return "windows"
+}
+
+func TestInitSkipsPlatformMismatch(t *testing.T) {
+	setupTestEnv(t, "")
+	setupTestSpec(t, models.SystemSpecification{
+		Services: []models.ServiceSpecification{
+			{Name: "native", Startup: models.StartupAlways, Command: "native", Os: runtime.GOOS, Arch: runtime.GOARCH},
+			{Name: "wrong-os", Startup: models.StartupAlways, Command: "wrong-os", Os: otherOS()},
+			{Name: "wrong-arch", Startup: models.StartupAlways, Command: "wrong-arch", Arch: "no-such-arch"},
+		},
+	})
+	// 跳过的服务不需要组件，否则Init会因为组件不存在而失败
+	newTestComponentManager(t, "http://127.0.0.1:1", "native")
+	sm := newTestServiceManager(t)
+	if err := sm.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if sm.GetInstance("native") == nil {
+		t.Error("service matching the platform isn't loaded")
+	}
+	for _, name := range []string{"wrong-os", "wrong-arch"} {
+		if sm.GetInstance(name) != nil {
+			t.Errorf("service [%s] doesn't match the platform but is loaded", name)
+		}
+	}
+}
+
+/**
+ * Create a running service whose process is the test binary, the process doesn't listen on the port
+ * @param {int} port - Port the service is regarded to listen on
+ */
+func newRunningService(t *testing.T, name string, port int) *ServiceInstance {
+	t.Helper()
+	t.Setenv("COSTRICT_TEST_SERVE_DELAY", "1m")
+	p := proc.NewProcessInstance(name, name, os.Args[0], []string{"-test.run=^$", "0"})
+	if err := p.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.StopProcess() })
+	return &ServiceInstance{
+		spec:   models.ServiceSpecification{Name: name, Startup: models.StartupAlways, Host: "127.0.0.1"},
+		proc:   p,
+		status: models.StatusRunning,
+		port:   port,
+		child:  true,
+	}
+}
+
+// 侦听后立即关闭，得到一个无人侦听的端口
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestCheckServiceReason(t *testing.T) {
+	setupTestEnv(t, "")
+	// 健康检查接口总是返回503，也不响应TCP握手
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	srvPort := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T) *ServiceInstance
+		health models.HealthyStatus
+		reason string
+	}{
+		{"stopped", func(t *testing.T) *ServiceInstance {
+			svc := newRunningService(t, "stopped-svc", 0)
+			svc.status = models.StatusStopped
+			return svc
+		}, models.Unavailable, "service is stopped"},
+		{"process exited", func(t *testing.T) *ServiceInstance {
+			svc := newRunningService(t, "exited-svc", 0)
+			svc.proc.StopProcess()
+			return svc
+		}, models.Unavailable, "process"},
+		{"port closed", func(t *testing.T) *ServiceInstance {
+			port := closedPort(t)
+			return newRunningService(t, "closed-svc", port)
+		}, models.Unhealthy, "not accepting connections"},
+		{"http probe", func(t *testing.T) *ServiceInstance {
+			svc := newRunningService(t, "http-svc", srvPort)
+			svc.spec.Healthy = "/healthz"
+			svc.spec.HealthCheck.Type = "http"
+			return svc
+		}, models.Unhealthy, "http health check failed"},
+		{"tcp handshake", func(t *testing.T) *ServiceInstance {
+			svc := newRunningService(t, "tcp-svc", srvPort)
+			svc.spec.HealthCheck = models.HealthCheckSpec{Send: "PING\r\n", Expect: "^\\+PONG", Timeout: 1}
+			return svc
+		}, models.Unhealthy, "tcp handshake failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := tt.setup(t)
+			if got := svc.CheckService(); got != tt.health {
+				t.Fatalf("CheckService() = %s, want %s", got, tt.health)
+			}
+			health, reason := svc.checkedHealth()
+			if health != tt.health || !strings.Contains(reason, tt.reason) {
+				t.Errorf("recorded health = %s (%s), want %s containing %q", health, reason, tt.health, tt.reason)
+			}
+			// 详情只读取监测记录的结果，GetHealthy不覆盖记录的原因
+			svc.GetHealthy()
+			detail := svc.GetDetail()
+			if detail.Healthy != tt.health || !strings.Contains(detail.Reason, tt.reason) {
+				t.Errorf("detail health = %s (%s), want %s containing %q", detail.Healthy, detail.Reason, tt.health, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,623 @@
+//go:build linux || darwin
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/proc"
+	"costrict-keeper/internal/tun"
+	"costrict-keeper/internal/utils"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// 创建运行sleep的测试服务，sleep不侦听端口，服务端口只由keeper分配
+func newSleepService(t *testing.T, name string) *ServiceInstance {
+	t.Helper()
+	svc := &ServiceInstance{
+		spec: models.ServiceSpecification{
+			Name:    name,
+			Startup: models.StartupAlways,
+			Command: "sleep",
+			Args:    []string{"30"},
+		},
+		child: true,
+	}
+	t.Cleanup(func() {
+		if svc.proc != nil {
+			svc.StopService()
+		}
+		if svc.port > 0 {
+			utils.FreePort(svc.port)
+		}
+	})
+	return svc
+}
+
+func TestRestartKeepsPort(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	svc := newSleepService(t, "sticky-svc")
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	port := svc.port
+	if port <= 0 {
+		t.Fatalf("port isn't allocated: %d", port)
+	}
+	if err := svc.Restart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if svc.port != port {
+		t.Errorf("port changed across restart: %d -> %d", port, svc.port)
+	}
+	if svc.status != models.StatusRunning {
+		t.Errorf("status = %s, want running", svc.status)
+	}
+}
+
+func TestExportTunnelMapping(t *testing.T) {
+	tunman := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req tun.PortAllocationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(tun.PortAllocationResponse{
+			AppName:     req.AppName,
+			ClientPort:  req.ClientPort,
+			MappingPort: 40001,
+		})
+	}))
+	defer tunman.Close()
+	setupTestEnv(t, `{"service":{"ready_timeout":-1},"cloud":{"tunman_url":"`+tunman.URL+`"},`+
+		`"tunnel":{"command":"sleep","args":["30"]}}`)
+
+	svc := newSleepService(t, "remote-svc")
+	svc.spec.Accessible = "remote"
+	newTestServiceManager(t, svc)
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.CloseTunnel() })
+
+	// 隧道打开后通过钩子刷新导出文件
+	data, err := os.ReadFile(filepath.Join(env.CostrictDir, "share", ".well-known.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var knowledge models.SystemKnowledge
+	if err := json.Unmarshal(data, &knowledge); err != nil {
+		t.Fatal(err)
+	}
+	for _, sk := range knowledge.Services {
+		if sk.Name != "remote-svc" {
+			continue
+		}
+		if sk.Tunnel == nil || sk.Tunnel.Status != models.StatusRunning {
+			t.Fatalf("tunnel isn't exported as running: %+v", sk.Tunnel)
+		}
+		want := models.PortPair{LocalPort: svc.port, MappingPort: 40001}
+		if len(sk.Tunnel.Pairs) != 1 || sk.Tunnel.Pairs[0] != want {
+			t.Errorf("pairs = %+v, want %+v", sk.Tunnel.Pairs, want)
+		}
+		return
+	}
+	t.Fatalf("remote-svc isn't exported: %s", data)
+}
+
+func TestStartServiceWithTunnelManagerDown(t *testing.T) {
+	tunman := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer tunman.Close()
+	setupTestEnv(t, `{"service":{"ready_timeout":-1},"cloud":{"tunman_url":"`+tunman.URL+`"},`+
+		`"tunnel":{"command":"sleep","args":["30"]}}`)
+
+	svc := newSleepService(t, "pending-svc")
+	svc.spec.Accessible = "remote"
+	// 隧道管理服务不可达不影响服务在本地运行
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.CloseTunnel() })
+	detail := svc.GetDetail()
+	if detail.Status != models.StatusRunning {
+		t.Errorf("service status = %s, want running", detail.Status)
+	}
+	if detail.Tunnel == nil || detail.Tunnel.Status != models.StatusPending {
+		t.Errorf("tunnel isn't pending: %+v", detail.Tunnel)
+	}
+}
+
+func TestStartStopGroup(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	svcA := newSleepService(t, "svc-a")
+	svcB := newSleepService(t, "svc-b")
+	proxy := newSleepService(t, "proxy")
+	svcA.spec.Group, svcB.spec.Group, proxy.spec.Group = "ai-tools", "ai-tools", "proxies"
+	// 和初始化后的服务一样，未启动的服务也有进程实例
+	for _, svc := range []*ServiceInstance{svcA, svcB, proxy} {
+		svc.proc = proc.NewProcessInstance("service "+svc.spec.Name, svc.spec.Name, "sleep", []string{"30"})
+	}
+	sm := newTestServiceManager(t, svcA, svcB, proxy)
+
+	results, err := sm.StartGroup(context.Background(), "ai-tools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Name != "svc-a" || results[1].Name != "svc-b" {
+		t.Fatalf("results = %+v, want svc-a and svc-b", results)
+	}
+	for _, r := range results {
+		if r.Status != "success" {
+			t.Errorf("start %s: %+v", r.Name, r)
+		}
+	}
+	if svcA.status != models.StatusRunning || svcB.status != models.StatusRunning {
+		t.Errorf("group members aren't running: %s, %s", svcA.status, svcB.status)
+	}
+	if proxy.status == models.StatusRunning || proxy.proc.Pid() > 0 {
+		t.Errorf("service of another group is started: %s", proxy.status)
+	}
+
+	if err := proxy.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.StopGroup("ai-tools"); err != nil {
+		t.Fatal(err)
+	}
+	if svcA.status != models.StatusStopped || svcB.status != models.StatusStopped {
+		t.Errorf("group members aren't stopped: %s, %s", svcA.status, svcB.status)
+	}
+	if proxy.status != models.StatusRunning {
+		t.Errorf("service of another group is stopped: %s", proxy.status)
+	}
+
+	if _, err := sm.StopGroup("missing"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("stop unknown group: err = %v, want ErrGroupNotFound", err)
+	}
+}
+
+func TestStopAllConcurrent(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1,"kill_timeout":1}}`)
+	svcA := newSleepService(t, "svc-a")
+	svcB := newSleepService(t, "svc-b")
+	// 忽略SIGTERM的服务，exec后sleep继承被忽略的信号
+	stubborn := newSleepService(t, "stubborn")
+	stubborn.spec.Command = "sh"
+	stubborn.spec.Args = []string{"-c", "trap '' TERM; exec sleep 30"}
+	sm := newTestServiceManager(t, svcA, svcB, stubborn)
+	for _, svc := range []*ServiceInstance{svcA, svcB, stubborn} {
+		if err := svc.StartService(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 等待shell设置好信号处理
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	failed := sm.StopAll(context.Background())
+	elapsed := time.Since(start)
+	if len(failed) != 1 || failed[0] != "stubborn" {
+		t.Errorf("failed = %v, want [stubborn]", failed)
+	}
+	// 并发停止，总时间只取决于最慢的服务
+	if elapsed < time.Second || elapsed > 2500*time.Millisecond {
+		t.Errorf("StopAll took %v, want about kill_timeout", elapsed)
+	}
+	for _, svc := range []*ServiceInstance{svcA, svcB, stubborn} {
+		if svc.status != models.StatusStopped {
+			t.Errorf("%s status = %s, want stopped", svc.spec.Name, svc.status)
+		}
+		if svc.proc.Pid() != 0 {
+			t.Errorf("%s process is still tracked: %d", svc.spec.Name, svc.proc.Pid())
+		}
+	}
+}
+
+func TestServiceHooks(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	dir := t.TempDir()
+	svc := newSleepService(t, "hooked")
+	svc.spec.PreStart = "mkdir -p " + dir + "/data && echo {{.LocalPort}} > " + dir + "/pre-start"
+	svc.spec.PostStop = "rm -rf " + dir + "/data && touch " + dir + "/post-stop"
+
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pre-start"))
+	if err != nil {
+		t.Fatalf("pre-start hook isn't run: %v", err)
+	}
+	// 钩子命令和服务命令一样展开模板
+	if got := strings.TrimSpace(string(data)); got != strconv.Itoa(svc.port) {
+		t.Errorf("pre-start hook got port %q, want %d", got, svc.port)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data")); err != nil {
+		t.Errorf("pre-start hook didn't create the directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "post-stop")); err == nil {
+		t.Error("post-stop hook is run before the service stops")
+	}
+
+	svc.StopService()
+	if _, err := os.Stat(filepath.Join(dir, "post-stop")); err != nil {
+		t.Errorf("post-stop hook isn't run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data")); !os.IsNotExist(err) {
+		t.Errorf("post-stop hook didn't clean up: %v", err)
+	}
+}
+
+func TestServicePreStartHookFailure(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	svc := newSleepService(t, "hooked")
+	svc.spec.PreStart = "echo disk full >&2; exit 3"
+
+	err := svc.StartService(context.Background())
+	if err == nil {
+		t.Fatal("start should fail when the pre-start hook fails")
+	}
+	if !strings.Contains(err.Error(), "pre-start hook of service [hooked] failed") || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("error doesn't explain the hook failure: %v", err)
+	}
+	if svc.status != models.StatusError {
+		t.Errorf("status = %s, want %s", svc.status, models.StatusError)
+	}
+	if svc.proc.Pid() != 0 {
+		t.Errorf("service process is started although the pre-start hook failed")
+	}
+}
+
+func TestServiceHooksRunAsUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("running service as another user requires root")
+	}
+	u, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("user nobody doesn't exist: %v", err)
+	}
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	// 钩子以nobody运行，临时目录及其上级目录需所有用户可写
+	dir := t.TempDir()
+	for _, d := range []string{filepath.Dir(dir), dir} {
+		if err := os.Chmod(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := newSleepService(t, "hooked")
+	svc.spec.User = "nobody"
+	svc.spec.PreStart = "id -u > " + dir + "/pre-start"
+	svc.spec.PostStop = "id -u > " + dir + "/post-stop"
+
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	svc.StopService()
+	for _, stage := range []string{"pre-start", "post-stop"} {
+		data, err := os.ReadFile(filepath.Join(dir, stage))
+		if err != nil {
+			t.Fatalf("%s hook isn't run: %v", stage, err)
+		}
+		if got := strings.TrimSpace(string(data)); got != u.Uid {
+			t.Errorf("%s hook runs as uid %s, want %s", stage, got, u.Uid)
+		}
+	}
+}
+
+// 创建启动后很快异常退出的always服务，以守护进程方式监测，退出后自动重启
+func newCrashingService(t *testing.T, name string, maxRestart int) *ServiceInstance {
+	t.Helper()
+	daemon := env.Daemon
+	env.Daemon = true
+	t.Cleanup(func() { env.Daemon = daemon })
+	svc := newSleepService(t, name)
+	svc.spec.Command = "sh"
+	svc.spec.Args = []string{"-c", "sleep 0.2; exit 1"}
+	svc.spec.MaxRestart = maxRestart
+	return svc
+}
+
+// 等待服务进程的状态满足条件
+func waitProcDetail(t *testing.T, svc *ServiceInstance, desc string, cond func(models.ProcessDetail) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(svc.proc.GetDetail()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s: %+v", desc, svc.proc.GetDetail())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestResetRestartCount(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	svc := newCrashingService(t, "crashing-svc", 1)
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 自动重启一次后再次退出，重启次数用完
+	waitProcDetail(t, svc, "restart limit", func(d models.ProcessDetail) bool {
+		return d.RestartCount == 1 && d.Status == models.StatusExited
+	})
+	time.Sleep(100 * time.Millisecond)
+	if healthy, _ := svc.getCachedHealthy(); !svc.crashLoop || healthy != models.Unavailable {
+		t.Fatalf("service should be in crash loop, healthy=%s", healthy)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if d := svc.proc.GetDetail(); d.RestartCount != 1 || d.Status != models.StatusExited {
+		t.Fatalf("process shouldn't be restarted beyond the limit: %+v", d)
+	}
+
+	svc.ResetRestartCount()
+	if svc.crashLoop || svc.proc.GetDetail().RestartCount != 0 {
+		t.Fatalf("reset doesn't clear the counter, crashLoop=%v", svc.crashLoop)
+	}
+	// 清零后进程再次退出时恢复自动重启
+	if err := svc.proc.StartProcess(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitProcDetail(t, svc, "auto restart after reset", func(d models.ProcessDetail) bool {
+		return d.RestartCount == 1
+	})
+}
+
+func TestNegativeMaxRestartNotCrashLoop(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	svc := newCrashingService(t, "no-restart-svc", -1)
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitProcDetail(t, svc, "process exit", func(d models.ProcessDetail) bool {
+		return d.Status == models.StatusExited
+	})
+	time.Sleep(1500 * time.Millisecond)
+	if d := svc.proc.GetDetail(); d.RestartCount != 0 || d.Status != models.StatusExited {
+		t.Errorf("process shouldn't be restarted: %+v", d)
+	}
+	if svc.crashLoop {
+		t.Error("disabled auto restart shouldn't be reported as crash loop")
+	}
+}
+
+func TestAlertServiceNotRestarted(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	svc := newCrashingService(t, "alert-svc", 3)
+	svc.spec.FailureAction = models.FailureAlert
+	alerts := serviceAlertCount.WithLabelValues(svc.spec.Name)
+	before := testutil.ToFloat64(alerts)
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitProcDetail(t, svc, "process exit", func(d models.ProcessDetail) bool {
+		return d.Status == models.StatusExited
+	})
+	// 进程退出后既不由监测程序重启，也不由监控恢复
+	time.Sleep(1500 * time.Millisecond)
+	svc.RecoverService()
+	if d := svc.proc.GetDetail(); d.RestartCount != 0 || d.Status != models.StatusExited {
+		t.Errorf("alert service shouldn't be restarted: %+v", d)
+	}
+	if svc.crashLoop {
+		t.Error("alert service shouldn't be reported as crash loop")
+	}
+	// 同一次不可用只告警一次
+	if got := testutil.ToFloat64(alerts) - before; got != 1 {
+		t.Errorf("%v alerts are raised, want 1", got)
+	}
+}
+
+func TestDisabledServiceNotStartedImplicitly(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	db := newSleepService(t, "db")
+	db.spec.CascadeStop = true
+	db.spec.CascadeRestart = true
+	app := newSleepService(t, "app")
+	app.spec.DependsOn = []string{"db"}
+	enabled := false
+	app.spec.Enabled = &enabled
+	// 服务管理器加载的服务总是有进程实例
+	app.proc = createProcessInstance(&app.spec, 0)
+	sm := newTestServiceManager(t, db, app)
+	ctx := context.Background()
+
+	if err := sm.StartService(ctx, "db", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.StartService(ctx, "app", false); !errors.Is(err, ErrServiceDisabled) {
+		t.Fatalf("start of disabled service: err = %v, want ErrServiceDisabled", err)
+	}
+	if err := sm.StartService(ctx, "app", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.StopService("app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.RestartService(ctx, "app"); !errors.Is(err, ErrServiceDisabled) {
+		t.Fatalf("restart of stopped disabled service: err = %v, want ErrServiceDisabled", err)
+	}
+	if app.status == models.StatusRunning {
+		t.Fatal("disabled service is started by restart")
+	}
+
+	// 用force启动后，停用的服务可以重启
+	if err := sm.StartService(ctx, "app", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.RestartService(ctx, "app"); err != nil {
+		t.Fatalf("restart of force started service failed: %v", err)
+	}
+
+	// 依赖停止时被级联停止，依赖重新启动后停用的服务不被级联启动
+	if err := sm.StopService("db"); err != nil {
+		t.Fatal(err)
+	}
+	if app.status == models.StatusRunning {
+		t.Fatal("dependent isn't stopped by cascade")
+	}
+	if err := sm.StartService(ctx, "db", false); err != nil {
+		t.Fatal(err)
+	}
+	if app.status == models.StatusRunning {
+		t.Error("disabled service is started by cascade")
+	}
+}
+
+func TestStartAllSkipsPlatformMismatch(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1}}`)
+	native := newSleepService(t, "native")
+	native.spec.Os = runtime.GOOS
+	// 规格重新加载后，已加载的服务也可能不再匹配当前平台
+	foreign := newSleepService(t, "foreign")
+	foreign.spec.Os = otherOS()
+	foreign.proc = createProcessInstance(&foreign.spec, 0)
+	sm := newTestServiceManager(t, native, foreign)
+
+	if err := sm.StartAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if native.status != models.StatusRunning {
+		t.Errorf("service matching the platform isn't started, status = %s", native.status)
+	}
+	if foreign.status == models.StatusRunning {
+		t.Error("service of another platform is started")
+	}
+}
+
+// 取一个当前空闲的端口
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestStickyPortPersisted(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1,"sticky_port":true}}`)
+	svc := newSleepService(t, "sticky-svc")
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	port := svc.port
+	svc.StopService()
+	utils.FreePort(port)
+
+	// keeper重启后重新创建服务实例，从缓存文件读取上次分配的端口
+	restarted := newSleepService(t, "sticky-svc")
+	restarted.lastPort = restarted.loadLastPort()
+	if restarted.lastPort != port {
+		t.Fatalf("persisted port = %d, want %d", restarted.lastPort, port)
+	}
+	if err := restarted.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.port != port {
+		t.Errorf("port = %d, want the persisted port %d", restarted.port, port)
+	}
+}
+
+func TestStickyPortKeepsSpecPort(t *testing.T) {
+	setupTestEnv(t, `{"service":{"ready_timeout":-1,"sticky_port":true}}`)
+	svc := newSleepService(t, "fixed-svc")
+	svc.spec.Port = freePort(t)
+	// 上次指定端口被占用时临时分配了别的端口
+	svc.lastPort = freePort(t)
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if svc.port != svc.spec.Port {
+		t.Errorf("port = %d, want the port of the spec %d", svc.port, svc.spec.Port)
+	}
+}
+
+func TestTunnelReopenCount(t *testing.T) {
+	tunman := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req tun.PortAllocationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(tun.PortAllocationResponse{
+			AppName:     req.AppName,
+			ClientPort:  req.ClientPort,
+			MappingPort: 40001,
+		})
+	}))
+	defer tunman.Close()
+	setupTestEnv(t, `{"service":{"ready_timeout":-1},"cloud":{"tunman_url":"`+tunman.URL+`"},`+
+		`"tunnel":{"command":"sleep","args":["30"]}}`)
+
+	svc := newSleepService(t, "flapping-svc")
+	svc.spec.Accessible = "remote"
+	newTestServiceManager(t, svc)
+	if err := svc.StartService(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.CloseTunnel() })
+	for i := 0; i < 2; i++ {
+		if err := svc.ReopenTunnel(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	detail := svc.GetDetail().Tunnel
+	if detail == nil || detail.ReopenCount != 2 {
+		t.Fatalf("reopen count isn't 2: %+v", detail)
+	}
+	if detail.Status != models.StatusRunning || detail.Uptime == "" {
+		t.Errorf("uptime of the running tunnel isn't reported: %+v", detail)
+	}
+
+	// keeper重启后重新创建的隧道从缓存读取重启次数
+	if n := tun.CreateTunnel("flapping-svc", []int{svc.port}).GetReopenCount(); n != 2 {
+		t.Errorf("persisted reopen count = %d, want 2", n)
+	}
+}
+
+func TestExportAtomic(t *testing.T) {
+	setupTestEnv(t, "")
+	sm := newTestServiceManager(t)
+	wellKnown := filepath.Join(env.CostrictDir, "share", ".well-known.json")
+	if err := sm.export(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 导出过程中读取文件，不会读到写了一半的内容(Windows上替换文件时读取可能被拒绝，只在unix上测试)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			sm.export()
+		}
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		data, err := os.ReadFile(wellKnown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var info models.SystemKnowledge
+		if err := json.Unmarshal(data, &info); err != nil {
+			t.Fatalf("half-written .well-known.json is read: %v", err)
+		}
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(filepath.Dir(wellKnown), ".*.tmp")); len(tmps) > 0 {
+		t.Errorf("temporary files are left: %v", tmps)
+	}
+}
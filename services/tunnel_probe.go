@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"costrict-keeper/internal/config"
+	"costrict-keeper/internal/models"
+)
+
+// 深度健康检查时，探测每个隧道映射端口的超时
+const tunnelProbeTimeout = 3 * time.Second
+
+/**
+ * Prober which checks whether the remote side of a tunnel reaches the local service
+ */
+type TunnelProber interface {
+	ProbeTunnel(svc *ServiceInstance, pair models.PortPair) error
+}
+
+/**
+ * Prober which runs the health probe of the service through the mapping port on the tunnel server
+ * @property {time.Duration} timeout - Timeout of each probe
+ * @description
+ * - The request travels the tunnel and is answered by the local service, so a success means
+ *   the remote path works, not only that the tunnel server listens on the mapping port
+ * - A service whose health check is "port" can only be connected, which doesn't prove the round trip
+ */
+type MappingPortProber struct {
+	timeout time.Duration
+}
+
+/**
+ * Create prober which probes services through the mapping port on the tunnel server
+ * @param {time.Duration} timeout - Timeout of each probe
+ * @returns {MappingPortProber} Returns the prober
+ */
+func NewMappingPortProber(timeout time.Duration) *MappingPortProber {
+	return &MappingPortProber{timeout: timeout}
+}
+
+/**
+ * Run the health probe of the service against the mapping port of the tunnel
+ * @param {*ServiceInstance} svc - Service the tunnel maps
+ * @param {models.PortPair} pair - Local port and mapping port of the tunnel
+ * @returns {error} Returns error if the service doesn't answer through the mapping port
+ */
+func (mp *MappingPortProber) ProbeTunnel(svc *ServiceInstance, pair models.PortPair) error {
+	if pair.MappingPort == 0 {
+		return fmt.Errorf("mapping port isn't allocated")
+	}
+	u, err := url.Parse(config.Cloud().TunnelUrl)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid tunnel_url: %s", config.Cloud().TunnelUrl)
+	}
+	addr := net.JoinHostPort(u.Hostname(), strconv.Itoa(pair.MappingPort))
+	return svc.probeAddr(addr, mp.timeout)
+}
+
+var tunnelProber TunnelProber = NewMappingPortProber(tunnelProbeTimeout)
+var tunnelProberLock sync.Mutex
+
+/**
+ * Replace the prober used by the deep health check
+ * @param {TunnelProber} prober - New prober
+ */
+func SetTunnelProber(prober TunnelProber) {
+	tunnelProberLock.Lock()
+	defer tunnelProberLock.Unlock()
+	tunnelProber = prober
+}
+
+func getTunnelProber() TunnelProber {
+	tunnelProberLock.Lock()
+	defer tunnelProberLock.Unlock()
+	return tunnelProber
+}
+
+/**
+ * Check end-to-end reachability of the tunnels of remote accessible services
+ * @param {[]*ServiceInstance} instances - Services to check
+ * @returns {[]models.TunnelReachability} Returns reachability of each port pair, in order of services
+ * @description
+ * - Services which aren't running are skipped, a running service without tunnel is unreachable
+ * - Port pairs are probed concurrently, so the check takes about one probe timeout at most
+ */
+func probeTunnels(instances []*ServiceInstance) []models.TunnelReachability {
+	type tunnelProbe struct {
+		svc   *ServiceInstance
+		index int // 在results中的下标
+	}
+	results := []models.TunnelReachability{}
+	// 隧道运行中的端口对需要探测，其余的直接记录不可达的原因
+	var probes []tunnelProbe
+	for _, svc := range instances {
+		if svc.spec.Accessible != "remote" || svc.getStatus() != models.StatusRunning {
+			continue
+		}
+		tun := svc.GetTunnel()
+		if tun == nil {
+			results = append(results, models.TunnelReachability{
+				Name:      svc.GetName(),
+				LocalPort: svc.port,
+				Error:     "tunnel isn't opened",
+			})
+			continue
+		}
+		detail := tun.GetDetail()
+		if detail.Status != models.StatusRunning {
+			results = append(results, models.TunnelReachability{
+				Name:      svc.GetName(),
+				LocalPort: svc.port,
+				Error:     fmt.Sprintf("tunnel status is %s", detail.Status),
+			})
+			continue
+		}
+		for _, pair := range detail.Pairs {
+			probes = append(probes, tunnelProbe{svc: svc, index: len(results)})
+			results = append(results, models.TunnelReachability{
+				Name:        svc.GetName(),
+				LocalPort:   pair.LocalPort,
+				MappingPort: pair.MappingPort,
+			})
+		}
+	}
+
+	prober := getTunnelProber()
+	var wg sync.WaitGroup
+	for _, p := range probes {
+		wg.Add(1)
+		go func(p tunnelProbe) {
+			defer wg.Done()
+			r := &results[p.index]
+			pair := models.PortPair{LocalPort: r.LocalPort, MappingPort: r.MappingPort}
+			if err := prober.ProbeTunnel(p.svc, pair); err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Reachable = true
+			}
+		}(p)
+	}
+	wg.Wait()
+	return results
+}
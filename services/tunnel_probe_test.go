@@ -0,0 +1,62 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"costrict-keeper/internal/models"
+)
+
+// 模拟隧道服务器上的映射端口，请求经隧道转发给本地服务后返回status
+func newMappingPort(t *testing.T, status int) int {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+func TestMappingPortProberRoundTrip(t *testing.T) {
+	setupTestEnv(t, `{"cloud":{"tunnel_url":"ws://127.0.0.1/ws"}}`)
+	svc := &ServiceInstance{
+		spec: models.ServiceSpecification{Name: "remote-svc", Healthy: "/healthz", Accessible: "remote"},
+	}
+	prober := NewMappingPortProber(tunnelProbeTimeout)
+
+	tests := []struct {
+		name        string
+		mappingPort int
+		wantErr     string
+	}{
+		{"service answers", newMappingPort(t, http.StatusOK), ""},
+		// 隧道服务器侦听映射端口，但请求没有到达健康的本地服务
+		{"service unhealthy", newMappingPort(t, http.StatusBadGateway), "http health check failed"},
+		{"tunnel server down", closedPort(t), "http health check failed"},
+		{"no mapping port", 0, "mapping port isn't allocated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := prober.ProbeTunnel(svc, models.PortPair{LocalPort: 8080, MappingPort: tt.mappingPort})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ProbeTunnel() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ProbeTunnel() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
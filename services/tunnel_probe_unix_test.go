@@ -0,0 +1,113 @@
+//go:build linux || darwin
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/tun"
+)
+
+// 记录探测过的隧道，对指定的服务返回错误
+type fakeTunnelProber struct {
+	mutex  sync.Mutex
+	probed map[string]models.PortPair
+	fail   map[string]error
+}
+
+func (fp *fakeTunnelProber) ProbeTunnel(svc *ServiceInstance, pair models.PortPair) error {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	fp.probed[svc.GetName()] = pair
+	return fp.fail[svc.GetName()]
+}
+
+func TestHealthzDeepProbesTunnels(t *testing.T) {
+	mappingPorts := map[string]int{"good-svc": 40001, "bad-svc": 40002}
+	tunman := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req tun.PortAllocationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(tun.PortAllocationResponse{
+			AppName:     req.AppName,
+			ClientPort:  req.ClientPort,
+			MappingPort: mappingPorts[req.AppName],
+		})
+	}))
+	defer tunman.Close()
+	setupTestEnv(t, `{"service":{"ready_timeout":-1},"cloud":{"tunman_url":"`+tunman.URL+`"},`+
+		`"tunnel":{"command":"sleep","args":["30"]}}`)
+
+	good := newSleepService(t, "good-svc")
+	bad := newSleepService(t, "bad-svc")
+	local := newSleepService(t, "local-svc")
+	stopped := newSleepService(t, "stopped-svc")
+	for _, svc := range []*ServiceInstance{good, bad, stopped} {
+		svc.spec.Accessible = "remote"
+	}
+	// 导出.well-known.json时需要服务的进程实例，未启动的服务也要有
+	for _, svc := range []*ServiceInstance{good, bad, local, stopped} {
+		svc.proc = createProcessInstance(&svc.spec, 0)
+	}
+	s := newTestServer(t)
+	s.service = newTestServiceManager(t, good, bad, local, stopped)
+	s.component = newTestComponentManager(t, "")
+	s.tunnel = GetTunnelManager()
+	s.startTime = time.Now()
+	for _, svc := range []*ServiceInstance{good, bad, local} {
+		if err := svc.StartService(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { svc.CloseTunnel() })
+	}
+
+	prober := &fakeTunnelProber{
+		probed: map[string]models.PortPair{},
+		fail:   map[string]error{"bad-svc": errors.New("no reply through the tunnel")},
+	}
+	SetTunnelProber(prober)
+	t.Cleanup(func() { SetTunnelProber(NewMappingPortProber(tunnelProbeTimeout)) })
+
+	// 非深度检查不探测隧道
+	if resp := s.GetHealthz(false); resp.Metrics.TunnelReachable != nil || len(prober.probed) != 0 {
+		t.Fatalf("tunnels are probed without deep: %+v", resp.Metrics)
+	}
+	resp := s.GetHealthz(true)
+	if resp.Metrics.TunnelReachable == nil || *resp.Metrics.TunnelReachable != 1 {
+		t.Errorf("tunnelReachable = %v, want 1", resp.Metrics.TunnelReachable)
+	}
+	// 只探测运行中的远程访问服务的隧道
+	var names []string
+	for name := range prober.probed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "bad-svc" || names[1] != "good-svc" {
+		t.Errorf("probed tunnels = %v, want [bad-svc good-svc]", names)
+	}
+	if want := (models.PortPair{LocalPort: good.port, MappingPort: 40001}); prober.probed["good-svc"] != want {
+		t.Errorf("good-svc is probed with %+v, want %+v", prober.probed["good-svc"], want)
+	}
+
+	results := map[string]models.TunnelReachability{}
+	for _, r := range resp.Tunnels {
+		results[r.Name] = r
+	}
+	if len(resp.Tunnels) != 2 {
+		t.Fatalf("tunnels = %+v, want good-svc and bad-svc", resp.Tunnels)
+	}
+	if r := results["good-svc"]; !r.Reachable || r.Error != "" || r.MappingPort != 40001 {
+		t.Errorf("good-svc = %+v, want reachable through 40001", r)
+	}
+	if r := results["bad-svc"]; r.Reachable || r.Error != "no reply through the tunnel" || r.MappingPort != 40002 {
+		t.Errorf("bad-svc = %+v, want unreachable with the probe error", r)
+	}
+}
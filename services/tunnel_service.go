@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/tun"
+)
+
+/**
+ * Manager of ad-hoc tunnels, which expose arbitrary local ports without defining a service
+ * @property {map[string]*tun.TunnelInstance} tunnels - Tunnels keyed by {app}:{port}
+ */
+type TunnelManager struct {
+	lock    sync.Mutex
+	tunnels map[string]*tun.TunnelInstance
+}
+
+var tunnelManager *TunnelManager
+
+/**
+ * Get tunnel manager singleton instance
+ * @returns {TunnelManager} Returns the singleton TunnelManager instance
+ */
+func GetTunnelManager() *TunnelManager {
+	if tunnelManager != nil {
+		return tunnelManager
+	}
+	tunnelManager = &TunnelManager{
+		tunnels: make(map[string]*tun.TunnelInstance),
+	}
+	return tunnelManager
+}
+
+func tunnelKey(appName string, port int) string {
+	return fmt.Sprintf("%s:%d", appName, port)
+}
+
+/**
+ * Open tunnel for a local port of an application
+ * @param {context.Context} ctx - Context for the tunnel process
+ * @param {string} appName - Application name
+ * @param {int} port - Local port to expose
+ * @returns {models.TunnelDetail} Returns detail of the opened tunnel
+ * @description
+ * - Reuses the tunnel if it has been opened for the same app and port
+ */
+func (tm *TunnelManager) OpenTunnel(ctx context.Context, appName string, port int) (models.TunnelDetail, error) {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	key := tunnelKey(appName, port)
+	t, ok := tm.tunnels[key]
+	if !ok {
+		t = tun.CreateTunnel(appName, []int{port})
+	}
+	if err := t.OpenTunnel(ctx); err != nil {
+		return models.TunnelDetail{}, err
+	}
+	tm.tunnels[key] = t
+	return t.GetDetail(), nil
+}
+
+/**
+ * Close tunnel opened by OpenTunnel
+ * @param {string} appName - Application name
+ * @param {int} port - Local port of the tunnel
+ * @returns {error} Returns error if the tunnel doesn't exist or fails to close
+ */
+func (tm *TunnelManager) CloseTunnel(appName string, port int) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	key := tunnelKey(appName, port)
+	t, ok := tm.tunnels[key]
+	if !ok {
+		return fmt.Errorf("tunnel [%s] isn't exist", key)
+	}
+	if err := t.CloseTunnel(); err != nil {
+		return err
+	}
+	delete(tm.tunnels, key)
+	return nil
+}
+
+/**
+ * Close all ad-hoc tunnels, used when keeper exits
+ */
+func (tm *TunnelManager) CloseAll() {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	for key, t := range tm.tunnels {
+		t.CloseTunnel()
+		delete(tm.tunnels, key)
+	}
+}
+
+/**
+ * Get details of all ad-hoc tunnels, sorted by app name and port
+ */
+func (tm *TunnelManager) GetTunnels() []models.TunnelDetail {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	keys := make([]string, 0, len(tm.tunnels))
+	for key := range tm.tunnels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	details := []models.TunnelDetail{}
+	for _, key := range keys {
+		details = append(details, tm.tunnels[key].GetDetail())
+	}
+	return details
+}
+
+/**
+ * Get processes of all ad-hoc tunnels, sorted by app name and port
+ */
+func (tm *TunnelManager) GetProcesses() []models.ManagedProcess {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	keys := make([]string, 0, len(tm.tunnels))
+	for key := range tm.tunnels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	processes := []models.ManagedProcess{}
+	for _, key := range keys {
+		if detail := tm.tunnels[key].GetProcessDetail(); detail != nil {
+			processes = append(processes, managedProcess("tunnel", key, *detail))
+		}
+	}
+	return processes
+}
+
+/**
+ * Get ad-hoc tunnels which aren't running, sorted by app name and port
+ * @description
+ * - Uses the recorded status, doesn't check the tunnel processes
+ */
+func (tm *TunnelManager) GetUnhealthy() []models.UnhealthyItem {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	keys := make([]string, 0, len(tm.tunnels))
+	for key := range tm.tunnels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := []models.UnhealthyItem{}
+	for _, key := range keys {
+		if status := tm.tunnels[key].GetKnowledge().Status; status != models.StatusRunning {
+			items = append(items, models.UnhealthyItem{
+				Kind:    "tunnel",
+				Name:    key,
+				Healthy: models.Unavailable,
+				Reason:  fmt.Sprintf("tunnel status is %s", status),
+			})
+		}
+	}
+	return items
+}
+
+/**
+ * Get PIDs of all ad-hoc tunnel processes
+ */
+func (tm *TunnelManager) GetPids() []int {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	var pids []int
+	for _, t := range tm.tunnels {
+		if pid := t.GetPid(); pid != 0 {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/logger"
+	"costrict-keeper/internal/models"
+	"costrict-keeper/internal/utils"
+)
+
+// 升级历史最多保留的记录数，超出时丢弃最旧的记录
+const maxUpgradeHistory = 1000
+
+// 保护升级历史文件，批量升级时多个组件会同时写入
+// CLI和服务端进程之间则由文件锁(upgrade-history.json.lock)互斥
+var upgradeHistoryLock sync.Mutex
+
+// 升级历史文件内容无法解析
+var errCorruptHistory = errors.New("corrupt upgrade history")
+
+func getUpgradeHistoryFname() string {
+	return filepath.Join(env.GetCacheDir(), "upgrade-history.json")
+}
+
+func loadUpgradeHistory() ([]models.UpgradeRecord, error) {
+	data, err := os.ReadFile(getUpgradeHistoryFname())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var history []models.UpgradeRecord
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptHistory, err)
+	}
+	return history, nil
+}
+
+/**
+ * Lock the upgrade history against other goroutines and processes
+ * @returns {func()} Returns function to release the lock
+ * @returns {error} Returns error if the lock file can't be locked
+ */
+func lockUpgradeHistory() (func(), error) {
+	fname := getUpgradeHistoryFname()
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return nil, fmt.Errorf("create directory of '%s' failed: %v", fname, err)
+	}
+	upgradeHistoryLock.Lock()
+	unlock, err := utils.LockFile(fname + ".lock")
+	if err != nil {
+		upgradeHistoryLock.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		upgradeHistoryLock.Unlock()
+	}, nil
+}
+
+/**
+ * Append an upgrade record to cache/upgrade-history.json
+ * @param {models.UpgradeRecord} rec - Upgrade record
+ * @description
+ * - Records are only appended, the oldest ones are dropped beyond maxUpgradeHistory
+ * - The CLI and the server both record upgrades, a file lock keeps their records
+ * - A corrupt history file is renamed aside and kept, a new history is started
+ * - Failures are only logged, the history never affects the upgrade itself
+ */
+func RecordUpgrade(rec models.UpgradeRecord) {
+	unlock, err := lockUpgradeHistory()
+	if err != nil {
+		logger.Errorf("Lock upgrade history failed: %v", err)
+		return
+	}
+	defer unlock()
+
+	fname := getUpgradeHistoryFname()
+	history, err := loadUpgradeHistory()
+	if errors.Is(err, errCorruptHistory) {
+		corrupt := fmt.Sprintf("%s.corrupt-%s", fname, time.Now().Format("20060102150405"))
+		if err := os.Rename(fname, corrupt); err != nil {
+			logger.Errorf("Rename corrupt upgrade history '%s' failed: %v", fname, err)
+			return
+		}
+		logger.Warnf("Upgrade history is corrupt, it's kept as '%s' and a new history is started: %v", corrupt, err)
+	} else if err != nil {
+		logger.Errorf("Load upgrade history failed: %v", err)
+		return
+	}
+	history = append(history, rec)
+	if len(history) > maxUpgradeHistory {
+		history = history[len(history)-maxUpgradeHistory:]
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		logger.Errorf("Marshal upgrade history failed: %v", err)
+		return
+	}
+	if err := utils.WriteFileAtomic(fname, data, 0644); err != nil {
+		logger.Errorf("Write upgrade history '%s' failed: %v", fname, err)
+	}
+}
+
+/**
+ * Get the most recent upgrade records
+ * @param {int} limit - Max number of records, all records if limit <= 0
+ * @returns {[]models.UpgradeRecord} Returns records, newest first
+ * @returns {error} Returns error if the history file can't be read
+ */
+func GetUpgradeHistory(limit int) ([]models.UpgradeRecord, error) {
+	unlock, err := lockUpgradeHistory()
+	if err != nil {
+		return nil, err
+	}
+	history, err := loadUpgradeHistory()
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	records := make([]models.UpgradeRecord, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		records = append(records, history[i])
+	}
+	return records, nil
+}
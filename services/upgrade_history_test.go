@@ -0,0 +1,80 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"costrict-keeper/internal/env"
+	"costrict-keeper/internal/models"
+)
+
+// 每个进程(或协程)写入的升级记录数
+const historyRecordsPerProcess = 20
+
+func TestRecordUpgradeAcrossProcesses(t *testing.T) {
+	setupTestEnv(t, "")
+
+	// 两个子进程和本进程的多个协程同时记录，任何记录都不能丢失
+	var cmds []*exec.Cmd
+	for i := 0; i < 2; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^$")
+		cmd.Env = append(os.Environ(), "COSTRICT_TEST_RECORD_UPGRADES="+env.CostrictDir)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < historyRecordsPerProcess; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RecordUpgrade(models.UpgradeRecord{Component: "server", Result: models.UpgradeSuccess})
+		}()
+	}
+	wg.Wait()
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := GetUpgradeHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 3 * historyRecordsPerProcess; len(history) != want {
+		t.Errorf("history has %d records, want %d", len(history), want)
+	}
+}
+
+func TestRecordUpgradeKeepsCorruptHistory(t *testing.T) {
+	setupTestEnv(t, "")
+	fname := getUpgradeHistoryFname()
+	writeTestFile(t, fname, `[{"component":"costrict","result":"success"`)
+
+	RecordUpgrade(models.UpgradeRecord{Component: "costrict", Result: models.UpgradeSuccess})
+
+	// 无法解析的历史被改名保留，而不是被新的历史覆盖
+	kept, err := filepath.Glob(fname + ".corrupt-*")
+	if err != nil || len(kept) != 1 {
+		t.Fatalf("corrupt history is not kept: %v, %v", kept, err)
+	}
+	data, err := os.ReadFile(kept[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"component":"costrict","result":"success"` {
+		t.Errorf("kept history = %q, want the corrupt content", data)
+	}
+	history, err := GetUpgradeHistory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Component != "costrict" {
+		t.Errorf("history = %+v, want a new history with the record", history)
+	}
+}